@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// taskETag returns the entity tag of a task, "<version>-<comment_count>", followed by "-overdue" once the task is
+// overdue. It changes with every update of the task, whenever a comment is added or deleted and when the task passes
+// its due date, as all of them change the representation of the task.
+func taskETag(task models.Task) string {
+	etag := `"` + strconv.Itoa(task.Version) + "-" + strconv.Itoa(task.CommentCount)
+	if task.Overdue {
+		etag += "-overdue"
+	}
+	return etag + `"`
+}
+
+// etagVersion returns the version part of an entity tag, `"3"` for `"3"`, `"3-1"` and `"3-1-overdue"`
+func etagVersion(etag string) string {
+	if version, _, found := strings.Cut(etag, "-"); found {
+		return version + `"`
+	}
+	return etag
+}
+
+// ifMatch converts the If-Match request header into a precondition for a change to the task
+func ifMatch(r *http.Request) []services.Precondition {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+	return []services.Precondition{func(current models.Task) error {
+		// If-Match uses the strong comparison function (RFC 9110 section 13.1.1) on the version only, so that a
+		// new comment or computed field does not fail an update of the task
+		if !etagListMatches(header, etagVersion(taskETag(current)), false, etagVersion) {
+			return services.ErrPreconditionFailed
+		}
+		return nil
+	}}
+}
+
+// notModified reports whether the If-None-Match request header matches the task's current entity tag
+func notModified(r *http.Request, task models.Task) bool {
+	header := r.Header.Get("If-None-Match")
+	// If-None-Match uses the weak comparison function (RFC 9110 section 13.1.2)
+	return header != "" && etagListMatches(header, taskETag(task), true, nil)
+}
+
+// etagListMatches reports whether header, "*" or a comma separated list of entity tags, matches etag.
+// Weak entity tags (W/ prefix) only match when weak comparison is allowed. A non-nil normalize is applied to each
+// entity tag of the list before the comparison.
+func etagListMatches(header, etag string, weak bool, normalize func(string) string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if normalize != nil {
+			candidate = normalize(candidate)
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+)
+
+const (
+	// problemTypePrefix prefixes the error code to build the problem "type" URI
+	problemTypePrefix = "urn:task-manager:problem:"
+
+	validationFailed = "one or more fields are invalid"
+)
+
+// apiError is a documented error of the API. The codes are listed in the README and must not change.
+type apiError struct {
+	code   string
+	status int
+	title  string
+}
+
+var (
+	errInvalidPayload         = apiError{"invalid_payload", http.StatusBadRequest, "Invalid request payload"}
+	errValidationFailed       = apiError{"validation_failed", http.StatusBadRequest, "Validation failed"}
+	errInvalidTaskID          = apiError{"invalid_task_id", http.StatusBadRequest, "Invalid task ID"}
+	errInvalidQuery           = apiError{"invalid_query", http.StatusBadRequest, "Invalid query parameters"}
+	errInvalidRevision        = apiError{"invalid_revision", http.StatusBadRequest, "Invalid revision number"}
+	errInvalidIdempotencyKey  = apiError{"invalid_idempotency_key", http.StatusBadRequest, "Invalid Idempotency-Key"}
+	errActorRequired          = apiError{"actor_required", http.StatusBadRequest, "X-User header required"}
+	errAdminRequired          = apiError{"admin_required", http.StatusForbidden, "Administrator required"}
+	errNotCommentAuthor       = apiError{"not_comment_author", http.StatusForbidden, "Not the author of the comment"}
+	errTaskNotFound           = apiError{"task_not_found", http.StatusNotFound, "Task not found"}
+	errRevisionNotFound       = apiError{"revision_not_found", http.StatusNotFound, "Revision not found"}
+	errLabelNotFound          = apiError{"label_not_found", http.StatusNotFound, "Label not found"}
+	errDependencyNotFound     = apiError{"dependency_not_found", http.StatusNotFound, "Dependency not found"}
+	errLinkNotFound           = apiError{"link_not_found", http.StatusNotFound, "Link not found"}
+	errCommentNotFound        = apiError{"comment_not_found", http.StatusNotFound, "Comment not found"}
+	errAttachmentNotFound     = apiError{"attachment_not_found", http.StatusNotFound, "Attachment not found"}
+	errChecklistItemNotFound  = apiError{"checklist_item_not_found", http.StatusNotFound, "Checklist item not found"}
+	errReminderNotFound       = apiError{"reminder_not_found", http.StatusNotFound, "Reminder not found"}
+	errWebhookNotFound        = apiError{"webhook_not_found", http.StatusNotFound, "Webhook not found"}
+	errDeliveryNotFound       = apiError{"delivery_not_found", http.StatusNotFound, "Delivery not found"}
+	errNotFound               = apiError{"not_found", http.StatusNotFound, "Not found"}
+	errMethodNotAllowed       = apiError{"method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed"}
+	errTaskNotInTrash         = apiError{"task_not_in_trash", http.StatusConflict, "Task is not in the trash"}
+	errTaskNotRecurring       = apiError{"task_not_recurring", http.StatusConflict, "Task does not recur"}
+	errNothingToUndo          = apiError{"nothing_to_undo", http.StatusConflict, "Nothing to undo"}
+	errUndoConflict           = apiError{"undo_conflict", http.StatusConflict, "Change cannot be undone"}
+	errPatchConflict          = apiError{"patch_conflict", http.StatusConflict, "Patch cannot be applied"}
+	errIllegalTransition      = apiError{"illegal_transition", http.StatusConflict, "Illegal status transition"}
+	errParentCycle            = apiError{"parent_cycle", http.StatusConflict, "Subtask cycle"}
+	errDependencyCycle        = apiError{"dependency_cycle", http.StatusConflict, "Dependency cycle"}
+	errBlocked                = apiError{"blocked", http.StatusConflict, "Task is blocked"}
+	errOpenSubtasks           = apiError{"open_subtasks", http.StatusConflict, "Subtasks are not done"}
+	errParentDone             = apiError{"parent_done", http.StatusConflict, "Parent task is done"}
+	errHasSubtasks            = apiError{"has_subtasks", http.StatusConflict, "Task has subtasks"}
+	errLabelExists            = apiError{"label_exists", http.StatusConflict, "Label already exists"}
+	errLinkExists             = apiError{"link_exists", http.StatusConflict, "Link already exists"}
+	errDeliveryPending        = apiError{"delivery_pending", http.StatusConflict, "Delivery is still pending"}
+	errUploadConflict         = apiError{"upload_conflict", http.StatusConflict, "Upload conflicted with a collection"}
+	errPreconditionFailed     = apiError{"precondition_failed", http.StatusPreconditionFailed, "Precondition failed"}
+	errAttachmentTooLarge     = apiError{"attachment_too_large", http.StatusRequestEntityTooLarge, "Attachment too large"}
+	errUnsupportedMediaType   = apiError{"unsupported_media_type", http.StatusUnsupportedMediaType, "Unsupported media type"}
+	errIdempotencyKeyReused   = apiError{"idempotency_key_reused", http.StatusUnprocessableEntity, "Idempotency key reused"}
+	errParentNotFound         = apiError{"parent_not_found", http.StatusUnprocessableEntity, "Parent task not found"}
+	errBlockerNotFound        = apiError{"blocker_not_found", http.StatusUnprocessableEntity, "Blocking task not found"}
+	errLinkedTaskNotFound     = apiError{"linked_task_not_found", http.StatusUnprocessableEntity, "Linked task not found"}
+	errInvalidLink            = apiError{"invalid_link", http.StatusUnprocessableEntity, "Invalid link"}
+	errParentCommentNotFound  = apiError{"parent_comment_not_found", http.StatusUnprocessableEntity, "Parent comment not found"}
+	errNotAnImage             = apiError{"not_an_image", http.StatusUnprocessableEntity, "Attachment is not an image"}
+	errImageTooLarge          = apiError{"image_too_large", http.StatusUnprocessableEntity, "Image too large"}
+	errInvalidChecklistOrder  = apiError{"invalid_checklist_order", http.StatusUnprocessableEntity, "Invalid checklist order"}
+	errInternal               = apiError{"internal_error", http.StatusInternalServerError, "Internal Server Error"}
+	errLabelsUnsupported      = apiError{"labels_unsupported", http.StatusNotImplemented, "Labels not supported"}
+	errLinksUnsupported       = apiError{"links_unsupported", http.StatusNotImplemented, "Links not supported"}
+	errCommentsUnsupported    = apiError{"comments_unsupported", http.StatusNotImplemented, "Comments not supported"}
+	errAttachmentsUnsupported = apiError{"attachments_unsupported", http.StatusNotImplemented, "Attachments not supported"}
+	errRemindersUnsupported   = apiError{"reminders_unsupported", http.StatusNotImplemented, "Reminders not supported"}
+	errWebhooksUnsupported    = apiError{"webhooks_unsupported", http.StatusNotImplemented, "Webhooks not supported"}
+)
+
+// fieldErrorCodes maps the validation messages to their documented error codes
+var fieldErrorCodes = map[string]string{
+	titleRequired:           "title_required",
+	descriptionRequired:     "description_required",
+	statusRequired:          "status_required",
+	invalidStatus:           "invalid_status",
+	invalidPriority:         "invalid_priority",
+	negativeEffort:          "invalid_effort",
+	labelNameRequired:       "name_required",
+	labelsRequired:          "labels_required",
+	intoRequired:            "into_required",
+	invalidLabel:            "invalid_label",
+	invalidColor:            "invalid_color",
+	invalidParentID:         "invalid_parent_id",
+	invalidBlockedBy:        "invalid_blocked_by",
+	blockerIDRequired:       "blocker_id_required",
+	invalidLinkType:         "invalid_link_type",
+	linkTaskIDRequired:      "task_id_required",
+	selfLink:                "self_link",
+	invalidResolution:       "invalid_resolution",
+	bodyRequired:            "body_required",
+	bodyTooLong:             "body_too_long",
+	invalidParentCommentID:  "invalid_parent_comment_id",
+	fileRequired:            "file_required",
+	textRequired:            "text_required",
+	textTooLong:             "text_too_long",
+	checklistIDsRequired:    "ids_required",
+	rruleRequired:           "rrule_required",
+	invalidRRule:            "invalid_rrule",
+	invalidTimezone:         "invalid_timezone",
+	recurrenceStartRequired: "recurrence_start_required",
+	beforeRequired:          "before_required",
+	invalidBefore:           "invalid_before",
+	invalidChannel:          "invalid_channel",
+	recipientTooLong:        "recipient_too_long",
+	snoozeRequired:          "snooze_required",
+	invalidSnoozeFor:        "invalid_snooze",
+	snoozeInPast:            "invalid_snooze",
+	webhookURLRequired:      "url_required",
+	invalidWebhookURL:       "invalid_url",
+	eventsRequired:          "events_required",
+	invalidEvent:            "invalid_event",
+	webhookSecretTooLong:    "secret_too_long",
+}
+
+// requestError rejects a request with a problem response.
+// It can be returned from the callbacks passed to the services package.
+type requestError struct {
+	apiError
+	detail string
+	fields []utils.FieldError
+}
+
+func (e *requestError) Error() string {
+	if e.detail != "" {
+		return e.detail
+	}
+	return e.title
+}
+
+func newRequestError(apiErr apiError, detail string) *requestError {
+	return &requestError{apiError: apiErr, detail: detail}
+}
+
+// newValidationError reports every invalid field at once
+func newValidationError(fields []utils.FieldError) *requestError {
+	return &requestError{apiError: errValidationFailed, detail: validationFailed, fields: fields}
+}
+
+// fieldError builds the error of a single field from one of the validation messages
+func fieldError(field, message string) utils.FieldError {
+	return utils.FieldError{Field: field, Code: fieldErrorCodes[message], Message: message}
+}
+
+// sendProblem sends a documented error as application/problem+json
+func sendProblem(w http.ResponseWriter, apiErr apiError, detail string, fields ...utils.FieldError) {
+	utils.SendProblem(w, utils.Problem{
+		Type:   problemTypePrefix + apiErr.code,
+		Title:  apiErr.title,
+		Status: apiErr.status,
+		Detail: detail,
+		Code:   apiErr.code,
+		Errors: fields,
+	})
+}
+
+// sendServiceError maps an error returned by the services package to a problem response
+func sendServiceError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		sendProblem(w, reqErr.apiError, reqErr.detail, reqErr.fields...)
+	case errors.Is(err, services.ErrTaskNotFound):
+		sendProblem(w, errTaskNotFound, err.Error())
+	case errors.Is(err, services.ErrRevisionNotFound):
+		sendProblem(w, errRevisionNotFound, err.Error())
+	case errors.Is(err, services.ErrLabelNotFound):
+		sendProblem(w, errLabelNotFound, err.Error())
+	case errors.Is(err, services.ErrLabelExists):
+		sendProblem(w, errLabelExists, err.Error())
+	case errors.Is(err, services.ErrParentNotFound):
+		sendProblem(w, errParentNotFound, err.Error())
+	case errors.Is(err, services.ErrParentCycle):
+		sendProblem(w, errParentCycle, err.Error())
+	case errors.Is(err, services.ErrOpenSubtasks):
+		sendProblem(w, errOpenSubtasks, err.Error())
+	case errors.Is(err, services.ErrParentDone):
+		sendProblem(w, errParentDone, err.Error())
+	case errors.Is(err, services.ErrHasSubtasks):
+		sendProblem(w, errHasSubtasks, err.Error())
+	case errors.Is(err, services.ErrBlockerNotFound):
+		sendProblem(w, errBlockerNotFound, err.Error())
+	case errors.Is(err, services.ErrDependencyNotFound):
+		sendProblem(w, errDependencyNotFound, err.Error())
+	case errors.Is(err, services.ErrDependencyCycle):
+		sendProblem(w, errDependencyCycle, err.Error())
+	case errors.Is(err, services.ErrBlocked):
+		sendProblem(w, errBlocked, err.Error())
+	case errors.Is(err, services.ErrLinkNotFound):
+		sendProblem(w, errLinkNotFound, err.Error())
+	case errors.Is(err, services.ErrLinkExists):
+		sendProblem(w, errLinkExists, err.Error())
+	case errors.Is(err, services.ErrLinkedTaskNotFound):
+		sendProblem(w, errLinkedTaskNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidLink):
+		sendProblem(w, errInvalidLink, err.Error())
+	case errors.Is(err, services.ErrLinksUnsupported):
+		sendProblem(w, errLinksUnsupported, err.Error())
+	case errors.Is(err, services.ErrCommentNotFound):
+		sendProblem(w, errCommentNotFound, err.Error())
+	case errors.Is(err, services.ErrParentCommentNotFound):
+		sendProblem(w, errParentCommentNotFound, err.Error())
+	case errors.Is(err, services.ErrNotCommentAuthor):
+		sendProblem(w, errNotCommentAuthor, err.Error())
+	case errors.Is(err, services.ErrCommentsUnsupported):
+		sendProblem(w, errCommentsUnsupported, err.Error())
+	case errors.Is(err, services.ErrAttachmentNotFound):
+		sendProblem(w, errAttachmentNotFound, err.Error())
+	case errors.Is(err, services.ErrAttachmentsUnsupported):
+		sendProblem(w, errAttachmentsUnsupported, err.Error())
+	case errors.Is(err, services.ErrUploadConflict):
+		sendProblem(w, errUploadConflict, err.Error())
+	case errors.Is(err, services.ErrNotAnImage):
+		sendProblem(w, errNotAnImage, err.Error())
+	case errors.Is(err, services.ErrImageTooLarge):
+		sendProblem(w, errImageTooLarge, err.Error())
+	case errors.Is(err, services.ErrInvalidThumbnailSize):
+		sendProblem(w, errInvalidQuery, invalidThumbnailSize())
+	case errors.Is(err, services.ErrChecklistItemNotFound):
+		sendProblem(w, errChecklistItemNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidChecklistOrder):
+		sendProblem(w, errInvalidChecklistOrder, err.Error())
+	case errors.Is(err, services.ErrNotRecurring):
+		sendProblem(w, errTaskNotRecurring, err.Error())
+	case errors.Is(err, services.ErrInvalidRecurrence):
+		sendProblem(w, errValidationFailed, err.Error())
+	case errors.Is(err, services.ErrReminderNotFound):
+		sendProblem(w, errReminderNotFound, err.Error())
+	case errors.Is(err, services.ErrUnknownChannel), errors.Is(err, services.ErrInvalidReminder),
+		errors.Is(err, services.ErrInvalidRecipient):
+		sendProblem(w, errValidationFailed, err.Error())
+	case errors.Is(err, services.ErrRemindersUnsupported):
+		sendProblem(w, errRemindersUnsupported, err.Error())
+	case errors.Is(err, services.ErrWebhookNotFound):
+		sendProblem(w, errWebhookNotFound, err.Error())
+	case errors.Is(err, services.ErrDeliveryNotFound):
+		sendProblem(w, errDeliveryNotFound, err.Error())
+	case errors.Is(err, services.ErrDeliveryPending):
+		sendProblem(w, errDeliveryPending, err.Error())
+	case errors.Is(err, services.ErrInvalidWebhook):
+		sendProblem(w, errValidationFailed, err.Error())
+	case errors.Is(err, services.ErrWebhooksUnsupported):
+		sendProblem(w, errWebhooksUnsupported, err.Error())
+	case errors.Is(err, services.ErrLabelsUnsupported):
+		sendProblem(w, errLabelsUnsupported, err.Error())
+	case errors.Is(err, services.ErrPreconditionFailed):
+		sendProblem(w, errPreconditionFailed, err.Error())
+	case errors.Is(err, services.ErrIllegalTransition):
+		sendProblem(w, errIllegalTransition, err.Error())
+	case errors.Is(err, services.ErrTaskNotInTrash):
+		sendProblem(w, errTaskNotInTrash, err.Error())
+	case errors.Is(err, services.ErrAdminRequired):
+		sendProblem(w, errAdminRequired, err.Error())
+	case errors.Is(err, services.ErrActorRequired):
+		sendProblem(w, errActorRequired, err.Error())
+	case errors.Is(err, services.ErrNothingToUndo):
+		sendProblem(w, errNothingToUndo, err.Error())
+	case errors.Is(err, services.ErrUndoConflict):
+		sendProblem(w, errUndoConflict, err.Error())
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		sendProblem(w, errIdempotencyKeyReused, err.Error())
+	case errors.Is(err, services.ErrInvalidListOptions):
+		sendProblem(w, errInvalidQuery, err.Error())
+	default:
+		sendProblem(w, errInternal, "")
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	fileRequired         = "file is required"
+	unsupportedMultipart = "attachments must be uploaded as multipart/form-data with a file field"
+
+	// attachmentFormField is the multipart form field holding the uploaded file
+	attachmentFormField = "file"
+	// multipartOverhead is the room left for the multipart headers and boundaries on top of the maximum attachment size
+	multipartOverhead = 64 << 10
+	// multipartMemory is how much of an upload is buffered in memory before it spills to a temporary file
+	multipartMemory = 1 << 20
+)
+
+// handleTaskAttachments serves GET and POST /tasks/{id}/attachments, GET, HEAD and DELETE
+// /tasks/{id}/attachments/{attachmentID} and GET /tasks/{id}/attachments/{attachmentID}/thumbnail
+func handleTaskAttachments(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if len(segments) > 2 || len(segments) == 2 && segments[1] != "thumbnail" {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) > 0 {
+		attachmentID, err := strconv.Atoi(segments[0])
+		if err != nil {
+			sendProblem(w, errNotFound, "")
+			return
+		}
+		if len(segments) == 2 {
+			handleAttachmentThumbnail(w, r, id, attachmentID)
+		} else {
+			handleTaskAttachment(w, r, id, attachmentID)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		attachments, err := services.ListAttachments(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, attachments, http.StatusOK)
+
+	case http.MethodPost:
+		uploadAttachment(w, r, id)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// uploadAttachment attaches the file of a multipart/form-data request to the task
+func uploadAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		sendProblem(w, errUnsupportedMediaType, unsupportedMultipart)
+		return
+	}
+
+	maxSize := services.MaxAttachmentSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			sendProblem(w, errAttachmentTooLarge, attachmentTooLarge(maxSize))
+			return
+		}
+		sendProblem(w, errInvalidPayload, err.Error())
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	files := r.MultipartForm.File[attachmentFormField]
+	if len(files) == 0 {
+		sendProblem(w, errValidationFailed, validationFailed, fieldError(attachmentFormField, fileRequired))
+		return
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	attachment, err := services.AddAttachment(requestContext(r), id, files[0].Filename, file)
+	if errors.Is(err, services.ErrAttachmentTooLarge) {
+		sendProblem(w, errAttachmentTooLarge, attachmentTooLarge(maxSize))
+		return
+	} else if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, attachment, http.StatusCreated)
+}
+
+// handleTaskAttachment serves GET, HEAD and DELETE /tasks/{id}/attachments/{attachmentID}.
+// GET downloads the content of the attachment and supports Range requests.
+func handleTaskAttachment(w http.ResponseWriter, r *http.Request, id, attachmentID int) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		attachment, content, err := services.OpenAttachment(r.Context(), id, attachmentID)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		defer func() { _ = content.Close() }()
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Disposition", disposition)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// The content of an attachment never changes, so its digest is a strong ETag
+		w.Header().Set("ETag", strconv.Quote(attachment.SHA256))
+		http.ServeContent(w, r, attachment.Filename, attachment.CreatedAt, content)
+
+	case http.MethodDelete:
+		if err := services.DeleteAttachment(requestContext(r), id, attachmentID); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleAttachmentThumbnail serves GET and HEAD /tasks/{id}/attachments/{attachmentID}/thumbnail?size=
+func handleAttachmentThumbnail(w http.ResponseWriter, r *http.Request, id, attachmentID int) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	size := services.DefaultThumbnailSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil || !services.IsThumbnailSize(size) {
+			sendProblem(w, errInvalidQuery, invalidThumbnailSize())
+			return
+		}
+	}
+
+	attachment, thumbnail, err := services.Thumbnail(r.Context(), id, attachmentID, size)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	defer func() { _ = thumbnail.Close() }()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", strconv.Quote(attachment.SHA256+"-"+strconv.Itoa(size)))
+	http.ServeContent(w, r, "", attachment.CreatedAt, thumbnail)
+}
+
+// invalidThumbnailSize lists the sizes thumbnails are available at
+func invalidThumbnailSize() string {
+	sizes := make([]string, len(services.ThumbnailSizes))
+	for i, size := range services.ThumbnailSizes {
+		sizes[i] = strconv.Itoa(size)
+	}
+	return "size must be one of " + strings.Join(sizes, ", ")
+}
+
+// attachmentTooLarge describes the maximum attachment size
+func attachmentTooLarge(maxSize int64) string {
+	return fmt.Sprintf("attachments must be at most %d bytes", maxSize)
+}
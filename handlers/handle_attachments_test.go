@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/storage"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+)
+
+var _ = Describe("Attachments Tests", func() {
+	const attachmentsPath = tasksPath + "/1/attachments"
+
+	// shot is the signature of a PNG file followed by some data
+	shot := append([]byte("\x89PNG\r\n\x1a\n"), []byte("image data")...)
+
+	var blobs *storage.BlobStore
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		var err error
+		blobs, err = storage.OpenBlobStore(GinkgoT().TempDir())
+		Expect(err).ToNot(HaveOccurred())
+		services.SetBlobStore(blobs)
+		DeferCleanup(func() { services.SetBlobStore(nil) })
+
+		for _, title := range []string{"Task", "Other Task"} {
+			_, err := services.CreateTask(context.Background(), models.Task{Title: title, Description: "Task Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	upload := func(path, filename string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", filename)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = part.Write(content)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(form.Close()).To(Succeed())
+		return performRawRequest(http.MethodPost, path, form.FormDataContentType(), body.Bytes())
+	}
+
+	attach := func(path, filename string, content []byte) models.Attachment {
+		response := upload(path, filename, content)
+		Expect(response.Code).To(Equal(http.StatusCreated))
+
+		var attachment models.Attachment
+		Expect(json.Unmarshal(response.Body.Bytes(), &attachment)).To(Succeed())
+		return attachment
+	}
+
+	storedBlobs := func() []string {
+		digests, err := blobs.List(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		return digests
+	}
+
+	It("should store an uploaded file with its sniffed content type", func() {
+		attachment := attach(attachmentsPath, "../screenshots/shot.png", shot)
+		Expect(attachment.TaskID).To(Equal(1))
+		Expect(attachment.Filename).To(Equal("shot.png"))
+		Expect(attachment.ContentType).To(Equal("image/png"))
+		Expect(attachment.Size).To(Equal(int64(len(shot))))
+		Expect(storedBlobs()).To(ConsistOf(attachment.SHA256))
+
+		response := performRequest(http.MethodGet, attachmentsPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		var attachments []models.Attachment
+		Expect(json.Unmarshal(response.Body.Bytes(), &attachments)).To(Succeed())
+		Expect(attachments).To(Equal([]models.Attachment{attachment}))
+	})
+
+	It("should download an attachment in full or in ranges", func() {
+		attachment := attach(attachmentsPath, "build log.txt", []byte("line 1\nline 2\n"))
+		path := attachmentsPath + "/" + strconv.Itoa(attachment.ID)
+
+		response := performRequest(http.MethodGet, path, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(Equal("line 1\nline 2\n"))
+		Expect(response.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+		Expect(response.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="build log.txt"`))
+		Expect(response.Header().Get("Accept-Ranges")).To(Equal("bytes"))
+		Expect(response.Header().Get("ETag")).To(Equal(`"` + attachment.SHA256 + `"`))
+
+		response = performRequestWithHeaders(http.MethodGet, path, nil, map[string]string{"Range": "bytes=7-12"})
+		Expect(response.Code).To(Equal(http.StatusPartialContent))
+		Expect(response.Body.String()).To(Equal("line 2"))
+		Expect(response.Header().Get("Content-Range")).To(Equal("bytes 7-12/14"))
+
+		response = performRequestWithHeaders(http.MethodGet, path, nil, map[string]string{"Range": "bytes=20-"})
+		Expect(response.Code).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+
+		response = performRequest(http.MethodGet, tasksPath+"/2/attachments/"+strconv.Itoa(attachment.ID), nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errAttachmentNotFound.code))
+	})
+
+	It("should share the blob of identical files until the last one is deleted", func() {
+		first := attach(attachmentsPath, "shot.png", shot)
+		second := attach(tasksPath+"/2/attachments", "copy.png", shot)
+		Expect(second.SHA256).To(Equal(first.SHA256))
+		Expect(storedBlobs()).To(HaveLen(1))
+
+		response := performRequest(http.MethodDelete, attachmentsPath+"/"+strconv.Itoa(first.ID), nil)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(storedBlobs()).To(HaveLen(1))
+
+		response = performRequest(http.MethodDelete, tasksPath+"/2/attachments/"+strconv.Itoa(second.ID), nil)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(storedBlobs()).To(BeEmpty())
+	})
+
+	It("should delete the blobs of a task when it is purged", func() {
+		services.SetAdminToken(adminToken)
+		DeferCleanup(services.SetAdminToken, "")
+
+		attach(attachmentsPath, "shot.png", shot)
+		kept := attach(tasksPath+"/2/attachments", "log.txt", []byte("log"))
+
+		response := performRequest(http.MethodDelete, tasksPath+"/1", nil)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(storedBlobs()).To(HaveLen(2))
+
+		response = performRequestWithHeaders(http.MethodDelete, tasksPath+"/1?hard=true", nil, asAdminHeaders)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(storedBlobs()).To(ConsistOf(kept.SHA256))
+	})
+
+	It("should not record an upload whose blob was collected while it was stored", func() {
+		services.SetBlobStore(&collectingBlobStore{blobs})
+
+		response := upload(attachmentsPath, "shot.png", shot)
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errUploadConflict.code))
+		Expect(storedBlobs()).To(BeEmpty())
+
+		services.SetBlobStore(blobs)
+		attach(attachmentsPath, "shot.png", shot)
+	})
+
+	It("should reject files larger than the maximum attachment size", func() {
+		services.SetMaxAttachmentSize(8)
+		DeferCleanup(services.SetMaxAttachmentSize, int64(services.DefaultMaxAttachmentSize))
+
+		response := upload(attachmentsPath, "shot.png", shot)
+		Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(response.Body.String()).To(ContainSubstring(errAttachmentTooLarge.code))
+		Expect(storedBlobs()).To(BeEmpty())
+
+		attach(attachmentsPath, "small.txt", []byte("12345678"))
+	})
+
+	It("should reject uploads without a file", func() {
+		response := performRequest(http.MethodPost, attachmentsPath, map[string]string{"file": "shot.png"})
+		Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		Expect(form.WriteField("name", "shot.png")).To(Succeed())
+		Expect(form.Close()).To(Succeed())
+		response = performRawRequest(http.MethodPost, attachmentsPath, form.FormDataContentType(), body.Bytes())
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("file_required"))
+
+		response = upload(tasksPath+"/3/attachments", "shot.png", shot)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errTaskNotFound.code))
+	})
+
+	It("should not support attachments without a blob store", func() {
+		services.SetBlobStore(nil)
+
+		response := performRequest(http.MethodGet, attachmentsPath, nil)
+		Expect(response.Code).To(Equal(http.StatusNotImplemented))
+		Expect(response.Body.String()).To(ContainSubstring(errAttachmentsUnsupported.code))
+	})
+
+	Describe("GET /tasks/{id}/attachments/{attachmentID}/thumbnail", func() {
+		// encodePNG returns a PNG image of width by height pixels
+		encodePNG := func(width, height int) []byte {
+			img := image.NewRGBA(image.Rect(0, 0, width, height))
+			for x := 0; x < width; x++ {
+				img.Set(x, 0, color.RGBA{R: 255, A: 255})
+			}
+			var encoded bytes.Buffer
+			Expect(png.Encode(&encoded, img)).To(Succeed())
+			return encoded.Bytes()
+		}
+
+		// pngHeader returns the start of a PNG file declaring an image of width by height pixels,
+		// enough for its dimensions to be read but not for it to be decoded
+		pngHeader := func(width, height uint32) []byte {
+			chunk := []byte("IHDR")
+			chunk = binary.BigEndian.AppendUint32(chunk, width)
+			chunk = binary.BigEndian.AppendUint32(chunk, height)
+			chunk = append(chunk, 8, 6, 0, 0, 0)
+
+			header := []byte("\x89PNG\r\n\x1a\n")
+			header = binary.BigEndian.AppendUint32(header, 13)
+			header = append(header, chunk...)
+			return binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(chunk))
+		}
+
+		thumbnail := func(path string) image.Image {
+			response := performRequest(http.MethodGet, path, nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("image/png"))
+
+			img, err := png.Decode(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return img
+		}
+
+		It("should scale images down to the requested size and cache the thumbnails", func() {
+			attachment := attach(attachmentsPath, "wide.png", encodePNG(400, 200))
+			path := attachmentsPath + "/" + strconv.Itoa(attachment.ID) + "/thumbnail"
+
+			Expect(thumbnail(path).Bounds()).To(Equal(image.Rect(0, 0, 128, 64)))
+			Expect(thumbnail(path + "?size=64").Bounds()).To(Equal(image.Rect(0, 0, 64, 32)))
+			Expect(blobs.Path(attachment.SHA256) + ".thumb-64.png").To(BeAnExistingFile())
+			Expect(thumbnail(path + "?size=64").Bounds()).To(Equal(image.Rect(0, 0, 64, 32)))
+
+			response := performRequest(http.MethodGet, path+"?size=100", nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(errInvalidQuery.code))
+
+			response = performRequest(http.MethodDelete, attachmentsPath+"/"+strconv.Itoa(attachment.ID), nil)
+			Expect(response.Code).To(Equal(http.StatusNoContent))
+			_, err := os.Stat(blobs.Path(attachment.SHA256) + ".thumb-64.png")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("should not generate thumbnails of files that are not images", func() {
+			attachment := attach(attachmentsPath, "log.txt", []byte("line 1"))
+
+			response := performRequest(http.MethodGet, attachmentsPath+"/"+strconv.Itoa(attachment.ID)+"/thumbnail", nil)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring(errNotAnImage.code))
+		})
+
+		DescribeTable("should reject images too large to be decoded safely",
+			func(width, height uint32) {
+				attachment := attach(attachmentsPath, "bomb.png", pngHeader(width, height))
+				Expect(attachment.ContentType).To(Equal("image/png"))
+
+				response := performRequest(http.MethodGet, attachmentsPath+"/"+strconv.Itoa(attachment.ID)+"/thumbnail", nil)
+				Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(response.Body.String()).To(ContainSubstring(errImageTooLarge.code))
+			},
+			Entry("too many pixels", uint32(6000), uint32(6000)),
+			Entry("too wide", uint32(20000), uint32(1)),
+		)
+	})
+})
+
+// collectingBlobStore collects the unreferenced blobs right after storing one, as a concurrent collection could
+type collectingBlobStore struct {
+	*storage.BlobStore
+}
+
+func (s *collectingBlobStore) Put(ctx context.Context, r io.Reader) (string, int64, error) {
+	digest, size, err := s.BlobStore.Put(ctx, r)
+	if err == nil {
+		_, err = services.CollectBlobs(ctx)
+	}
+	return digest, size, err
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	textRequired         = "text is required"
+	textTooLong          = "text must be at most 500 characters"
+	checklistIDsRequired = "ids is required"
+
+	maxChecklistTextLength = 500
+)
+
+// handleTaskChecklist serves GET and POST /tasks/{id}/checklist, PUT /tasks/{id}/checklist/order
+// and GET, PUT and DELETE /tasks/{id}/checklist/{itemID}
+func handleTaskChecklist(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if len(segments) > 1 {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) == 1 {
+		if segments[0] == "order" {
+			handleChecklistOrder(w, r, id)
+			return
+		}
+		itemID, err := strconv.Atoi(segments[0])
+		if err != nil {
+			sendProblem(w, errNotFound, "")
+			return
+		}
+		handleChecklistItem(w, r, id, itemID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := services.ChecklistItems(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, items, http.StatusOK)
+
+	case http.MethodPost:
+		item, ok := decodeChecklistItem(w, r)
+		if !ok {
+			return
+		}
+		task, item, err := services.AddChecklistItem(requestContext(r), id, item, ifMatch(r)...)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.Header().Set("ETag", taskETag(task))
+		utils.SendResponse(w, item, http.StatusCreated)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleChecklistItem serves GET, PUT and DELETE /tasks/{id}/checklist/{itemID}.
+// Changing an item responds with the task, whose checklist progress reflects the change.
+func handleChecklistItem(w http.ResponseWriter, r *http.Request, id, itemID int) {
+	var (
+		task models.Task
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		items, err := services.ChecklistItems(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		for _, item := range items {
+			if item.ID == itemID {
+				utils.SendResponse(w, item, http.StatusOK)
+				return
+			}
+		}
+		sendServiceError(w, services.ErrChecklistItemNotFound)
+		return
+
+	case http.MethodPut:
+		item, ok := decodeChecklistItem(w, r)
+		if !ok {
+			return
+		}
+		item.ID = itemID
+		task, err = services.UpdateChecklistItem(requestContext(r), id, item, ifMatch(r)...)
+
+	case http.MethodDelete:
+		task, err = services.DeleteChecklistItem(requestContext(r), id, itemID, ifMatch(r)...)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// handleChecklistOrder serves PUT /tasks/{id}/checklist/order, whose body lists the IDs of every item in their new order
+func handleChecklistOrder(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPut {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	var body struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return
+	}
+	if body.IDs == nil {
+		sendProblem(w, errValidationFailed, validationFailed, fieldError("ids", checklistIDsRequired))
+		return
+	}
+	task, err := services.ReorderChecklist(requestContext(r), id, body.IDs, ifMatch(r)...)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// decodeChecklistItem reads and validates a checklist item from the request body.
+// It sends the error response and returns false when the item is invalid.
+func decodeChecklistItem(w http.ResponseWriter, r *http.Request) (models.ChecklistItem, bool) {
+	var item models.ChecklistItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return models.ChecklistItem{}, false
+	}
+	if fields := validateChecklistItem("", item); len(fields) > 0 {
+		sendProblem(w, errValidationFailed, validationFailed, fields...)
+		return models.ChecklistItem{}, false
+	}
+	return item, true
+}
+
+// validateChecklist returns an error for every invalid item of the checklist of a new task
+func validateChecklist(checklist []models.ChecklistItem) []utils.FieldError {
+	var fields []utils.FieldError
+	for i, item := range checklist {
+		fields = append(fields, validateChecklistItem(models.JsonChecklist+"["+strconv.Itoa(i)+"].", item)...)
+	}
+	return fields
+}
+
+// validateChecklistItem returns an error for every invalid field of a checklist item, prefixing the field names
+func validateChecklistItem(prefix string, item models.ChecklistItem) []utils.FieldError {
+	text := strings.TrimSpace(item.Text)
+	switch {
+	case text == "":
+		return []utils.FieldError{fieldError(prefix+"text", textRequired)}
+	case utf8.RuneCountInString(text) > maxChecklistTextLength:
+		return []utils.FieldError{fieldError(prefix+"text", textTooLong)}
+	}
+	return nil
+}
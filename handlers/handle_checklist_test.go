@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strings"
+)
+
+var _ = Describe("Checklist Tests", func() {
+	const checklistPath = tasksPath + "/1/checklist"
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		_, err := services.CreateTask(context.Background(), models.Task{Title: "Task", Description: "Task Description", Status: "TODO"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	decodeTask := func(body []byte) models.Task {
+		var task models.Task
+		Expect(json.Unmarshal(body, &task)).To(Succeed())
+		return task
+	}
+
+	addItem := func(text string) models.ChecklistItem {
+		response := performRequest(http.MethodPost, checklistPath, map[string]any{"text": text})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+
+		var item models.ChecklistItem
+		Expect(json.Unmarshal(response.Body.Bytes(), &item)).To(Succeed())
+		return item
+	}
+
+	checklistTexts := func() []string {
+		response := performRequest(http.MethodGet, checklistPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var items []models.ChecklistItem
+		Expect(json.Unmarshal(response.Body.Bytes(), &items)).To(Succeed())
+		texts := make([]string, len(items))
+		for i, item := range items {
+			texts[i] = item.Text
+		}
+		return texts
+	}
+
+	It("should add items and derive the progress of the task from them", func() {
+		Expect(checklistTexts()).To(BeEmpty())
+		response := performRequest(http.MethodGet, tasksPath+"/1", nil)
+		Expect(response.Body.String()).ToNot(ContainSubstring(models.JsonChecklistProgress))
+
+		Expect(addItem(" Write ").ID).To(Equal(1))
+		Expect(addItem("Review").ID).To(Equal(2))
+		Expect(addItem("Ship").ID).To(Equal(3))
+		Expect(checklistTexts()).To(Equal([]string{"Write", "Review", "Ship"}))
+
+		response = performRequest(http.MethodPut, checklistPath+"/1", map[string]any{"text": "Write", "done": true, "assignee": "alice"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		task := decodeTask(response.Body.Bytes())
+		Expect(task.Checklist[0]).To(Equal(models.ChecklistItem{ID: 1, Text: "Write", Done: true, Assignee: "alice"}))
+		Expect(task.ChecklistProgress).To(Equal(&models.Progress{Completed: 1, Total: 3, Percent: 33.3}))
+		Expect(response.Header().Get("ETag")).To(Equal(`"5-0"`))
+
+		response = performRequest(http.MethodGet, tasksPath, nil)
+		Expect(response.Body.String()).To(ContainSubstring(`"checklist_progress":{"completed":1,"total":3,"percent":33.3}`))
+
+		response = performRequest(http.MethodGet, checklistPath+"/1", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(ContainSubstring(`"assignee":"alice"`))
+	})
+
+	It("should delete items without reusing their IDs", func() {
+		addItem("Write")
+		addItem("Review")
+
+		response := performRequest(http.MethodDelete, checklistPath+"/2", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(addItem("Ship").ID).To(Equal(2))
+		Expect(checklistTexts()).To(Equal([]string{"Write", "Ship"}))
+
+		response = performRequest(http.MethodDelete, checklistPath+"/1", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		response = performRequest(http.MethodDelete, checklistPath+"/2", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeTask(response.Body.Bytes()).ChecklistProgress).To(BeNil())
+
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			response = performRequest(method, checklistPath+"/2", map[string]any{"text": "Ship"})
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+			Expect(response.Body.String()).To(ContainSubstring(errChecklistItemNotFound.code))
+		}
+	})
+
+	It("should reorder the checklist", func() {
+		addItem("Write")
+		addItem("Review")
+		addItem("Ship")
+
+		response := performRequest(http.MethodPut, checklistPath+"/order", map[string]any{"ids": []int{3, 1, 2}})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(checklistTexts()).To(Equal([]string{"Ship", "Write", "Review"}))
+
+		for _, ids := range [][]int{{1, 2}, {1, 2, 2}, {1, 2, 4}} {
+			response = performRequest(http.MethodPut, checklistPath+"/order", map[string]any{"ids": ids})
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring(errInvalidChecklistOrder.code))
+		}
+		Expect(checklistTexts()).To(Equal([]string{"Ship", "Write", "Review"}))
+
+		response = performRequest(http.MethodPut, checklistPath+"/order", map[string]any{})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("ids_required"))
+	})
+
+	It("should validate checklist items", func() {
+		response := performRequest(http.MethodPost, checklistPath, map[string]any{"text": "  "})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("text_required"))
+
+		response = performRequest(http.MethodPost, checklistPath, map[string]any{"text": strings.Repeat("a", maxChecklistTextLength+1)})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("text_too_long"))
+
+		response = performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Task", "description": "Task Description", "status": "TODO", "checklist": []map[string]any{{"text": ""}},
+		})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(`"field":"checklist[0].text"`))
+	})
+
+	It("should reject changes when If-Match does not match", func() {
+		response := performRequestWithHeaders(http.MethodPost, checklistPath, map[string]any{"text": "Write"}, map[string]string{"If-Match": `"2"`})
+		Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+
+		response = performRequestWithHeaders(http.MethodPost, checklistPath, map[string]any{"text": "Write"}, map[string]string{"If-Match": `"1"`})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		Expect(response.Header().Get("ETag")).To(Equal(`"2-0"`))
+	})
+
+	It("should create the checklist from the task list of the description", func() {
+		response := performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Release", "description": "Steps:\n- [x] Tag\n- [ ] Publish", "status": "TODO",
+		})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		task := decodeTask(response.Body.Bytes())
+		Expect(task.Checklist).To(Equal([]models.ChecklistItem{{ID: 1, Text: "Tag", Done: true}, {ID: 2, Text: "Publish"}}))
+		Expect(task.ChecklistProgress).To(Equal(&models.Progress{Completed: 1, Total: 2, Percent: 50}))
+
+		response = performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Release", "description": "- [ ] Ignored", "status": "TODO", "checklist": []map[string]any{{"text": "Given", "id": 7}},
+		})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		Expect(decodeTask(response.Body.Bytes()).Checklist).To(Equal([]models.ChecklistItem{{ID: 1, Text: "Given"}}))
+	})
+
+	It("should keep the checklist when the task is replaced or patched", func() {
+		addItem("Write")
+
+		response := performRequest(http.MethodPut, tasksPath+"/1", map[string]any{"title": "Renamed", "description": "Task Description", "status": "TODO"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeTask(response.Body.Bytes()).Checklist).To(HaveLen(1))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/1", "application/merge-patch+json", []byte(`{"checklist":null}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeTask(response.Body.Bytes()).Checklist).To(HaveLen(1))
+
+		response = performRequest(http.MethodGet, tasksPath+"/1/history", nil)
+		Expect(response.Body.String()).To(ContainSubstring(`"field":"checklist"`))
+		Expect(response.Body.String()).ToNot(ContainSubstring(`"field":"checklist_progress"`))
+	})
+
+	It("should not find the checklist of a task in the trash", func() {
+		Expect(services.DeleteTask(context.Background(), 1, services.DeleteReject)).To(Succeed())
+
+		response := performRequest(http.MethodGet, checklistPath, nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		response = performRequest(http.MethodPost, checklistPath, map[string]any{"text": "Write"})
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+	})
+})
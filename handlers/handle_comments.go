@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	bodyRequired           = "body is required"
+	bodyTooLong            = "body must be at most 10000 characters"
+	invalidParentCommentID = "parent_id must be a positive comment ID"
+
+	maxCommentLength = 10000
+)
+
+// handleTaskComments serves GET and POST /tasks/{id}/comments and GET, PUT and DELETE /tasks/{id}/comments/{commentID}
+func handleTaskComments(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if len(segments) > 1 {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) == 1 {
+		commentID, err := strconv.Atoi(segments[0])
+		if err != nil {
+			sendProblem(w, errNotFound, "")
+			return
+		}
+		handleTaskComment(w, r, id, commentID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		comments, err := services.ListComments(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, comments, http.StatusOK)
+
+	case http.MethodPost:
+		var comment models.Comment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		fields := validateCommentBody(comment.Body)
+		if comment.ParentID != nil && *comment.ParentID < 1 {
+			fields = append(fields, fieldError(models.JsonParentID, invalidParentCommentID))
+		}
+		if len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+
+		created, err := services.AddComment(requestContext(r), id, comment)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, created, http.StatusCreated)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleTaskComment serves GET, PUT and DELETE /tasks/{id}/comments/{commentID}
+func handleTaskComment(w http.ResponseWriter, r *http.Request, id, commentID int) {
+	switch r.Method {
+	case http.MethodGet:
+		comment, err := services.GetComment(r.Context(), id, commentID)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, comment, http.StatusOK)
+
+	case http.MethodPut:
+		var body struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		if fields := validateCommentBody(body.Body); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+
+		comment, err := services.EditComment(requestContext(r), id, commentID, body.Body)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, comment, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := services.DeleteComment(requestContext(r), id, commentID); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// validateCommentBody returns an error if the Markdown body of a comment is empty or too long
+func validateCommentBody(body string) []utils.FieldError {
+	switch {
+	case body == "":
+		return []utils.FieldError{fieldError("body", bodyRequired)}
+	case utf8.RuneCountInString(body) > maxCommentLength:
+		return []utils.FieldError{fieldError("body", bodyTooLong)}
+	}
+	return nil
+}
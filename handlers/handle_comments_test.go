@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var _ = Describe("Comments Tests", func() {
+	const commentsPath = tasksPath + "/1/comments"
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		_, err := services.CreateTask(context.Background(), models.Task{Title: "Task", Description: "Task Description", Status: "TODO"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	comment := func(actor string, body map[string]any) models.Comment {
+		response := performRequestWithHeaders(http.MethodPost, commentsPath, body, map[string]string{actorHeader: actor})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+
+		var created models.Comment
+		Expect(json.Unmarshal(response.Body.Bytes(), &created)).To(Succeed())
+		return created
+	}
+
+	threads := func() []models.Comment {
+		response := performRequest(http.MethodGet, commentsPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var comments []models.Comment
+		Expect(json.Unmarshal(response.Body.Bytes(), &comments)).To(Succeed())
+		return comments
+	}
+
+	commentCount := func() int {
+		task, err := services.GetTaskByID(context.Background(), 1)
+		Expect(err).ToNot(HaveOccurred())
+		return task.CommentCount
+	}
+
+	It("should render the Markdown body of a comment", func() {
+		created := comment("alice", map[string]any{"body": "**Done** in `main`"})
+		Expect(created.Author).To(Equal("alice"))
+		Expect(created.BodyHTML).To(Equal("<p><strong>Done</strong> in <code>main</code></p>"))
+		Expect(commentCount()).To(Equal(1))
+	})
+
+	It("should nest replies under the comments they reply to", func() {
+		first := comment("alice", map[string]any{"body": "Question"})
+		reply := comment("bob", map[string]any{"body": "Answer", "parent_id": first.ID})
+		comment("alice", map[string]any{"body": "Thanks", "parent_id": reply.ID})
+		comment("bob", map[string]any{"body": "Another topic"})
+
+		comments := threads()
+		Expect(comments).To(HaveLen(2))
+		Expect(comments[0].Replies).To(HaveLen(1))
+		Expect(comments[0].Replies[0].Body).To(Equal("Answer"))
+		Expect(comments[0].Replies[0].Replies[0].Body).To(Equal("Thanks"))
+		Expect(comments[1].Replies).To(BeEmpty())
+		Expect(commentCount()).To(Equal(4))
+
+		response := performRequest(http.MethodPost, commentsPath, map[string]any{"body": "Reply", "parent_id": 10})
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(ContainSubstring(errParentCommentNotFound.code))
+	})
+
+	It("should keep the previous bodies of an edited comment", func() {
+		created := comment("alice", map[string]any{"body": "Frist"})
+		path := commentsPath + "/" + strconv.Itoa(created.ID)
+
+		response := performRequestWithHeaders(http.MethodPut, path, map[string]string{"body": "First"}, map[string]string{actorHeader: "bob"})
+		Expect(response.Code).To(Equal(http.StatusForbidden))
+		Expect(response.Body.String()).To(ContainSubstring(errNotCommentAuthor.code))
+
+		response = performRequestWithHeaders(http.MethodPut, path, map[string]string{"body": "First"}, map[string]string{actorHeader: "alice"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		response = performRequest(http.MethodGet, path, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		var edited models.Comment
+		Expect(json.Unmarshal(response.Body.Bytes(), &edited)).To(Succeed())
+		Expect(edited.Body).To(Equal("First"))
+		Expect(edited.Edits).To(HaveLen(1))
+		Expect(edited.Edits[0].Body).To(Equal("Frist"))
+		Expect(edited.Edits[0].Editor).To(Equal("alice"))
+	})
+
+	It("should keep a deleted comment with replies as a placeholder", func() {
+		first := comment("alice", map[string]any{"body": "Question"})
+		reply := comment("bob", map[string]any{"body": "Answer", "parent_id": first.ID})
+
+		response := performRequestWithHeaders(http.MethodDelete, commentsPath+"/"+strconv.Itoa(first.ID), nil, map[string]string{actorHeader: "alice"})
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		comments := threads()
+		Expect(comments).To(HaveLen(1))
+		Expect(comments[0].DeletedAt).ToNot(BeNil())
+		Expect(comments[0].Body).To(BeEmpty())
+		Expect(comments[0].Replies).To(HaveLen(1))
+		Expect(commentCount()).To(Equal(1))
+
+		response = performRequestWithHeaders(http.MethodDelete, commentsPath+"/"+strconv.Itoa(reply.ID), nil, map[string]string{actorHeader: "bob"})
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(threads()).To(BeEmpty())
+		Expect(commentCount()).To(Equal(0))
+	})
+
+	It("should change the ETag of the task when a comment is added or deleted", func() {
+		taskPath := tasksPath + "/1"
+		response := performRequest(http.MethodGet, taskPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		etag := response.Header().Get("ETag")
+
+		created := comment("alice", map[string]any{"body": "Hello"})
+		response = performRequestWithHeaders(http.MethodGet, taskPath, nil, map[string]string{"If-None-Match": etag})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("ETag")).ToNot(Equal(etag))
+		var task models.Task
+		Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+		Expect(task.CommentCount).To(Equal(1))
+
+		commented := response.Header().Get("ETag")
+		response = performRequestWithHeaders(http.MethodDelete, commentsPath+"/"+strconv.Itoa(created.ID), nil, map[string]string{actorHeader: "alice"})
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		response = performRequestWithHeaders(http.MethodGet, taskPath, nil, map[string]string{"If-None-Match": commented})
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		// The version is unchanged, so a comment does not fail an update of the task
+		response = performRequestWithHeaders(http.MethodDelete, taskPath, nil, map[string]string{"If-Match": commented})
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("should validate the body of a comment", func() {
+		response := performRequest(http.MethodPost, commentsPath, map[string]any{"body": "", "parent_id": 0})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("body_required"))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_parent_comment_id"))
+
+		response = performRequest(http.MethodPost, commentsPath, map[string]any{"body": strings.Repeat("a", maxCommentLength+1)})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("body_too_long"))
+	})
+
+	It("should not find the comments of a task in the trash", func() {
+		comment("alice", map[string]any{"body": "Hello"})
+		Expect(services.DeleteTask(context.Background(), 1, services.DeleteReject)).To(Succeed())
+
+		response := performRequest(http.MethodGet, commentsPath, nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errTaskNotFound.code))
+	})
+})
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	blockerIDRequired = "blocker_id is required"
+	invalidBlockedBy  = "blocked_by must only contain positive task IDs"
+)
+
+// handleTaskBlockers serves GET and POST /tasks/{id}/blockers and DELETE /tasks/{id}/blockers/{blockerID}
+func handleTaskBlockers(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if len(segments) == 0 && r.Method == http.MethodGet {
+		blockers, err := services.Blockers(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, blockers, http.StatusOK)
+		return
+	}
+
+	var (
+		task models.Task
+		err  error
+	)
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		var body struct {
+			BlockerID int `json:"blocker_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		if body.BlockerID < 1 {
+			sendProblem(w, errValidationFailed, validationFailed, fieldError("blocker_id", blockerIDRequired))
+			return
+		}
+		task, err = services.AddBlocker(requestContext(r), id, body.BlockerID, ifMatch(r)...)
+
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		blockerID, convErr := strconv.Atoi(segments[0])
+		if convErr != nil {
+			sendProblem(w, errInvalidTaskID, "")
+			return
+		}
+		task, err = services.RemoveBlocker(requestContext(r), id, blockerID, ifMatch(r)...)
+
+	case len(segments) <= 1:
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+
+	default:
+		sendProblem(w, errNotFound, "")
+		return
+	}
+
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// handleReadyTasks serves GET /tasks/ready
+func handleReadyTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	tasks, err := services.ReadyTasks(r.Context())
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, tasks, http.StatusOK)
+}
+
+// handleTaskOrder serves GET /tasks/order
+func handleTaskOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	tasks, err := services.TopologicalOrder(r.Context())
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, tasks, http.StatusOK)
+}
+
+// handleCriticalPath serves GET /tasks/critical-path
+func handleCriticalPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	path, err := services.CriticalPath(r.Context())
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, path, http.StatusOK)
+}
+
+// warnIfBlocked adds a Warning header to the response when the task is in progress while some of its blockers
+// are not done. Under the fail blocker policy such a change is rejected by the services package instead.
+func warnIfBlocked(w http.ResponseWriter, r *http.Request, task models.Task) {
+	status, ok := services.Workflow().Status(task.Status)
+	if !ok || status.Category != models.CategoryInProgress {
+		return
+	}
+	open, err := services.OpenBlockers(r.Context(), task)
+	if err != nil {
+		log.Printf("failed to read the blockers of task %d: %v", task.ID, err)
+		return
+	}
+	if len(open) == 0 {
+		return
+	}
+	ids := make([]string, len(open))
+	for i, id := range open {
+		ids[i] = strconv.Itoa(id)
+	}
+	w.Header().Set("Warning", fmt.Sprintf(`199 - "task is blocked by open tasks %s"`, strings.Join(ids, ", ")))
+}
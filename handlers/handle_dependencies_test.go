@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+)
+
+var _ = Describe("Dependencies Tests", func() {
+	// Task 1 blocks 2 and 3, which both block 4. Task 5 is independent.
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		blockers := [][]int{nil, {1}, {1}, {3, 2}, nil}
+		efforts := []float64{2, 1, 5, 1, 3}
+		for i, blockedBy := range blockers {
+			task := models.Task{Title: "Task " + strconv.Itoa(i+1), Description: "Task Description", Status: "TODO", EffortHours: efforts[i], BlockedBy: blockedBy}
+			_, err := services.CreateTask(context.Background(), task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	taskIDs := func(path string) []int {
+		response := performRequest(http.MethodGet, path, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var tasks []models.Task
+		Expect(json.Unmarshal(response.Body.Bytes(), &tasks)).To(Succeed())
+		ids := make([]int, len(tasks))
+		for i, task := range tasks {
+			ids[i] = task.ID
+		}
+		return ids
+	}
+
+	It("should list the blockers of a task", func() {
+		Expect(taskIDs(tasksPath + "/4/blockers")).To(Equal([]int{2, 3}))
+	})
+
+	It("should add and remove a blocker", func() {
+		response := performRequest(http.MethodPost, tasksPath+"/5/blockers", map[string]int{"blocker_id": 4})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(taskIDs(tasksPath + "/5/blockers")).To(Equal([]int{4}))
+
+		response = performRequest(http.MethodDelete, tasksPath+"/5/blockers/4", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(taskIDs(tasksPath + "/5/blockers")).To(BeEmpty())
+
+		response = performRequest(http.MethodDelete, tasksPath+"/5/blockers/4", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errDependencyNotFound.code))
+	})
+
+	It("should reject a blocker that would create a cycle", func() {
+		response := performRequest(http.MethodPost, tasksPath+"/1/blockers", map[string]int{"blocker_id": 4})
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errDependencyCycle.code))
+
+		response = performRequest(http.MethodPost, tasksPath+"/1/blockers", map[string]int{"blocker_id": 1})
+		Expect(response.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should reject a missing blocker", func() {
+		response := performRequest(http.MethodPost, tasksPath+"/1/blockers", map[string]int{"blocker_id": 10})
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(ContainSubstring(errBlockerNotFound.code))
+
+		response = performRequest(http.MethodPost, tasksPath+"/1/blockers", map[string]int{})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("blocker_id_required"))
+	})
+
+	It("should list the tasks without open blockers", func() {
+		Expect(taskIDs(tasksPath + "/ready")).To(Equal([]int{1, 5}))
+
+		_, err := services.PatchTask(context.Background(), 1, func(task *models.Task) error {
+			task.Status = "Completed"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(taskIDs(tasksPath + "/ready")).To(Equal([]int{2, 3, 5}))
+	})
+
+	It("should order every task after its blockers", func() {
+		Expect(taskIDs(tasksPath + "/order")).To(Equal([]int{1, 2, 3, 4, 5}))
+
+		_, err := services.AddBlocker(context.Background(), 1, 5)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(taskIDs(tasksPath + "/order")).To(Equal([]int{5, 1, 2, 3, 4}))
+	})
+
+	It("should return the chain of open tasks with the most effort", func() {
+		response := performRequest(http.MethodGet, tasksPath+"/critical-path", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var path services.TaskPath
+		Expect(json.Unmarshal(response.Body.Bytes(), &path)).To(Succeed())
+		Expect(path.EffortHours).To(Equal(8.0))
+		Expect(path.Tasks).To(HaveLen(3))
+		Expect([]int{path.Tasks[0].ID, path.Tasks[1].ID, path.Tasks[2].ID}).To(Equal([]int{1, 3, 4}))
+	})
+
+	It("should warn when a blocked task is started", func() {
+		response := performRawRequest(http.MethodPatch, tasksPath+"/2", utils.MergePatchContentType, []byte(`{"status":"in-progress"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Warning")).To(ContainSubstring("blocked by open tasks 1"))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/5", utils.MergePatchContentType, []byte(`{"status":"in-progress"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Warning")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/services"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	invalidTaskIDFilter = "task_id must be a positive task ID"
+
+	// eventKeepAlive is how often a comment is sent on an idle change feed, so proxies do not close the connection
+	eventKeepAlive = 15 * time.Second
+)
+
+// handleTaskEvents serves GET /tasks/events, the change feed of the tasks as Server-Sent Events.
+// The status and task_id query parameters (repeatable) filter the events, and the Last-Event-ID header resumes the
+// feed after the event with this ID.
+func handleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	filter, err := parseFeedFilter(r.URL.Query())
+	if err != nil {
+		sendProblem(w, errInvalidQuery, err.Error())
+		return
+	}
+
+	sub := services.Subscribe(r.Header.Get("Last-Event-ID"), filter)
+	defer sub.Close()
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disables the response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if sub.Reset {
+		_, _ = io.WriteString(w, "event: reset\ndata: {}\n\n")
+	} else {
+		_, _ = io.WriteString(w, ": connected\n\n")
+	}
+	for _, event := range sub.Replay {
+		if err := writeFeedEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// The client fell behind and resumes from its last event when it reconnects
+				return
+			}
+			if err := writeFeedEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeFeedEvent writes an event of the change feed in the text/event-stream format
+func writeFeedEvent(w io.Writer, event services.FeedEvent) error {
+	data, err := json.Marshal(event.TaskEvent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// parseFeedFilter reads the status and task_id query parameters of GET /tasks/events
+func parseFeedFilter(query url.Values) (services.FeedFilter, error) {
+	filter := services.FeedFilter{Statuses: query["status"]}
+	for _, value := range query["task_id"] {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return services.FeedFilter{}, errors.New(invalidTaskIDFilter)
+		}
+		filter.TaskIDs = append(filter.TaskIDs, id)
+	}
+	return filter, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Change Feed Tests", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+		server = httptest.NewServer(http.HandlerFunc(HandleTaskByID))
+		DeferCleanup(server.Close)
+	})
+
+	// sseEvent is an event read from the stream
+	type sseEvent struct {
+		id, event, data string
+	}
+
+	// connect opens the change feed and returns a function reading its next event, skipping comments
+	connect := func(query, lastEventID string) func() sseEvent {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/tasks/events"+query, nil)
+		Expect(err).ToNot(HaveOccurred())
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(response.Body.Close)
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		reader := bufio.NewReader(response.Body)
+		return func() sseEvent {
+			var event sseEvent
+			for {
+				line, err := reader.ReadString('\n')
+				Expect(err).ToNot(HaveOccurred())
+				line = strings.TrimSuffix(line, "\n")
+				switch {
+				case line == "" && event.event != "":
+					return event
+				case strings.HasPrefix(line, "id: "):
+					event.id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					event.event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					event.data = strings.TrimPrefix(line, "data: ")
+				}
+			}
+		}
+	}
+
+	createTask := func(title string) {
+		response := performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": title, "description": "Description", "status": "TODO",
+		})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+	}
+
+	It("should stream task events and resume from the Last-Event-ID", func() {
+		next := connect("", "")
+		createTask("First")
+		created := next()
+		Expect(created.event).To(Equal(services.EventTaskCreated))
+		Expect(created.id).ToNot(BeEmpty())
+		var event services.TaskEvent
+		Expect(json.Unmarshal([]byte(created.data), &event)).To(Succeed())
+		Expect(event.TaskID).To(Equal(1))
+		Expect(event.Task.Title).To(Equal("First"))
+
+		response := performRawRequest(http.MethodPatch, tasksPath+"/1", utils.MergePatchContentType, []byte(`{"title": "Renamed"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(performRequest(http.MethodDelete, tasksPath+"/1", nil).Code).To(Equal(http.StatusNoContent))
+
+		resumed := connect("", created.id)
+		Expect(resumed().event).To(Equal(services.EventTaskUpdated))
+		deleted := resumed()
+		Expect(deleted.event).To(Equal(services.EventTaskDeleted))
+		Expect(deleted.data).To(ContainSubstring(`"title":"Renamed"`))
+
+		Expect(connect("", "unknown")().event).To(Equal("reset"))
+	})
+
+	It("should filter the events by task ID", func() {
+		next := connect("?task_id=2", "")
+		createTask("First")
+		createTask("Second")
+		event := next()
+		Expect(event.data).To(ContainSubstring(`"task_id":2`))
+
+		response := performRequest(http.MethodGet, tasksPath+"/events?task_id=first", nil)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(errInvalidQuery.code))
+	})
+})
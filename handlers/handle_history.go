@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// actorHeader identifies the user making a request. It is recorded in the task history.
+const actorHeader = "X-User"
+
+// bearerPrefix precedes the admin token in the Authorization header
+const bearerPrefix = "Bearer "
+
+const invalidRevision = "revision must be a positive integer"
+
+// requestContext returns the context of the request carrying its actor and the admin token it presents, if any
+func requestContext(r *http.Request) context.Context {
+	ctx := services.WithActor(r.Context(), r.Header.Get(actorHeader))
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix); ok {
+		ctx = services.WithAdminToken(ctx, token)
+	}
+	return ctx
+}
+
+// handleTaskHistory serves GET /tasks/{id}/history
+func handleTaskHistory(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	revisions, err := services.TaskHistory(r.Context(), id)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, revisions, http.StatusOK)
+}
+
+// handleTaskRevision serves GET /tasks/{id}/revisions/{n}
+func handleTaskRevision(w http.ResponseWriter, r *http.Request, id int, number string) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	n, ok := parseRevision(number)
+	if !ok {
+		sendProblem(w, errInvalidRevision, invalidRevision)
+		return
+	}
+
+	revision, err := services.TaskRevision(r.Context(), id, n)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, revision, http.StatusOK)
+}
+
+// handleTaskRevert serves POST /tasks/{id}/revert?revision=N
+func handleTaskRevert(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	n, ok := parseRevision(r.URL.Query().Get("revision"))
+	if !ok {
+		sendProblem(w, errInvalidRevision, invalidRevision)
+		return
+	}
+
+	task, err := services.RevertTask(requestContext(r), id, n, ifMatch(r)...)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// HandleUndo serves POST /undo, which reverses the most recent change made by the user of the X-User header
+func HandleUndo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	revision, err := services.Undo(requestContext(r))
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, revision, http.StatusOK)
+}
+
+func parseRevision(value string) (int, bool) {
+	n, err := strconv.Atoi(value)
+	return n, err == nil && n > 0
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+)
+
+var _ = Describe("Task History Tests", func() {
+	var path string
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		response := performRequestWithHeaders(http.MethodPost, tasksPath, models.Task{
+			Title:       "New Task",
+			Description: "Task Description",
+			Status:      "TODO",
+		}, map[string]string{actorHeader: "alice"})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		path = tasksPath + "/1"
+	})
+
+	history := func() []models.Revision {
+		response := performRequest(http.MethodGet, path+"/history", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var revisions []models.Revision
+		Expect(json.Unmarshal(response.Body.Bytes(), &revisions)).To(Succeed())
+		return revisions
+	}
+
+	It("should record the creation with its actor", func() {
+		revisions := history()
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Number).To(Equal(1))
+		Expect(revisions[0].Action).To(Equal(models.RevisionCreated))
+		Expect(revisions[0].Actor).To(Equal("alice"))
+		Expect(revisions[0].Task.Title).To(Equal("New Task"))
+	})
+
+	It("should record only the fields that changed", func() {
+		response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"description":"Updated Description"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		revisions := history()
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[1].Action).To(Equal(models.RevisionUpdated))
+		Expect(revisions[1].Changes).To(HaveLen(1))
+		Expect(revisions[1].Changes[0].Field).To(Equal(models.JsonDescription))
+		Expect(string(revisions[1].Changes[0].From)).To(Equal(`"Task Description"`))
+		Expect(string(revisions[1].Changes[0].To)).To(Equal(`"Updated Description"`))
+	})
+
+	It("should keep the history of a deleted task", func() {
+		Expect(performRequest(http.MethodDelete, path, nil).Code).To(Equal(http.StatusNoContent))
+
+		revisions := history()
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[1].Action).To(Equal(models.RevisionDeleted))
+		Expect(revisions[1].Task.Description).To(Equal("Task Description"))
+	})
+
+	It("should not record changes that were rejected", func() {
+		response := performRequestWithHeaders(http.MethodDelete, path, nil, map[string]string{"If-Match": `"7"`})
+		Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+		Expect(history()).To(HaveLen(1))
+	})
+
+	Describe("GET /tasks/{id}/revisions/{n}", func() {
+		It("should return a single revision", func() {
+			_, err := services.UpdateTask(context.Background(), 1, models.Task{Title: "Updated Task", Description: "Task Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+
+			response := performRequest(http.MethodGet, path+"/revisions/2", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var revision models.Revision
+			Expect(json.Unmarshal(response.Body.Bytes(), &revision)).To(Succeed())
+			Expect(revision.Number).To(Equal(2))
+			Expect(revision.Task.Title).To(Equal("Updated Task"))
+		})
+
+		It("should fail when the revision does not exist", func() {
+			response := performRequest(http.MethodGet, path+"/revisions/"+strconv.Itoa(5), nil)
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal(errRevisionNotFound.code))
+		})
+
+		It("should fail when the revision is not a number", func() {
+			response := performRequest(http.MethodGet, path+"/revisions/latest", nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	It("should fail for a task that never existed", func() {
+		response := performRequest(http.MethodGet, tasksPath+"/42/history", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("POST /tasks/{id}/revert", func() {
+		BeforeEach(func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"title":"Renamed Task","status":"Completed"}`))
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should restore the fields of the revision as a new revision", func() {
+			response := performRequest(http.MethodPost, path+"/revert?revision=1", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(Equal(`"3-0"`))
+
+			var task models.Task
+			Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+			Expect(task.Title).To(Equal("New Task"))
+			Expect(task.Status).To(Equal("TODO"))
+			Expect(task.Version).To(Equal(3))
+
+			revisions := history()
+			Expect(revisions).To(HaveLen(3))
+			Expect(revisions[2].Action).To(Equal(models.RevisionReverted))
+			Expect(revisions[2].RevertedTo).To(Equal(1))
+		})
+
+		It("should restore a deleted task with its original ID", func() {
+			Expect(performRequest(http.MethodDelete, path, nil).Code).To(Equal(http.StatusNoContent))
+
+			response := performRequest(http.MethodPost, path+"/revert?revision=2", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			task, err := services.GetTaskByID(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Title).To(Equal("Renamed Task"))
+			Expect(task.Version).To(Equal(4))
+			Expect(history()[3].Action).To(Equal(models.RevisionRestored))
+		})
+
+		It("should fail when If-Match does not match", func() {
+			response := performRequestWithHeaders(http.MethodPost, path+"/revert?revision=1", nil, map[string]string{"If-Match": `"1"`})
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("should fail when the revision would create a dependency cycle", func() {
+			Expect(performRequest(http.MethodPost, tasksPath, models.Task{Title: "Blocker", Description: "Blocker Description", Status: "TODO"}).Code).To(Equal(http.StatusCreated))
+			Expect(performRequest(http.MethodPost, path+"/blockers", map[string]int{"blocker_id": 2}).Code).To(Equal(http.StatusOK))
+			Expect(performRequest(http.MethodDelete, path+"/blockers/2", nil).Code).To(Equal(http.StatusOK))
+			Expect(performRequest(http.MethodPost, tasksPath+"/2/blockers", map[string]int{"blocker_id": 1}).Code).To(Equal(http.StatusOK))
+
+			response := performRequest(http.MethodPost, path+"/revert?revision=3", nil)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal(errDependencyCycle.code))
+			Expect(history()).To(HaveLen(4))
+		})
+
+		It("should fail when the revision is missing or invalid", func() {
+			Expect(performRequest(http.MethodPost, path+"/revert?revision=9", nil).Code).To(Equal(http.StatusNotFound))
+			Expect(performRequest(http.MethodPost, path+"/revert", nil).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("POST /undo", func() {
+		asAlice := map[string]string{actorHeader: "alice"}
+
+		undo := func(headers map[string]string) models.Revision {
+			response := performRequestWithHeaders(http.MethodPost, undoPath, nil, headers)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var revision models.Revision
+			Expect(json.Unmarshal(response.Body.Bytes(), &revision)).To(Succeed())
+			return revision
+		}
+
+		It("should bring back a task deleted by mistake", func() {
+			Expect(performRequestWithHeaders(http.MethodDelete, path, nil, asAlice).Code).To(Equal(http.StatusNoContent))
+
+			revision := undo(asAlice)
+			Expect(revision.Action).To(Equal(models.RevisionRestored))
+			Expect(revision.Undoes).To(Equal(2))
+
+			task, err := services.GetTaskByID(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Title).To(Equal("New Task"))
+		})
+
+		It("should walk back through the changes of the user", func() {
+			response := performRequestWithHeaders(http.MethodPut, path, models.Task{Title: "Updated Task", Description: "Task Description", Status: "TODO"}, asAlice)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			Expect(undo(asAlice).Action).To(Equal(models.RevisionReverted))
+			task, err := services.GetTaskByID(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Title).To(Equal("New Task"))
+
+			// the next undo reverses the creation
+			Expect(undo(asAlice).Action).To(Equal(models.RevisionDeleted))
+			_, err = services.GetTaskByID(context.Background(), 1)
+			Expect(err).To(MatchError(services.ErrTaskNotFound))
+
+			response = performRequestWithHeaders(http.MethodPost, undoPath, nil, asAlice)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errNothingToUndo.code))
+		})
+
+		It("should only undo the changes of the requesting user", func() {
+			response := performRequestWithHeaders(http.MethodPut, path, models.Task{Title: "Bob's Task", Description: "Task Description", Status: "TODO"}, map[string]string{actorHeader: "bob"})
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			response = performRequestWithHeaders(http.MethodPost, undoPath, nil, asAlice)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errUndoConflict.code))
+
+			Expect(undo(map[string]string{actorHeader: "bob"}).Task.Title).To(Equal("New Task"))
+		})
+
+		It("should require the user to be identified", func() {
+			response := performRequest(http.MethodPost, undoPath, nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(errActorRequired.code))
+		})
+	})
+})
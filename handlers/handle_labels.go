@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	labelNameRequired = "name is required"
+	labelsRequired    = "labels is required"
+	intoRequired      = "into is required"
+	invalidLabel      = "labels must be at most 50 characters, without slashes, commas or surrounding spaces"
+	invalidColor      = "color must be a hex color such as #1d76db"
+
+	maxLabelLength = 50
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// HandleLabels serves GET and POST /labels
+func HandleLabels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		labels, err := services.ListLabels(r.Context())
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, labels, http.StatusOK)
+
+	case http.MethodPost:
+		var label models.Label
+		if err := json.NewDecoder(r.Body).Decode(&label); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		if fields := validateLabel(label); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+
+		created, err := services.CreateLabel(r.Context(), label)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, created, http.StatusCreated)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// HandleLabelByName serves /labels/{name} and POST /labels/{name}/merge
+func HandleLabelByName(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/labels/"), "/")
+	name := segments[0]
+	if len(segments) == 2 && segments[1] == "merge" {
+		handleLabelMerge(w, r, name)
+		return
+	}
+	if len(segments) > 1 {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		label, err := services.GetLabel(r.Context(), name)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, label, http.StatusOK)
+
+	case http.MethodPut:
+		var label models.Label
+		if err := json.NewDecoder(r.Body).Decode(&label); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		// The label keeps its name unless the body renames it
+		if label.Name == "" {
+			label.Name = name
+		}
+		if fields := validateLabel(label); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+
+		updated, err := services.UpdateLabel(requestContext(r), name, label)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, updated, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := services.DeleteLabel(requestContext(r), name); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleLabelMerge serves POST /labels/{name}/merge, which replaces the label with the one named by "into"
+// on every task and deletes it
+func handleLabelMerge(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	var body struct {
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return
+	}
+	if body.Into == "" {
+		sendProblem(w, errValidationFailed, validationFailed, fieldError("into", intoRequired))
+		return
+	}
+
+	label, err := services.MergeLabel(requestContext(r), name, body.Into)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, label, http.StatusOK)
+}
+
+// handleTaskLabels serves POST /tasks/{id}/labels and DELETE /tasks/{id}/labels/{name}
+func handleTaskLabels(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	var (
+		task models.Task
+		err  error
+	)
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		var body struct {
+			Labels []string `json:"labels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		if fields := validateLabels(body.Labels, true); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+		task, err = services.AddTaskLabels(requestContext(r), id, body.Labels, ifMatch(r)...)
+
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		task, err = services.RemoveTaskLabel(requestContext(r), id, segments[0], ifMatch(r)...)
+
+	case len(segments) <= 1:
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+
+	default:
+		sendProblem(w, errNotFound, "")
+		return
+	}
+
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// validateLabel returns an error for every invalid field of the label
+func validateLabel(label models.Label) []utils.FieldError {
+	var fields []utils.FieldError
+	if label.Name == "" {
+		fields = append(fields, fieldError("name", labelNameRequired))
+	} else if !isValidLabel(label.Name) {
+		fields = append(fields, fieldError("name", invalidLabel))
+	}
+	if label.Color != "" && !colorPattern.MatchString(label.Color) {
+		fields = append(fields, fieldError("color", invalidColor))
+	}
+	return fields
+}
+
+// validateLabels returns an error if a label is invalid, or if there are none while they are required
+func validateLabels(labels []string, required bool) []utils.FieldError {
+	if required && len(labels) == 0 {
+		return []utils.FieldError{fieldError(models.JsonLabels, labelsRequired)}
+	}
+	for _, label := range labels {
+		if !isValidLabel(label) {
+			return []utils.FieldError{fieldError(models.JsonLabels, invalidLabel)}
+		}
+	}
+	return nil
+}
+
+func isValidLabel(label string) bool {
+	return label != "" && len(label) <= maxLabelLength &&
+		label == strings.TrimSpace(label) && !strings.ContainsAny(label, "/,")
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+)
+
+var _ = Describe("Labels Tests", func() {
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		for _, labels := range [][]string{{"backend", "urgent"}, {"backend"}, {"frontend", "urgent"}} {
+			_, err := services.CreateTask(context.Background(), models.Task{
+				Title:       "Task",
+				Description: "Task Description",
+				Status:      "TODO",
+				Labels:      labels,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	getTask := func(path string) models.Task {
+		response := performRequest(http.MethodGet, path, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var task models.Task
+		Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+		return task
+	}
+
+	labels := func() []models.Label {
+		response := performRequest(http.MethodGet, labelsPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var labels []models.Label
+		Expect(json.Unmarshal(response.Body.Bytes(), &labels)).To(Succeed())
+		return labels
+	}
+
+	It("should filter the tasks carrying all of the labels", func() {
+		Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=backend", nil))).To(Equal([]int{1, 2}))
+		Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=backend&label=urgent", nil))).To(Equal([]int{1}))
+		Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=unknown", nil))).To(BeEmpty())
+	})
+
+	It("should leave trashed tasks out of the label filter", func() {
+		Expect(performRequest(http.MethodDelete, tasksPath+"/1", nil).Code).To(Equal(http.StatusNoContent))
+		Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=urgent", nil))).To(Equal([]int{3}))
+	})
+
+	It("should list the labels carried by tasks with their task counts", func() {
+		Expect(labels()).To(Equal([]models.Label{
+			{Name: "backend", TaskCount: 2},
+			{Name: "frontend", TaskCount: 1},
+			{Name: "urgent", TaskCount: 2},
+		}))
+	})
+
+	It("should reject invalid labels on a task", func() {
+		response := performRequest(http.MethodPost, tasksPath, models.Task{
+			Title: "Task", Description: "Task Description", Status: "TODO", Labels: []string{"a/b"},
+		})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(`"code":"invalid_label"`))
+	})
+
+	Describe("POST and DELETE /tasks/{id}/labels", func() {
+		It("should add labels to a task", func() {
+			response := performRequest(http.MethodPost, tasksPath+"/2/labels", map[string][]string{"labels": {"urgent", "backend"}})
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).ToNot(BeEmpty())
+
+			Expect(getTask(tasksPath + "/2").Labels).To(Equal([]string{"backend", "urgent"}))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=backend&label=urgent", nil))).To(Equal([]int{1, 2}))
+		})
+
+		It("should require labels", func() {
+			response := performRequest(http.MethodPost, tasksPath+"/2/labels", map[string][]string{})
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(`"code":"labels_required"`))
+		})
+
+		It("should remove a label from a task", func() {
+			Expect(performRequest(http.MethodDelete, tasksPath+"/1/labels/urgent", nil).Code).To(Equal(http.StatusOK))
+
+			Expect(getTask(tasksPath + "/1").Labels).To(Equal([]string{"backend"}))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=urgent", nil))).To(Equal([]int{3}))
+		})
+
+		It("should fail for a label the task does not carry", func() {
+			response := performRequest(http.MethodDelete, tasksPath+"/2/labels/urgent", nil)
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+			Expect(response.Body.String()).To(ContainSubstring(errLabelNotFound.code))
+		})
+	})
+
+	Describe("/labels", func() {
+		It("should create a label with a color and description", func() {
+			response := performRequest(http.MethodPost, labelsPath, models.Label{Name: "docs", Color: "#1d76db", Description: "Documentation"})
+			Expect(response.Code).To(Equal(http.StatusCreated))
+
+			var label models.Label
+			Expect(json.Unmarshal(performRequest(http.MethodGet, labelsPath+"/docs", nil).Body.Bytes(), &label)).To(Succeed())
+			Expect(label).To(Equal(models.Label{Name: "docs", Color: "#1d76db", Description: "Documentation"}))
+		})
+
+		It("should not create a label that exists", func() {
+			response := performRequest(http.MethodPost, labelsPath, models.Label{Name: "backend"})
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errLabelExists.code))
+		})
+
+		It("should reject an invalid color", func() {
+			response := performRequest(http.MethodPost, labelsPath, models.Label{Name: "docs", Color: "blue"})
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(`"code":"invalid_color"`))
+		})
+
+		It("should return 404 for an unknown label", func() {
+			Expect(performRequest(http.MethodGet, labelsPath+"/unknown", nil).Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should rename a label on every task", func() {
+			response := performRequest(http.MethodPut, labelsPath+"/backend", models.Label{Name: "server", Color: "#00ff00"})
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			Expect(getTask(tasksPath + "/1").Labels).To(Equal([]string{"server", "urgent"}))
+			Expect(getTask(tasksPath + "/1").Version).To(Equal(2))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=server", nil))).To(Equal([]int{1, 2}))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath+"?label=backend", nil))).To(BeEmpty())
+			Expect(labels()).To(ContainElement(models.Label{Name: "server", Color: "#00ff00", TaskCount: 2}))
+		})
+
+		It("should not rename a label to one that exists", func() {
+			response := performRequest(http.MethodPut, labelsPath+"/backend", models.Label{Name: "urgent"})
+			Expect(response.Code).To(Equal(http.StatusConflict))
+		})
+
+		It("should merge a label into another", func() {
+			response := performRequest(http.MethodPost, labelsPath+"/frontend/merge", map[string]string{"into": "backend"})
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var label models.Label
+			Expect(json.Unmarshal(response.Body.Bytes(), &label)).To(Succeed())
+			Expect(label.TaskCount).To(Equal(3))
+			Expect(getTask(tasksPath + "/3").Labels).To(Equal([]string{"backend", "urgent"}))
+			Expect(performRequest(http.MethodGet, labelsPath+"/frontend", nil).Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should record the rename in the history of the tasks", func() {
+			Expect(performRequest(http.MethodPost, labelsPath+"/urgent/merge", map[string]string{"into": "backend"}).Code).To(Equal(http.StatusOK))
+
+			history, err := services.TaskHistory(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history).To(HaveLen(2))
+			Expect(history[1].Changes).To(HaveLen(1))
+			Expect(history[1].Changes[0].Field).To(Equal(models.JsonLabels))
+		})
+
+		It("should delete a label from every task", func() {
+			Expect(performRequest(http.MethodDelete, labelsPath+"/urgent", nil).Code).To(Equal(http.StatusNoContent))
+
+			Expect(getTask(tasksPath + "/1").Labels).To(Equal([]string{"backend"}))
+			Expect(getTask(tasksPath + "/3").Labels).To(Equal([]string{"frontend"}))
+			Expect(performRequest(http.MethodGet, labelsPath+"/urgent", nil).Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	invalidLinkType    = "type must be one of duplicates, duplicated_by, relates_to, cloned_from, cloned_by"
+	linkTaskIDRequired = "task_id is required"
+	selfLink           = "a task cannot be linked to itself"
+	invalidResolution  = "resolution is only allowed on duplicates and duplicated_by links"
+	invalidExpand      = "expand only supports links"
+)
+
+// linkRequest is the body of POST /tasks/{id}/links
+type linkRequest struct {
+	Type   string `json:"type"`
+	TaskID int    `json:"task_id"`
+	// Resolution closes the duplicate task with this resolution
+	Resolution string `json:"resolution"`
+}
+
+// taskWithLinks is a task with its links, returned by GET /tasks/{id}?expand=links
+type taskWithLinks struct {
+	models.Task
+	Links []services.TaskLink `json:"links"`
+}
+
+// handleTaskLinks serves GET and POST /tasks/{id}/links and DELETE /tasks/{id}/links/{linkID}
+func handleTaskLinks(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		links, err := services.TaskLinks(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, links, http.StatusOK)
+
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		var body linkRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			sendProblem(w, errInvalidPayload, err.Error())
+			return
+		}
+		if fields := validateLink(id, body); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
+			return
+		}
+
+		link, err := services.LinkTasks(requestContext(r), id, body.Type, body.TaskID, body.Resolution)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, link, http.StatusCreated)
+
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		linkID, err := strconv.Atoi(segments[0])
+		if err != nil {
+			sendProblem(w, errNotFound, "")
+			return
+		}
+		if err := services.UnlinkTasks(requestContext(r), id, linkID); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(segments) <= 1:
+		sendProblem(w, errMethodNotAllowed, "")
+
+	default:
+		sendProblem(w, errNotFound, "")
+	}
+}
+
+// sendTaskWithLinks sends the task along with its links
+func sendTaskWithLinks(w http.ResponseWriter, r *http.Request, task models.Task) {
+	links, err := services.TaskLinks(r.Context(), task.ID)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, taskWithLinks{Task: task, Links: links}, http.StatusOK)
+}
+
+// parseExpand reads the comma separated expand query parameter and reports whether links were requested
+func parseExpand(query url.Values) (bool, error) {
+	links := false
+	for _, value := range query["expand"] {
+		for _, field := range strings.Split(value, ",") {
+			if field != "links" {
+				return false, errors.New(invalidExpand)
+			}
+			links = true
+		}
+	}
+	return links, nil
+}
+
+// validateLink returns an error for every invalid field of a new link of the task
+func validateLink(id int, link linkRequest) []utils.FieldError {
+	var fields []utils.FieldError
+	if !services.IsLinkType(link.Type) {
+		fields = append(fields, fieldError("type", invalidLinkType))
+	}
+	if link.TaskID < 1 {
+		fields = append(fields, fieldError("task_id", linkTaskIDRequired))
+	} else if link.TaskID == id {
+		fields = append(fields, fieldError("task_id", selfLink))
+	}
+	if link.Resolution != "" && link.Type != services.LinkDuplicates && link.Type != services.LinkDuplicatedBy {
+		fields = append(fields, fieldError(models.JsonResolution, invalidResolution))
+	}
+	return fields
+}
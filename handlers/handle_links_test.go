@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+)
+
+var _ = Describe("Links Tests", func() {
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		for i := 1; i <= 3; i++ {
+			task := models.Task{Title: "Task " + strconv.Itoa(i), Description: "Task Description", Status: "TODO"}
+			_, err := services.CreateTask(context.Background(), task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	link := func(id int, body map[string]any) services.TaskLink {
+		response := performRequest(http.MethodPost, tasksPath+"/"+strconv.Itoa(id)+"/links", body)
+		Expect(response.Code).To(Equal(http.StatusCreated))
+
+		var created services.TaskLink
+		Expect(json.Unmarshal(response.Body.Bytes(), &created)).To(Succeed())
+		return created
+	}
+
+	links := func(id int) []services.TaskLink {
+		response := performRequest(http.MethodGet, tasksPath+"/"+strconv.Itoa(id)+"/links", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var taskLinks []services.TaskLink
+		Expect(json.Unmarshal(response.Body.Bytes(), &taskLinks)).To(Succeed())
+		return taskLinks
+	}
+
+	It("should show the inverse relation on the other task", func() {
+		created := link(1, map[string]any{"type": "cloned_by", "task_id": 2})
+		Expect(created.Type).To(Equal(services.LinkClonedBy))
+		Expect(created.TaskID).To(Equal(2))
+		Expect(created.Title).To(Equal("Task 2"))
+
+		Expect(links(1)).To(Equal([]services.TaskLink{created}))
+		Expect(links(2)).To(Equal([]services.TaskLink{{ID: created.ID, Type: services.LinkClonedFrom, TaskID: 1, Title: "Task 1", Status: "TODO"}}))
+	})
+
+	It("should reject a link that already exists in either direction", func() {
+		link(1, map[string]any{"type": "relates_to", "task_id": 2})
+
+		response := performRequest(http.MethodPost, tasksPath+"/2/links", map[string]any{"type": "relates_to", "task_id": 1})
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errLinkExists.code))
+	})
+
+	It("should report every invalid field of a link", func() {
+		response := performRequest(http.MethodPost, tasksPath+"/1/links", map[string]any{"type": "blocks", "task_id": 1, "resolution": "duplicate"})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_link_type"))
+		Expect(response.Body.String()).To(ContainSubstring("self_link"))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_resolution"))
+
+		response = performRequest(http.MethodPost, tasksPath+"/1/links", map[string]any{"type": "relates_to", "task_id": 10})
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(ContainSubstring(errLinkedTaskNotFound.code))
+	})
+
+	It("should close a duplicate with a resolution", func() {
+		link(3, map[string]any{"type": "duplicated_by", "task_id": 2, "resolution": "duplicate"})
+
+		task, err := services.GetTaskByID(context.Background(), 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(task.Status).To(Equal("Completed"))
+		Expect(task.Resolution).To(Equal("duplicate"))
+
+		response := performRequest(http.MethodPut, tasksPath+"/2", models.Task{Title: "Task 2", Description: "Task Description", Status: "TODO", Resolution: "duplicate"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		task, err = services.GetTaskByID(context.Background(), 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(task.Resolution).To(BeEmpty())
+	})
+
+	It("should expand the links of a task", func() {
+		created := link(1, map[string]any{"type": "duplicates", "task_id": 3})
+
+		response := performRequest(http.MethodGet, tasksPath+"/3?expand=links", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		var task taskWithLinks
+		Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+		Expect(task.ID).To(Equal(3))
+		Expect(task.Links).To(Equal([]services.TaskLink{{ID: created.ID, Type: services.LinkDuplicatedBy, TaskID: 1, Title: "Task 1", Status: "TODO"}}))
+
+		response = performRequest(http.MethodGet, tasksPath+"/3", nil)
+		Expect(response.Body.String()).ToNot(ContainSubstring(`"links"`))
+
+		response = performRequest(http.MethodGet, tasksPath+"/3?expand=comments", nil)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should hide the links of deleted tasks and remove them when the task is purged", func() {
+		created := link(1, map[string]any{"type": "relates_to", "task_id": 2})
+
+		Expect(services.DeleteTask(context.Background(), 2, services.DeleteReject)).To(Succeed())
+		Expect(links(1)).To(BeEmpty())
+		_, err := services.RestoreTask(context.Background(), 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(links(1)).To(HaveLen(1))
+
+		services.SetAdminToken(adminToken)
+		DeferCleanup(services.SetAdminToken, "")
+		response := performRequestWithHeaders(http.MethodDelete, tasksPath+"/2?hard=true", nil, asAdminHeaders)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(models.DB.GetLink(context.Background(), created.ID)).Error().To(MatchError(models.ErrLinkNotFound))
+	})
+
+	It("should remove a link from either end", func() {
+		created := link(1, map[string]any{"type": "relates_to", "task_id": 2})
+
+		response := performRequest(http.MethodDelete, tasksPath+"/3/links/"+strconv.Itoa(created.ID), nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errLinkNotFound.code))
+
+		response = performRequest(http.MethodDelete, tasksPath+"/2/links/"+strconv.Itoa(created.ID), nil)
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(links(1)).To(BeEmpty())
+	})
+})
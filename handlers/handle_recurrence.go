@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	rruleRequired           = "recurrence.rrule is required"
+	invalidRRule            = "invalid recurrence rule"
+	invalidTimezone         = "recurrence.timezone must be an IANA time zone such as Europe/Paris"
+	recurrenceStartRequired = "recurrence.start or due_at is required"
+	invalidAfter            = "after must be an RFC 3339 time"
+)
+
+var invalidOccurrenceLimit = fmt.Sprintf("limit must be between 1 and %d", services.MaxOccurrences)
+
+// handleTaskOccurrences serves GET /tasks/{id}/occurrences?limit=N&after=T, the upcoming due dates of a recurring task
+func handleTaskOccurrences(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	limit := services.DefaultOccurrences
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxOccurrences {
+			sendProblem(w, errInvalidQuery, invalidOccurrenceLimit)
+			return
+		}
+		limit = n
+	}
+	after, err := parseTimeParam(query, "after", invalidAfter)
+	if err != nil {
+		sendProblem(w, errInvalidQuery, err.Error())
+		return
+	}
+
+	occurrences, err := services.Occurrences(r.Context(), id, after, limit)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, occurrences, http.StatusOK)
+}
+
+// validateRecurrence returns an error for every invalid field of the recurrence of a task
+func validateRecurrence(task models.Task) []utils.FieldError {
+	recurrence := task.Recurrence
+	if recurrence == nil {
+		return nil
+	}
+	var fields []utils.FieldError
+	loc, err := time.LoadLocation(recurrence.Timezone)
+	if err != nil {
+		fields = append(fields, fieldError(models.JsonRecurrence+".timezone", invalidTimezone))
+		loc = time.UTC
+	}
+	if recurrence.RRule == "" {
+		fields = append(fields, fieldError(models.JsonRecurrence+".rrule", rruleRequired))
+	} else if _, err := utils.ParseRecurrenceRule(recurrence.RRule, loc); err != nil {
+		// The error starts with the invalidRRule message and tells what is wrong with the rule
+		field := fieldError(models.JsonRecurrence+".rrule", invalidRRule)
+		field.Message = err.Error()
+		fields = append(fields, field)
+	}
+	if recurrence.Start.IsZero() && task.DueAt == nil {
+		fields = append(fields, fieldError(models.JsonRecurrence+".start", recurrenceStartRequired))
+	}
+	return fields
+}
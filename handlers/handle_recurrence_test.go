@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"time"
+)
+
+var _ = Describe("Recurrence Tests", func() {
+	recurring := map[string]any{
+		"title": "Standup notes", "description": "Notes", "status": "TODO", "due_at": "2024-01-01T09:00:00+01:00",
+		"recurrence": map[string]any{"rrule": "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=5", "timezone": "Europe/Paris", "exdates": []string{"2024-01-03T08:00:00Z"}},
+	}
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+	})
+
+	decodeTask := func(body []byte) models.Task {
+		var task models.Task
+		Expect(json.Unmarshal(body, &task)).To(Succeed())
+		return task
+	}
+
+	It("should preview the upcoming occurrences of a recurring task", func() {
+		response := performRequest(http.MethodPost, tasksPath, recurring)
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		Expect(response.Body.String()).To(ContainSubstring(`"start":"2024-01-01T09:00:00+01:00"`))
+
+		response = performRequest(http.MethodGet, tasksPath+"/1/occurrences", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(MatchJSON(`["2024-01-02T09:00:00+01:00", "2024-01-04T09:00:00+01:00", "2024-01-05T09:00:00+01:00"]`))
+
+		response = performRequest(http.MethodGet, tasksPath+"/1/occurrences?limit=1&after=2024-01-04T09:00:00%2B01:00", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(MatchJSON(`["2024-01-05T09:00:00+01:00"]`))
+
+		for _, query := range []string{"limit=0", "limit=101", "after=tomorrow"} {
+			response = performRequest(http.MethodGet, tasksPath+"/1/occurrences?"+query, nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(errInvalidQuery.code))
+		}
+	})
+
+	It("should create the next occurrence when the task is completed", func() {
+		Expect(performRequest(http.MethodPost, tasksPath, recurring).Code).To(Equal(http.StatusCreated))
+
+		completed := map[string]any{}
+		for name, value := range recurring {
+			completed[name] = value
+		}
+		completed["status"] = "Completed"
+		response := performRequest(http.MethodPut, tasksPath+"/1", completed)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(*decodeTask(response.Body.Bytes()).NextOccurrenceID).To(Equal(2))
+
+		response = performRequest(http.MethodGet, tasksPath+"/2", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		next := decodeTask(response.Body.Bytes())
+		Expect(next.Status).To(Equal("TODO"))
+		Expect(next.DueAt.Equal(time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC))).To(BeTrue())
+
+		// The next occurrence cannot be changed through PATCH
+		response = performRawRequest(http.MethodPatch, tasksPath+"/1", "application/merge-patch+json", []byte(`{"next_occurrence_id": 7}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(*decodeTask(response.Body.Bytes()).NextOccurrenceID).To(Equal(2))
+	})
+
+	It("should detach the next occurrence from a parent that is done", func() {
+		parent := models.Task{Title: "Release", Description: "Release Description", Status: "Completed"}
+		Expect(performRequest(http.MethodPost, tasksPath, parent).Code).To(Equal(http.StatusCreated))
+		Expect(performRequest(http.MethodPost, tasksPath, recurring).Code).To(Equal(http.StatusCreated))
+
+		completed := map[string]any{"parent_id": 1}
+		for name, value := range recurring {
+			completed[name] = value
+		}
+		completed["status"] = "Completed"
+		response := performRequest(http.MethodPut, tasksPath+"/2", completed)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(*decodeTask(response.Body.Bytes()).ParentID).To(Equal(1))
+
+		response = performRequest(http.MethodGet, tasksPath+"/3", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		next := decodeTask(response.Body.Bytes())
+		Expect(next.Status).To(Equal("TODO"))
+		Expect(next.ParentID).To(BeNil())
+	})
+
+	It("should validate the recurrence", func() {
+		response := performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Task", "description": "Description", "status": "TODO",
+			"recurrence": map[string]any{"rrule": "FREQ=HOURLY", "timezone": "Mars/Olympus"},
+		})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		for _, code := range []string{"invalid_rrule", "invalid_timezone", "recurrence_start_required"} {
+			Expect(response.Body.String()).To(ContainSubstring(code))
+		}
+		Expect(response.Body.String()).To(ContainSubstring("unsupported FREQ HOURLY"))
+
+		response = performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Task", "description": "Description", "status": "TODO", "recurrence": map[string]any{"start": "2024-01-01T09:00:00Z"},
+		})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("rrule_required"))
+	})
+
+	It("should reject the occurrences of a task that does not recur", func() {
+		Expect(performRequest(http.MethodPost, tasksPath, map[string]any{"title": "Task", "description": "Description", "status": "TODO"}).Code).
+			To(Equal(http.StatusCreated))
+
+		response := performRequest(http.MethodGet, tasksPath+"/1/occurrences", nil)
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errTaskNotRecurring.code))
+	})
+})
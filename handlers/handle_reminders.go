@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	beforeRequired   = "before is required"
+	invalidBefore    = "before must be a duration such as 24h or 30m that is not negative"
+	invalidChannel   = "unknown channel"
+	recipientTooLong = "recipient must be at most 320 characters"
+	snoozeRequired   = "for or until is required"
+	invalidSnoozeFor = "for must be a positive duration such as 15m"
+	snoozeInPast     = "until must be in the future"
+
+	maxRecipientLength = 320
+)
+
+// reminderRequest is the body of POST /tasks/{id}/reminders and PUT /tasks/{id}/reminders/{reminderID}
+type reminderRequest struct {
+	Before    string `json:"before"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient"`
+}
+
+// snoozeRequest is the body of POST /tasks/{id}/reminders/{reminderID}/snooze.
+// For snoozes the reminder relative to now, Until to a fixed time.
+type snoozeRequest struct {
+	For   string     `json:"for"`
+	Until *time.Time `json:"until"`
+}
+
+// handleTaskReminders serves GET and POST /tasks/{id}/reminders, GET, PUT and DELETE
+// /tasks/{id}/reminders/{reminderID} and POST /tasks/{id}/reminders/{reminderID}/snooze
+func handleTaskReminders(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if len(segments) > 2 || len(segments) == 2 && segments[1] != "snooze" {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) > 0 {
+		reminderID, err := strconv.Atoi(segments[0])
+		if err != nil {
+			sendProblem(w, errNotFound, "")
+			return
+		}
+		if len(segments) == 2 {
+			handleReminderSnooze(w, r, id, reminderID)
+		} else {
+			handleTaskReminder(w, r, id, reminderID)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reminders, err := services.ListReminders(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, reminders, http.StatusOK)
+
+	case http.MethodPost:
+		reminder, ok := decodeReminder(w, r)
+		if !ok {
+			return
+		}
+		created, err := services.AddReminder(requestContext(r), id, reminder)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, created, http.StatusCreated)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleTaskReminder serves GET, PUT and DELETE /tasks/{id}/reminders/{reminderID}
+func handleTaskReminder(w http.ResponseWriter, r *http.Request, id, reminderID int) {
+	switch r.Method {
+	case http.MethodGet:
+		reminder, err := services.GetReminder(r.Context(), id, reminderID)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, reminder, http.StatusOK)
+
+	case http.MethodPut:
+		reminder, ok := decodeReminder(w, r)
+		if !ok {
+			return
+		}
+		updated, err := services.UpdateReminder(requestContext(r), id, reminderID, reminder)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, updated, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := services.DeleteReminder(requestContext(r), id, reminderID); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleReminderSnooze serves POST /tasks/{id}/reminders/{reminderID}/snooze
+func handleReminderSnooze(w http.ResponseWriter, r *http.Request, id, reminderID int) {
+	if r.Method != http.MethodPost {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	var body snoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return
+	}
+
+	now := time.Now()
+	var until time.Time
+	switch {
+	case body.For != "":
+		duration, err := time.ParseDuration(body.For)
+		if err != nil || duration <= 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fieldError("for", invalidSnoozeFor))
+			return
+		}
+		until = now.Add(duration)
+	case body.Until != nil:
+		if !body.Until.After(now) {
+			sendProblem(w, errValidationFailed, validationFailed, fieldError("until", snoozeInPast))
+			return
+		}
+		until = *body.Until
+	default:
+		sendProblem(w, errValidationFailed, validationFailed, fieldError("for", snoozeRequired))
+		return
+	}
+
+	reminder, err := services.SnoozeReminder(requestContext(r), id, reminderID, until)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, reminder, http.StatusOK)
+}
+
+// decodeReminder reads and validates a reminder from the request body. The channel defaults to the log.
+// It sends the error response and returns false when the reminder is invalid.
+func decodeReminder(w http.ResponseWriter, r *http.Request) (models.Reminder, bool) {
+	var body reminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return models.Reminder{}, false
+	}
+	reminder := models.Reminder{Channel: body.Channel, Recipient: strings.TrimSpace(body.Recipient)}
+	if reminder.Channel == "" {
+		reminder.Channel = services.LogChannel
+	}
+
+	var fields []utils.FieldError
+	if body.Before == "" {
+		fields = append(fields, fieldError("before", beforeRequired))
+	} else if before, err := time.ParseDuration(body.Before); err != nil || before < 0 {
+		fields = append(fields, fieldError("before", invalidBefore))
+	} else {
+		reminder.Before = models.Duration(before)
+	}
+	if channels := services.Channels(); !isChannel(channels, reminder.Channel) {
+		field := fieldError("channel", invalidChannel)
+		field.Message += ". Valid channels are: " + strings.Join(channels, ", ")
+		fields = append(fields, field)
+	}
+	if len(reminder.Recipient) > maxRecipientLength {
+		fields = append(fields, fieldError("recipient", recipientTooLong))
+	}
+	if len(fields) > 0 {
+		sendProblem(w, errValidationFailed, validationFailed, fields...)
+		return models.Reminder{}, false
+	}
+	return reminder, true
+}
+
+// isChannel reports whether channel is one of the configured reminder channels
+func isChannel(channels []string, channel string) bool {
+	for _, name := range channels {
+		if name == channel {
+			return true
+		}
+	}
+	return false
+}
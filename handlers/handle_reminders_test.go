@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"time"
+)
+
+var _ = Describe("Reminder Tests", func() {
+	remindersPath := tasksPath + "/1/reminders"
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+		response := performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Task", "description": "Description", "status": "TODO", "due_at": "2099-01-02T09:00:00Z",
+		})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+	})
+
+	decodeReminder := func(body []byte) models.Reminder {
+		var reminder models.Reminder
+		Expect(json.Unmarshal(body, &reminder)).To(Succeed())
+		return reminder
+	}
+
+	It("should add, list, update and delete the reminders of a task", func() {
+		response := performRequest(http.MethodPost, remindersPath, map[string]any{"before": "24h"})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		Expect(response.Body.String()).To(ContainSubstring(`"before":"24h0m0s"`))
+		reminder := decodeReminder(response.Body.Bytes())
+		Expect(reminder.Channel).To(Equal("log"))
+		Expect(reminder.FireAt.Equal(time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC))).To(BeTrue())
+
+		response = performRequest(http.MethodPost, remindersPath, map[string]any{"before": "1h", "recipient": "alice"})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+
+		response = performRequest(http.MethodGet, remindersPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		var reminders []models.Reminder
+		Expect(json.Unmarshal(response.Body.Bytes(), &reminders)).To(Succeed())
+		Expect(reminders).To(HaveLen(2))
+		Expect(reminders[1].Recipient).To(Equal("alice"))
+
+		response = performRequest(http.MethodPut, remindersPath+"/1", map[string]any{"before": "30m"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeReminder(response.Body.Bytes()).Before).To(Equal(models.Duration(30 * time.Minute)))
+
+		Expect(performRequest(http.MethodDelete, remindersPath+"/1", nil).Code).To(Equal(http.StatusNoContent))
+		response = performRequest(http.MethodGet, remindersPath+"/1", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errReminderNotFound.code))
+	})
+
+	It("should snooze a reminder", func() {
+		Expect(performRequest(http.MethodPost, remindersPath, map[string]any{"before": "1h"}).Code).To(Equal(http.StatusCreated))
+
+		before := time.Now()
+		response := performRequest(http.MethodPost, remindersPath+"/1/snooze", map[string]any{"for": "15m"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		reminder := decodeReminder(response.Body.Bytes())
+		Expect(*reminder.SnoozedUntil).To(BeTemporally("~", before.Add(15*time.Minute), time.Minute))
+		Expect(reminder.FireAt.Equal(*reminder.SnoozedUntil)).To(BeTrue())
+
+		response = performRequest(http.MethodPost, remindersPath+"/1/snooze", map[string]any{"until": "2098-06-01T10:00:00Z"})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeReminder(response.Body.Bytes()).SnoozedUntil.Equal(time.Date(2098, 6, 1, 10, 0, 0, 0, time.UTC))).To(BeTrue())
+
+		for body, code := range map[string]string{
+			`{}`:                                "snooze_required",
+			`{"for": "-5m"}`:                    "invalid_snooze",
+			`{"until": "2000-01-01T00:00:00Z"}`: "invalid_snooze",
+		} {
+			response = performRawRequest(http.MethodPost, remindersPath+"/1/snooze", "application/json", []byte(body))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(code))
+		}
+	})
+
+	It("should validate reminders", func() {
+		response := performRequest(http.MethodPost, remindersPath, map[string]any{"channel": "pager"})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("before_required"))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_channel"))
+		Expect(response.Body.String()).To(ContainSubstring("Valid channels are: log"))
+
+		response = performRequest(http.MethodPost, remindersPath, map[string]any{"before": "tomorrow"})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_before"))
+
+		Expect(performRequest(http.MethodPost, tasksPath+"/9/reminders", map[string]any{"before": "1h"}).Code).To(Equal(http.StatusNotFound))
+		Expect(performRequest(http.MethodPost, remindersPath+"/1/dismiss", nil).Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+var invalidDepth = fmt.Sprintf("depth must be between 1 and %d", services.MaxTreeDepth)
+
+// handleTaskChildren serves GET /tasks/{id}/children?depth=N
+func handleTaskChildren(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	depth := 1
+	if value := r.URL.Query().Get("depth"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxTreeDepth {
+			sendProblem(w, errInvalidQuery, invalidDepth)
+			return
+		}
+		depth = n
+	}
+
+	children, err := services.Children(r.Context(), id, depth)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, children, http.StatusOK)
+}
+
+// handleTaskProgress serves GET /tasks/{id}/progress
+func handleTaskProgress(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	progress, err := services.TaskProgress(r.Context(), id)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, progress, http.StatusOK)
+}
+
+// parseDeleteMode reads the children query parameter of DELETE /tasks/{id}
+func parseDeleteMode(query url.Values) (services.DeleteMode, error) {
+	switch mode := services.DeleteMode(query.Get("children")); mode {
+	case "":
+		return services.DeleteReject, nil
+	case services.DeleteReject, services.DeleteCascade, services.DeleteOrphan:
+		return mode, nil
+	default:
+		return "", errors.New(invalidDeleteMode)
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+)
+
+var _ = Describe("Subtasks Tests", func() {
+	// The tree is 1 -> (2 -> (4, 5), 3)
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		parents := []int{0, 1, 1, 2, 2}
+		for i, parent := range parents {
+			task := models.Task{Title: "Task " + strconv.Itoa(i+1), Description: "Task Description", Status: "TODO"}
+			if parent != 0 {
+				task.ParentID = &parent
+			}
+			_, err := services.CreateTask(context.Background(), task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		_, err := services.PatchTask(context.Background(), 4, func(task *models.Task) error {
+			task.Status = "Completed"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	children := func(path string) []services.TaskNode {
+		response := performRequest(http.MethodGet, path, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var nodes []services.TaskNode
+		Expect(json.Unmarshal(response.Body.Bytes(), &nodes)).To(Succeed())
+		return nodes
+	}
+
+	It("should return the direct children of a task", func() {
+		nodes := children(tasksPath + "/1/children")
+		Expect(nodes).To(HaveLen(2))
+		Expect(nodes[0].ID).To(Equal(2))
+		Expect(*nodes[0].ParentID).To(Equal(1))
+		Expect(nodes[0].Progress).To(Equal(&services.Progress{Completed: 1, Total: 2, Percent: 50}))
+		Expect(nodes[0].Children).To(BeEmpty())
+		Expect(nodes[1].ID).To(Equal(3))
+		Expect(nodes[1].Progress).To(BeNil())
+	})
+
+	It("should nest the children up to the requested depth", func() {
+		nodes := children(tasksPath + "/1/children?depth=2")
+		Expect(nodes[0].Children).To(HaveLen(2))
+		Expect(nodes[0].Children[0].ID).To(Equal(4))
+		Expect(nodes[0].Children[1].ID).To(Equal(5))
+	})
+
+	It("should reject an invalid depth", func() {
+		response := performRequest(http.MethodGet, tasksPath+"/1/children?depth=0", nil)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(errInvalidQuery.code))
+	})
+
+	It("should roll up the progress of all descendants", func() {
+		response := performRequest(http.MethodGet, tasksPath+"/1/progress", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var progress services.Progress
+		Expect(json.Unmarshal(response.Body.Bytes(), &progress)).To(Succeed())
+		Expect(progress).To(Equal(services.Progress{Completed: 1, Total: 4, Percent: 25}))
+	})
+
+	It("should not complete a task while its subtasks are open", func() {
+		response := performRawRequest(http.MethodPatch, tasksPath+"/2", utils.MergePatchContentType, []byte(`{"status":"Completed"}`))
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errOpenSubtasks.code))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/5", utils.MergePatchContentType, []byte(`{"status":"Completed"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		response = performRawRequest(http.MethodPatch, tasksPath+"/2", utils.MergePatchContentType, []byte(`{"status":"Completed"}`))
+		Expect(response.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should reject a parent that does not exist or creates a cycle", func() {
+		response := performRawRequest(http.MethodPatch, tasksPath+"/3", utils.MergePatchContentType, []byte(`{"parent_id":10}`))
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(ContainSubstring(errParentNotFound.code))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/1", utils.MergePatchContentType, []byte(`{"parent_id":4}`))
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errParentCycle.code))
+	})
+
+	It("should not add or reopen a subtask under a done parent", func() {
+		patch := func(id int, body string) int {
+			return performRawRequest(http.MethodPatch, tasksPath+"/"+strconv.Itoa(id), utils.MergePatchContentType, []byte(body)).Code
+		}
+		Expect(patch(5, `{"status":"Completed"}`)).To(Equal(http.StatusOK))
+		Expect(patch(2, `{"status":"Completed"}`)).To(Equal(http.StatusOK))
+
+		parent := 2
+		response := performRequest(http.MethodPost, tasksPath, models.Task{Title: "Task 6", Description: "Task Description", Status: "TODO", ParentID: &parent})
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errParentDone.code))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/4", utils.MergePatchContentType, []byte(`{"status":"TODO"}`))
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errParentDone.code))
+
+		response = performRawRequest(http.MethodPatch, tasksPath+"/3", utils.MergePatchContentType, []byte(`{"parent_id":2}`))
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errParentDone.code))
+	})
+
+	Describe("POST /tasks/{id}/revert", func() {
+		patch := func(id int, body string) {
+			response := performRawRequest(http.MethodPatch, tasksPath+"/"+strconv.Itoa(id), utils.MergePatchContentType, []byte(body))
+			Expect(response.Code).To(Equal(http.StatusOK))
+		}
+		revert := func(id, n int) *httptest.ResponseRecorder {
+			return performRequest(http.MethodPost, tasksPath+"/"+strconv.Itoa(id)+"/revert?revision="+strconv.Itoa(n), nil)
+		}
+
+		It("should not restore a parent that is in the trash", func() {
+			patch(3, `{"parent_id":5}`)
+			patch(3, `{"parent_id":1}`)
+			Expect(performRequest(http.MethodDelete, tasksPath+"/5", nil).Code).To(Equal(http.StatusNoContent))
+
+			response := revert(3, 2)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring(errParentNotFound.code))
+		})
+
+		It("should not restore a parent that creates a cycle", func() {
+			patch(3, `{"parent_id":5}`)
+			patch(3, `{"parent_id":1}`)
+			patch(2, `{"parent_id":3}`)
+
+			response := revert(3, 2)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errParentCycle.code))
+		})
+
+		It("should not complete a task while its subtasks are open", func() {
+			patch(5, `{"status":"Completed"}`)
+			patch(2, `{"status":"Completed"}`)
+			patch(2, `{"status":"TODO"}`)
+			patch(5, `{"status":"TODO"}`)
+
+			response := revert(2, 2)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errOpenSubtasks.code))
+		})
+	})
+
+	Describe("DELETE /tasks/{id}", func() {
+		It("should refuse to delete a task with subtasks by default", func() {
+			response := performRequest(http.MethodDelete, tasksPath+"/2", nil)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errHasSubtasks.code))
+			Expect(performRequest(http.MethodGet, tasksPath+"/2", nil).Code).To(Equal(http.StatusOK))
+		})
+
+		It("should delete the subtasks along with the task in cascade mode", func() {
+			Expect(performRequest(http.MethodDelete, tasksPath+"/1?children=cascade", nil).Code).To(Equal(http.StatusNoContent))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath, nil))).To(BeEmpty())
+		})
+
+		It("should leave the whole tree untouched when the task does not match If-Match in cascade mode", func() {
+			response := performRequestWithHeaders(http.MethodDelete, tasksPath+"/1?children=cascade", nil, map[string]string{"If-Match": `"9"`})
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath, nil))).To(Equal([]int{1, 2, 3, 4, 5}))
+		})
+
+		It("should restore the subtasks deleted in cascade mode along with the task", func() {
+			Expect(performRequest(http.MethodDelete, tasksPath+"/5", nil).Code).To(Equal(http.StatusNoContent))
+			Expect(performRequest(http.MethodDelete, tasksPath+"/1?children=cascade", nil).Code).To(Equal(http.StatusNoContent))
+
+			Expect(performRequest(http.MethodPost, tasksPath+"/1/restore", nil).Code).To(Equal(http.StatusOK))
+			Expect(responseIDs(performRequest(http.MethodGet, tasksPath, nil))).To(Equal([]int{1, 2, 3, 4}))
+			// The subtask deleted on its own before stays in the trash
+			Expect(performRequest(http.MethodGet, tasksPath+"/5", nil).Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should turn the subtasks into top-level tasks in orphan mode", func() {
+			Expect(performRequest(http.MethodDelete, tasksPath+"/2?children=orphan", nil).Code).To(Equal(http.StatusNoContent))
+
+			var task models.Task
+			Expect(json.Unmarshal(performRequest(http.MethodGet, tasksPath+"/4", nil).Body.Bytes(), &task)).To(Succeed())
+			Expect(task.ParentID).To(BeNil())
+			Expect(children(tasksPath + "/1/children")).To(HaveLen(1))
+		})
+
+		It("should reject an unknown mode", func() {
+			response := performRequest(http.MethodDelete, tasksPath+"/2?children=all", nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
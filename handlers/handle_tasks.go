@@ -3,21 +3,44 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ofirmad/task-manager/models"
 	"github.com/ofirmad/task-manager/services"
 	"github.com/ofirmad/task-manager/utils"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
-var validStatuses = []string{"TODO", "in-progress", "Pending", "Completed"}
+const (
+	titleRequired         = "title is required"
+	descriptionRequired   = "description is required"
+	statusRequired        = "status is required"
+	invalidStatus         = "invalid status. Valid statuses are: "
+	invalidPriority       = "invalid priority. Valid priorities are: P0, P1, P2, P3"
+	negativeEffort        = "effort_hours must not be negative"
+	invalidLimit          = "limit must be a positive integer"
+	invalidHard           = "hard must be true or false"
+	invalidDeleteMode     = "children must be reject, cascade or orphan"
+	invalidParentID       = "parent_id must be a positive task ID"
+	invalidOverdue        = "overdue must be true or false"
+	invalidDueBefore      = "due_before must be an RFC 3339 time"
+	invalidDueAfter       = "due_after must be an RFC 3339 time"
+	idempotencyKeyTooLong = "Idempotency-Key must be at most 255 characters"
+	unsupportedPatchType  = "PATCH requires Content-Type application/merge-patch+json or application/json-patch+json"
+)
 
 const (
-	titleRequired       = "title is required"
-	descriptionRequired = "description is required"
-	statusRequired      = "status is required"
-	invalidStatus       = "invalid status. Valid statuses are: TODO, in-progress, Pending, Completed"
+	totalCountHeader         = "X-Total-Count"
+	nextCursorHeader         = "X-Next-Cursor"
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
 )
 
 func HandleTasks(w http.ResponseWriter, r *http.Request) {
@@ -25,40 +48,179 @@ func HandleTasks(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var task models.Task
 		if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-			utils.SendError(w, "Invalid request payload", http.StatusBadRequest)
+			sendProblem(w, errInvalidPayload, err.Error())
 			return
 		}
 
-		if validateTaskErr := validateTask(task); validateTaskErr != nil {
-			utils.SendError(w, validateTaskErr.Error(), http.StatusBadRequest)
+		if fields := append(validateTask(task), validateChecklist(task.Checklist)...); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
 			return
 		}
 
-		newTask := services.CreateTask(task)
+		var newTask models.Task
+		var err error
+		if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+			if len(key) > maxIdempotencyKeyLength {
+				sendProblem(w, errInvalidIdempotencyKey, idempotencyKeyTooLong)
+				return
+			}
+			var replayed bool
+			newTask, replayed, err = services.CreateTaskIdempotent(requestContext(r), key, task)
+			if replayed {
+				w.Header().Set(idempotentReplayedHeader, "true")
+			}
+		} else {
+			newTask, err = services.CreateTask(requestContext(r), task)
+		}
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.Header().Set("ETag", taskETag(newTask))
 		utils.SendResponse(w, newTask, http.StatusCreated)
 
 	case http.MethodGet:
-		tasks := services.GetAllTasks()
-		utils.SendResponse(w, tasks, http.StatusOK)
+		opts, err := parseListOptions(r.URL.Query())
+		if err != nil {
+			sendProblem(w, errInvalidQuery, err.Error())
+			return
+		}
+
+		page, err := services.ListTasks(r.Context(), opts)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+
+		w.Header().Set(totalCountHeader, strconv.Itoa(page.Total))
+		if page.NextCursor != "" {
+			next := *r.URL
+			query := next.Query()
+			query.Set("cursor", page.NextCursor)
+			next.RawQuery = query.Encode()
+			w.Header().Set(nextCursorHeader, page.NextCursor)
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+		}
+		utils.SendResponse(w, page.Tasks, http.StatusOK)
 
 	default:
-		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		sendProblem(w, errMethodNotAllowed, "")
 	}
 }
 
 func HandleTaskByID(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/tasks/")
-	id, err := strconv.Atoi(idStr)
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
+	// Collections under /tasks/ whose names are not task IDs
+	if len(segments) == 1 {
+		switch segments[0] {
+		case "trash":
+			handleTrash(w, r)
+			return
+		case "ready":
+			handleReadyTasks(w, r)
+			return
+		case "order":
+			handleTaskOrder(w, r)
+			return
+		case "critical-path":
+			handleCriticalPath(w, r)
+			return
+		case "events":
+			handleTaskEvents(w, r)
+			return
+		}
+	}
+
+	id, err := strconv.Atoi(segments[0])
 	if err != nil {
-		utils.SendError(w, "Invalid task ID", http.StatusBadRequest)
+		sendProblem(w, errInvalidTaskID, "")
+		return
+	}
+
+	// Sub-resources of the task: /tasks/{id}/{resource}/...
+	if len(segments) > 1 {
+		switch segments[1] {
+		case "history":
+			if len(segments) == 2 {
+				handleTaskHistory(w, r, id)
+				return
+			}
+		case "revisions":
+			if len(segments) == 3 {
+				handleTaskRevision(w, r, id, segments[2])
+				return
+			}
+		case "revert":
+			if len(segments) == 2 {
+				handleTaskRevert(w, r, id)
+				return
+			}
+		case "restore":
+			if len(segments) == 2 {
+				handleTaskRestore(w, r, id)
+				return
+			}
+		case "labels":
+			handleTaskLabels(w, r, id, segments[2:])
+			return
+		case "children":
+			if len(segments) == 2 {
+				handleTaskChildren(w, r, id)
+				return
+			}
+		case "progress":
+			if len(segments) == 2 {
+				handleTaskProgress(w, r, id)
+				return
+			}
+		case "blockers":
+			handleTaskBlockers(w, r, id, segments[2:])
+			return
+		case "links":
+			handleTaskLinks(w, r, id, segments[2:])
+			return
+		case "comments":
+			handleTaskComments(w, r, id, segments[2:])
+			return
+		case "attachments":
+			handleTaskAttachments(w, r, id, segments[2:])
+			return
+		case "checklist":
+			handleTaskChecklist(w, r, id, segments[2:])
+			return
+		case "reminders":
+			handleTaskReminders(w, r, id, segments[2:])
+			return
+		case "occurrences":
+			if len(segments) == 2 {
+				handleTaskOccurrences(w, r, id)
+				return
+			}
+		}
+		sendProblem(w, errNotFound, "")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		task, err := services.GetTaskByID(id)
+		expandLinks, err := parseExpand(r.URL.Query())
 		if err != nil {
-			utils.SendError(w, err.Error(), http.StatusNotFound)
+			sendProblem(w, errInvalidQuery, err.Error())
+			return
+		}
+		task, err := services.GetTaskByID(r.Context(), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.Header().Set("ETag", taskETag(task))
+		if expandLinks {
+			// The version of the task does not cover its links, so the response is never 304 Not Modified
+			sendTaskWithLinks(w, r, task)
+			return
+		}
+		if notModified(r, task) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 		utils.SendResponse(w, task, http.StatusOK)
@@ -66,55 +228,206 @@ func HandleTaskByID(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var updatedTask models.Task
 		if err := json.NewDecoder(r.Body).Decode(&updatedTask); err != nil {
-			utils.SendError(w, "Invalid request payload", http.StatusBadRequest)
+			sendProblem(w, errInvalidPayload, err.Error())
 			return
 		}
 
-		if validateTaskErr := validateTask(updatedTask); validateTaskErr != nil {
-			utils.SendError(w, validateTaskErr.Error(), http.StatusBadRequest)
+		if fields := validateTask(updatedTask); len(fields) > 0 {
+			sendProblem(w, errValidationFailed, validationFailed, fields...)
 			return
 		}
 
-		task, err := services.UpdateTask(id, updatedTask)
+		task, err := services.UpdateTask(requestContext(r), id, updatedTask, ifMatch(r)...)
 		if err != nil {
-			utils.SendError(w, err.Error(), http.StatusNotFound)
+			sendServiceError(w, err)
 			return
 		}
+		warnIfBlocked(w, r, task)
+		w.Header().Set("ETag", taskETag(task))
+		utils.SendResponse(w, task, http.StatusOK)
+
+	case http.MethodPatch:
+		task, err := patchTask(r, id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		warnIfBlocked(w, r, task)
+		w.Header().Set("ETag", taskETag(task))
 		utils.SendResponse(w, task, http.StatusOK)
 
 	case http.MethodDelete:
-		if err := services.DeleteTask(id); err != nil {
-			utils.SendError(w, err.Error(), http.StatusNotFound)
+		hard, err := parseHardDelete(r.URL.Query())
+		if err != nil {
+			sendProblem(w, errInvalidQuery, err.Error())
+			return
+		}
+		mode, err := parseDeleteMode(r.URL.Query())
+		if err != nil {
+			sendProblem(w, errInvalidQuery, err.Error())
+			return
+		}
+		deleteTask := services.DeleteTask
+		if hard {
+			deleteTask = services.PurgeTask
+		}
+		if err := deleteTask(requestContext(r), id, mode, ifMatch(r)...); err != nil {
+			sendServiceError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		utils.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		sendProblem(w, errMethodNotAllowed, "")
 	}
 }
 
-func validateTask(task models.Task) error {
+// patchTask applies a JSON Merge Patch or JSON Patch request body to the task,
+// validating the patched task before it is stored
+func patchTask(r *http.Request, id int) (models.Task, error) {
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case utils.MergePatchContentType:
+		applyPatch = utils.ApplyMergePatch
+	case utils.JSONPatchContentType:
+		applyPatch = utils.ApplyJSONPatch
+	default:
+		return models.Task{}, newRequestError(errUnsupportedMediaType, unsupportedPatchType)
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(patch) {
+		return models.Task{}, newRequestError(errInvalidPayload, "")
+	}
+
+	return services.PatchTask(requestContext(r), id, func(task *models.Task) error {
+		doc, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+
+		patched, err := applyPatch(doc, patch)
+		switch {
+		case errors.Is(err, utils.ErrPatchConflict):
+			return newRequestError(errPatchConflict, err.Error())
+		case err != nil:
+			return newRequestError(errInvalidPayload, err.Error())
+		}
+
+		var merged models.Task
+		if err := json.Unmarshal(patched, &merged); err != nil {
+			return newRequestError(errInvalidPayload, err.Error())
+		}
+		if fields := validateTask(merged); len(fields) > 0 {
+			return newValidationError(fields)
+		}
+		*task = merged
+		return nil
+	}, ifMatch(r)...)
+}
+
+// parseListOptions reads the GET /tasks query parameters:
+// status and label (repeatable), q, overdue, due_before, due_after, sort, limit and cursor
+func parseListOptions(query url.Values) (services.ListOptions, error) {
+	opts := services.ListOptions{
+		Statuses: query["status"],
+		Labels:   query["label"],
+		Query:    query.Get("q"),
+		Sort:     query.Get("sort"),
+		Cursor:   query.Get("cursor"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return services.ListOptions{}, errors.New(invalidLimit)
+		}
+		opts.Limit = n
+	}
+	if overdue := query.Get("overdue"); overdue != "" {
+		value, err := strconv.ParseBool(overdue)
+		if err != nil {
+			return services.ListOptions{}, errors.New(invalidOverdue)
+		}
+		opts.Overdue = &value
+	}
+	var err error
+	if opts.DueBefore, err = parseTimeParam(query, "due_before", invalidDueBefore); err != nil {
+		return services.ListOptions{}, err
+	}
+	if opts.DueAfter, err = parseTimeParam(query, "due_after", invalidDueAfter); err != nil {
+		return services.ListOptions{}, err
+	}
+	return opts, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(query url.Values, name, invalid string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(invalid)
+	}
+	return &t, nil
+}
+
+// validateTask returns an error for every invalid field of the task
+func validateTask(task models.Task) []utils.FieldError {
+	var fields []utils.FieldError
 	if task.Title == "" {
-		return errors.New(titleRequired)
+		fields = append(fields, fieldError(models.JsonTitle, titleRequired))
 	}
 	if task.Description == "" {
-		return errors.New(descriptionRequired)
+		fields = append(fields, fieldError(models.JsonDescription, descriptionRequired))
 	}
 	if task.Status == "" {
-		return errors.New(statusRequired)
+		fields = append(fields, fieldError(models.JsonStatus, statusRequired))
+	} else if workflow := services.Workflow(); !isKnownStatus(workflow, task.Status) {
+		field := fieldError(models.JsonStatus, invalidStatus)
+		field.Message = invalidStatusMessage(workflow)
+		field.Detail = workflowDetail(workflow)
+		fields = append(fields, field)
 	}
-	if !comtains(validStatuses, task.Status) {
-		return errors.New(invalidStatus)
+	if task.Priority != "" && models.PriorityRank(task.Priority) == len(models.Priorities) {
+		fields = append(fields, fieldError(models.JsonPriority, invalidPriority))
 	}
-	return nil
+	if task.EffortHours < 0 {
+		fields = append(fields, fieldError(models.JsonEffortHours, negativeEffort))
+	}
+	fields = append(fields, validateLabels(task.Labels, false)...)
+	if task.ParentID != nil && *task.ParentID < 1 {
+		fields = append(fields, fieldError(models.JsonParentID, invalidParentID))
+	}
+	for _, blockerID := range task.BlockedBy {
+		if blockerID < 1 {
+			fields = append(fields, fieldError(models.JsonBlockedBy, invalidBlockedBy))
+			break
+		}
+	}
+	fields = append(fields, validateRecurrence(task)...)
+	return fields
+}
+
+// invalidStatusMessage names the statuses of the configured workflow after invalidStatus
+func invalidStatusMessage(workflow models.Workflow) string {
+	return invalidStatus + strings.Join(workflow.StatusNames(), ", ")
 }
 
-func comtains(statuses []string, status string) bool {
-	for _, s := range statuses {
-		if s == status {
-			return true
+// workflowDetail lists the transitions of the configured workflow
+func workflowDetail(workflow models.Workflow) string {
+	transitions := make([]string, 0, len(workflow.Statuses))
+	for _, status := range workflow.StatusNames() {
+		if targets := workflow.Transitions[status]; len(targets) > 0 {
+			transitions = append(transitions, status+" -> "+strings.Join(targets, ", "))
 		}
 	}
-	return false
+	return "Allowed transitions: " + strings.Join(transitions, "; ")
+}
+
+func isKnownStatus(workflow models.Workflow, status string) bool {
+	_, ok := workflow.Status(status)
+	return ok
 }
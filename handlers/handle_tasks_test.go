@@ -2,16 +2,21 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/ofirmad/task-manager/models"
 	"github.com/ofirmad/task-manager/services"
 	"github.com/ofirmad/task-manager/testutils"
+	"github.com/ofirmad/task-manager/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRequester(t *testing.T) {
@@ -22,9 +27,18 @@ func TestRequester(t *testing.T) {
 }
 
 const (
-	tasksPath = "/tasks"
+	tasksPath    = "/tasks"
+	workflowPath = "/workflow"
+	undoPath     = "/undo"
+	labelsPath   = "/labels"
+	webhooksPath = "/webhooks"
+	// adminToken is the admin token set by the tests of administrator requests
+	adminToken = "s3cret-admin-token"
 )
 
+// asAdminHeaders are the headers of a request presenting adminToken
+var asAdminHeaders = map[string]string{"Authorization": bearerPrefix + adminToken}
+
 var _ = Describe("Handle Tasks Tests", func() {
 	var task models.Task
 
@@ -39,8 +53,7 @@ var _ = Describe("Handle Tasks Tests", func() {
 	Describe("GET /tasks", func() {
 		BeforeEach(func() {
 			// Reset the in-memory database before each test
-			models.DB.Tasks = make(map[int]*models.Task)
-			models.DB.NextID = 1
+			models.DB.Restore(models.Snapshot{})
 		})
 
 		It("should successfully return empty tasks list when there are no tasks", func() {
@@ -54,7 +67,7 @@ var _ = Describe("Handle Tasks Tests", func() {
 		})
 
 		It("should successfully a task when there is one task", func() {
-			services.CreateTask(task)
+			_, _ = services.CreateTask(context.Background(), task)
 
 			response := performRequest(http.MethodGet, tasksPath, nil)
 			Expect(response.Code).To(Equal(http.StatusOK))
@@ -71,14 +84,14 @@ var _ = Describe("Handle Tasks Tests", func() {
 				Description: task.Description + " 1",
 				Status:      task.Status,
 			}
-			services.CreateTask(task1)
+			_, _ = services.CreateTask(context.Background(), task1)
 
 			task2 := models.Task{
 				Title:       task.Title + " 2",
 				Description: task.Description + " 2",
 				Status:      "Completed",
 			}
-			services.CreateTask(task2)
+			_, _ = services.CreateTask(context.Background(), task2)
 
 			response := performRequest(http.MethodGet, tasksPath, nil)
 			Expect(response.Code).To(Equal(http.StatusOK))
@@ -89,6 +102,150 @@ var _ = Describe("Handle Tasks Tests", func() {
 			testutils.ValidateResponse(task1, responseBody[0])
 			testutils.ValidateResponse(task2, responseBody[1])
 		})
+
+		Describe("filtering, sorting and pagination", func() {
+			BeforeEach(func() {
+				for i, status := range []string{"TODO", "Completed", "Pending", "Completed", "TODO"} {
+					_, _ = services.CreateTask(context.Background(), models.Task{
+						Title:       "Task " + strconv.Itoa(i+1),
+						Description: "Description " + strconv.Itoa(i+1),
+						Status:      status,
+					})
+				}
+			})
+
+			It("should filter by status and report the total count", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?status=Completed&status=Pending", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Header().Get(totalCountHeader)).To(Equal("3"))
+				Expect(responseIDs(response)).To(Equal([]int{2, 3, 4}))
+			})
+
+			It("should filter by text in the title or description", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?q=description+4", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{4}))
+			})
+
+			It("should sort by multiple fields in either direction", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?sort=status,-title", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{4, 2, 3, 5, 1}))
+			})
+
+			It("should return stable pages while tasks are created and deleted", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?limit=2", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{1, 2}))
+				Expect(response.Header().Get(totalCountHeader)).To(Equal("5"))
+				cursor := response.Header().Get(nextCursorHeader)
+				Expect(cursor).NotTo(BeEmpty())
+				Expect(response.Header().Get("Link")).To(ContainSubstring(`rel="next"`))
+
+				Expect(services.DeleteTask(context.Background(), 1, services.DeleteReject)).To(Succeed())
+				Expect(services.DeleteTask(context.Background(), 3, services.DeleteReject)).To(Succeed())
+				_, _ = services.CreateTask(context.Background(), task)
+
+				response = performRequest(http.MethodGet, tasksPath+"?limit=2&cursor="+cursor, nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{4, 5}))
+				cursor = response.Header().Get(nextCursorHeader)
+
+				response = performRequest(http.MethodGet, tasksPath+"?limit=2&cursor="+cursor, nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{6}))
+				Expect(response.Header().Get(nextCursorHeader)).To(BeEmpty())
+			})
+
+			It("should fail with invalid query parameters", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?sort=unknown", nil)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+				response = performRequest(http.MethodGet, tasksPath+"?limit=0", nil)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(response.Body.String()).To(ContainSubstring(invalidLimit))
+
+				response = performRequest(http.MethodGet, tasksPath+"?cursor=not-a-cursor", nil)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should reject a cursor issued for a different sort order", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?limit=2&sort=title", nil)
+				cursor := response.Header().Get(nextCursorHeader)
+
+				response = performRequest(http.MethodGet, tasksPath+"?limit=2&sort=-title&cursor="+cursor, nil)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Describe("due dates and priorities", func() {
+			BeforeEach(func() {
+				now := time.Now()
+				yesterday, tomorrow, nextWeek := now.Add(-24*time.Hour), now.Add(24*time.Hour), now.Add(7*24*time.Hour)
+				for _, t := range []models.Task{
+					{Status: "TODO", DueAt: &yesterday, Priority: "P2"},
+					{Status: "Completed", DueAt: &yesterday, Priority: "P0"},
+					{Status: "TODO", DueAt: &nextWeek, Priority: "P0"},
+					{Status: "TODO", Priority: "P1"},
+					{Status: "Pending", DueAt: &tomorrow, Priority: "P0"},
+				} {
+					t.Title, t.Description = "Task", "Description"
+					_, err := services.CreateTask(context.Background(), t)
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+
+			It("should flag and filter overdue tasks that are not done", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?overdue=true", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{1}))
+
+				var tasks []models.Task
+				Expect(json.Unmarshal(response.Body.Bytes(), &tasks)).To(Succeed())
+				Expect(tasks[0].Overdue).To(BeTrue())
+			})
+
+			It("should filter by due date", func() {
+				dueAfter := url.QueryEscape(time.Now().Format(time.RFC3339))
+				dueBefore := url.QueryEscape(time.Now().Add(72 * time.Hour).Format(time.RFC3339))
+				response := performRequest(http.MethodGet, tasksPath+"?due_after="+dueAfter+"&due_before="+dueBefore, nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{5}))
+			})
+
+			It("should sort by priority then due date", func() {
+				response := performRequest(http.MethodGet, tasksPath+"?sort=priority,due_at", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(responseIDs(response)).To(Equal([]int{2, 5, 3, 4, 1}))
+			})
+
+			It("should paginate when sorting by due date", func() {
+				var ids []int
+				path := tasksPath + "?sort=due_at&limit=2"
+				for path != "" {
+					response := performRequest(http.MethodGet, path, nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					ids = append(ids, responseIDs(response)...)
+					path = response.Header().Get("Link")
+					if path != "" {
+						path = path[1:strings.Index(path, ">")]
+					}
+				}
+				Expect(ids).To(Equal([]int{1, 2, 5, 3, 4}))
+			})
+
+			It("should keep the time zone of the due date", func() {
+				response := performRawRequest(http.MethodPost, tasksPath, "application/json",
+					[]byte(`{"title":"Task","description":"Description","status":"TODO","due_at":"2030-01-02T09:00:00+02:00"}`))
+				Expect(response.Code).To(Equal(http.StatusCreated))
+				Expect(response.Body.String()).To(ContainSubstring(`"due_at":"2030-01-02T09:00:00+02:00"`))
+			})
+
+			It("should fail with invalid filters", func() {
+				Expect(performRequest(http.MethodGet, tasksPath+"?overdue=soon", nil).Code).To(Equal(http.StatusBadRequest))
+				Expect(performRequest(http.MethodGet, tasksPath+"?due_before=tomorrow", nil).Code).To(Equal(http.StatusBadRequest))
+			})
+		})
 	})
 
 	Describe("POST /tasks", func() {
@@ -102,6 +259,90 @@ var _ = Describe("Handle Tasks Tests", func() {
 			testutils.ValidateResponse(task, responseBody)
 		})
 
+		Describe("with an Idempotency-Key", func() {
+			BeforeEach(func() {
+				models.DB.Restore(models.Snapshot{})
+			})
+
+			It("should replay the original response when the request is retried", func() {
+				key := map[string]string{idempotencyKeyHeader: "create-task-retry"}
+				first := performRequestWithHeaders(http.MethodPost, tasksPath, task, key)
+				Expect(first.Code).To(Equal(http.StatusCreated))
+
+				retry := performRequestWithHeaders(http.MethodPost, tasksPath, task, key)
+				Expect(retry.Code).To(Equal(http.StatusCreated))
+				Expect(retry.Header().Get(idempotentReplayedHeader)).To(Equal("true"))
+				Expect(retry.Body.String()).To(MatchJSON(first.Body.String()))
+
+				tasks, err := services.GetAllTasks(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+			})
+
+			It("should fail with 422 when the key is reused with a different body", func() {
+				key := map[string]string{idempotencyKeyHeader: "create-task-reused"}
+				Expect(performRequestWithHeaders(http.MethodPost, tasksPath, task, key).Code).To(Equal(http.StatusCreated))
+
+				other := task
+				other.Title = "Other Task"
+				response := performRequestWithHeaders(http.MethodPost, tasksPath, other, key)
+				Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(response.Body.String()).To(ContainSubstring(services.ErrIdempotencyKeyReused.Error()))
+			})
+
+			It("should create separate tasks for different keys", func() {
+				Expect(performRequestWithHeaders(http.MethodPost, tasksPath, task, map[string]string{idempotencyKeyHeader: "a"}).Code).To(Equal(http.StatusCreated))
+				Expect(performRequestWithHeaders(http.MethodPost, tasksPath, task, map[string]string{idempotencyKeyHeader: "b"}).Code).To(Equal(http.StatusCreated))
+
+				tasks, err := services.GetAllTasks(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(2))
+			})
+		})
+
+		It("should report every invalid field as problem details", func() {
+			response := performRequest(http.MethodPost, tasksPath, models.Task{Status: "Invalid"})
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Header().Get("Content-Type")).To(Equal(utils.ProblemContentType))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Type).To(Equal(problemTypePrefix + errValidationFailed.code))
+			Expect(problem.Code).To(Equal(errValidationFailed.code))
+			Expect(problem.Status).To(Equal(http.StatusBadRequest))
+			Expect(problem.Errors).To(ConsistOf(
+				utils.FieldError{Field: models.JsonTitle, Code: "title_required", Message: titleRequired},
+				utils.FieldError{Field: models.JsonDescription, Code: "description_required", Message: descriptionRequired},
+				utils.FieldError{
+					Field: models.JsonStatus, Code: "invalid_status", Message: invalidStatusMessage(models.DefaultWorkflow()),
+					Detail: workflowDetail(models.DefaultWorkflow()),
+				},
+			))
+		})
+
+		It("should validate the priority and effort", func() {
+			task.Priority = "urgent"
+			task.EffortHours = -1
+			response := performRequest(http.MethodPost, tasksPath, task)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Errors).To(ConsistOf(
+				utils.FieldError{Field: models.JsonPriority, Code: "invalid_priority", Message: invalidPriority},
+				utils.FieldError{Field: models.JsonEffortHours, Code: "invalid_effort", Message: negativeEffort},
+			))
+		})
+
+		It("should fail with a problem when the payload is not JSON", func() {
+			response := performRawRequest(http.MethodPost, tasksPath, "application/json", []byte(`{"title":`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal(errInvalidPayload.code))
+		})
+
 		It("should fail to create a new task with invalid request payload - title is missing", func() {
 			task := models.Task{
 				Description: "Task Description",
@@ -145,6 +386,8 @@ var _ = Describe("Handle Tasks Tests", func() {
 			response := performRequest(http.MethodPost, tasksPath, task)
 			Expect(response.Code).To(Equal(http.StatusBadRequest))
 			Expect(response.Body.String()).To(ContainSubstring(invalidStatus))
+			Expect(response.Body.String()).To(ContainSubstring(`"message":"invalid status. Valid statuses are: TODO, in-progress, Pending, Completed"`))
+			Expect(response.Body.String()).To(ContainSubstring(`"detail":"Allowed transitions: TODO -\u003e in-progress`))
 		})
 	})
 })
@@ -154,8 +397,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 
 	BeforeEach(func() {
 		// Reset the in-memory database before each test
-		models.DB.Tasks = make(map[int]*models.Task)
-		models.DB.NextID = 1
+		models.DB.Restore(models.Snapshot{})
 
 		task = models.Task{
 			Title:       "New Task",
@@ -166,7 +408,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 
 	Describe("GET /tasks/{id}", func() {
 		It("should successfully return a task by ID", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			response := performRequest(http.MethodGet, tasksPath+"/"+strconv.Itoa(newTask.ID), nil)
 			Expect(response.Code).To(Equal(http.StatusOK))
@@ -180,12 +422,16 @@ var _ = Describe("Handle Task By ID Tests", func() {
 			response := performRequest(http.MethodGet, tasksPath+"/1", nil)
 			Expect(response.Code).To(Equal(http.StatusNotFound))
 			Expect(response.Body.String()).To(ContainSubstring(services.TaskNotFound))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal(errTaskNotFound.code))
 		})
 	})
 
 	Describe("PUT /tasks/{id}", func() {
 		It("should successfully update a task by ID", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			updatedTask := models.Task{
 				Title:       "Updated Task",
@@ -206,7 +452,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 		})
 
 		It("should fail to update a task by ID dut to invalid request payload - title is missing", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			updatedTask := models.Task{
 				Description: "Updated Task Description",
@@ -229,7 +475,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 		})
 
 		It("should fail to update a task by ID dut to invalid request payload - description is missing", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			updatedTask := models.Task{
 				Title:  "Updated Task",
@@ -252,7 +498,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 		})
 
 		It("should fail to update a task by ID dut to invalid request payload - status is missing", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			updatedTask := models.Task{
 				Title:       "Updated Task",
@@ -275,7 +521,7 @@ var _ = Describe("Handle Task By ID Tests", func() {
 		})
 
 		It("should fail to update a task by ID dut to invalid request payload - invalid status", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			updatedTask := models.Task{
 				Title:       "Updated Task",
@@ -305,9 +551,193 @@ var _ = Describe("Handle Task By ID Tests", func() {
 		})
 	})
 
+	Describe("PATCH /tasks/{id}", func() {
+		var newTask models.Task
+		var path string
+
+		BeforeEach(func() {
+			newTask, _ = services.CreateTask(context.Background(), task)
+			path = tasksPath + "/" + strconv.Itoa(newTask.ID)
+		})
+
+		It("should update only the fields sent in a merge patch", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status": "Completed"}`))
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var responseBody map[string]interface{}
+			Expect(json.Unmarshal(response.Body.Bytes(), &responseBody)).To(Succeed())
+			expected := newTask
+			expected.Status = "Completed"
+			testutils.ValidateResponse(expected, responseBody)
+			testutils.ValidateIDAndCreatedAt(newTask, responseBody)
+		})
+
+		It("should not change the ID or creation time", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType,
+				[]byte(`{"id": 42, "created_at": "2000-01-01T00:00:00Z"}`))
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var responseBody map[string]interface{}
+			Expect(json.Unmarshal(response.Body.Bytes(), &responseBody)).To(Succeed())
+			testutils.ValidateIDAndCreatedAt(newTask, responseBody)
+		})
+
+		It("should validate the merged task", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"title": null}`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(titleRequired))
+
+			response = performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status": "Invalid"}`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(invalidStatus))
+
+			stored, err := services.GetTaskByID(context.Background(), newTask.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored).To(Equal(newTask))
+		})
+
+		It("should apply a JSON patch", func() {
+			patch := `[
+				{"op": "test", "path": "/status", "value": "Pending"},
+				{"op": "replace", "path": "/status", "value": "in-progress"},
+				{"op": "copy", "from": "/title", "path": "/description"}
+			]`
+			response := performRawRequest(http.MethodPatch, path, utils.JSONPatchContentType, []byte(patch))
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var responseBody map[string]interface{}
+			Expect(json.Unmarshal(response.Body.Bytes(), &responseBody)).To(Succeed())
+			Expect(responseBody[models.JsonStatus]).To(Equal("in-progress"))
+			Expect(responseBody[models.JsonDescription]).To(Equal(newTask.Title))
+		})
+
+		It("should fail with a conflict when a JSON patch test operation fails", func() {
+			patch := `[
+				{"op": "replace", "path": "/title", "value": "Changed"},
+				{"op": "test", "path": "/status", "value": "Completed"}
+			]`
+			response := performRawRequest(http.MethodPatch, path, utils.JSONPatchContentType, []byte(patch))
+			Expect(response.Code).To(Equal(http.StatusConflict))
+
+			stored, err := services.GetTaskByID(context.Background(), newTask.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored.Title).To(Equal(newTask.Title))
+		})
+
+		It("should fail with an invalid patch document", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.JSONPatchContentType, []byte(`[{"op": "jump", "path": "/title"}]`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+			response = performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status":`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should fail with an unsupported content type", func() {
+			response := performRawRequest(http.MethodPatch, path, "application/json", []byte(`{"status": "Completed"}`))
+			Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+
+		It("should fail when the task does not exist", func() {
+			response := performRawRequest(http.MethodPatch, tasksPath+"/42", utils.MergePatchContentType, []byte(`{"status": "Completed"}`))
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+			Expect(response.Body.String()).To(ContainSubstring(services.TaskNotFound))
+		})
+	})
+
+	Describe("conditional requests", func() {
+		var newTask models.Task
+		var path string
+
+		BeforeEach(func() {
+			newTask, _ = services.CreateTask(context.Background(), task)
+			path = tasksPath + "/" + strconv.Itoa(newTask.ID)
+		})
+
+		It("should return the version, updated_at and an ETag", func() {
+			response := performRequest(http.MethodGet, path, nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(Equal(`"1-0"`))
+
+			var responseBody map[string]interface{}
+			Expect(json.Unmarshal(response.Body.Bytes(), &responseBody)).To(Succeed())
+			Expect(responseBody[models.JsonVersion]).To(Equal(float64(1)))
+			Expect(responseBody).To(HaveKey(models.JsonUpdatedAt))
+		})
+
+		It("should return 304 when If-None-Match matches the current ETag", func() {
+			response := performRequestWithHeaders(http.MethodGet, path, nil, map[string]string{"If-None-Match": `W/"1-0"`})
+			Expect(response.Code).To(Equal(http.StatusNotModified))
+			Expect(response.Body.Len()).To(BeZero())
+
+			_, _ = services.UpdateTask(context.Background(), newTask.ID, task)
+			response = performRequestWithHeaders(http.MethodGet, path, nil, map[string]string{"If-None-Match": `"1-0"`})
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(Equal(`"2-0"`))
+		})
+
+		It("should change the ETag when the task becomes overdue", func() {
+			yesterday := time.Now().Add(-24 * time.Hour)
+			overdueTask := task
+			overdueTask.DueAt = &yesterday
+			created, err := services.CreateTask(context.Background(), overdueTask)
+			Expect(err).ToNot(HaveOccurred())
+			overduePath := tasksPath + "/" + strconv.Itoa(created.ID)
+
+			// The ETag the task had before its due date passed
+			response := performRequestWithHeaders(http.MethodGet, overduePath, nil, map[string]string{"If-None-Match": `"1-0"`})
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(Equal(`"1-0-overdue"`))
+
+			response = performRequestWithHeaders(http.MethodGet, overduePath, nil, map[string]string{"If-None-Match": `"1-0-overdue"`})
+			Expect(response.Code).To(Equal(http.StatusNotModified))
+
+			response = performRequestWithHeaders(http.MethodDelete, overduePath, nil, map[string]string{"If-Match": `"1-0"`})
+			Expect(response.Code).To(Equal(http.StatusNoContent))
+		})
+
+		It("should update when If-Match matches and bump the version", func() {
+			updatedTask := task
+			updatedTask.Status = "Completed"
+			response := performRequestWithHeaders(http.MethodPut, path, updatedTask, map[string]string{"If-Match": `"1"`})
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(Equal(`"2-0"`))
+
+			stored, err := services.GetTaskByID(context.Background(), newTask.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored.Version).To(Equal(2))
+			Expect(stored.UpdatedAt).To(BeTemporally(">", newTask.UpdatedAt))
+		})
+
+		It("should fail with 412 when If-Match does not match", func() {
+			_, _ = services.UpdateTask(context.Background(), newTask.ID, task)
+
+			updatedTask := task
+			updatedTask.Title = "Lost Update"
+			response := performRequestWithHeaders(http.MethodPut, path, updatedTask, map[string]string{"If-Match": `"1"`})
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+
+			req := httptest.NewRequest(http.MethodPatch, path, bytes.NewBufferString(`{"title": "Lost Update"}`))
+			req.Header.Set("Content-Type", utils.MergePatchContentType)
+			req.Header.Set("If-Match", `"1"`)
+			Expect(serve(req).Code).To(Equal(http.StatusPreconditionFailed))
+
+			response = performRequestWithHeaders(http.MethodDelete, path, nil, map[string]string{"If-Match": `W/"2"`})
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+
+			stored, err := services.GetTaskByID(context.Background(), newTask.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored.Title).To(Equal(task.Title))
+		})
+
+		It("should delete when If-Match matches one of the listed ETags", func() {
+			response := performRequestWithHeaders(http.MethodDelete, path, nil, map[string]string{"If-Match": `"7", "1"`})
+			Expect(response.Code).To(Equal(http.StatusNoContent))
+		})
+	})
+
 	Describe("DELETE /tasks/{id}", func() {
 		It("should successfully delete a task by ID", func() {
-			newTask := services.CreateTask(task)
+			newTask, _ := services.CreateTask(context.Background(), task)
 
 			response := performRequest(http.MethodDelete, tasksPath+"/"+strconv.Itoa(newTask.ID), nil)
 			Expect(response.Code).To(Equal(http.StatusNoContent))
@@ -331,18 +761,64 @@ var _ = Describe("Handle Task By ID Tests", func() {
 })
 
 func performRequest(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var requestBody []byte
+	if body != nil {
+		requestBody, _ = json.Marshal(body)
+	}
+	return performRawRequest(method, path, "application/json", requestBody)
+}
+
+func performRequestWithHeaders(method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
 	var requestBody []byte
 	if body != nil {
 		requestBody, _ = json.Marshal(body)
 	}
 	req := httptest.NewRequest(method, path, bytes.NewBuffer(requestBody))
 	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return serve(req)
+}
+
+func performRawRequest(method, path, contentType string, requestBody []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", contentType)
+	return serve(req)
+}
 
+func serve(req *http.Request) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
-	if path == tasksPath {
+	switch req.URL.Path {
+	case tasksPath:
 		HandleTasks(w, req)
-	} else {
-		HandleTaskByID(w, req)
+	case workflowPath:
+		HandleWorkflow(w, req)
+	case undoPath:
+		HandleUndo(w, req)
+	case labelsPath:
+		HandleLabels(w, req)
+	case webhooksPath:
+		HandleWebhooks(w, req)
+	default:
+		if strings.HasPrefix(req.URL.Path, labelsPath+"/") {
+			HandleLabelByName(w, req)
+		} else if strings.HasPrefix(req.URL.Path, webhooksPath+"/") {
+			HandleWebhookByID(w, req)
+		} else {
+			HandleTaskByID(w, req)
+		}
 	}
 	return w
 }
+
+func responseIDs(response *httptest.ResponseRecorder) []int {
+	var responseBody []models.Task
+	Expect(json.Unmarshal(response.Body.Bytes(), &responseBody)).To(Succeed())
+
+	ids := make([]int, 0, len(responseBody))
+	for _, task := range responseBody {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
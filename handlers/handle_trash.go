@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// handleTrash serves GET /tasks/trash
+func handleTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	tasks, err := services.ListTrash(r.Context())
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, tasks, http.StatusOK)
+}
+
+// handleTaskRestore serves POST /tasks/{id}/restore
+func handleTaskRestore(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+
+	task, err := services.RestoreTask(requestContext(r), id, ifMatch(r)...)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(task))
+	utils.SendResponse(w, task, http.StatusOK)
+}
+
+// parseHardDelete reads the hard query parameter of DELETE /tasks/{id}
+func parseHardDelete(query url.Values) (bool, error) {
+	value := query.Get("hard")
+	if value == "" {
+		return false, nil
+	}
+	hard, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New(invalidHard)
+	}
+	return hard, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+)
+
+var _ = Describe("Trash Tests", func() {
+	const (
+		path      = tasksPath + "/1"
+		trashPath = tasksPath + "/trash"
+	)
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		_, err := services.CreateTask(context.Background(), models.Task{
+			Title:       "New Task",
+			Description: "Task Description",
+			Status:      "TODO",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(performRequest(http.MethodDelete, path, nil).Code).To(Equal(http.StatusNoContent))
+	})
+
+	trash := func() []models.Task {
+		response := performRequest(http.MethodGet, trashPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var tasks []models.Task
+		Expect(json.Unmarshal(response.Body.Bytes(), &tasks)).To(Succeed())
+		return tasks
+	}
+
+	It("should move a deleted task to the trash", func() {
+		Expect(performRequest(http.MethodGet, path, nil).Code).To(Equal(http.StatusNotFound))
+		Expect(responseIDs(performRequest(http.MethodGet, tasksPath, nil))).To(BeEmpty())
+
+		tasks := trash()
+		Expect(tasks).To(HaveLen(1))
+		Expect(tasks[0].ID).To(Equal(1))
+		Expect(tasks[0].DeletedAt).ToNot(BeNil())
+	})
+
+	It("should not update or delete a task in the trash", func() {
+		response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"title":"Updated Task"}`))
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(performRequest(http.MethodDelete, path, nil).Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("POST /tasks/{id}/restore", func() {
+		It("should bring the task back with the same ID", func() {
+			response := performRequest(http.MethodPost, path+"/restore", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var task models.Task
+			Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+			Expect(task.ID).To(Equal(1))
+			Expect(task.DeletedAt).To(BeNil())
+
+			Expect(performRequest(http.MethodGet, path, nil).Code).To(Equal(http.StatusOK))
+			Expect(trash()).To(BeEmpty())
+		})
+
+		It("should fail for a task that is not in the trash", func() {
+			Expect(performRequest(http.MethodPost, path+"/restore", nil).Code).To(Equal(http.StatusOK))
+
+			response := performRequest(http.MethodPost, path+"/restore", nil)
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(response.Body.String()).To(ContainSubstring(errTaskNotInTrash.code))
+		})
+	})
+
+	Describe("DELETE /tasks/{id}?hard=true", func() {
+		BeforeEach(func() {
+			services.SetAdminToken(adminToken)
+			DeferCleanup(services.SetAdminToken, "")
+		})
+
+		It("should only be allowed to administrators", func() {
+			response := performRequestWithHeaders(http.MethodDelete, path+"?hard=true", nil, map[string]string{actorHeader: "alice"})
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+			Expect(response.Body.String()).To(ContainSubstring(errAdminRequired.code))
+			Expect(trash()).To(HaveLen(1))
+		})
+
+		It("should permanently remove the task and keep its history", func() {
+			response := performRequestWithHeaders(http.MethodDelete, path+"?hard=true", nil, asAdminHeaders)
+			Expect(response.Code).To(Equal(http.StatusNoContent))
+			Expect(trash()).To(BeEmpty())
+			Expect(performRequest(http.MethodPost, path+"/restore", nil).Code).To(Equal(http.StatusNotFound))
+
+			revisions, err := services.TaskHistory(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revisions[len(revisions)-1].Action).To(Equal(models.RevisionPurged))
+		})
+
+		It("should reject an invalid value", func() {
+			Expect(performRequest(http.MethodDelete, path+"?hard=maybe", nil).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
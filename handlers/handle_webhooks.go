@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	webhookURLRequired    = "url is required"
+	invalidWebhookURL     = "url must be an absolute http or https URL"
+	eventsRequired        = "events is required"
+	invalidEvent          = "unknown event"
+	webhookSecretTooLong  = "secret must be at most 256 characters"
+	invalidDeliveryStatus = "status must be one of pending, succeeded or dead"
+
+	maxWebhookSecretLength = 256
+)
+
+// webhookRequest is the body of POST /webhooks and PUT /webhooks/{id}.
+// Active defaults to true, and an empty secret generates one on creation and keeps the current one on update.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// HandleWebhooks serves GET and POST /webhooks
+func HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		webhooks, err := services.ListWebhooks(requestContext(r))
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, webhooks, http.StatusOK)
+
+	case http.MethodPost:
+		webhook, ok := decodeWebhook(w, r)
+		if !ok {
+			return
+		}
+		created, err := services.CreateWebhook(requestContext(r), webhook)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, created, http.StatusCreated)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// HandleWebhookByID serves GET, PUT and DELETE /webhooks/{id}, the delivery log at /webhooks/{id}/deliveries
+// and the dead-letter list of every webhook at /webhooks/deliveries
+func HandleWebhookByID(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/")
+	if len(segments) == 1 && segments[0] == "deliveries" {
+		handleDeliveries(w, r, 0)
+		return
+	}
+	id, err := strconv.Atoi(segments[0])
+	if err != nil {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) > 1 {
+		handleWebhookDeliveries(w, r, id, segments[1:])
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		webhook, err := services.GetWebhook(requestContext(r), id)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, webhook, http.StatusOK)
+
+	case http.MethodPut:
+		webhook, ok := decodeWebhook(w, r)
+		if !ok {
+			return
+		}
+		updated, err := services.UpdateWebhook(requestContext(r), id, webhook)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, updated, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := services.DeleteWebhook(requestContext(r), id); err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendProblem(w, errMethodNotAllowed, "")
+	}
+}
+
+// handleWebhookDeliveries serves GET /webhooks/{id}/deliveries, GET /webhooks/{id}/deliveries/{deliveryID}
+// and POST /webhooks/{id}/deliveries/{deliveryID}/redeliver
+func handleWebhookDeliveries(w http.ResponseWriter, r *http.Request, id int, segments []string) {
+	if segments[0] != "deliveries" || len(segments) > 3 || len(segments) == 3 && segments[2] != "redeliver" {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+	if len(segments) == 1 {
+		handleDeliveries(w, r, id)
+		return
+	}
+	deliveryID, err := strconv.Atoi(segments[1])
+	if err != nil {
+		sendProblem(w, errNotFound, "")
+		return
+	}
+
+	if len(segments) == 3 {
+		if r.Method != http.MethodPost {
+			sendProblem(w, errMethodNotAllowed, "")
+			return
+		}
+		delivery, err := services.Redeliver(requestContext(r), id, deliveryID)
+		if err != nil {
+			sendServiceError(w, err)
+			return
+		}
+		utils.SendResponse(w, delivery, http.StatusAccepted)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	delivery, err := services.GetDelivery(requestContext(r), id, deliveryID)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, delivery, http.StatusOK)
+}
+
+// handleDeliveries serves GET /webhooks/{id}/deliveries?status=S&limit=N, the delivery log of a webhook, most recent
+// first. Without a webhook ID it serves GET /webhooks/deliveries, the dead-letter list unless status is given.
+func handleDeliveries(w http.ResponseWriter, r *http.Request, webhookID int) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	filter, err := parseDeliveryFilter(r.URL.Query())
+	if err != nil {
+		sendProblem(w, errInvalidQuery, err.Error())
+		return
+	}
+	filter.WebhookID = webhookID
+	if webhookID == 0 && filter.Status == "" {
+		filter.Status = models.DeliveryDead
+	}
+
+	deliveries, err := services.ListDeliveries(requestContext(r), filter)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+	utils.SendResponse(w, deliveries, http.StatusOK)
+}
+
+// parseDeliveryFilter reads the status and limit query parameters of the delivery lists
+func parseDeliveryFilter(query url.Values) (models.DeliveryFilter, error) {
+	var filter models.DeliveryFilter
+	switch status := query.Get("status"); status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+		filter.Status = status
+	default:
+		return models.DeliveryFilter{}, errors.New(invalidDeliveryStatus)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return models.DeliveryFilter{}, errors.New(invalidLimit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// decodeWebhook reads and validates a webhook from the request body.
+// It sends the error response and returns false when the webhook is invalid.
+func decodeWebhook(w http.ResponseWriter, r *http.Request) (models.Webhook, bool) {
+	var body webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendProblem(w, errInvalidPayload, err.Error())
+		return models.Webhook{}, false
+	}
+	webhook := models.Webhook{URL: strings.TrimSpace(body.URL), Events: body.Events, Secret: body.Secret, Active: true}
+	if body.Active != nil {
+		webhook.Active = *body.Active
+	}
+
+	var fields []utils.FieldError
+	if webhook.URL == "" {
+		fields = append(fields, fieldError("url", webhookURLRequired))
+	} else if target, err := url.Parse(webhook.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") ||
+		target.Host == "" {
+		fields = append(fields, fieldError("url", invalidWebhookURL))
+	}
+	if len(webhook.Events) == 0 {
+		fields = append(fields, fieldError("events", eventsRequired))
+	}
+	for _, event := range webhook.Events {
+		if !services.IsEventType(event) {
+			field := fieldError("events", invalidEvent)
+			field.Message += " " + strconv.Quote(event) + ". Valid events are: " + strings.Join(services.EventTypes, ", ")
+			fields = append(fields, field)
+			break
+		}
+	}
+	if len(webhook.Secret) > maxWebhookSecretLength {
+		fields = append(fields, fieldError("secret", webhookSecretTooLong))
+	}
+	if len(fields) > 0 {
+		sendProblem(w, errValidationFailed, validationFailed, fields...)
+		return models.Webhook{}, false
+	}
+	return webhook, true
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Webhook Tests", func() {
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+		services.SetAdminToken(adminToken)
+		DeferCleanup(services.SetAdminToken, "")
+	})
+
+	asAdmin := func(method, path string, body any) *httptest.ResponseRecorder {
+		return performRequestWithHeaders(method, path, body, asAdminHeaders)
+	}
+
+	decodeWebhook := func(body []byte) models.Webhook {
+		var webhook models.Webhook
+		Expect(json.Unmarshal(body, &webhook)).To(Succeed())
+		return webhook
+	}
+
+	decodeDeliveries := func(body []byte) []models.Delivery {
+		var deliveries []models.Delivery
+		Expect(json.Unmarshal(body, &deliveries)).To(Succeed())
+		return deliveries
+	}
+
+	It("should create, list, update and delete webhooks without returning their secrets again", func() {
+		response := asAdmin(http.MethodPost, webhooksPath, map[string]any{
+			"url": "https://example.com/hook", "events": []string{"task.deleted", "task.created"},
+		})
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		webhook := decodeWebhook(response.Body.Bytes())
+		Expect(webhook.Secret).ToNot(BeEmpty())
+		Expect(webhook.Active).To(BeTrue())
+		Expect(webhook.Events).To(Equal([]string{"task.created", "task.deleted"}))
+
+		response = asAdmin(http.MethodGet, webhooksPath, nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).ToNot(ContainSubstring("secret"))
+
+		response = asAdmin(http.MethodPut, webhooksPath+"/1", map[string]any{
+			"url": "https://example.com/other", "events": []string{"task.updated"}, "active": false,
+		})
+		Expect(response.Code).To(Equal(http.StatusOK))
+		updated := decodeWebhook(response.Body.Bytes())
+		Expect(updated.URL).To(Equal("https://example.com/other"))
+		Expect(updated.Active).To(BeFalse())
+		Expect(updated.Secret).To(BeEmpty())
+		stored, err := models.DB.GetWebhook(context.Background(), 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Secret).To(Equal(webhook.Secret))
+
+		Expect(asAdmin(http.MethodDelete, webhooksPath+"/1", nil).Code).To(Equal(http.StatusNoContent))
+		response = asAdmin(http.MethodGet, webhooksPath+"/1", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errWebhookNotFound.code))
+	})
+
+	It("should only let administrators manage webhooks", func() {
+		for _, headers := range []map[string]string{{}, {actorHeader: "admin"}, {"Authorization": bearerPrefix + "guess"}} {
+			response := performRequestWithHeaders(http.MethodPost, webhooksPath, map[string]any{
+				"url": "https://example.com/hook", "events": []string{"task.created"},
+			}, headers)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+			Expect(response.Body.String()).To(ContainSubstring(errAdminRequired.code))
+
+			response = performRequestWithHeaders(http.MethodGet, webhooksPath+"/deliveries", nil, headers)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+		}
+	})
+
+	It("should reject webhooks targeting internal addresses", func() {
+		response := asAdmin(http.MethodPost, webhooksPath, map[string]any{
+			"url": "http://127.0.0.1:8080/hook", "events": []string{"task.created"},
+		})
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("internal address"))
+	})
+
+	It("should report every invalid field of a webhook", func() {
+		for body, codes := range map[string][]string{
+			`{}`: {"url_required", "events_required"},
+			`{"url": "ftp://example.com", "events": ["task.archived"]}`: {"invalid_url", "invalid_event"},
+		} {
+			response := performRawRequest(http.MethodPost, webhooksPath, "application/json", []byte(body))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			for _, code := range codes {
+				Expect(response.Body.String()).To(ContainSubstring(code))
+			}
+		}
+	})
+
+	It("should log the deliveries of task events and redeliver dead ones", func() {
+		Expect(asAdmin(http.MethodPost, webhooksPath, map[string]any{
+			"url": "https://example.com/hook", "events": []string{"task.created"},
+		}).Code).To(Equal(http.StatusCreated))
+		Expect(performRequest(http.MethodPost, tasksPath, map[string]any{
+			"title": "Task", "description": "Description", "status": "TODO",
+		}).Code).To(Equal(http.StatusCreated))
+
+		response := asAdmin(http.MethodGet, webhooksPath+"/1/deliveries", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		deliveries := decodeDeliveries(response.Body.Bytes())
+		Expect(deliveries).To(HaveLen(1))
+		Expect(deliveries[0].Event).To(Equal("task.created"))
+		Expect(deliveries[0].Status).To(Equal(models.DeliveryPending))
+		Expect(string(deliveries[0].Payload)).To(ContainSubstring(`"event":"task.created"`))
+
+		response = asAdmin(http.MethodPost, webhooksPath+"/1/deliveries/1/redeliver", nil)
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(response.Body.String()).To(ContainSubstring(errDeliveryPending.code))
+
+		_, err := models.DB.UpdateDelivery(context.Background(), 1, func(delivery *models.Delivery) error {
+			delivery.Status = models.DeliveryDead
+			delivery.NextAttemptAt = nil
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		response = asAdmin(http.MethodGet, webhooksPath+"/deliveries", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(decodeDeliveries(response.Body.Bytes())).To(HaveLen(1))
+
+		response = asAdmin(http.MethodPost, webhooksPath+"/1/deliveries/1/redeliver", nil)
+		Expect(response.Code).To(Equal(http.StatusAccepted))
+		response = asAdmin(http.MethodGet, webhooksPath+"/1/deliveries/1", nil)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(ContainSubstring(`"status":"pending"`))
+		Expect(decodeDeliveries(asAdmin(http.MethodGet, webhooksPath+"/deliveries", nil).Body.Bytes())).To(BeEmpty())
+
+		response = asAdmin(http.MethodGet, webhooksPath+"/1/deliveries?status=lost", nil)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(errInvalidQuery.code))
+		response = asAdmin(http.MethodGet, webhooksPath+"/2/deliveries/1", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errWebhookNotFound.code))
+		response = asAdmin(http.MethodGet, webhooksPath+"/1/deliveries/9", nil)
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Body.String()).To(ContainSubstring(errDeliveryNotFound.code))
+	})
+})
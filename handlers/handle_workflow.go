@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	"net/http"
+)
+
+// HandleWorkflow exposes the statuses and transitions enforced on tasks
+func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendProblem(w, errMethodNotAllowed, "")
+		return
+	}
+	utils.SendResponse(w, services.Workflow(), http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+)
+
+var _ = Describe("Workflow Tests", func() {
+	var path string
+
+	BeforeEach(func() {
+		models.DB.Restore(models.Snapshot{})
+
+		Expect(services.SetWorkflow(models.Workflow{
+			Statuses: []models.WorkflowStatus{
+				{Name: "TODO", Category: models.CategoryOpen},
+				{Name: "in-progress", Category: models.CategoryInProgress},
+				{Name: "Completed", Category: models.CategoryDone},
+			},
+			Transitions: map[string][]string{
+				"TODO":        {"in-progress"},
+				"in-progress": {"TODO", "Completed"},
+			},
+		})).To(Succeed())
+		DeferCleanup(func() {
+			Expect(services.SetWorkflow(models.DefaultWorkflow())).To(Succeed())
+		})
+
+		newTask, err := services.CreateTask(context.Background(), models.Task{
+			Title:       "New Task",
+			Description: "Task Description",
+			Status:      "TODO",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		path = tasksPath + "/" + strconv.Itoa(newTask.ID)
+	})
+
+	Describe("GET /workflow", func() {
+		It("should return the configured statuses and transitions", func() {
+			response := performRequest(http.MethodGet, workflowPath, nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var workflow models.Workflow
+			Expect(json.Unmarshal(response.Body.Bytes(), &workflow)).To(Succeed())
+			Expect(workflow.StatusNames()).To(Equal([]string{"TODO", "in-progress", "Completed"}))
+			Expect(workflow.Transitions["TODO"]).To(Equal([]string{"in-progress"}))
+		})
+
+		It("should fail with method not allowed for other methods", func() {
+			response := performRequest(http.MethodPost, workflowPath, nil)
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("status transitions", func() {
+		It("should allow a transition declared by the workflow", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status":"in-progress"}`))
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should reject an illegal transition with a conflict", func() {
+			response := performRequest(http.MethodPut, path, models.Task{
+				Title:       "New Task",
+				Description: "Task Description",
+				Status:      "Completed",
+			})
+			Expect(response.Code).To(Equal(http.StatusConflict))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Code).To(Equal(errIllegalTransition.code))
+			Expect(problem.Detail).To(ContainSubstring(`from "TODO" to "Completed"`))
+
+			task, err := services.GetTaskByID(context.Background(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Status).To(Equal("TODO"))
+		})
+
+		It("should match statuses case-insensitively and store the declared name", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status":"IN-PROGRESS"}`))
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var task models.Task
+			Expect(json.Unmarshal(response.Body.Bytes(), &task)).To(Succeed())
+			Expect(task.Status).To(Equal("in-progress"))
+		})
+
+		It("should reject a status missing from the workflow", func() {
+			response := performRawRequest(http.MethodPatch, path, utils.MergePatchContentType, []byte(`{"status":"Pending"}`))
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+			var problem utils.Problem
+			Expect(json.Unmarshal(response.Body.Bytes(), &problem)).To(Succeed())
+			Expect(problem.Errors).To(ConsistOf(utils.FieldError{
+				Field:   models.JsonStatus,
+				Code:    "invalid_status",
+				Message: "invalid status. Valid statuses are: TODO, in-progress, Completed",
+				Detail:  "Allowed transitions: TODO -> in-progress; in-progress -> TODO, Completed",
+			}))
+		})
+	})
+})
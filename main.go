@@ -1,17 +1,93 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/ofirmad/task-manager/handlers"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/notify"
+	"github.com/ofirmad/task-manager/services"
+	"github.com/ofirmad/task-manager/storage"
 	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // embeds the time zone database of recurrences, which the alpine image lacks
+)
+
+const (
+	// trashPurgeInterval is how often tasks older than TRASH_RETENTION are purged from the trash
+	trashPurgeInterval = time.Hour
+	// defaultWorkflowConfig is the workflow loaded unless WORKFLOW_CONFIG names another one
+	defaultWorkflowConfig = "config/workflow.json"
 )
 
 func main() {
+	if err := configureStorage(); err != nil {
+		fmt.Printf("failed to configure storage: %v\n", err)
+		os.Exit(1)
+	}
+	if err := configureAttachments(); err != nil {
+		fmt.Printf("failed to configure attachments: %v\n", err)
+		os.Exit(1)
+	}
+	idempotencyTTL, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", services.DefaultIdempotencyTTL.String()))
+	if err != nil {
+		fmt.Printf("invalid IDEMPOTENCY_TTL: %v\n", err)
+		os.Exit(1)
+	}
+	services.SetIdempotencyTTL(idempotencyTTL)
+	workflow, err := services.LoadWorkflow(getEnv("WORKFLOW_CONFIG", defaultWorkflowConfig))
+	if err == nil {
+		err = services.SetWorkflow(workflow)
+	}
+	if err != nil {
+		fmt.Printf("invalid WORKFLOW_CONFIG: %v\n", err)
+		os.Exit(1)
+	}
+	if err := services.SetBlockerPolicy(services.BlockerPolicy(getEnv("BLOCKER_POLICY", string(services.BlockerPolicyWarn)))); err != nil {
+		fmt.Printf("invalid BLOCKER_POLICY: %v\n", err)
+		os.Exit(1)
+	}
+	services.SetAdminToken(getEnv("ADMIN_TOKEN", ""))
+	trashRetention, err := time.ParseDuration(getEnv("TRASH_RETENTION", services.DefaultTrashRetention.String()))
+	if err != nil {
+		fmt.Printf("invalid TRASH_RETENTION: %v\n", err)
+		os.Exit(1)
+	}
+	if trashRetention > 0 {
+		go services.RunTrashPurge(context.Background(), trashRetention, trashPurgeInterval)
+	}
+	configureNotifiers()
+	reminderInterval, err := time.ParseDuration(getEnv("REMINDER_INTERVAL", services.DefaultReminderInterval.String()))
+	if err != nil || reminderInterval <= 0 {
+		fmt.Printf("invalid REMINDER_INTERVAL %q\n", getEnv("REMINDER_INTERVAL", ""))
+		os.Exit(1)
+	}
+	go services.RunReminders(context.Background(), reminderInterval)
+	if hosts := getEnv("WEBHOOK_ALLOWED_HOSTS", ""); hosts != "" {
+		services.SetWebhookAllowedHosts(strings.Split(hosts, ","))
+	}
+	webhookInterval, err := time.ParseDuration(getEnv("WEBHOOK_INTERVAL", services.DefaultWebhookInterval.String()))
+	if err != nil || webhookInterval <= 0 {
+		fmt.Printf("invalid WEBHOOK_INTERVAL %q\n", getEnv("WEBHOOK_INTERVAL", ""))
+		os.Exit(1)
+	}
+	go services.RunWebhooks(context.Background(), webhookInterval)
+
 	mux := http.NewServeMux()
 
 	// Separate the routes into their own handlers package
 	mux.HandleFunc("/tasks", handlers.HandleTasks)
 	mux.HandleFunc("/tasks/", handlers.HandleTaskByID)
+	mux.HandleFunc("/workflow", handlers.HandleWorkflow)
+	mux.HandleFunc("/undo", handlers.HandleUndo)
+	mux.HandleFunc("/labels", handlers.HandleLabels)
+	mux.HandleFunc("/labels/", handlers.HandleLabelByName)
+	mux.HandleFunc("/webhooks", handlers.HandleWebhooks)
+	mux.HandleFunc("/webhooks/", handlers.HandleWebhookByID)
 
 	// Wrap the mux with the CORS middleware
 	handler := corsMiddleware(mux)
@@ -22,12 +98,101 @@ func main() {
 	}
 }
 
+// configureStorage selects the storage backend according to the STORAGE environment variable.
+// "memory" (the default) keeps tasks in models.DB, journaled to WAL_DIR when it is set.
+// "sqlite" persists them in SQLITE_PATH.
+func configureStorage() error {
+	switch backend := getEnv("STORAGE", "memory"); backend {
+	case "memory":
+		dir := getEnv("WAL_DIR", "")
+		if dir == "" {
+			return nil
+		}
+		snapshotEvery, err := strconv.Atoi(getEnv("WAL_SNAPSHOT_EVERY", strconv.Itoa(storage.DefaultSnapshotEvery)))
+		if err != nil {
+			return fmt.Errorf("invalid WAL_SNAPSHOT_EVERY: %w", err)
+		}
+		if _, err := storage.OpenWAL(dir, &models.DB, snapshotEvery); err != nil {
+			return err
+		}
+		fmt.Printf("Using in-memory storage with write-ahead log at %s\n", dir)
+		return nil
+	case "sqlite":
+		path := getEnv("SQLITE_PATH", "tasks.db")
+		store, err := storage.OpenSQLite(context.Background(), path)
+		if err != nil {
+			return err
+		}
+		services.SetStore(store)
+		fmt.Printf("Using SQLite storage at %s\n", path)
+		return nil
+	default:
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// configureAttachments keeps the contents of attachments in BLOB_DIR and limits them to MAX_ATTACHMENT_SIZE bytes.
+// Blobs no attachment references anymore, e.g. after a restart without a write-ahead log, are deleted.
+func configureAttachments() error {
+	maxSize, err := strconv.ParseInt(getEnv("MAX_ATTACHMENT_SIZE", strconv.Itoa(services.DefaultMaxAttachmentSize)), 10, 64)
+	if err != nil || maxSize <= 0 {
+		return fmt.Errorf("invalid MAX_ATTACHMENT_SIZE %q", getEnv("MAX_ATTACHMENT_SIZE", ""))
+	}
+	services.SetMaxAttachmentSize(maxSize)
+
+	dir := getEnv("BLOB_DIR", "blobs")
+	blobs, err := storage.OpenBlobStore(dir)
+	if err != nil {
+		return err
+	}
+	services.SetBlobStore(blobs)
+	collected, err := services.CollectBlobs(context.Background())
+	if err != nil {
+		return err
+	}
+	if collected > 0 {
+		fmt.Printf("Deleted %d unreferenced blobs from %s\n", collected, dir)
+	}
+	return nil
+}
+
+// configureNotifiers adds the reminder channels configured by the environment to the "log" channel:
+// "webhook" posts to REMINDER_WEBHOOK_URL and "email" sends through the SMTP server at SMTP_ADDR (host:port)
+// from SMTP_FROM, to the recipient of the reminder or SMTP_TO. Recipients are limited to SMTP_TO and the addresses in
+// SMTP_ALLOWED_RECIPIENTS. SMTP_USERNAME and SMTP_PASSWORD enable PLAIN authentication.
+func configureNotifiers() {
+	if url := getEnv("REMINDER_WEBHOOK_URL", ""); url != "" {
+		services.SetNotifier("webhook", notify.NewWebhookNotifier(url, nil))
+	}
+	if addr := getEnv("SMTP_ADDR", ""); addr != "" {
+		var auth smtp.Auth
+		if username := getEnv("SMTP_USERNAME", ""); username != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			auth = smtp.PlainAuth("", username, getEnv("SMTP_PASSWORD", ""), host)
+		}
+		var allowed []string
+		if recipients := getEnv("SMTP_ALLOWED_RECIPIENTS", ""); recipients != "" {
+			allowed = strings.Split(recipients, ",")
+		}
+		services.SetNotifier("email", notify.NewSMTPNotifier(addr, auth, getEnv("SMTP_FROM", "tasks@localhost"), getEnv("SMTP_TO", ""), allowed))
+	}
+}
+
+// getEnv returns the value of the environment variable key, or fallback when it is not set
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
 // corsMiddleware sets the CORS headers
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, Idempotency-Key, X-User, Range, If-Range, Last-Event-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Total-Count, X-Next-Cursor, Link, Idempotent-Replayed, Content-Disposition, Content-Range, Accept-Ranges")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
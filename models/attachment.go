@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when the requested attachment does not exist
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrBlobNotFound is returned when the content of an attachment is missing from the blob store
+	ErrBlobNotFound = errors.New("blob not found")
+)
+
+// Attachment is a file attached to a task. Its content is kept in a BlobStore under its SHA-256 digest,
+// so attachments with the same content share a single blob.
+type Attachment struct {
+	ID       int    `json:"id"`
+	TaskID   int    `json:"task_id"`
+	Filename string `json:"filename"`
+	// ContentType is sniffed from the content when the file is uploaded
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// SHA256 is the hex encoded digest of the content and the key of its blob
+	SHA256    string    `json:"sha256"`
+	Uploader  string    `json:"uploader,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AttachmentStore is implemented by storage backends that support attachments.
+// Deleting a task from the store deletes its attachments along with it, but not their blobs.
+type AttachmentStore interface {
+	// CreateAttachment stores a new attachment and assigns it the next available attachment ID
+	CreateAttachment(ctx context.Context, attachment Attachment) (Attachment, error)
+	// GetAttachment returns an attachment by its ID
+	GetAttachment(ctx context.Context, id int) (Attachment, error)
+	// DeleteAttachment removes an attachment by its ID
+	DeleteAttachment(ctx context.Context, id int) error
+	// ListAttachments returns the attachments of a task ordered by ID
+	ListAttachments(ctx context.Context, taskID int) ([]Attachment, error)
+	// ReferencedBlobs returns the digests of the blobs referenced by any attachment
+	ReferencedBlobs(ctx context.Context) (map[string]bool, error)
+}
+
+// BlobStore keeps immutable contents under their hex encoded SHA-256 digest
+type BlobStore interface {
+	// Put stores the content read from r and returns its digest and size.
+	// Storing a content that is already stored keeps a single copy of it.
+	Put(ctx context.Context, r io.Reader) (digest string, size int64, err error)
+	// Open returns the content of a blob, or ErrBlobNotFound
+	Open(ctx context.Context, digest string) (io.ReadSeekCloser, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, digest string) error
+	// List returns the digests of every stored blob
+	List(ctx context.Context) ([]string, error)
+}
+
+// BlobCache is implemented by blob stores that can keep files derived from a blob, e.g. its thumbnails.
+// Cached files are deleted along with their blob.
+type BlobCache interface {
+	// OpenCached returns a file cached for a blob, or ErrBlobNotFound
+	OpenCached(ctx context.Context, digest, name string) (io.ReadSeekCloser, error)
+	// PutCached caches a file for a blob. It returns ErrBlobNotFound when the blob does not exist.
+	PutCached(ctx context.Context, digest, name string, content []byte) error
+}
+
+// CreateAttachment stores a new attachment
+func (db *Database) CreateAttachment(_ context.Context, attachment Attachment) (Attachment, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	attachment.ID = db.NextAttachmentID
+	if err := db.commit(Record{Op: OpPutAttachment, Attachment: &attachment}); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// GetAttachment returns an attachment by its ID
+func (db *Database) GetAttachment(_ context.Context, id int) (Attachment, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	attachment, exists := db.Attachments[id]
+	if !exists {
+		return Attachment{}, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment removes an attachment by its ID
+func (db *Database) DeleteAttachment(_ context.Context, id int) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Attachments[id]; !exists {
+		return ErrAttachmentNotFound
+	}
+	return db.commit(Record{Op: OpDeleteAttachment, ID: id})
+}
+
+// ListAttachments returns the attachments of a task ordered by ID
+func (db *Database) ListAttachments(_ context.Context, taskID int) ([]Attachment, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	attachments := make([]Attachment, 0)
+	for _, attachment := range db.Attachments {
+		if attachment.TaskID == taskID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+// ReferencedBlobs returns the digests of the blobs referenced by any attachment
+func (db *Database) ReferencedBlobs(_ context.Context) (map[string]bool, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	digests := make(map[string]bool, len(db.Attachments))
+	for _, attachment := range db.Attachments {
+		digests[attachment.SHA256] = true
+	}
+	return digests, nil
+}
+
+// deleteTaskAttachments removes the attachments of a deleted task. The caller must hold the write lock.
+func (db *Database) deleteTaskAttachments(taskID int) {
+	for id, attachment := range db.Attachments {
+		if attachment.TaskID == taskID {
+			delete(db.Attachments, id)
+		}
+	}
+}
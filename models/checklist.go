@@ -0,0 +1,37 @@
+package models
+
+import "math"
+
+// ChecklistItem is a step of the checklist of a task
+type ChecklistItem struct {
+	// ID is unique within the checklist of the task
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Done     bool   `json:"done"`
+	Assignee string `json:"assignee,omitempty"`
+}
+
+// Progress is the share of a set of steps that are done
+type Progress struct {
+	Completed int     `json:"completed"`
+	Total     int     `json:"total"`
+	Percent   float64 `json:"percent"`
+}
+
+// NewProgress returns the progress of completed steps out of total, with a percentage rounded to one decimal
+func NewProgress(completed, total int) Progress {
+	progress := Progress{Completed: completed, Total: total}
+	if total > 0 {
+		progress.Percent = math.Round(1000*float64(completed)/float64(total)) / 10
+	}
+	return progress
+}
+
+// NextChecklistItemID returns the ID of an item added to the checklist
+func NextChecklistItemID(items []ChecklistItem) int {
+	next := 1
+	for _, item := range items {
+		next = max(next, item.ID+1)
+	}
+	return next
+}
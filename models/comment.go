@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrCommentNotFound is returned when the requested comment does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// Comment is a Markdown comment on a task, or a reply to another comment of the same task
+type Comment struct {
+	ID     int `json:"id"`
+	TaskID int `json:"task_id"`
+	// ParentID is the ID of the comment this comment replies to
+	ParentID *int   `json:"parent_id,omitempty"`
+	Author   string `json:"author,omitempty"`
+	// Body is Markdown. It is emptied when a comment with replies is deleted.
+	Body string `json:"body"`
+	// BodyHTML is the rendered Body. It is computed when the comment is read and never stored.
+	BodyHTML  string    `json:"body_html"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Edits holds the previous bodies of the comment, oldest first
+	Edits []CommentEdit `json:"edits,omitempty"`
+	// DeletedAt is set on a deleted comment kept as a placeholder for its replies
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Replies are filled when comments are read as threads. They are never stored.
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// CommentEdit is a body a comment had before it was edited
+type CommentEdit struct {
+	Body string `json:"body"`
+	// Editor is the user who replaced the body
+	Editor string `json:"editor,omitempty"`
+	// EditedAt is when the body was replaced
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// CommentStore is implemented by storage backends that support comments.
+// Tasks read from the store carry the number of their comments, and deleting a task deletes its comments.
+type CommentStore interface {
+	// CreateComment stores a new comment and assigns it the next available comment ID
+	CreateComment(ctx context.Context, comment Comment) (Comment, error)
+	// GetComment returns a comment by its ID
+	GetComment(ctx context.Context, id int) (Comment, error)
+	// UpdateComment applies update to a copy of the comment and stores it if update succeeds
+	UpdateComment(ctx context.Context, id int, update func(comment *Comment) error) (Comment, error)
+	// DeleteComment removes a comment by its ID
+	DeleteComment(ctx context.Context, id int) error
+	// ListComments returns the comments of a task ordered by ID, including deleted placeholders
+	ListComments(ctx context.Context, taskID int) ([]Comment, error)
+}
+
+// CreateComment stores a new comment
+func (db *Database) CreateComment(_ context.Context, comment Comment) (Comment, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	comment.ID = db.NextCommentID
+	if err := db.commit(Record{Op: OpPutComment, Comment: &comment}); err != nil {
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+// GetComment returns a comment by its ID
+func (db *Database) GetComment(_ context.Context, id int) (Comment, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	comment, exists := db.Comments[id]
+	if !exists {
+		return Comment{}, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// UpdateComment applies update to a copy of the comment and stores it if update succeeds
+func (db *Database) UpdateComment(_ context.Context, id int, update func(comment *Comment) error) (Comment, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	comment, exists := db.Comments[id]
+	if !exists {
+		return Comment{}, ErrCommentNotFound
+	}
+	comment.Edits = append([]CommentEdit(nil), comment.Edits...)
+	if err := update(&comment); err != nil {
+		return Comment{}, err
+	}
+	comment.ID = id
+	if err := db.commit(Record{Op: OpPutComment, Comment: &comment}); err != nil {
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+// DeleteComment removes a comment by its ID
+func (db *Database) DeleteComment(_ context.Context, id int) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Comments[id]; !exists {
+		return ErrCommentNotFound
+	}
+	return db.commit(Record{Op: OpDeleteComment, ID: id})
+}
+
+// ListComments returns the comments of a task ordered by ID
+func (db *Database) ListComments(_ context.Context, taskID int) ([]Comment, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	comments := make([]Comment, 0, db.commentCounts[taskID])
+	for _, comment := range db.Comments {
+		if comment.TaskID == taskID {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+// putComment stores a comment and keeps the comment counts up to date. The caller must hold the write lock.
+func (db *Database) putComment(comment Comment) {
+	if previous, exists := db.Comments[comment.ID]; exists && previous.DeletedAt == nil {
+		db.commentCounts[previous.TaskID]--
+	}
+	comment.BodyHTML = ""
+	comment.Replies = nil
+	db.Comments[comment.ID] = comment
+	if comment.DeletedAt == nil {
+		db.commentCounts[comment.TaskID]++
+	}
+	if comment.ID >= db.NextCommentID {
+		db.NextCommentID = comment.ID + 1
+	}
+}
+
+// deleteComment removes a comment and keeps the comment counts up to date. The caller must hold the write lock.
+func (db *Database) deleteComment(id int) {
+	if previous, exists := db.Comments[id]; exists && previous.DeletedAt == nil {
+		db.commentCounts[previous.TaskID]--
+	}
+	delete(db.Comments, id)
+}
+
+// deleteTaskComments removes the comments of a deleted task. The caller must hold the write lock.
+func (db *Database) deleteTaskComments(taskID int) {
+	for id, comment := range db.Comments {
+		if comment.TaskID == taskID {
+			delete(db.Comments, id)
+		}
+	}
+	delete(db.commentCounts, taskID)
+}
+
+// withCommentCount returns the task with the number of its comments. The caller must hold the lock.
+func (db *Database) withCommentCount(task Task) Task {
+	task.CommentCount = db.commentCounts[task.ID]
+	return task
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyNotFound is returned when an idempotency key is unknown or expired
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+// IdempotencyKey remembers the outcome of a request sent with an Idempotency-Key header,
+// so a retry of the same request can be answered without repeating it
+type IdempotencyKey struct {
+	Key string `json:"key"`
+	// RequestHash fingerprints the request body, to detect a key reused for a different request
+	RequestHash string `json:"request_hash"`
+	// Task is the task created by the original request
+	Task      Task      `json:"task"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IdempotencyStore is implemented by storage backends that persist idempotency keys
+type IdempotencyStore interface {
+	// GetIdempotencyKey returns the key, or ErrIdempotencyKeyNotFound if it does not exist or expired at now
+	GetIdempotencyKey(ctx context.Context, key string, now time.Time) (IdempotencyKey, error)
+	// PutIdempotencyKey stores the key, replacing an existing one
+	PutIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+	// DeleteExpiredIdempotencyKeys removes every key that expired before now
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) error
+}
+
+// GetIdempotencyKey returns a stored idempotency key that has not expired
+func (db *Database) GetIdempotencyKey(_ context.Context, key string, now time.Time) (IdempotencyKey, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	record, exists := db.IdempotencyKeys[key]
+	if !exists || !now.Before(record.ExpiresAt) {
+		return IdempotencyKey{}, ErrIdempotencyKeyNotFound
+	}
+	return record, nil
+}
+
+// PutIdempotencyKey stores an idempotency key
+func (db *Database) PutIdempotencyKey(_ context.Context, key IdempotencyKey) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	return db.commit(Record{Op: OpPutIdempotencyKey, IdempotencyKey: &key})
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys that expired before now
+func (db *Database) DeleteExpiredIdempotencyKeys(_ context.Context, now time.Time) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	expired := false
+	for _, record := range db.IdempotencyKeys {
+		if !now.Before(record.ExpiresAt) {
+			expired = true
+			break
+		}
+	}
+	if !expired {
+		return nil
+	}
+	return db.commit(Record{Op: OpExpireIdempotencyKeys, Time: &now})
+}
@@ -0,0 +1,430 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Record operations
+const (
+	OpPutTask               = "put_task"
+	OpDeleteTask            = "delete_task"
+	OpPutIdempotencyKey     = "put_idempotency_key"
+	OpExpireIdempotencyKeys = "expire_idempotency_keys"
+	OpPutRevision           = "put_revision"
+	OpPutLabel              = "put_label"
+	OpDeleteLabel           = "delete_label"
+	OpPutLink               = "put_link"
+	OpDeleteLink            = "delete_link"
+	OpPutComment            = "put_comment"
+	OpDeleteComment         = "delete_comment"
+	OpPutAttachment         = "put_attachment"
+	OpDeleteAttachment      = "delete_attachment"
+	OpPutReminder           = "put_reminder"
+	OpDeleteReminder        = "delete_reminder"
+	OpPutWebhook            = "put_webhook"
+	OpDeleteWebhook         = "delete_webhook"
+	OpPutDelivery           = "put_delivery"
+	OpPruneDeliveries       = "prune_deliveries"
+	// OpBatch applies several records atomically
+	OpBatch = "batch"
+)
+
+// Record describes a single mutation of a Database.
+// Records hold the resulting state rather than a delta, so applying one twice is harmless.
+type Record struct {
+	Op             string          `json:"op"`
+	Task           *Task           `json:"task,omitempty"`
+	ID             int             `json:"id,omitempty"`
+	IdempotencyKey *IdempotencyKey `json:"idempotency_key,omitempty"`
+	Time           *time.Time      `json:"time,omitempty"`
+	Revision       *Revision       `json:"revision,omitempty"`
+	Label          *Label          `json:"label,omitempty"`
+	Link           *Link           `json:"link,omitempty"`
+	Comment        *Comment        `json:"comment,omitempty"`
+	Attachment     *Attachment     `json:"attachment,omitempty"`
+	Reminder       *Reminder       `json:"reminder,omitempty"`
+	Webhook        *Webhook        `json:"webhook,omitempty"`
+	Delivery       *Delivery       `json:"delivery,omitempty"`
+	Name           string          `json:"name,omitempty"`
+	Records        []Record        `json:"records,omitempty"`
+}
+
+// Journal persists Database mutations. Append is called with the Database lock held,
+// before the mutation is applied, and must only return once the record is durable.
+type Journal interface {
+	Append(record Record) error
+}
+
+// Snapshot is a point-in-time copy of the whole Database state
+type Snapshot struct {
+	Tasks            []Task           `json:"tasks"`
+	NextID           int              `json:"next_id"`
+	IdempotencyKeys  []IdempotencyKey `json:"idempotency_keys,omitempty"`
+	Revisions        []Revision       `json:"revisions,omitempty"`
+	Labels           []Label          `json:"labels,omitempty"`
+	Links            []Link           `json:"links,omitempty"`
+	NextLinkID       int              `json:"next_link_id,omitempty"`
+	Comments         []Comment        `json:"comments,omitempty"`
+	NextCommentID    int              `json:"next_comment_id,omitempty"`
+	Attachments      []Attachment     `json:"attachments,omitempty"`
+	NextAttachmentID int              `json:"next_attachment_id,omitempty"`
+	Reminders        []Reminder       `json:"reminders,omitempty"`
+	NextReminderID   int              `json:"next_reminder_id,omitempty"`
+	Webhooks         []Webhook        `json:"webhooks,omitempty"`
+	NextWebhookID    int              `json:"next_webhook_id,omitempty"`
+	Deliveries       []Delivery       `json:"deliveries,omitempty"`
+	NextDeliveryID   int              `json:"next_delivery_id,omitempty"`
+}
+
+// SetJournal attaches a journal that receives every subsequent mutation
+func (db *Database) SetJournal(journal Journal) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	db.journal = journal
+}
+
+// Replay applies a record read back from a journal, without journaling it again
+func (db *Database) Replay(record Record) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	return db.apply(record)
+}
+
+// Restore replaces the whole Database state with the snapshot
+func (db *Database) Restore(snapshot Snapshot) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	db.Tasks = make(map[int]*Task, len(snapshot.Tasks))
+	db.labelIndex = make(map[string]map[int]bool)
+	for i := range snapshot.Tasks {
+		task := snapshot.Tasks[i]
+		db.Tasks[task.ID] = &task
+		db.indexTask(&task)
+	}
+	db.NextID = snapshot.NextID
+	if db.NextID < 1 {
+		db.NextID = 1
+	}
+
+	db.IdempotencyKeys = make(map[string]IdempotencyKey, len(snapshot.IdempotencyKeys))
+	for _, key := range snapshot.IdempotencyKeys {
+		db.IdempotencyKeys[key.Key] = key
+	}
+
+	// Revisions are stored in snapshots ordered by task and number
+	db.Revisions = make(map[int][]Revision)
+	for _, revision := range snapshot.Revisions {
+		db.Revisions[revision.TaskID] = append(db.Revisions[revision.TaskID], revision)
+	}
+
+	db.Labels = make(map[string]Label, len(snapshot.Labels))
+	for _, label := range snapshot.Labels {
+		db.Labels[label.Name] = label
+	}
+
+	db.Links = make(map[int]Link, len(snapshot.Links))
+	for _, link := range snapshot.Links {
+		db.Links[link.ID] = link
+	}
+	db.NextLinkID = snapshot.NextLinkID
+	if db.NextLinkID < 1 {
+		db.NextLinkID = 1
+	}
+
+	db.Comments = make(map[int]Comment, len(snapshot.Comments))
+	db.commentCounts = make(map[int]int)
+	for _, comment := range snapshot.Comments {
+		db.putComment(comment)
+	}
+	db.NextCommentID = snapshot.NextCommentID
+	if db.NextCommentID < 1 {
+		db.NextCommentID = 1
+	}
+
+	db.Attachments = make(map[int]Attachment, len(snapshot.Attachments))
+	for _, attachment := range snapshot.Attachments {
+		db.Attachments[attachment.ID] = attachment
+	}
+	db.NextAttachmentID = snapshot.NextAttachmentID
+	if db.NextAttachmentID < 1 {
+		db.NextAttachmentID = 1
+	}
+
+	db.Reminders = make(map[int]Reminder, len(snapshot.Reminders))
+	for _, reminder := range snapshot.Reminders {
+		db.Reminders[reminder.ID] = reminder
+	}
+	db.NextReminderID = snapshot.NextReminderID
+	if db.NextReminderID < 1 {
+		db.NextReminderID = 1
+	}
+
+	db.Webhooks = make(map[int]Webhook, len(snapshot.Webhooks))
+	for _, webhook := range snapshot.Webhooks {
+		db.Webhooks[webhook.ID] = webhook
+	}
+	db.NextWebhookID = snapshot.NextWebhookID
+	if db.NextWebhookID < 1 {
+		db.NextWebhookID = 1
+	}
+	db.Deliveries = make(map[int]Delivery, len(snapshot.Deliveries))
+	for _, delivery := range snapshot.Deliveries {
+		db.Deliveries[delivery.ID] = delivery
+	}
+	db.NextDeliveryID = snapshot.NextDeliveryID
+	if db.NextDeliveryID < 1 {
+		db.NextDeliveryID = 1
+	}
+}
+
+// Checkpoint calls fn with a snapshot of the current state while blocking all mutations,
+// so a journal can persist the snapshot and discard the records it already covers.
+func (db *Database) Checkpoint(fn func(snapshot Snapshot) error) error {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	snapshot := Snapshot{
+		Tasks:  make([]Task, 0, len(db.Tasks)),
+		NextID: db.NextID,
+	}
+	for _, task := range db.Tasks {
+		snapshot.Tasks = append(snapshot.Tasks, *task)
+	}
+	sort.Slice(snapshot.Tasks, func(i, j int) bool { return snapshot.Tasks[i].ID < snapshot.Tasks[j].ID })
+
+	for _, key := range db.IdempotencyKeys {
+		snapshot.IdempotencyKeys = append(snapshot.IdempotencyKeys, key)
+	}
+	sort.Slice(snapshot.IdempotencyKeys, func(i, j int) bool {
+		return snapshot.IdempotencyKeys[i].Key < snapshot.IdempotencyKeys[j].Key
+	})
+
+	taskIDs := make([]int, 0, len(db.Revisions))
+	for taskID := range db.Revisions {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Ints(taskIDs)
+	for _, taskID := range taskIDs {
+		snapshot.Revisions = append(snapshot.Revisions, db.Revisions[taskID]...)
+	}
+
+	for _, label := range db.Labels {
+		snapshot.Labels = append(snapshot.Labels, label)
+	}
+	sort.Slice(snapshot.Labels, func(i, j int) bool { return snapshot.Labels[i].Name < snapshot.Labels[j].Name })
+
+	snapshot.NextLinkID = db.NextLinkID
+	for _, link := range db.Links {
+		snapshot.Links = append(snapshot.Links, link)
+	}
+	sort.Slice(snapshot.Links, func(i, j int) bool { return snapshot.Links[i].ID < snapshot.Links[j].ID })
+
+	snapshot.NextCommentID = db.NextCommentID
+	for _, comment := range db.Comments {
+		snapshot.Comments = append(snapshot.Comments, comment)
+	}
+	sort.Slice(snapshot.Comments, func(i, j int) bool { return snapshot.Comments[i].ID < snapshot.Comments[j].ID })
+
+	snapshot.NextAttachmentID = db.NextAttachmentID
+	for _, attachment := range db.Attachments {
+		snapshot.Attachments = append(snapshot.Attachments, attachment)
+	}
+	sort.Slice(snapshot.Attachments, func(i, j int) bool { return snapshot.Attachments[i].ID < snapshot.Attachments[j].ID })
+
+	snapshot.NextReminderID = db.NextReminderID
+	for _, reminder := range db.Reminders {
+		snapshot.Reminders = append(snapshot.Reminders, reminder)
+	}
+	sort.Slice(snapshot.Reminders, func(i, j int) bool { return snapshot.Reminders[i].ID < snapshot.Reminders[j].ID })
+
+	snapshot.NextWebhookID = db.NextWebhookID
+	for _, webhook := range db.Webhooks {
+		snapshot.Webhooks = append(snapshot.Webhooks, webhook)
+	}
+	sort.Slice(snapshot.Webhooks, func(i, j int) bool { return snapshot.Webhooks[i].ID < snapshot.Webhooks[j].ID })
+	snapshot.NextDeliveryID = db.NextDeliveryID
+	for _, delivery := range db.Deliveries {
+		snapshot.Deliveries = append(snapshot.Deliveries, delivery)
+	}
+	sort.Slice(snapshot.Deliveries, func(i, j int) bool { return snapshot.Deliveries[i].ID < snapshot.Deliveries[j].ID })
+	return fn(snapshot)
+}
+
+// commit writes the record to the journal and applies it. The caller must hold the write lock.
+func (db *Database) commit(record Record) error {
+	if db.journal != nil {
+		if err := db.journal.Append(record); err != nil {
+			return fmt.Errorf("journal %s: %w", record.Op, err)
+		}
+	}
+	return db.apply(record)
+}
+
+// apply changes the state according to the record. The caller must hold the write lock.
+func (db *Database) apply(record Record) error {
+	switch record.Op {
+	case OpPutTask:
+		if record.Task == nil {
+			return fmt.Errorf("%s record without a task", record.Op)
+		}
+		task := *record.Task
+		task.CommentCount = 0
+		if previous, exists := db.Tasks[task.ID]; exists {
+			db.unindexTask(previous)
+		}
+		db.Tasks[task.ID] = &task
+		db.indexTask(&task)
+		if task.ID >= db.NextID {
+			db.NextID = task.ID + 1
+		}
+	case OpDeleteTask:
+		if previous, exists := db.Tasks[record.ID]; exists {
+			db.unindexTask(previous)
+		}
+		delete(db.Tasks, record.ID)
+		db.unlinkTask(record.ID)
+		db.deleteTaskComments(record.ID)
+		db.deleteTaskAttachments(record.ID)
+		db.deleteTaskReminders(record.ID)
+	case OpPutIdempotencyKey:
+		if record.IdempotencyKey == nil {
+			return fmt.Errorf("%s record without an idempotency key", record.Op)
+		}
+		if db.IdempotencyKeys == nil {
+			db.IdempotencyKeys = make(map[string]IdempotencyKey)
+		}
+		db.IdempotencyKeys[record.IdempotencyKey.Key] = *record.IdempotencyKey
+	case OpExpireIdempotencyKeys:
+		if record.Time == nil {
+			return fmt.Errorf("%s record without a time", record.Op)
+		}
+		for key, idempotencyKey := range db.IdempotencyKeys {
+			if !record.Time.Before(idempotencyKey.ExpiresAt) {
+				delete(db.IdempotencyKeys, key)
+			}
+		}
+	case OpPutRevision:
+		if record.Revision == nil {
+			return fmt.Errorf("%s record without a revision", record.Op)
+		}
+		if db.Revisions == nil {
+			db.Revisions = make(map[int][]Revision)
+		}
+		revision := *record.Revision
+		revisions := db.Revisions[revision.TaskID]
+		if revision.Number <= len(revisions) {
+			revisions[revision.Number-1] = revision
+		} else {
+			db.Revisions[revision.TaskID] = append(revisions, revision)
+		}
+	case OpPutLabel:
+		if record.Label == nil {
+			return fmt.Errorf("%s record without a label", record.Op)
+		}
+		if db.Labels == nil {
+			db.Labels = make(map[string]Label)
+		}
+		db.Labels[record.Label.Name] = *record.Label
+	case OpDeleteLabel:
+		delete(db.Labels, record.Name)
+	case OpPutLink:
+		if record.Link == nil {
+			return fmt.Errorf("%s record without a link", record.Op)
+		}
+		if db.Links == nil {
+			db.Links = make(map[int]Link)
+		}
+		db.Links[record.Link.ID] = *record.Link
+		if record.Link.ID >= db.NextLinkID {
+			db.NextLinkID = record.Link.ID + 1
+		}
+	case OpDeleteLink:
+		delete(db.Links, record.ID)
+	case OpPutComment:
+		if record.Comment == nil {
+			return fmt.Errorf("%s record without a comment", record.Op)
+		}
+		if db.Comments == nil {
+			db.Comments = make(map[int]Comment)
+		}
+		if db.commentCounts == nil {
+			db.commentCounts = make(map[int]int)
+		}
+		db.putComment(*record.Comment)
+	case OpDeleteComment:
+		db.deleteComment(record.ID)
+	case OpPutAttachment:
+		if record.Attachment == nil {
+			return fmt.Errorf("%s record without an attachment", record.Op)
+		}
+		if db.Attachments == nil {
+			db.Attachments = make(map[int]Attachment)
+		}
+		db.Attachments[record.Attachment.ID] = *record.Attachment
+		if record.Attachment.ID >= db.NextAttachmentID {
+			db.NextAttachmentID = record.Attachment.ID + 1
+		}
+	case OpDeleteAttachment:
+		delete(db.Attachments, record.ID)
+	case OpPutReminder:
+		if record.Reminder == nil {
+			return fmt.Errorf("%s record without a reminder", record.Op)
+		}
+		if db.Reminders == nil {
+			db.Reminders = make(map[int]Reminder)
+		}
+		db.Reminders[record.Reminder.ID] = *record.Reminder
+		if record.Reminder.ID >= db.NextReminderID {
+			db.NextReminderID = record.Reminder.ID + 1
+		}
+	case OpDeleteReminder:
+		delete(db.Reminders, record.ID)
+	case OpPutWebhook:
+		if record.Webhook == nil {
+			return fmt.Errorf("%s record without a webhook", record.Op)
+		}
+		if db.Webhooks == nil {
+			db.Webhooks = make(map[int]Webhook)
+		}
+		db.Webhooks[record.Webhook.ID] = *record.Webhook
+		if record.Webhook.ID >= db.NextWebhookID {
+			db.NextWebhookID = record.Webhook.ID + 1
+		}
+	case OpDeleteWebhook:
+		db.deleteWebhook(record.ID)
+	case OpPutDelivery:
+		if record.Delivery == nil {
+			return fmt.Errorf("%s record without a delivery", record.Op)
+		}
+		if db.Deliveries == nil {
+			db.Deliveries = make(map[int]Delivery)
+		}
+		db.Deliveries[record.Delivery.ID] = *record.Delivery
+		if record.Delivery.ID >= db.NextDeliveryID {
+			db.NextDeliveryID = record.Delivery.ID + 1
+		}
+	case OpPruneDeliveries:
+		if record.Time == nil {
+			return fmt.Errorf("%s record without a time", record.Op)
+		}
+		for id, delivery := range db.Deliveries {
+			if prunable(delivery, *record.Time) {
+				delete(db.Deliveries, id)
+			}
+		}
+	case OpBatch:
+		for _, nested := range record.Records {
+			if err := db.apply(nested); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown record operation %q", record.Op)
+	}
+	return nil
+}
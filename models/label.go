@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ErrLabelNotFound is returned when the requested label does not exist
+var ErrLabelNotFound = errors.New("label not found")
+
+// Label describes a label that tasks can carry
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+	// TaskCount is the number of tasks carrying the label, outside of the trash. It is never stored.
+	TaskCount int `json:"task_count"`
+}
+
+// LabelStore is implemented by storage backends that support labels.
+// It maintains an index from each label to the tasks carrying it.
+type LabelStore interface {
+	// ListLabels returns every label ordered by name, including the labels carried by tasks
+	// that were never defined
+	ListLabels(ctx context.Context) ([]Label, error)
+	// GetLabel returns the label, or ErrLabelNotFound when it is neither defined nor carried by a task
+	GetLabel(ctx context.Context, name string) (Label, error)
+	// PutLabel creates or replaces a label
+	PutLabel(ctx context.Context, label Label) error
+	// ReplaceLabel replaces the label from with to on every task carrying it, or removes it when to is empty,
+	// calling touch on every changed task. The label from is renamed to to, or merged into it when to exists,
+	// or deleted when to is empty. Everything happens atomically.
+	// It returns the changed tasks before and after the change.
+	ReplaceLabel(ctx context.Context, from, to string, touch func(task *Task)) (before, after []Task, err error)
+	// TaskIDsWithLabels returns the IDs of the tasks carrying all of the labels, in ascending order
+	TaskIDsWithLabels(ctx context.Context, labels []string) ([]int, error)
+}
+
+// ListLabels returns every label ordered by name
+func (db *Database) ListLabels(_ context.Context) ([]Label, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	labels := make([]Label, 0, len(db.Labels))
+	for _, label := range db.Labels {
+		label.TaskCount = db.countTasks(label.Name)
+		labels = append(labels, label)
+	}
+	// Labels carried by tasks exist even without a definition
+	for name := range db.labelIndex {
+		if _, defined := db.Labels[name]; !defined {
+			labels = append(labels, Label{Name: name, TaskCount: db.countTasks(name)})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
+
+// GetLabel returns a label by its name
+func (db *Database) GetLabel(_ context.Context, name string) (Label, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	label, defined := db.Labels[name]
+	if !defined {
+		if len(db.labelIndex[name]) == 0 {
+			return Label{}, ErrLabelNotFound
+		}
+		label = Label{Name: name}
+	}
+	label.TaskCount = db.countTasks(name)
+	return label, nil
+}
+
+// PutLabel creates or replaces a label
+func (db *Database) PutLabel(_ context.Context, label Label) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	label.TaskCount = 0
+	return db.commit(Record{Op: OpPutLabel, Label: &label})
+}
+
+// ReplaceLabel replaces a label on every task carrying it and renames, merges or deletes its definition,
+// as a single journal record
+func (db *Database) ReplaceLabel(_ context.Context, from, to string, touch func(task *Task)) ([]Task, []Task, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	definition, defined := db.Labels[from]
+	ids := db.labelIndex[from]
+	if !defined && len(ids) == 0 {
+		return nil, nil, ErrLabelNotFound
+	}
+
+	var before, after []Task
+	var records []Record
+	for _, id := range sortedIDs(ids) {
+		task := *db.Tasks[id]
+		updated := task
+		updated.Labels = ReplaceLabel(task.Labels, from, to)
+		touch(&updated)
+		before = append(before, task)
+		after = append(after, updated)
+		records = append(records, Record{Op: OpPutTask, Task: &after[len(after)-1]})
+	}
+
+	if _, exists := db.Labels[to]; to != "" && !exists && defined {
+		definition.Name = to
+		records = append(records, Record{Op: OpPutLabel, Label: &definition})
+	}
+	records = append(records, Record{Op: OpDeleteLabel, Name: from})
+
+	if err := db.commit(Record{Op: OpBatch, Records: records}); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// TaskIDsWithLabels returns the IDs of the tasks carrying all of the labels, using the label index
+func (db *Database) TaskIDsWithLabels(_ context.Context, labels []string) ([]int, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	if len(labels) == 0 {
+		return []int{}, nil
+	}
+	// Intersect starting from the smallest set
+	sets := make([]map[int]bool, len(labels))
+	for i, label := range labels {
+		sets[i] = db.labelIndex[label]
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	ids := make([]int, 0, len(sets[0]))
+	for id := range sets[0] {
+		matches := true
+		for _, set := range sets[1:] {
+			if !set[id] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// ReplaceLabel returns labels with from replaced by to, or removed when to is empty, without duplicates
+func ReplaceLabel(labels []string, from, to string) []string {
+	replaced := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label == from {
+			label = to
+		}
+		if label != "" {
+			replaced = append(replaced, label)
+		}
+	}
+	return NormalizeLabels(replaced)
+}
+
+// NormalizeLabels sorts labels and removes duplicates
+func NormalizeLabels(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+	normalized := sorted[:1]
+	for _, label := range sorted[1:] {
+		if label != normalized[len(normalized)-1] {
+			normalized = append(normalized, label)
+		}
+	}
+	return normalized
+}
+
+// NormalizeIDs sorts task IDs and removes duplicates
+func NormalizeIDs(ids []int) []int {
+	if len(ids) == 0 {
+		return nil
+	}
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	normalized := sorted[:1]
+	for _, id := range sorted[1:] {
+		if id != normalized[len(normalized)-1] {
+			normalized = append(normalized, id)
+		}
+	}
+	return normalized
+}
+
+// countTasks returns the number of tasks outside of the trash carrying the label. The caller must hold the lock.
+func (db *Database) countTasks(label string) int {
+	count := 0
+	for id := range db.labelIndex[label] {
+		if db.Tasks[id].DeletedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// indexTask adds the labels of the task to the index. The caller must hold the write lock.
+func (db *Database) indexTask(task *Task) {
+	if db.labelIndex == nil {
+		db.labelIndex = make(map[string]map[int]bool)
+	}
+	for _, label := range task.Labels {
+		if db.labelIndex[label] == nil {
+			db.labelIndex[label] = make(map[int]bool)
+		}
+		db.labelIndex[label][task.ID] = true
+	}
+}
+
+// unindexTask removes the labels of the task from the index. The caller must hold the write lock.
+func (db *Database) unindexTask(task *Task) {
+	for _, label := range task.Labels {
+		delete(db.labelIndex[label], task.ID)
+		if len(db.labelIndex[label]) == 0 {
+			delete(db.labelIndex, label)
+		}
+	}
+}
+
+func sortedIDs(set map[int]bool) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
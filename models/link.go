@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrLinkNotFound is returned when the requested link does not exist
+var ErrLinkNotFound = errors.New("link not found")
+
+// Link is a typed relation from one task to another, e.g. SourceID duplicates TargetID.
+// The inverse relation, e.g. TargetID is duplicated by SourceID, is not stored.
+type Link struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	SourceID  int       `json:"source_id"`
+	TargetID  int       `json:"target_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkStore is implemented by storage backends that support links between tasks.
+// Deleting a task from the store deletes its links along with it.
+type LinkStore interface {
+	// CreateLink stores a new link and assigns it the next available link ID
+	CreateLink(ctx context.Context, link Link) (Link, error)
+	// GetLink returns a link by its ID
+	GetLink(ctx context.Context, id int) (Link, error)
+	// DeleteLink removes a link by its ID
+	DeleteLink(ctx context.Context, id int) error
+	// TaskLinks returns the links from or to a task, ordered by ID
+	TaskLinks(ctx context.Context, taskID int) ([]Link, error)
+}
+
+// CreateLink stores a new link
+func (db *Database) CreateLink(_ context.Context, link Link) (Link, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	link.ID = db.NextLinkID
+	if err := db.commit(Record{Op: OpPutLink, Link: &link}); err != nil {
+		return Link{}, err
+	}
+	return link, nil
+}
+
+// GetLink returns a link by its ID
+func (db *Database) GetLink(_ context.Context, id int) (Link, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	link, exists := db.Links[id]
+	if !exists {
+		return Link{}, ErrLinkNotFound
+	}
+	return link, nil
+}
+
+// DeleteLink removes a link by its ID
+func (db *Database) DeleteLink(_ context.Context, id int) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Links[id]; !exists {
+		return ErrLinkNotFound
+	}
+	return db.commit(Record{Op: OpDeleteLink, ID: id})
+}
+
+// TaskLinks returns the links from or to a task, ordered by ID
+func (db *Database) TaskLinks(_ context.Context, taskID int) ([]Link, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	links := make([]Link, 0)
+	for _, link := range db.Links {
+		if link.SourceID == taskID || link.TargetID == taskID {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+	return links, nil
+}
+
+// unlinkTask removes the links from or to a deleted task. The caller must hold the write lock.
+func (db *Database) unlinkTask(taskID int) {
+	for id, link := range db.Links {
+		if link.SourceID == taskID || link.TargetID == taskID {
+			delete(db.Links, id)
+		}
+	}
+}
@@ -1,18 +1,49 @@
 package models
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
 )
 
 const (
-	JsonID          = "id"
-	JsonTitle       = "title"
-	JsonDescription = "description"
-	JsonStatus      = "status"
-	JsonCreatedAt   = "created_at"
+	JsonID                = "id"
+	JsonTitle             = "title"
+	JsonDescription       = "description"
+	JsonStatus            = "status"
+	JsonCreatedAt         = "created_at"
+	JsonUpdatedAt         = "updated_at"
+	JsonVersion           = "version"
+	JsonDeletedAt         = "deleted_at"
+	JsonDueAt             = "due_at"
+	JsonPriority          = "priority"
+	JsonEffortHours       = "effort_hours"
+	JsonOverdue           = "overdue"
+	JsonLabels            = "labels"
+	JsonParentID          = "parent_id"
+	JsonBlockedBy         = "blocked_by"
+	JsonResolution        = "resolution"
+	JsonCommentCount      = "comment_count"
+	JsonChecklist         = "checklist"
+	JsonChecklistProgress = "checklist_progress"
+	JsonRecurrence        = "recurrence"
+	JsonNextOccurrenceID  = "next_occurrence_id"
 )
 
+// Priorities from the most to the least urgent
+var Priorities = []string{"P0", "P1", "P2", "P3"}
+
+// PriorityRank orders priorities from the most urgent (0). Tasks without a valid priority come last.
+func PriorityRank(priority string) int {
+	for i, p := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return len(Priorities)
+}
+
 // Task represents a task
 type Task struct {
 	ID          int       `json:"id"`
@@ -20,17 +51,241 @@ type Task struct {
 	Description string    `json:"description"`
 	Status      string    `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Version starts at 1 and is incremented by every update
+	Version int `json:"version"`
+	// DeletedAt is set while the task is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// DueAt keeps the time zone offset it was given in
+	DueAt *time.Time `json:"due_at,omitempty"`
+	// Priority is one of Priorities, or empty
+	Priority string `json:"priority,omitempty"`
+	// EffortHours is the estimated effort of the task
+	EffortHours float64 `json:"effort_hours,omitempty"`
+	// Labels are sorted and unique
+	Labels []string `json:"labels,omitempty"`
+	// ParentID is the ID of the task this task is a subtask of
+	ParentID *int `json:"parent_id,omitempty"`
+	// BlockedBy holds the IDs of the tasks blocking this task, sorted and unique
+	BlockedBy []int `json:"blocked_by,omitempty"`
+	// Resolution tells how a done task was closed, e.g. "duplicate". It is cleared when the task is reopened.
+	Resolution string `json:"resolution,omitempty"`
+	// Recurrence makes the task repeat: its due date is an occurrence of the recurrence
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// NextOccurrenceID is the ID of the task created for the next occurrence when this one was completed
+	NextOccurrenceID *int `json:"next_occurrence_id,omitempty"`
+	// Checklist holds the steps of the task in their order
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+	// ChecklistProgress is computed from the checklist when the task is read, for tasks with a checklist. It is never stored.
+	ChecklistProgress *Progress `json:"checklist_progress,omitempty"`
+	// Overdue is computed when the task is read: it is due in the past and not done. It is never stored.
+	Overdue bool `json:"overdue"`
+	// CommentCount is the number of comments on the task, filled by the store when the task is read. It is never stored.
+	CommentCount int `json:"comment_count"`
 }
 
 // Database represents the in-memory storage
 type Database struct {
-	Tasks  map[int]*Task
-	NextID int
-	Mutex  sync.RWMutex
+	Tasks           map[int]*Task
+	NextID          int
+	IdempotencyKeys map[string]IdempotencyKey
+	// Revisions holds the history of every task, including deleted ones
+	Revisions map[int][]Revision
+	// Labels holds the label definitions by name
+	Labels map[string]Label
+	// Links holds the links between tasks by ID
+	Links      map[int]Link
+	NextLinkID int
+	// Comments holds the comments of every task by ID
+	Comments      map[int]Comment
+	NextCommentID int
+	// Attachments holds the attachments of every task by ID
+	Attachments      map[int]Attachment
+	NextAttachmentID int
+	// Reminders holds the reminders of every task by ID
+	Reminders      map[int]Reminder
+	NextReminderID int
+	// Webhooks holds the webhook subscriptions by ID
+	Webhooks      map[int]Webhook
+	NextWebhookID int
+	// Deliveries holds the deliveries of every webhook by ID
+	Deliveries     map[int]Delivery
+	NextDeliveryID int
+	Mutex          sync.RWMutex
+
+	// labelIndex maps each label to the IDs of the tasks carrying it. It is derived from Tasks.
+	labelIndex map[string]map[int]bool
+	// commentCounts maps each task ID to the number of its comments that are not deleted. It is derived from Comments.
+	commentCounts map[int]int
+	// journal, when set, receives every mutation before it is applied
+	journal Journal
 }
 
 // Global instance of the database
 var DB = Database{
-	Tasks:  make(map[int]*Task),
-	NextID: 1,
+	Tasks:            make(map[int]*Task),
+	NextID:           1,
+	IdempotencyKeys:  make(map[string]IdempotencyKey),
+	Revisions:        make(map[int][]Revision),
+	Labels:           make(map[string]Label),
+	Links:            make(map[int]Link),
+	NextLinkID:       1,
+	Comments:         make(map[int]Comment),
+	NextCommentID:    1,
+	Attachments:      make(map[int]Attachment),
+	NextAttachmentID: 1,
+	Reminders:        make(map[int]Reminder),
+	NextReminderID:   1,
+	Webhooks:         make(map[int]Webhook),
+	NextWebhookID:    1,
+	Deliveries:       make(map[int]Delivery),
+	NextDeliveryID:   1,
+	labelIndex:       make(map[string]map[int]bool),
+	commentCounts:    make(map[int]int),
+}
+
+// NewDatabase returns an empty in-memory database
+func NewDatabase() *Database {
+	return &Database{
+		Tasks:            make(map[int]*Task),
+		NextID:           1,
+		IdempotencyKeys:  make(map[string]IdempotencyKey),
+		Revisions:        make(map[int][]Revision),
+		Labels:           make(map[string]Label),
+		Links:            make(map[int]Link),
+		NextLinkID:       1,
+		Comments:         make(map[int]Comment),
+		NextCommentID:    1,
+		Attachments:      make(map[int]Attachment),
+		NextAttachmentID: 1,
+		Reminders:        make(map[int]Reminder),
+		NextReminderID:   1,
+		Webhooks:         make(map[int]Webhook),
+		NextWebhookID:    1,
+		Deliveries:       make(map[int]Delivery),
+		NextDeliveryID:   1,
+		labelIndex:       make(map[string]map[int]bool),
+		commentCounts:    make(map[int]int),
+	}
+}
+
+// Create adds a new task and assigns it the next available ID
+func (db *Database) Create(_ context.Context, task Task) (Task, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	task.ID = db.NextID
+	if err := db.commit(Record{Op: OpPutTask, Task: &task}); err != nil {
+		return Task{}, err
+	}
+	return db.withCommentCount(task), nil
+}
+
+// Recreate stores a deleted task again under its original ID
+func (db *Database) Recreate(_ context.Context, task Task) (Task, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Tasks[task.ID]; exists {
+		return Task{}, ErrTaskExists
+	}
+	if err := db.commit(Record{Op: OpPutTask, Task: &task}); err != nil {
+		return Task{}, err
+	}
+	return db.withCommentCount(task), nil
+}
+
+// Get retrieves a task by its ID
+func (db *Database) Get(_ context.Context, id int) (Task, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	task, exists := db.Tasks[id]
+	if !exists {
+		return Task{}, ErrTaskNotFound
+	}
+	return db.withCommentCount(*task), nil
+}
+
+// List retrieves all tasks ordered by ID
+func (db *Database) List(_ context.Context) ([]Task, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	tasks := make([]Task, 0, len(db.Tasks))
+	for _, task := range db.Tasks {
+		tasks = append(tasks, db.withCommentCount(*task))
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+// Update applies update to a copy of the task and stores it if update succeeds
+func (db *Database) Update(_ context.Context, id int, update func(task *Task) error) (Task, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	task, exists := db.Tasks[id]
+	if !exists {
+		return Task{}, ErrTaskNotFound
+	}
+
+	updated := db.withCommentCount(*task)
+	if err := update(&updated); err != nil {
+		return Task{}, err
+	}
+	updated.ID = id
+	if err := db.commit(Record{Op: OpPutTask, Task: &updated}); err != nil {
+		return Task{}, err
+	}
+	return db.withCommentCount(updated), nil
+}
+
+// UpdateTasks applies update to copies of the tasks and stores them as a single journal record if update succeeds
+// for all of them
+func (db *Database) UpdateTasks(_ context.Context, ids []int, update func(task *Task) error) ([]Task, []Task, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	before := make([]Task, 0, len(ids))
+	after := make([]Task, 0, len(ids))
+	for _, id := range ids {
+		task, exists := db.Tasks[id]
+		if !exists {
+			return nil, nil, ErrTaskNotFound
+		}
+		updated := db.withCommentCount(*task)
+		before = append(before, updated)
+		if err := update(&updated); err != nil {
+			return nil, nil, err
+		}
+		updated.ID = id
+		after = append(after, updated)
+	}
+
+	records := make([]Record, len(after))
+	for i := range after {
+		records[i] = Record{Op: OpPutTask, Task: &after[i]}
+	}
+	if err := db.commit(Record{Op: OpBatch, Records: records}); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// Delete removes a task by its ID if check, when set, accepts it
+func (db *Database) Delete(_ context.Context, id int, check func(task Task) error) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	task, exists := db.Tasks[id]
+	if !exists {
+		return ErrTaskNotFound
+	}
+	if check != nil {
+		if err := check(db.withCommentCount(*task)); err != nil {
+			return err
+		}
+	}
+	return db.commit(Record{Op: OpDeleteTask, ID: id})
 }
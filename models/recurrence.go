@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Recurrence makes a task repeat. Completing an occurrence creates the next one.
+type Recurrence struct {
+	// RRule is an RFC 5545 recurrence rule, e.g. "FREQ=WEEKLY;BYDAY=MO"
+	RRule string `json:"rrule"`
+	// Timezone is the IANA time zone the rule is evaluated in, e.g. "Europe/Paris". Empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Start is the first occurrence of the series (DTSTART). It gives the time of day of every occurrence.
+	Start time.Time `json:"start"`
+	// ExDates are the occurrences that are skipped (EXDATE)
+	ExDates []time.Time `json:"exdates,omitempty"`
+}
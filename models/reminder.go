@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrReminderNotFound is returned when the requested reminder does not exist
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// Duration is a time.Duration written in JSON as a Go duration string, e.g. "1h30m"
+type Duration time.Duration
+
+// MarshalText writes the duration as a Go duration string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a Go duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Reminder notifies someone through a channel some time before a task is due
+type Reminder struct {
+	ID     int `json:"id"`
+	TaskID int `json:"task_id"`
+	// Before is how long before the due date of the task the reminder fires, e.g. "24h" or "1h"
+	Before Duration `json:"before"`
+	// Channel is the name of the notifier the reminder is sent through, e.g. "log", "webhook" or "email"
+	Channel string `json:"channel"`
+	// Recipient is who the notification is sent to, e.g. an email address. Empty means the default of the channel.
+	Recipient string `json:"recipient,omitempty"`
+	// SnoozedUntil, when set, replaces the time the reminder fires at
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// FiredFor is the fire time the reminder last fired for. The reminder fires again when its fire time
+	// changes, e.g. because the task was rescheduled or the reminder snoozed.
+	FiredFor *time.Time `json:"fired_for,omitempty"`
+	FiredAt  *time.Time `json:"fired_at,omitempty"`
+	// Failures counts the failed attempts to send the reminder for FiredFor, and LastError describes the last one
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	// RetryAt is when a reminder that failed to send is attempted again. It is nil once the reminder was sent or
+	// given up.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	// FireAt is computed when the reminder is read: the next time it fires, if any. It is never stored.
+	FireAt    *time.Time `json:"fire_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Notification is what a Notifier sends when a reminder fires
+type Notification struct {
+	Reminder Reminder `json:"reminder"`
+	Task     Task     `json:"task"`
+}
+
+// Notifier delivers the notifications of a reminder channel
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// RecipientChecker is implemented by notifiers that restrict who notifications may be sent to
+type RecipientChecker interface {
+	// CheckRecipient returns an error when reminders may not be sent to recipient
+	CheckRecipient(recipient string) error
+}
+
+// ReminderStore is implemented by storage backends that support reminders.
+// Deleting a task from the store deletes its reminders along with it.
+type ReminderStore interface {
+	// CreateReminder stores a new reminder and assigns it the next available reminder ID
+	CreateReminder(ctx context.Context, reminder Reminder) (Reminder, error)
+	// GetReminder returns a reminder by its ID
+	GetReminder(ctx context.Context, id int) (Reminder, error)
+	// UpdateReminder atomically applies update to a reminder and stores it if update succeeds
+	UpdateReminder(ctx context.Context, id int, update func(reminder *Reminder) error) (Reminder, error)
+	// DeleteReminder removes a reminder by its ID
+	DeleteReminder(ctx context.Context, id int) error
+	// ListReminders returns the reminders of a task ordered by ID
+	ListReminders(ctx context.Context, taskID int) ([]Reminder, error)
+	// AllReminders returns the reminders of every task ordered by ID
+	AllReminders(ctx context.Context) ([]Reminder, error)
+}
+
+// CreateReminder stores a new reminder
+func (db *Database) CreateReminder(_ context.Context, reminder Reminder) (Reminder, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	reminder.ID = db.NextReminderID
+	reminder.FireAt = nil
+	if err := db.commit(Record{Op: OpPutReminder, Reminder: &reminder}); err != nil {
+		return Reminder{}, err
+	}
+	return reminder, nil
+}
+
+// GetReminder returns a reminder by its ID
+func (db *Database) GetReminder(_ context.Context, id int) (Reminder, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	reminder, exists := db.Reminders[id]
+	if !exists {
+		return Reminder{}, ErrReminderNotFound
+	}
+	return reminder, nil
+}
+
+// UpdateReminder applies update to a copy of the reminder and stores it if update succeeds
+func (db *Database) UpdateReminder(_ context.Context, id int, update func(reminder *Reminder) error) (Reminder, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	reminder, exists := db.Reminders[id]
+	if !exists {
+		return Reminder{}, ErrReminderNotFound
+	}
+	if err := update(&reminder); err != nil {
+		return Reminder{}, err
+	}
+	reminder.ID = id
+	reminder.FireAt = nil
+	if err := db.commit(Record{Op: OpPutReminder, Reminder: &reminder}); err != nil {
+		return Reminder{}, err
+	}
+	return reminder, nil
+}
+
+// DeleteReminder removes a reminder by its ID
+func (db *Database) DeleteReminder(_ context.Context, id int) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Reminders[id]; !exists {
+		return ErrReminderNotFound
+	}
+	return db.commit(Record{Op: OpDeleteReminder, ID: id})
+}
+
+// ListReminders returns the reminders of a task ordered by ID
+func (db *Database) ListReminders(_ context.Context, taskID int) ([]Reminder, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	reminders := make([]Reminder, 0)
+	for _, reminder := range db.Reminders {
+		if reminder.TaskID == taskID {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].ID < reminders[j].ID })
+	return reminders, nil
+}
+
+// AllReminders returns the reminders of every task ordered by ID
+func (db *Database) AllReminders(_ context.Context) ([]Reminder, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	reminders := make([]Reminder, 0, len(db.Reminders))
+	for _, reminder := range db.Reminders {
+		reminders = append(reminders, reminder)
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].ID < reminders[j].ID })
+	return reminders, nil
+}
+
+// deleteTaskReminders removes the reminders of a deleted task. The caller must hold the write lock.
+func (db *Database) deleteTaskReminders(taskID int) {
+	for id, reminder := range db.Reminders {
+		if reminder.TaskID == taskID {
+			delete(db.Reminders, id)
+		}
+	}
+}
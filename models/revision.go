@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Revision actions
+const (
+	RevisionCreated = "created"
+	RevisionUpdated = "updated"
+	// RevisionDeleted moves a task to the trash
+	RevisionDeleted = "deleted"
+	// RevisionReverted replaces the fields of a task with those of an earlier revision
+	RevisionReverted = "reverted"
+	// RevisionRestored brings a task back from the trash, or a purged task with its original ID
+	RevisionRestored = "restored"
+	// RevisionPurged permanently removes a task
+	RevisionPurged = "purged"
+)
+
+// Revision is an immutable record of a single change of a task
+type Revision struct {
+	TaskID int `json:"task_id"`
+	// Number starts at 1 for the first revision of a task and is incremented by every change
+	Number    int           `json:"revision"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Changes   []FieldChange `json:"changes"`
+	// Task is the task after the change, or before it when it was purged
+	Task Task `json:"task"`
+	// RevertedTo is the number of the revision the task was reverted to, if any
+	RevertedTo int `json:"reverted_to,omitempty"`
+	// Undoes is the number of the revision of the same task this revision undid, if any
+	Undoes int `json:"undoes,omitempty"`
+}
+
+// FieldChange is the old and new JSON value of a changed task field.
+// From is omitted for a created field and To for a deleted one.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from,omitempty"`
+	To    json.RawMessage `json:"to,omitempty"`
+}
+
+// HistoryStore is implemented by storage backends that persist task revisions
+type HistoryStore interface {
+	// AppendRevision stores the revision, numbering it after the latest revision of its task
+	AppendRevision(ctx context.Context, revision Revision) (Revision, error)
+	// ListRevisions returns the revisions of a task ordered by number, even after the task was deleted
+	ListRevisions(ctx context.Context, taskID int) ([]Revision, error)
+	// ListRevisionsByActor returns the revisions made by an actor across all tasks, oldest first
+	ListRevisionsByActor(ctx context.Context, actor string) ([]Revision, error)
+}
+
+// AppendRevision stores a revision of a task
+func (db *Database) AppendRevision(_ context.Context, revision Revision) (Revision, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	revision.Number = len(db.Revisions[revision.TaskID]) + 1
+	if err := db.commit(Record{Op: OpPutRevision, Revision: &revision}); err != nil {
+		return Revision{}, err
+	}
+	return revision, nil
+}
+
+// ListRevisions returns the revisions of a task
+func (db *Database) ListRevisions(_ context.Context, taskID int) ([]Revision, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	return append([]Revision(nil), db.Revisions[taskID]...), nil
+}
+
+// ListRevisionsByActor returns the revisions made by an actor
+func (db *Database) ListRevisionsByActor(_ context.Context, actor string) ([]Revision, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	var revisions []Revision
+	for _, taskRevisions := range db.Revisions {
+		for _, revision := range taskRevisions {
+			if revision.Actor == actor {
+				revisions = append(revisions, revision)
+			}
+		}
+	}
+	SortRevisions(revisions)
+	return revisions, nil
+}
+
+// SortRevisions orders revisions of different tasks by the time they were made
+func SortRevisions(revisions []Revision) {
+	sort.Slice(revisions, func(i, j int) bool {
+		a, b := revisions[i], revisions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.TaskID != b.TaskID {
+			return a.TaskID < b.TaskID
+		}
+		return a.Number < b.Number
+	})
+}
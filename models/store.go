@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTaskNotFound is returned by a TaskStore when the requested task does not exist
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrTaskExists is returned by TaskStore.Recreate when a task with the same ID already exists
+var ErrTaskExists = errors.New("task already exists")
+
+// TaskStore is the storage backend used by the services package.
+// Implementations must be safe for concurrent use.
+type TaskStore interface {
+	// Create stores a new task, assigns its ID and returns the stored task
+	Create(ctx context.Context, task Task) (Task, error)
+	// Recreate stores a deleted task again under its original ID, or returns ErrTaskExists
+	Recreate(ctx context.Context, task Task) (Task, error)
+	// Get returns the task with the given ID or ErrTaskNotFound
+	Get(ctx context.Context, id int) (Task, error)
+	// List returns all tasks ordered by ID
+	List(ctx context.Context) ([]Task, error)
+	// Update applies update to the task with the given ID atomically and returns the result.
+	// If update returns an error, the task is left untouched and the error is returned.
+	Update(ctx context.Context, id int, update func(task *Task) error) (Task, error)
+	// UpdateTasks applies update to each of the tasks with the given IDs atomically and returns them before and after
+	// the change, in the order of ids. If a task does not exist or update returns an error, no task is changed.
+	UpdateTasks(ctx context.Context, ids []int, update func(task *Task) error) (before, after []Task, err error)
+	// Delete removes the task with the given ID or returns ErrTaskNotFound.
+	// If check is not nil it is called with the task first, atomically with the removal,
+	// and an error returned by it aborts the removal.
+	Delete(ctx context.Context, id int, check func(task Task) error) error
+}
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Delivery statuses
+const (
+	// DeliveryPending deliveries are waiting for their next attempt
+	DeliveryPending = "pending"
+	// DeliverySucceeded deliveries were acknowledged with a 2xx response
+	DeliverySucceeded = "succeeded"
+	// DeliveryDead deliveries failed every attempt and wait in the dead-letter list for a manual redelivery
+	DeliveryDead = "dead"
+)
+
+var (
+	// ErrWebhookNotFound is returned when the requested webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotFound is returned when the requested webhook delivery does not exist
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Webhook subscribes a URL to task events
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Events are the event types the webhook receives, e.g. "task.created"
+	Events []string `json:"events"`
+	// Secret is the key the payloads are signed with. It is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeliveryAttempt is a single attempt to deliver an event to a webhook
+type DeliveryAttempt struct {
+	At time.Time `json:"at"`
+	// StatusCode is the status of the response, absent when no response was received
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Delivery is an event sent, or to be sent, to a webhook
+type Delivery struct {
+	ID        int    `json:"id"`
+	WebhookID int    `json:"webhook_id"`
+	Event     string `json:"event"`
+	TaskID    int    `json:"task_id"`
+	// Payload is the JSON body posted to the webhook
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+	// Failures counts the failed attempts since the delivery was created or last redelivered
+	Failures      int               `json:"failures"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at,omitempty"`
+}
+
+// DeliveryFilter selects the deliveries listed by WebhookStore.ListDeliveries
+type DeliveryFilter struct {
+	// WebhookID, when not zero, only selects the deliveries of this webhook
+	WebhookID int
+	// Status, when set, only selects the deliveries with this status
+	Status string
+	// Limit, when positive, is the maximum number of deliveries returned
+	Limit int
+}
+
+// WebhookStore is implemented by storage backends that support webhooks.
+// Deleting a webhook deletes its deliveries along with it.
+type WebhookStore interface {
+	// CreateWebhook stores a new webhook and assigns it the next available webhook ID
+	CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error)
+	// GetWebhook returns a webhook by its ID
+	GetWebhook(ctx context.Context, id int) (Webhook, error)
+	// UpdateWebhook atomically applies update to a webhook and stores it if update succeeds
+	UpdateWebhook(ctx context.Context, id int, update func(webhook *Webhook) error) (Webhook, error)
+	// DeleteWebhook removes a webhook and its deliveries
+	DeleteWebhook(ctx context.Context, id int) error
+	// ListWebhooks returns every webhook ordered by ID
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	// CreateDelivery stores a new delivery and assigns it the next available delivery ID
+	CreateDelivery(ctx context.Context, delivery Delivery) (Delivery, error)
+	// GetDelivery returns a delivery by its ID
+	GetDelivery(ctx context.Context, id int) (Delivery, error)
+	// UpdateDelivery atomically applies update to a delivery and stores it if update succeeds
+	UpdateDelivery(ctx context.Context, id int, update func(delivery *Delivery) error) (Delivery, error)
+	// ListDeliveries returns the deliveries selected by the filter, most recent first
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]Delivery, error)
+	// DueDeliveries returns the pending deliveries whose next attempt is not after now, the most overdue first
+	DueDeliveries(ctx context.Context, now time.Time) ([]Delivery, error)
+	// PruneDeliveries removes the succeeded deliveries created before the cutoff and returns how many were removed
+	PruneDeliveries(ctx context.Context, before time.Time) (int, error)
+}
+
+// CreateWebhook stores a new webhook
+func (db *Database) CreateWebhook(_ context.Context, webhook Webhook) (Webhook, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	webhook.ID = db.NextWebhookID
+	if err := db.commit(Record{Op: OpPutWebhook, Webhook: &webhook}); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// GetWebhook returns a webhook by its ID
+func (db *Database) GetWebhook(_ context.Context, id int) (Webhook, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	webhook, exists := db.Webhooks[id]
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook applies update to a copy of the webhook and stores it if update succeeds
+func (db *Database) UpdateWebhook(_ context.Context, id int, update func(webhook *Webhook) error) (Webhook, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	webhook, exists := db.Webhooks[id]
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	webhook.Events = append([]string(nil), webhook.Events...)
+	if err := update(&webhook); err != nil {
+		return Webhook{}, err
+	}
+	webhook.ID = id
+	if err := db.commit(Record{Op: OpPutWebhook, Webhook: &webhook}); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries
+func (db *Database) DeleteWebhook(_ context.Context, id int) error {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	return db.commit(Record{Op: OpDeleteWebhook, ID: id})
+}
+
+// ListWebhooks returns every webhook ordered by ID
+func (db *Database) ListWebhooks(_ context.Context) ([]Webhook, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	webhooks := make([]Webhook, 0, len(db.Webhooks))
+	for _, webhook := range db.Webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+// CreateDelivery stores a new delivery
+func (db *Database) CreateDelivery(_ context.Context, delivery Delivery) (Delivery, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	delivery.ID = db.NextDeliveryID
+	if err := db.commit(Record{Op: OpPutDelivery, Delivery: &delivery}); err != nil {
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// GetDelivery returns a delivery by its ID
+func (db *Database) GetDelivery(_ context.Context, id int) (Delivery, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	delivery, exists := db.Deliveries[id]
+	if !exists {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// UpdateDelivery applies update to a copy of the delivery and stores it if update succeeds
+func (db *Database) UpdateDelivery(_ context.Context, id int, update func(delivery *Delivery) error) (Delivery, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	delivery, exists := db.Deliveries[id]
+	if !exists {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	delivery.Attempts = append([]DeliveryAttempt(nil), delivery.Attempts...)
+	if err := update(&delivery); err != nil {
+		return Delivery{}, err
+	}
+	delivery.ID = id
+	if err := db.commit(Record{Op: OpPutDelivery, Delivery: &delivery}); err != nil {
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// ListDeliveries returns the deliveries selected by the filter, most recent first
+func (db *Database) ListDeliveries(_ context.Context, filter DeliveryFilter) ([]Delivery, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	deliveries := make([]Delivery, 0)
+	for _, delivery := range db.Deliveries {
+		if (filter.WebhookID == 0 || delivery.WebhookID == filter.WebhookID) &&
+			(filter.Status == "" || delivery.Status == filter.Status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if filter.Limit > 0 && len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+// DueDeliveries returns the pending deliveries whose next attempt is not after now, the most overdue first
+func (db *Database) DueDeliveries(_ context.Context, now time.Time) ([]Delivery, error) {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	deliveries := make([]Delivery, 0)
+	for _, delivery := range db.Deliveries {
+		if delivery.Status == DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(*deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries, nil
+}
+
+// PruneDeliveries removes the succeeded deliveries created before the cutoff
+func (db *Database) PruneDeliveries(_ context.Context, before time.Time) (int, error) {
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	pruned := 0
+	for _, delivery := range db.Deliveries {
+		if prunable(delivery, before) {
+			pruned++
+		}
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	if err := db.commit(Record{Op: OpPruneDeliveries, Time: &before}); err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+// prunable reports whether PruneDeliveries removes the delivery
+func prunable(delivery Delivery, before time.Time) bool {
+	return delivery.Status == DeliverySucceeded && delivery.CreatedAt.Before(before)
+}
+
+// deleteWebhook removes a webhook and its deliveries. The caller must hold the write lock.
+func (db *Database) deleteWebhook(id int) {
+	delete(db.Webhooks, id)
+	for deliveryID, delivery := range db.Deliveries {
+		if delivery.WebhookID == id {
+			delete(db.Deliveries, deliveryID)
+		}
+	}
+}
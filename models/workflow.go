@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Status categories group the workflow statuses
+const (
+	CategoryOpen       = "open"
+	CategoryInProgress = "in_progress"
+	CategoryDone       = "done"
+)
+
+// WorkflowStatus is a status a task can be in
+type WorkflowStatus struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// Workflow declares the task statuses and the allowed transitions between them
+type Workflow struct {
+	Statuses []WorkflowStatus `json:"statuses"`
+	// Transitions maps a status to the statuses a task can move to from it.
+	// Staying in the same status is always allowed.
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// DefaultWorkflow is used when no workflow is configured. It allows every transition.
+func DefaultWorkflow() Workflow {
+	statuses := []WorkflowStatus{
+		{Name: "TODO", Category: CategoryOpen},
+		{Name: "in-progress", Category: CategoryInProgress},
+		{Name: "Pending", Category: CategoryOpen},
+		{Name: "Completed", Category: CategoryDone},
+	}
+
+	transitions := make(map[string][]string, len(statuses))
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if from.Name != to.Name {
+				transitions[from.Name] = append(transitions[from.Name], to.Name)
+			}
+		}
+	}
+	return Workflow{Statuses: statuses, Transitions: transitions}
+}
+
+// Validate checks that the statuses are unique and valid and that transitions only reference them
+func (w Workflow) Validate() error {
+	if len(w.Statuses) == 0 {
+		return errors.New("workflow must declare at least one status")
+	}
+
+	seen := make(map[string]bool, len(w.Statuses))
+	for _, status := range w.Statuses {
+		if status.Name == "" {
+			return errors.New("workflow status name is required")
+		}
+		if seen[strings.ToLower(status.Name)] {
+			return fmt.Errorf("workflow status %q is declared more than once", status.Name)
+		}
+		seen[strings.ToLower(status.Name)] = true
+
+		switch status.Category {
+		case CategoryOpen, CategoryInProgress, CategoryDone:
+		default:
+			return fmt.Errorf("workflow status %q has invalid category %q", status.Name, status.Category)
+		}
+	}
+
+	for from, targets := range w.Transitions {
+		if _, ok := w.findStatus(from); !ok {
+			return fmt.Errorf("workflow transition from unknown status %q", from)
+		}
+		for _, to := range targets {
+			if _, ok := w.findStatus(to); !ok {
+				return fmt.Errorf("workflow transition from %q to unknown status %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Status looks up a status by name, case-insensitively
+func (w Workflow) Status(name string) (WorkflowStatus, bool) {
+	return w.findStatus(name)
+}
+
+// StatusNames returns the names of the statuses in declaration order
+func (w Workflow) StatusNames() []string {
+	names := make([]string, len(w.Statuses))
+	for i, status := range w.Statuses {
+		names[i] = status.Name
+	}
+	return names
+}
+
+// Allows reports whether a task may move from one status to another
+func (w Workflow) Allows(from, to string) bool {
+	fromStatus, ok := w.findStatus(from)
+	if !ok {
+		// Tasks in a status that is no longer declared may move anywhere, so they are not stuck
+		return true
+	}
+	toStatus, ok := w.findStatus(to)
+	if !ok {
+		return false
+	}
+	if fromStatus.Name == toStatus.Name {
+		return true
+	}
+	for source, targets := range w.Transitions {
+		if !strings.EqualFold(source, fromStatus.Name) {
+			continue
+		}
+		for _, target := range targets {
+			if strings.EqualFold(target, toStatus.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w Workflow) findStatus(name string) (WorkflowStatus, bool) {
+	for _, status := range w.Statuses {
+		if strings.EqualFold(status.Name, name) {
+			return status, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
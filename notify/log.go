@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+	"github.com/ofirmad/task-manager/models"
+	"log"
+	"strings"
+)
+
+// LogNotifier writes notifications to a logger
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier returns a LogNotifier writing to logger, or to the standard logger when logger is nil
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+// Notify logs the notification
+func (n *LogNotifier) Notify(_ context.Context, notification models.Notification) error {
+	_, body := Message(notification)
+	if recipient := notification.Reminder.Recipient; recipient != "" {
+		n.logger.Printf("reminder for %s: %s", recipient, strings.TrimSpace(body))
+	} else {
+		n.logger.Printf("reminder: %s", strings.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Package notify implements the models.Notifier channels reminders are sent through
+package notify
+
+import (
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"strings"
+	"time"
+)
+
+// Message returns the subject and the plain text body describing a notification
+func Message(notification models.Notification) (subject, body string) {
+	task := notification.Task
+	// Titles are written into mail headers and log lines, so they must stay on a single line
+	title := strings.Join(strings.Fields(task.Title), " ")
+	subject = fmt.Sprintf("Reminder: %s", title)
+	if task.DueAt == nil {
+		return subject, fmt.Sprintf("Task %d %q\n", task.ID, title)
+	}
+	return subject, fmt.Sprintf("Task %d %q is due at %s\n", task.ID, title, task.DueAt.Format(time.RFC1123Z))
+}
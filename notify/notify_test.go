@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	"log"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+// email is a message received by the SMTP stand-in
+type email struct {
+	from    string
+	to      []string
+	message string
+}
+
+// serveSMTP runs a minimal SMTP server accepting a single message on a local port.
+// It returns the address of the server and a channel receiving the message.
+func serveSMTP() (string, <-chan email) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(func() { _ = listener.Close() })
+
+	received := make(chan email, 1)
+	go func() {
+		defer GinkgoRecover()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		var current email
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				current.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				current.to = append(current.to, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				current.message = data.String()
+				received <- current
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+var _ = Describe("Notifier Tests", func() {
+	var notification models.Notification
+
+	BeforeEach(func() {
+		dueAt := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+		notification = models.Notification{
+			Reminder: models.Reminder{ID: 1, TaskID: 7, Before: models.Duration(time.Hour), Channel: "test"},
+			Task:     models.Task{ID: 7, Title: "Ship the\nrelease", DueAt: &dueAt},
+		}
+	})
+
+	It("should describe a notification on a single subject line", func() {
+		subject, body := Message(notification)
+		Expect(subject).To(Equal("Reminder: Ship the release"))
+		Expect(body).To(Equal("Task 7 \"Ship the release\" is due at Mon, 02 Mar 2026 09:00:00 +0000\n"))
+	})
+
+	Describe("LogNotifier", func() {
+		It("should log the notification with its recipient", func() {
+			var output bytes.Buffer
+			notification.Reminder.Recipient = "alice"
+
+			Expect(NewLogNotifier(log.New(&output, "", 0)).Notify(context.Background(), notification)).To(Succeed())
+			Expect(output.String()).To(Equal("reminder for alice: Task 7 \"Ship the release\" is due at Mon, 02 Mar 2026 09:00:00 +0000\n"))
+		})
+	})
+
+	Describe("WebhookNotifier", func() {
+		It("should post the reminder and the task as JSON", func() {
+			var payload WebhookPayload
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.Method).To(Equal(http.MethodPost))
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+				Expect(json.NewDecoder(r.Body).Decode(&payload)).To(Succeed())
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			DeferCleanup(server.Close)
+
+			Expect(NewWebhookNotifier(server.URL, nil).Notify(context.Background(), notification)).To(Succeed())
+			Expect(payload.Event).To(Equal("reminder"))
+			Expect(payload.Reminder.ID).To(Equal(1))
+			Expect(payload.Reminder.Before).To(Equal(models.Duration(time.Hour)))
+			Expect(payload.Task.ID).To(Equal(7))
+		})
+
+		It("should fail when the webhook does not respond with 2xx", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			DeferCleanup(server.Close)
+
+			err := NewWebhookNotifier(server.URL, nil).Notify(context.Background(), notification)
+			Expect(err).To(MatchError(ContainSubstring("status 502")))
+		})
+	})
+
+	Describe("SMTPNotifier", func() {
+		It("should send the notification to the recipient of the reminder", func() {
+			addr, received := serveSMTP()
+			notification.Reminder.Recipient = "Alice <alice@example.com>"
+			notifier := NewSMTPNotifier(addr, nil, "tasks@example.com", "team@example.com", nil)
+
+			Expect(notifier.Notify(context.Background(), notification)).To(Succeed())
+			var sent email
+			Eventually(received).Should(Receive(&sent))
+			Expect(sent.from).To(Equal("tasks@example.com"))
+			Expect(sent.to).To(Equal([]string{"alice@example.com"}))
+
+			message, err := mail.ReadMessage(strings.NewReader(sent.message))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message.Header.Get("Subject")).To(Equal("Reminder: Ship the release"))
+			Expect(message.Header.Get("To")).To(Equal(`"Alice" <alice@example.com>`))
+			body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring(`Task 7 "Ship the release" is due at`))
+		})
+
+		It("should fall back to the default recipient", func() {
+			addr, received := serveSMTP()
+			notifier := NewSMTPNotifier(addr, nil, "tasks@example.com", "team@example.com", nil)
+
+			Expect(notifier.Notify(context.Background(), notification)).To(Succeed())
+			var sent email
+			Eventually(received).Should(Receive(&sent))
+			Expect(sent.to).To(Equal([]string{"team@example.com"}))
+		})
+
+		It("should only accept the default and allowed addresses as recipients", func() {
+			notifier := NewSMTPNotifier("127.0.0.1:1", nil, "tasks@example.com", "team@example.com", []string{"Ops <ops@example.com>"})
+
+			Expect(notifier.CheckRecipient("Team <TEAM@example.com>")).To(Succeed())
+			Expect(notifier.CheckRecipient("ops@example.com")).To(Succeed())
+			Expect(notifier.CheckRecipient("anyone@example.net")).To(MatchError(ErrRecipientNotAllowed))
+			Expect(notifier.CheckRecipient("not an address")).To(MatchError(ContainSubstring("invalid recipient")))
+		})
+
+		It("should give up on a server that does not answer", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(func() { _ = listener.Close() })
+			notifier := NewSMTPNotifier(listener.Addr().String(), nil, "tasks@example.com", "team@example.com", nil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			DeferCleanup(cancel)
+			Expect(notifier.Notify(ctx, notification)).To(MatchError(os.ErrDeadlineExceeded))
+		})
+
+		It("should fail without any recipient", func() {
+			notifier := NewSMTPNotifier("127.0.0.1:1", nil, "tasks@example.com", "", nil)
+			Expect(notifier.Notify(context.Background(), notification)).To(MatchError(ErrNoRecipient))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds the whole exchange with the SMTP server, from dialing to QUIT
+const smtpTimeout = 30 * time.Second
+
+var (
+	// ErrNoRecipient is returned when sending an email for a reminder without a recipient to a notifier without a default one
+	ErrNoRecipient = errors.New("no recipient for the email")
+	// ErrRecipientNotAllowed is returned for a recipient that is neither the default one nor an allowed one
+	ErrRecipientNotAllowed = errors.New("emails may only be sent to the default or allowed recipients")
+)
+
+// SMTPNotifier sends notifications as plain text emails through an SMTP server
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   string
+	// allowed are the lower-cased addresses reminders may be sent to, including to
+	allowed map[string]bool
+}
+
+// NewSMTPNotifier returns an SMTPNotifier sending emails from the from address through the server at addr (host:port).
+// Emails go to the recipient of the reminder, or to the to address when the reminder has none. A recipient must be the
+// to address or one of the allowed addresses (see CheckRecipient).
+// auth may be nil for servers that do not require authentication.
+func NewSMTPNotifier(addr string, auth smtp.Auth, from, to string, allowed []string) *SMTPNotifier {
+	n := &SMTPNotifier{addr: addr, auth: auth, from: from, to: to, allowed: make(map[string]bool, len(allowed)+1)}
+	for _, address := range append([]string{to}, allowed...) {
+		if parsed, err := mail.ParseAddress(address); err == nil {
+			n.allowed[strings.ToLower(parsed.Address)] = true
+		}
+	}
+	return n
+}
+
+// CheckRecipient returns ErrRecipientNotAllowed unless recipient is the default recipient or one of the allowed ones,
+// so the server cannot be used to send emails to anyone
+func (n *SMTPNotifier) CheckRecipient(recipient string) error {
+	parsed, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", recipient, err)
+	}
+	if !n.allowed[strings.ToLower(parsed.Address)] {
+		return ErrRecipientNotAllowed
+	}
+	return nil
+}
+
+// Notify sends the notification as an email. The exchange with the server is bounded by smtpTimeout, or the deadline
+// of the context when it is sooner.
+func (n *SMTPNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	to := notification.Reminder.Recipient
+	if to == "" {
+		to = n.to
+	}
+	if to == "" {
+		return ErrNoRecipient
+	}
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", to, err)
+	}
+	sender, err := mail.ParseAddress(n.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", n.from, err)
+	}
+
+	subject, body := Message(notification)
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", sender)
+	fmt.Fprintf(&message, "To: %s\r\n", recipient)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	encoder := quotedprintable.NewWriter(&message)
+	if _, err := encoder.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	return n.send(ctx, sender.Address, recipient.Address, message.Bytes())
+}
+
+// send does what smtp.SendMail does, with a deadline: smtp.SendMail can wait forever for an unresponsive server
+func (n *SMTPNotifier) send(ctx context.Context, from, to string, message []byte) error {
+	deadline := time.Now().Add(smtpTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	host, _, _ := net.SplitHostPort(n.addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(message); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds a webhook request when the client has no timeout of its own
+const webhookTimeout = 10 * time.Second
+
+// WebhookPayload is the JSON body posted by a WebhookNotifier
+type WebhookPayload struct {
+	Event    string          `json:"event"`
+	Reminder models.Reminder `json:"reminder"`
+	Task     models.Task     `json:"task"`
+}
+
+// WebhookNotifier posts notifications as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier posting to url with client, or with http.DefaultClient when client is nil
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookNotifier{url: url, client: client}
+}
+
+// Notify posts the notification and fails unless the response status is 2xx
+func (n *WebhookNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	body, err := json.Marshal(WebhookPayload{Event: "reminder", Reminder: notification.Reminder, Task: notification.Task})
+	if err != nil {
+		return err
+	}
+	if n.client.Timeout == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -2,7 +2,7 @@
 import React from 'react';
 /* eslint-enable no-unused-vars */
 import { useState, useEffect } from 'react';
-import { fetchTasks, addTask, updateTask, deleteTask } from './api/tasks';
+import { fetchTasks, fetchWorkflow, addTask, updateTask, deleteTask, undo, subscribeToTaskEvents } from './api/tasks';
 import TaskList from './components/TaskList';
 import TaskForm from './components/TaskForm';
 
@@ -10,11 +10,44 @@ const App = () => {
     const [tasks, setTasks] = useState([]);
     const [editingTask, setEditingTask] = useState(null);
     const [formKey, setFormKey] = useState(0); // Key to reset the form
+    const [formErrors, setFormErrors] = useState({}); // Field errors returned by the server
+    const [statuses, setStatuses] = useState(undefined); // Statuses declared by the server workflow
+
+    // Collect the field errors of a problem+json validation response, keyed by field
+    const fieldErrors = (error) => Object.fromEntries(
+        (error.response?.data?.errors || []).map((fieldError) => [fieldError.field, fieldError.message]),
+    );
 
     useEffect(() => {
         loadTasks();
+        loadWorkflow();
+    }, []);
+
+    // Apply the changes made by others as they happen instead of refetching the tasks
+    useEffect(() => {
+        const unsubscribe = subscribeToTaskEvents(applyTaskEvent, loadTasks);
+        return () => unsubscribe?.();
     }, []);
 
+    const applyTaskEvent = (event) => {
+        if (event.event === 'task.deleted') {
+            setTasks((current) => current.filter((task) => task.id !== event.task_id));
+            return;
+        }
+        setTasks((current) => (current.some((task) => task.id === event.task_id)
+            ? current.map((task) => (task.id === event.task_id ? event.task : task))
+            : [...current, event.task]));
+    };
+
+    const loadWorkflow = async () => {
+        try {
+            const response = await fetchWorkflow();
+            setStatuses(response.data.statuses);
+        } catch (error) {
+            console.error('Error fetching workflow:', error);
+        }
+    };
+
     const loadTasks = async () => {
         try {
             const response = await fetchTasks();
@@ -29,8 +62,10 @@ const App = () => {
             const response = await addTask(task);
             setTasks([...tasks, response.data]);
             setEditingTask(null); // Clear the editing state
+            setFormErrors({});
             setFormKey(formKey + 1); // Reset the form
         } catch (error) {
+            setFormErrors(fieldErrors(error));
             console.error('Error adding task:', error);
         }
     };
@@ -40,8 +75,22 @@ const App = () => {
             const response = await updateTask(updatedTask.id, updatedTask);
             setTasks(tasks.map((task) => (task.id === updatedTask.id ? response.data : task)));
             setEditingTask(null); // Clear the editing state
+            setFormErrors({});
             setFormKey(formKey + 1); // Reset the form
         } catch (error) {
+            if (error.response?.status === 412) {
+                window.alert('This task was changed by someone else. The latest version has been loaded.');
+                setEditingTask(null);
+                setFormKey(formKey + 1);
+                loadTasks();
+                return;
+            }
+            if (error.response?.status === 409) {
+                // The workflow does not allow the status change
+                setFormErrors({ status: error.response.data.detail });
+                return;
+            }
+            setFormErrors(fieldErrors(error));
             console.error('Error updating task:', error);
         }
     };
@@ -51,19 +100,42 @@ const App = () => {
             await deleteTask(id);
             setTasks(tasks.filter((task) => task.id !== id));
         } catch (error) {
+            if (error.response?.data?.code === 'has_subtasks') {
+                if (window.confirm('This task has subtasks. Delete them too?')) {
+                    await deleteTask(id, 'cascade');
+                    loadTasks();
+                }
+                return;
+            }
             console.error('Error deleting task:', error);
         }
     };
 
+    const handleUndo = async () => {
+        try {
+            await undo();
+            loadTasks();
+        } catch (error) {
+            if (error.response?.status === 409) {
+                window.alert(error.response.data.detail);
+                return;
+            }
+            console.error('Error undoing the last change:', error);
+        }
+    };
+
     return (
         <div className="App">
             <h1>Task Manager</h1>
-            <TaskList tasks={tasks} onEdit={(task) => { setEditingTask(task); setFormKey(formKey + 1); }} onDelete={handleDeleteTask} />
+            <button onClick={handleUndo}>Undo</button>
+            <TaskList tasks={tasks} onEdit={(task) => { setEditingTask(task); setFormErrors({}); setFormKey(formKey + 1); }} onDelete={handleDeleteTask} />
             <TaskForm
                 key={formKey} // Reset the form whenever the key changes
                 task={editingTask}
+                errors={formErrors}
+                statuses={statuses}
                 onSave={editingTask ? handleUpdateTask : handleAddTask}
-                onCancel={() => { setEditingTask(null); setFormKey(formKey + 1); }}
+                onCancel={() => { setEditingTask(null); setFormErrors({}); setFormKey(formKey + 1); }}
             />
         </div>
     );
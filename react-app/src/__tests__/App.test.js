@@ -16,9 +16,13 @@ const tasks = [
 describe('App', () => {
     beforeEach(() => {
         api.fetchTasks.mockResolvedValue({ data: tasks });
+        api.fetchWorkflow.mockResolvedValue({ data: { statuses: [{ name: 'TODO', category: 'open' }, { name: 'Completed', category: 'done' }], transitions: {} } });
         api.addTask.mockResolvedValue({ data: tasks[0] });
         api.updateTask.mockResolvedValue({ data: tasks[0] });
         api.deleteTask.mockResolvedValue(null);
+        api.undo.mockResolvedValue({ data: {} });
+        api.fetchAttachments.mockResolvedValue({ data: [] });
+        api.subscribeToTaskEvents.mockReturnValue(() => {});
     });
 
     test('loads and displays tasks', async () => {
@@ -85,4 +89,50 @@ describe('App', () => {
             expect(screen.queryByText('First Task')).not.toBeInTheDocument();
         });
     });
-});
\ No newline at end of file
+
+    test('should offer to delete the subtasks of a task along with it', async () => {
+        api.deleteTask.mockRejectedValueOnce({ response: { status: 409, data: { code: 'has_subtasks' } } });
+        jest.spyOn(window, 'confirm').mockReturnValue(true);
+        await act(async () => {
+            render(<App />);
+        });
+
+        await act(async () => {
+            fireEvent.click(screen.getAllByText('Delete')[0]);
+        });
+
+        expect(api.deleteTask).toHaveBeenLastCalledWith(1, 'cascade');
+        window.confirm.mockRestore();
+    });
+
+    test('should apply the task events streamed by the server', async () => {
+        await act(async () => {
+            render(<App />);
+        });
+        const [onEvent] = api.subscribeToTaskEvents.mock.calls[0];
+
+        act(() => {
+            onEvent({ event: 'task.created', task_id: 3, task: { id: 3, title: 'Third Task', description: 'Third Description', status: 'TODO' } });
+            onEvent({ event: 'task.updated', task_id: 1, task: { ...tasks[0], title: 'Renamed Task' } });
+            onEvent({ event: 'task.deleted', task_id: 2, task: tasks[1] });
+        });
+
+        expect(screen.getByText('Third Task')).toBeInTheDocument();
+        expect(screen.getByText('Renamed Task')).toBeInTheDocument();
+        expect(screen.queryByText('Second Task')).not.toBeInTheDocument();
+    });
+
+    test('should reload the tasks after undoing the last change', async () => {
+        await act(async () => {
+            render(<App />);
+        });
+
+        api.fetchTasks.mockClear();
+        await act(async () => {
+            fireEvent.click(screen.getByText('Undo'));
+        });
+
+        expect(api.undo).toHaveBeenCalled();
+        expect(api.fetchTasks).toHaveBeenCalledTimes(1);
+    });
+});
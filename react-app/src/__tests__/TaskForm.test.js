@@ -46,6 +46,25 @@ describe('TaskForm', () => {
         });
     });
 
+    test('should offer the statuses of the workflow', () => {
+        const statuses = [{ name: 'Backlog', category: 'open' }, { name: 'Done', category: 'done' }];
+        render(<TaskForm statuses={statuses} onSave={onSave} onCancel={onCancel} />);
+
+        expect(screen.getByDisplayValue('Backlog')).toBeInTheDocument();
+        const options = Array.from(screen.getByDisplayValue('Backlog').options);
+        expect(options.map((option) => option.value)).toEqual(['Backlog', 'Done']);
+    });
+
+    test('should save the comma separated labels', () => {
+        const task = { title: 'Test Task', description: 'Test Description', status: 'TODO', labels: ['backend'] };
+        render(<TaskForm task={task} onSave={onSave} onCancel={onCancel} />);
+
+        fireEvent.change(screen.getByDisplayValue('backend'), { target: { value: 'backend, urgent ,' } });
+        fireEvent.submit(screen.getByRole('button', { name: /save/i }));
+
+        expect(onSave).toHaveBeenCalledWith({ ...task, labels: ['backend', 'urgent'] });
+    });
+
     test('should call onCancel when cancel button is clicked', () => {
         render(<TaskForm onSave={onSave} onCancel={onCancel} />);
 
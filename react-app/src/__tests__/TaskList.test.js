@@ -3,12 +3,16 @@ import React from 'react';
 /* eslint-enable no-unused-vars */
 import { render, screen, fireEvent } from '@testing-library/react';
 import TaskList from '../components/TaskList';
+import * as api from '../api/tasks';
 import { describe, test, beforeEach, jest, expect } from '@jest/globals';
 
+jest.mock('../api/tasks');
+
 describe('TaskList', () => {
     const tasks = [
-        { id: 1, title: 'First Task', description: 'First Description', status: 'TODO' },
-        { id: 2, title: 'Second Task', description: 'Second Description', status: 'Completed' },
+        { id: 1, title: 'First Task', description: 'First Description', status: 'TODO', recurrence: { rrule: 'FREQ=WEEKLY;BYDAY=FR', timezone: 'Europe/Paris' } },
+        { id: 2, title: 'Second Task', description: 'Second Description', status: 'Completed', resolution: 'duplicate' },
+        { id: 3, title: 'Third Task', description: 'Third Description', status: 'TODO', priority: 'P0', due_at: '2020-01-01T09:00:00Z', overdue: true, labels: ['backend', 'urgent'], blocked_by: [1, 2], comment_count: 3, checklist_progress: { completed: 1, total: 3, percent: 33.3 } },
     ];
 
     const onEdit = jest.fn();
@@ -17,6 +21,9 @@ describe('TaskList', () => {
     beforeEach(() => {
         onEdit.mockClear();
         onDelete.mockClear();
+        api.fetchAttachments.mockClear();
+        api.fetchAttachments.mockResolvedValue({ data: [] });
+        api.thumbnailUrl.mockImplementation((id, attachmentId) => `/tasks/${id}/attachments/${attachmentId}/thumbnail?size=64`);
     });
 
     test('renders tasks correctly', () => {
@@ -45,4 +52,78 @@ describe('TaskList', () => {
 
         expect(onDelete).toHaveBeenCalledWith(1);
     });
-});
\ No newline at end of file
+
+    test('shows the priority and flags overdue tasks', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Priority: P0')).toBeInTheDocument();
+        expect(screen.getByText(/\(overdue\)/)).toHaveClass('overdue');
+    });
+
+    test('shows the labels of a task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Labels: backend, urgent')).toBeInTheDocument();
+    });
+
+    test('shows the resolution of a closed task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Resolution: duplicate')).toBeInTheDocument();
+    });
+
+    test('shows the tasks blocking a task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Blocked by: #1, #2')).toBeInTheDocument();
+    });
+
+    test('shows thumbnails of the image attachments of a task', async () => {
+        api.fetchAttachments.mockImplementation((id) => Promise.resolve({
+            data: id === 1 ? [
+                { id: 1, task_id: 1, filename: 'shot.png', content_type: 'image/png' },
+                { id: 2, task_id: 1, filename: 'build.log', content_type: 'text/plain; charset=utf-8' },
+            ] : [],
+        }));
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+        expect(api.fetchAttachments).not.toHaveBeenCalled();
+
+        fireEvent.click(screen.getAllByText('Show images')[0]);
+
+        const preview = await screen.findByAltText('shot.png');
+        expect(preview).toHaveAttribute('src', '/tasks/1/attachments/1/thumbnail?size=64');
+        expect(screen.queryByAltText('build.log')).not.toBeInTheDocument();
+        expect(api.fetchAttachments).toHaveBeenCalledTimes(1);
+        expect(api.fetchAttachments).toHaveBeenCalledWith(1);
+    });
+
+    test('tells when a task has no image attachments', async () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        fireEvent.click(screen.getAllByText('Show images')[1]);
+
+        expect(await screen.findByText('No images')).toBeInTheDocument();
+        expect(api.fetchAttachments).toHaveBeenCalledWith(2);
+    });
+
+    test('shows the number of comments of a task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Comments: 3')).toBeInTheDocument();
+        expect(screen.getAllByText(/^Comments:/)).toHaveLength(1);
+    });
+
+    test('shows the checklist progress of a task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Checklist: 1/3')).toBeInTheDocument();
+        expect(screen.getAllByText(/^Checklist:/)).toHaveLength(1);
+    });
+
+    test('shows the recurrence rule of a recurring task', () => {
+        render(<TaskList tasks={tasks} onEdit={onEdit} onDelete={onDelete} />);
+
+        expect(screen.getByText('Repeats: FREQ=WEEKLY;BYDAY=FR')).toBeInTheDocument();
+        expect(screen.getAllByText(/^Repeats:/)).toHaveLength(1);
+    });
+});
@@ -1,8 +1,49 @@
 import axios from 'axios';
 
 const API_URL = 'http://localhost:8080/tasks';
+const WORKFLOW_URL = 'http://localhost:8080/workflow';
+const UNDO_URL = 'http://localhost:8080/undo';
+const USER_KEY = 'task-manager-user';
+
+// Identifies this browser in the task history, so undo only reverses its own changes
+const currentUser = () => {
+    let user = localStorage.getItem(USER_KEY);
+    if (!user) {
+        user = `user-${Math.random().toString(36).slice(2)}`;
+        localStorage.setItem(USER_KEY, user);
+    }
+    return user;
+};
+
+const withUser = (headers = {}) => ({ headers: { ...headers, 'X-User': currentUser() } });
 
 export const fetchTasks = () => axios.get(API_URL);
-export const addTask = (task) => axios.post(API_URL, task);
-export const updateTask = (id, task) => axios.put(`${API_URL}/${id}`, task);
-export const deleteTask = (id) => axios.delete(`${API_URL}/${id}`);
+export const addTask = (task) => axios.post(API_URL, task, withUser());
+// The task version is sent as If-Match so a concurrent edit is rejected (412) instead of being overwritten
+export const updateTask = (id, task) => axios.put(
+    `${API_URL}/${id}`,
+    task,
+    withUser(task.version ? { 'If-Match': `"${task.version}"` } : {}),
+);
+// children decides what happens to the subtasks: reject (the default), cascade or orphan
+export const deleteTask = (id, children) => axios.delete(`${API_URL}/${id}`, {
+    ...withUser(),
+    params: children ? { children } : undefined,
+});
+// Reverses the most recent create, update or delete made from this browser
+export const undo = () => axios.post(UNDO_URL, null, withUser());
+export const fetchWorkflow = () => axios.get(WORKFLOW_URL);
+export const fetchAttachments = (id) => axios.get(`${API_URL}/${id}/attachments`);
+// Thumbnails are generated by the server for PNG, JPEG and GIF attachments at 64, 128 or 256 pixels
+export const thumbnailUrl = (id, attachmentId, size = 64) => `${API_URL}/${id}/attachments/${attachmentId}/thumbnail?size=${size}`;
+// Streams the changes made to the tasks by everyone, calling onEvent with each task.created, task.updated and
+// task.deleted event. EventSource reconnects by itself and resumes after the last event it received; onReset is
+// called when the server no longer has the events missed in between, so the tasks must be reloaded.
+export const subscribeToTaskEvents = (onEvent, onReset) => {
+    const source = new EventSource(`${API_URL}/events`);
+    ['task.created', 'task.updated', 'task.deleted'].forEach((type) => {
+        source.addEventListener(type, (message) => onEvent(JSON.parse(message.data)));
+    });
+    source.addEventListener('reset', onReset);
+    return () => source.close();
+};
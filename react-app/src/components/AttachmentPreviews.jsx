@@ -0,0 +1,39 @@
+/* eslint-disable no-unused-vars */
+import React from 'react';
+/* eslint-enable no-unused-vars */
+import { useState } from 'react';
+import PropTypes from 'prop-types';
+import { fetchAttachments, thumbnailUrl } from '../api/tasks';
+
+// Content types the server generates thumbnails for
+const PREVIEW_TYPES = ['image/png', 'image/jpeg', 'image/gif'];
+
+// Shows the thumbnails of the image attachments of a task. The attachments are only fetched when the user asks for
+// them, so listing the tasks does not send a request per task.
+const AttachmentPreviews = ({ taskId }) => {
+    const [images, setImages] = useState(null);
+
+    const loadImages = () => {
+        fetchAttachments(taskId)
+            .then((response) => setImages(response.data.filter((attachment) => PREVIEW_TYPES.includes(attachment.content_type))))
+            .catch((error) => console.error('Error fetching attachments:', error));
+    };
+
+    if (images === null) {
+        return <button onClick={loadImages}>Show images</button>;
+    }
+    if (images.length === 0) return <p>No images</p>;
+    return (
+        <div className="attachment-previews">
+            {images.map((image) => (
+                <img key={image.id} src={thumbnailUrl(taskId, image.id)} alt={image.filename} />
+            ))}
+        </div>
+    );
+};
+
+AttachmentPreviews.propTypes = {
+    taskId: PropTypes.number.isRequired,
+};
+
+export default AttachmentPreviews;
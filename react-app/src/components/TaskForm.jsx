@@ -4,11 +4,35 @@ import React from 'react';
 import { useState, useEffect } from 'react';
 import PropTypes from 'prop-types';
 
-const TaskForm = ({ task, onSave, onCancel }) => {
-    const [formData, setFormData] = useState({ title: '', description: '', status: 'TODO' });
+// Used until the workflow has been loaded from GET /workflow
+const DEFAULT_STATUSES = [
+    { name: 'TODO', category: 'open' },
+    { name: 'in-progress', category: 'in_progress' },
+    { name: 'Pending', category: 'open' },
+    { name: 'Completed', category: 'done' },
+];
+
+const PRIORITIES = ['P0', 'P1', 'P2', 'P3'];
+
+// Splits the comma separated labels input
+const parseLabels = (value) => value.split(',').map((label) => label.trim()).filter(Boolean);
+
+// Converts an RFC 3339 time to the local "YYYY-MM-DDTHH:mm" value of a datetime-local input
+const toLocalInput = (value) => {
+    if (!value) return '';
+    const date = new Date(value);
+    return new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+};
+
+const TaskForm = ({ task, errors = {}, statuses = DEFAULT_STATUSES, onSave, onCancel }) => {
+    const [formData, setFormData] = useState({ title: '', description: '', status: statuses[0]?.name || '' });
+    const [labelsText, setLabelsText] = useState('');
 
     useEffect(() => {
-        if (task) setFormData(task);
+        if (task) {
+            setFormData(task);
+            setLabelsText((task.labels || []).join(', '));
+        }
     }, [task]);
 
     const handleSubmit = (e) => {
@@ -24,21 +48,66 @@ const TaskForm = ({ task, onSave, onCancel }) => {
                 value={formData.title}
                 onChange={(e) => setFormData({ ...formData, title: e.target.value })}
             />
+            {errors.title && <span className="field-error">{errors.title}</span>}
             <input
                 type="text"
                 placeholder="Description"
                 value={formData.description}
                 onChange={(e) => setFormData({ ...formData, description: e.target.value })}
             />
+            {errors.description && <span className="field-error">{errors.description}</span>}
             <select
                 value={formData.status}
                 onChange={(e) => setFormData({ ...formData, status: e.target.value })}
             >
-                <option value="TODO">TODO</option>
-                <option value="in-progress">In Progress</option>
-                <option value="Pending">Pending</option>
-                <option value="Completed">Completed</option>
+                {statuses.map((status) => (
+                    <option key={status.name} value={status.name}>{status.name}</option>
+                ))}
+            </select>
+            {errors.status && <span className="field-error">{errors.status}</span>}
+            <select
+                aria-label="Priority"
+                value={formData.priority || ''}
+                onChange={(e) => setFormData({ ...formData, priority: e.target.value || undefined })}
+            >
+                <option value="">No priority</option>
+                {PRIORITIES.map((priority) => (
+                    <option key={priority} value={priority}>{priority}</option>
+                ))}
             </select>
+            {errors.priority && <span className="field-error">{errors.priority}</span>}
+            <input
+                type="datetime-local"
+                aria-label="Due"
+                value={toLocalInput(formData.due_at)}
+                onChange={(e) => setFormData({
+                    ...formData,
+                    due_at: e.target.value ? new Date(e.target.value).toISOString() : undefined,
+                })}
+            />
+            <input
+                type="number"
+                min="0"
+                step="0.5"
+                placeholder="Effort (hours)"
+                value={formData.effort_hours || ''}
+                onChange={(e) => setFormData({
+                    ...formData,
+                    effort_hours: e.target.value ? Number(e.target.value) : undefined,
+                })}
+            />
+            {errors.effort_hours && <span className="field-error">{errors.effort_hours}</span>}
+            <input
+                type="text"
+                placeholder="Labels (comma separated)"
+                value={labelsText}
+                onChange={(e) => {
+                    const labels = parseLabels(e.target.value);
+                    setLabelsText(e.target.value);
+                    setFormData({ ...formData, labels: labels.length ? labels : undefined });
+                }}
+            />
+            {errors.labels && <span className="field-error">{errors.labels}</span>}
             <button type="submit">Save</button>
             {onCancel && (
                 <button type="button" onClick={onCancel}>
@@ -51,6 +120,11 @@ const TaskForm = ({ task, onSave, onCancel }) => {
 
 TaskForm.propTypes = {
     task: PropTypes.object,
+    errors: PropTypes.objectOf(PropTypes.string),
+    statuses: PropTypes.arrayOf(PropTypes.shape({
+        name: PropTypes.string.isRequired,
+        category: PropTypes.string,
+    })),
     onSave: PropTypes.func.isRequired,
     onCancel: PropTypes.func.isRequired,
 };
@@ -2,6 +2,7 @@
 import React from 'react';
 /* eslint-enable no-unused-vars */
 import PropTypes from 'prop-types';
+import AttachmentPreviews from './AttachmentPreviews';
 
 const TaskList = ({ tasks, onEdit, onDelete }) => (
     <ul>
@@ -11,6 +12,22 @@ const TaskList = ({ tasks, onEdit, onDelete }) => (
                 <p>ID: {task.id}</p>
                 <p>Description: {task.description}</p>
                 <p>Status: {task.status}</p>
+                {task.resolution && <p>Resolution: {task.resolution}</p>}
+                {task.parent_id && <p>Subtask of: #{task.parent_id}</p>}
+                {task.blocked_by?.length > 0 && <p>Blocked by: {task.blocked_by.map((id) => `#${id}`).join(', ')}</p>}
+                {task.priority && <p>Priority: {task.priority}</p>}
+                {task.recurrence && <p>Repeats: {task.recurrence.rrule}</p>}
+                {task.labels?.length > 0 && <p>Labels: {task.labels.join(', ')}</p>}
+                {task.comment_count > 0 && <p>Comments: {task.comment_count}</p>}
+                {task.checklist_progress && (
+                    <p>Checklist: {task.checklist_progress.completed}/{task.checklist_progress.total}</p>
+                )}
+                {task.due_at && (
+                    <p className={task.overdue ? 'overdue' : undefined}>
+                        Due: {new Date(task.due_at).toLocaleString()}{task.overdue && ' (overdue)'}
+                    </p>
+                )}
+                <AttachmentPreviews taskId={task.id} />
                 <button onClick={() => onEdit(task)}>Edit</button>
                 <button onClick={() => onDelete(task.id)}>Delete</button>
             </li>
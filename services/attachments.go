@@ -0,0 +1,365 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxAttachmentSize is the largest attachment accepted unless configured otherwise
+	DefaultMaxAttachmentSize = 10 << 20
+
+	// maxFilenameLength is the length in bytes filenames are truncated to
+	maxFilenameLength = 255
+	// defaultFilename names the attachments uploaded without a usable filename
+	defaultFilename = "attachment"
+	// sniffLength is the number of bytes http.DetectContentType considers
+	sniffLength = 512
+)
+
+var (
+	// ErrAttachmentNotFound is returned when the requested attachment does not exist or does not belong to the task
+	ErrAttachmentNotFound = models.ErrAttachmentNotFound
+	// ErrAttachmentTooLarge is returned when an attachment exceeds the maximum attachment size
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	// ErrUploadConflict is returned when the blob of an attachment was collected before the attachment was recorded
+	ErrUploadConflict = errors.New("the attachment content was collected during the upload, retry it")
+	// ErrAttachmentsUnsupported is returned when the store does not implement models.AttachmentStore
+	// or no blob store is configured
+	ErrAttachmentsUnsupported = errors.New("attachments are not supported")
+)
+
+// SetBlobStore sets the store keeping the contents of the attachments
+func (s *TaskService) SetBlobStore(blobs models.BlobStore) {
+	s.attachmentsMutex.Lock()
+	defer s.attachmentsMutex.Unlock()
+
+	s.blobs = blobs
+}
+
+// SetMaxAttachmentSize sets the largest attachment accepted, in bytes
+func (s *TaskService) SetMaxAttachmentSize(size int64) {
+	s.attachmentsMutex.Lock()
+	defer s.attachmentsMutex.Unlock()
+
+	s.maxAttachmentSize = size
+}
+
+// MaxAttachmentSize returns the largest attachment accepted, in bytes
+func (s *TaskService) MaxAttachmentSize() int64 {
+	s.attachmentsMutex.RLock()
+	defer s.attachmentsMutex.RUnlock()
+
+	return s.maxAttachmentSize
+}
+
+// blobStore returns the blob store, or ErrAttachmentsUnsupported when attachments cannot be stored
+func (s *TaskService) blobStore() (models.BlobStore, error) {
+	s.attachmentsMutex.RLock()
+	defer s.attachmentsMutex.RUnlock()
+
+	if s.attachments == nil || s.blobs == nil {
+		return nil, ErrAttachmentsUnsupported
+	}
+	return s.blobs, nil
+}
+
+// ListAttachments returns the attachments of a task ordered by ID
+func (s *TaskService) ListAttachments(ctx context.Context, taskID int) ([]models.Attachment, error) {
+	if _, err := s.blobStore(); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return s.attachments.ListAttachments(ctx, taskID)
+}
+
+// GetAttachment returns an attachment of a task
+func (s *TaskService) GetAttachment(ctx context.Context, taskID, id int) (models.Attachment, error) {
+	if _, err := s.blobStore(); err != nil {
+		return models.Attachment{}, err
+	}
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return models.Attachment{}, err
+	}
+	return s.taskAttachment(ctx, taskID, id)
+}
+
+// OpenAttachment returns an attachment of a task and its content. The caller must close the content.
+func (s *TaskService) OpenAttachment(ctx context.Context, taskID, id int) (models.Attachment, io.ReadSeekCloser, error) {
+	blobs, err := s.blobStore()
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	attachment, err := s.GetAttachment(ctx, taskID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	content, err := blobs.Open(ctx, attachment.SHA256)
+	if err != nil {
+		return models.Attachment{}, nil, fmt.Errorf("open attachment %d: %w", id, err)
+	}
+	return attachment, content, nil
+}
+
+// AddAttachment stores the content read from r as an attachment of the task.
+// Its content type is sniffed from the content, and the uploader is the actor of the context.
+// It returns ErrAttachmentTooLarge when the content exceeds the maximum attachment size, and ErrUploadConflict when
+// a concurrent collection deleted the content before the attachment was recorded.
+func (s *TaskService) AddAttachment(ctx context.Context, taskID int, filename string, r io.Reader) (models.Attachment, error) {
+	blobs, err := s.blobStore()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return models.Attachment{}, err
+	}
+
+	content := &limitedReader{r: r, remaining: s.MaxAttachmentSize()}
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return models.Attachment{}, err
+	}
+	head = head[:n]
+
+	// The content is streamed without the write mutex, so slow uploads do not hold up other changes
+	digest, size, err := blobs.Put(ctx, io.MultiReader(bytes.NewReader(head), content))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		s.collectBlobsLocked(ctx, digest)
+		return models.Attachment{}, err
+	}
+	// The blob is not referenced until the attachment is created, so it may have been collected since it was stored.
+	// Collections hold the write mutex, so it cannot be collected anymore once it is found.
+	stored, err := blobs.Open(ctx, digest)
+	if errors.Is(err, models.ErrBlobNotFound) {
+		return models.Attachment{}, ErrUploadConflict
+	} else if err != nil {
+		return models.Attachment{}, err
+	}
+	_ = stored.Close()
+
+	attachment, err := s.attachments.CreateAttachment(ctx, models.Attachment{
+		TaskID:      taskID,
+		Filename:    attachmentFilename(filename),
+		ContentType: http.DetectContentType(head),
+		Size:        size,
+		SHA256:      digest,
+		Uploader:    ActorFromContext(ctx),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		s.collectBlobsLocked(ctx, digest)
+		return models.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment deletes an attachment of a task, and its blob unless another attachment has the same content
+func (s *TaskService) DeleteAttachment(ctx context.Context, taskID, id int) error {
+	if _, err := s.blobStore(); err != nil {
+		return err
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return err
+	}
+	attachment, err := s.taskAttachment(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.attachments.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+	s.collectBlobsLocked(ctx, attachment.SHA256)
+	return nil
+}
+
+// CollectBlobs deletes every blob no attachment references, e.g. blobs of attachments whose metadata was lost,
+// and returns how many were deleted
+func (s *TaskService) CollectBlobs(ctx context.Context) (int, error) {
+	blobs, err := s.blobStore()
+	if err != nil {
+		return 0, err
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	digests, err := blobs.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	referenced, err := s.attachments.ReferencedBlobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	collected := 0
+	for _, digest := range digests {
+		if referenced[digest] {
+			continue
+		}
+		if err := blobs.Delete(ctx, digest); err != nil {
+			return collected, err
+		}
+		collected++
+	}
+	return collected, nil
+}
+
+// taskAttachmentDigests returns the digests of the blobs of a task's attachments,
+// or nil when attachments are not supported
+func (s *TaskService) taskAttachmentDigests(ctx context.Context, taskID int) ([]string, error) {
+	if _, err := s.blobStore(); err != nil {
+		return nil, nil
+	}
+	attachments, err := s.attachments.ListAttachments(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	digests := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		digests[i] = attachment.SHA256
+	}
+	return digests, nil
+}
+
+// collectBlobsLocked deletes the blobs among digests that no attachment references anymore.
+// Failures are only logged: the attachments are already gone, and CollectBlobs deletes the blobs left behind.
+// The caller must hold the write mutex.
+func (s *TaskService) collectBlobsLocked(ctx context.Context, digests ...string) {
+	if len(digests) == 0 {
+		return
+	}
+	blobs, err := s.blobStore()
+	if err != nil {
+		return
+	}
+	referenced, err := s.attachments.ReferencedBlobs(ctx)
+	if err != nil {
+		log.Printf("failed to collect blobs: %v", err)
+		return
+	}
+	for _, digest := range digests {
+		if referenced[digest] {
+			continue
+		}
+		if err := blobs.Delete(ctx, digest); err != nil {
+			log.Printf("failed to delete blob %s: %v", digest, err)
+		}
+	}
+}
+
+// taskAttachment returns an attachment if it belongs to the task
+func (s *TaskService) taskAttachment(ctx context.Context, taskID, id int) (models.Attachment, error) {
+	attachment, err := s.attachments.GetAttachment(ctx, id)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if attachment.TaskID != taskID {
+		return models.Attachment{}, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// attachmentFilename strips the directories and control characters from an uploaded filename
+// and truncates it to maxFilenameLength bytes
+func attachmentFilename(filename string) string {
+	filename = filename[strings.LastIndexAny(filename, `/\`)+1:]
+	filename = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, filename))
+	for len(filename) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+	if filename == "" || filename == "." || filename == ".." {
+		return defaultFilename
+	}
+	return filename
+}
+
+// limitedReader reads from r until remaining bytes were read, then fails with ErrAttachmentTooLarge
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
+
+// SetBlobStore sets the store keeping the contents of the attachments of the default service
+func SetBlobStore(blobs models.BlobStore) {
+	defaultService.SetBlobStore(blobs)
+}
+
+// SetMaxAttachmentSize sets the largest attachment accepted by the default service
+func SetMaxAttachmentSize(size int64) {
+	defaultService.SetMaxAttachmentSize(size)
+}
+
+// MaxAttachmentSize returns the largest attachment accepted by the default service
+func MaxAttachmentSize() int64 {
+	return defaultService.MaxAttachmentSize()
+}
+
+// ListAttachments returns the attachments of a task using the default service
+func ListAttachments(ctx context.Context, taskID int) ([]models.Attachment, error) {
+	return defaultService.ListAttachments(ctx, taskID)
+}
+
+// GetAttachment returns an attachment of a task using the default service
+func GetAttachment(ctx context.Context, taskID, id int) (models.Attachment, error) {
+	return defaultService.GetAttachment(ctx, taskID, id)
+}
+
+// OpenAttachment returns an attachment of a task and its content using the default service
+func OpenAttachment(ctx context.Context, taskID, id int) (models.Attachment, io.ReadSeekCloser, error) {
+	return defaultService.OpenAttachment(ctx, taskID, id)
+}
+
+// AddAttachment attaches a file to a task using the default service
+func AddAttachment(ctx context.Context, taskID int, filename string, r io.Reader) (models.Attachment, error) {
+	return defaultService.AddAttachment(ctx, taskID, filename, r)
+}
+
+// DeleteAttachment deletes an attachment of a task using the default service
+func DeleteAttachment(ctx context.Context, taskID, id int) error {
+	return defaultService.DeleteAttachment(ctx, taskID, id)
+}
+
+// CollectBlobs deletes the blobs no attachment references using the default service
+func CollectBlobs(ctx context.Context) (int, error) {
+	return defaultService.CollectBlobs(ctx)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/utils"
+	"strings"
+)
+
+var (
+	// ErrChecklistItemNotFound is returned when the checklist of a task has no item with the requested ID
+	ErrChecklistItemNotFound = errors.New("checklist item not found")
+	// ErrInvalidChecklistOrder is returned when reordering a checklist with IDs that are not exactly those of its items
+	ErrInvalidChecklistOrder = errors.New("the order must list every item of the checklist exactly once")
+)
+
+// ChecklistItems returns the checklist of a task in its order
+func (s *TaskService) ChecklistItems(ctx context.Context, id int) ([]models.ChecklistItem, error) {
+	task, err := s.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Checklist == nil {
+		return []models.ChecklistItem{}, nil
+	}
+	return task.Checklist, nil
+}
+
+// AddChecklistItem appends an item to the checklist of a task and returns the task along with the new item
+func (s *TaskService) AddChecklistItem(ctx context.Context, id int, item models.ChecklistItem, preconditions ...Precondition) (models.Task, models.ChecklistItem, error) {
+	task, err := s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		item.ID = models.NextChecklistItemID(task.Checklist)
+		item.Text = strings.TrimSpace(item.Text)
+		task.Checklist = append(cloneChecklist(task.Checklist), item)
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+	if err != nil {
+		return models.Task{}, models.ChecklistItem{}, err
+	}
+	return task, task.Checklist[len(task.Checklist)-1], nil
+}
+
+// UpdateChecklistItem replaces the text, done flag and assignee of an item of the checklist of a task
+func (s *TaskService) UpdateChecklistItem(ctx context.Context, id int, item models.ChecklistItem, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		i := checklistIndex(task.Checklist, item.ID)
+		if i < 0 {
+			return ErrChecklistItemNotFound
+		}
+		item.Text = strings.TrimSpace(item.Text)
+		task.Checklist = cloneChecklist(task.Checklist)
+		task.Checklist[i] = item
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// DeleteChecklistItem removes an item from the checklist of a task
+func (s *TaskService) DeleteChecklistItem(ctx context.Context, id, itemID int, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		i := checklistIndex(task.Checklist, itemID)
+		if i < 0 {
+			return ErrChecklistItemNotFound
+		}
+		checklist := append(cloneChecklist(task.Checklist[:i]), task.Checklist[i+1:]...)
+		if len(checklist) == 0 {
+			checklist = nil
+		}
+		task.Checklist = checklist
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// ReorderChecklist puts the items of the checklist of a task in the order of ids,
+// which must list the ID of every item exactly once
+func (s *TaskService) ReorderChecklist(ctx context.Context, id int, ids []int, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		if len(ids) != len(task.Checklist) {
+			return ErrInvalidChecklistOrder
+		}
+		checklist := make([]models.ChecklistItem, 0, len(ids))
+		seen := make(map[int]bool, len(ids))
+		for _, itemID := range ids {
+			i := checklistIndex(task.Checklist, itemID)
+			if i < 0 || seen[itemID] {
+				return ErrInvalidChecklistOrder
+			}
+			seen[itemID] = true
+			checklist = append(checklist, task.Checklist[i])
+		}
+		if len(checklist) == 0 {
+			checklist = nil
+		}
+		task.Checklist = checklist
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// newChecklist numbers the items of the checklist of a new task. A task created without a checklist
+// gets one from the Markdown task list of its description, e.g. "- [ ] step".
+func newChecklist(items []models.ChecklistItem, description string) []models.ChecklistItem {
+	if len(items) == 0 {
+		for _, listItem := range utils.ParseTaskList(description) {
+			items = append(items, models.ChecklistItem{Text: listItem.Text, Done: listItem.Done})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	checklist := make([]models.ChecklistItem, len(items))
+	for i, item := range items {
+		item.ID = i + 1
+		item.Text = strings.TrimSpace(item.Text)
+		checklist[i] = item
+	}
+	return checklist
+}
+
+// checklistProgress returns the share of the items of a checklist that are done, or nil for an empty checklist
+func checklistProgress(checklist []models.ChecklistItem) *models.Progress {
+	if len(checklist) == 0 {
+		return nil
+	}
+	completed := 0
+	for _, item := range checklist {
+		if item.Done {
+			completed++
+		}
+	}
+	progress := models.NewProgress(completed, len(checklist))
+	return &progress
+}
+
+// checklistIndex returns the index of the item with the given ID, or -1
+func checklistIndex(checklist []models.ChecklistItem, itemID int) int {
+	for i, item := range checklist {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneChecklist copies a checklist, so changing it does not change the task read from the store
+func cloneChecklist(checklist []models.ChecklistItem) []models.ChecklistItem {
+	return append([]models.ChecklistItem(nil), checklist...)
+}
+
+// ChecklistItems returns the checklist of a task using the default service
+func ChecklistItems(ctx context.Context, id int) ([]models.ChecklistItem, error) {
+	return defaultService.ChecklistItems(ctx, id)
+}
+
+// AddChecklistItem appends an item to the checklist of a task using the default service
+func AddChecklistItem(ctx context.Context, id int, item models.ChecklistItem, preconditions ...Precondition) (models.Task, models.ChecklistItem, error) {
+	return defaultService.AddChecklistItem(ctx, id, item, preconditions...)
+}
+
+// UpdateChecklistItem replaces an item of the checklist of a task using the default service
+func UpdateChecklistItem(ctx context.Context, id int, item models.ChecklistItem, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.UpdateChecklistItem(ctx, id, item, preconditions...)
+}
+
+// DeleteChecklistItem removes an item from the checklist of a task using the default service
+func DeleteChecklistItem(ctx context.Context, id, itemID int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.DeleteChecklistItem(ctx, id, itemID, preconditions...)
+}
+
+// ReorderChecklist reorders the checklist of a task using the default service
+func ReorderChecklist(ctx context.Context, id int, ids []int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.ReorderChecklist(ctx, id, ids, preconditions...)
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/utils"
+	"time"
+)
+
+var (
+	// ErrCommentNotFound is returned when the requested comment does not exist or does not belong to the task
+	ErrCommentNotFound = models.ErrCommentNotFound
+	// ErrParentCommentNotFound is returned when replying to a comment that does not exist, belongs to another task
+	// or was deleted
+	ErrParentCommentNotFound = errors.New("parent comment not found")
+	// ErrNotCommentAuthor is returned when a user changes a comment written by someone else
+	ErrNotCommentAuthor = errors.New("only the author of a comment can change it")
+	// ErrCommentsUnsupported is returned when the store does not implement models.CommentStore
+	ErrCommentsUnsupported = errors.New("the task store does not support comments")
+)
+
+// ListComments returns the comments of a task as threads: the top-level comments ordered by ID,
+// each with its replies nested in Replies
+func (s *TaskService) ListComments(ctx context.Context, taskID int) ([]models.Comment, error) {
+	if s.comments == nil {
+		return nil, ErrCommentsUnsupported
+	}
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.ListComments(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[int][]models.Comment)
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+		}
+	}
+	var thread func(comments []models.Comment) []models.Comment
+	thread = func(comments []models.Comment) []models.Comment {
+		threaded := make([]models.Comment, len(comments))
+		for i, comment := range comments {
+			threaded[i] = presentComment(comment)
+			threaded[i].Replies = thread(replies[comment.ID])
+		}
+		return threaded
+	}
+
+	roots := make([]models.Comment, 0)
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		}
+	}
+	return thread(roots), nil
+}
+
+// GetComment returns a comment of a task, without its replies
+func (s *TaskService) GetComment(ctx context.Context, taskID, id int) (models.Comment, error) {
+	if s.comments == nil {
+		return models.Comment{}, ErrCommentsUnsupported
+	}
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return models.Comment{}, err
+	}
+	comment, err := s.taskComment(ctx, taskID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return presentComment(comment), nil
+}
+
+// AddComment adds a comment to a task, or a reply when comment.ParentID is set.
+// The author is the actor of the context.
+func (s *TaskService) AddComment(ctx context.Context, taskID int, comment models.Comment) (models.Comment, error) {
+	if s.comments == nil {
+		return models.Comment{}, ErrCommentsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return models.Comment{}, err
+	}
+	if comment.ParentID != nil {
+		parent, err := s.taskComment(ctx, taskID, *comment.ParentID)
+		if errors.Is(err, ErrCommentNotFound) || err == nil && parent.DeletedAt != nil {
+			return models.Comment{}, ErrParentCommentNotFound
+		} else if err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	now := time.Now()
+	created, err := s.comments.CreateComment(ctx, models.Comment{
+		TaskID:    taskID,
+		ParentID:  comment.ParentID,
+		Author:    ActorFromContext(ctx),
+		Body:      comment.Body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return presentComment(created), nil
+}
+
+// EditComment replaces the body of a comment, keeping the previous one in its edit history.
+// Only the author of the comment can edit it.
+func (s *TaskService) EditComment(ctx context.Context, taskID, id int, body string) (models.Comment, error) {
+	if s.comments == nil {
+		return models.Comment{}, ErrCommentsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return models.Comment{}, err
+	}
+	if _, err := s.taskComment(ctx, taskID, id); err != nil {
+		return models.Comment{}, err
+	}
+	actor := ActorFromContext(ctx)
+	edited, err := s.comments.UpdateComment(ctx, id, func(comment *models.Comment) error {
+		if comment.DeletedAt != nil {
+			return ErrCommentNotFound
+		}
+		if comment.Author != "" && comment.Author != actor {
+			return ErrNotCommentAuthor
+		}
+		if comment.Body == body {
+			return nil
+		}
+		now := time.Now()
+		comment.Edits = append(comment.Edits, models.CommentEdit{Body: comment.Body, Editor: actor, EditedAt: now})
+		comment.Body = body
+		comment.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return presentComment(edited), nil
+}
+
+// DeleteComment deletes a comment. Only its author or an administrator can delete it.
+// A comment with replies is kept as an empty placeholder so the thread stays intact,
+// and a placeholder is removed along with its last reply.
+func (s *TaskService) DeleteComment(ctx context.Context, taskID, id int) error {
+	if s.comments == nil {
+		return ErrCommentsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return err
+	}
+	comment, err := s.taskComment(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	if comment.DeletedAt != nil {
+		return ErrCommentNotFound
+	}
+	if actor := ActorFromContext(ctx); comment.Author != "" && comment.Author != actor && !s.isAdmin(ctx) {
+		return ErrNotCommentAuthor
+	}
+
+	comments, err := s.comments.ListComments(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	replyCounts := make(map[int]int)
+	byID := make(map[int]models.Comment, len(comments))
+	for _, other := range comments {
+		byID[other.ID] = other
+		if other.ParentID != nil {
+			replyCounts[*other.ParentID]++
+		}
+	}
+
+	if replyCounts[id] > 0 {
+		_, err := s.comments.UpdateComment(ctx, id, func(comment *models.Comment) error {
+			now := time.Now()
+			comment.Body = ""
+			comment.Edits = nil
+			comment.DeletedAt = &now
+			comment.UpdatedAt = now
+			return nil
+		})
+		return err
+	}
+
+	// Remove the comment, then the placeholders left without replies above it
+	for {
+		if err := s.comments.DeleteComment(ctx, comment.ID); err != nil {
+			return err
+		}
+		if comment.ParentID == nil {
+			return nil
+		}
+		parent, ok := byID[*comment.ParentID]
+		replyCounts[parent.ID]--
+		if !ok || parent.DeletedAt == nil || replyCounts[parent.ID] > 0 {
+			return nil
+		}
+		comment = parent
+	}
+}
+
+// taskComment returns a comment if it belongs to the task
+func (s *TaskService) taskComment(ctx context.Context, taskID, id int) (models.Comment, error) {
+	comment, err := s.comments.GetComment(ctx, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if comment.TaskID != taskID {
+		return models.Comment{}, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// presentComment fills the computed fields of a comment read from the store
+func presentComment(comment models.Comment) models.Comment {
+	if comment.DeletedAt == nil {
+		comment.BodyHTML = utils.RenderMarkdown(comment.Body)
+	}
+	return comment
+}
+
+// ListComments returns the comment threads of a task using the default service
+func ListComments(ctx context.Context, taskID int) ([]models.Comment, error) {
+	return defaultService.ListComments(ctx, taskID)
+}
+
+// GetComment returns a comment of a task using the default service
+func GetComment(ctx context.Context, taskID, id int) (models.Comment, error) {
+	return defaultService.GetComment(ctx, taskID, id)
+}
+
+// AddComment adds a comment to a task using the default service
+func AddComment(ctx context.Context, taskID int, comment models.Comment) (models.Comment, error) {
+	return defaultService.AddComment(ctx, taskID, comment)
+}
+
+// EditComment replaces the body of a comment using the default service
+func EditComment(ctx context.Context, taskID, id int, body string) (models.Comment, error) {
+	return defaultService.EditComment(ctx, taskID, id, body)
+}
+
+// DeleteComment deletes a comment using the default service
+func DeleteComment(ctx context.Context, taskID, id int) error {
+	return defaultService.DeleteComment(ctx, taskID, id)
+}
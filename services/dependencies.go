@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"sort"
+)
+
+var (
+	// ErrBlockerNotFound is returned when a blocker of a task does not exist or is in the trash
+	ErrBlockerNotFound = errors.New("blocking task not found")
+	// ErrDependencyNotFound is returned when removing a blocker the task is not blocked by
+	ErrDependencyNotFound = errors.New("task is not blocked by this task")
+	// ErrDependencyCycle is returned when a dependency would make a task block itself, directly or not
+	ErrDependencyCycle = errors.New("a task cannot block itself or the tasks blocking it")
+	// ErrBlocked is returned under BlockerPolicyFail when a task with open blockers moves to an in progress status
+	ErrBlocked = errors.New("task is blocked by tasks that are not done")
+)
+
+// BlockerPolicy decides what happens when a task with open blockers moves to a status of the in progress category
+type BlockerPolicy string
+
+const (
+	// BlockerPolicyWarn allows the change. The HTTP handlers add a Warning header. It is the default.
+	BlockerPolicyWarn BlockerPolicy = "warn"
+	// BlockerPolicyFail rejects the change with ErrBlocked
+	BlockerPolicyFail BlockerPolicy = "fail"
+)
+
+// TaskPath is a chain of tasks, each blocking the next one
+type TaskPath struct {
+	Tasks       []models.Task `json:"tasks"`
+	EffortHours float64       `json:"effort_hours"`
+}
+
+// SetBlockerPolicy sets what happens when a task with open blockers moves to an in progress status
+func (s *TaskService) SetBlockerPolicy(policy BlockerPolicy) error {
+	if policy != BlockerPolicyWarn && policy != BlockerPolicyFail {
+		return fmt.Errorf("unknown blocker policy %q", policy)
+	}
+
+	s.workflowMutex.Lock()
+	defer s.workflowMutex.Unlock()
+
+	s.blockerPolicy = policy
+	return nil
+}
+
+// BlockerPolicy returns the policy set by SetBlockerPolicy
+func (s *TaskService) BlockerPolicy() BlockerPolicy {
+	s.workflowMutex.RLock()
+	defer s.workflowMutex.RUnlock()
+
+	return s.blockerPolicy
+}
+
+// AddBlocker makes the task blocked by blockerID
+func (s *TaskService) AddBlocker(ctx context.Context, id, blockerID int, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		task.BlockedBy = append(task.BlockedBy, blockerID)
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// RemoveBlocker removes blockerID from the blockers of the task
+func (s *TaskService) RemoveBlocker(ctx context.Context, id, blockerID int, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		blockedBy := make([]int, 0, len(task.BlockedBy))
+		for _, blocker := range task.BlockedBy {
+			if blocker != blockerID {
+				blockedBy = append(blockedBy, blocker)
+			}
+		}
+		if len(blockedBy) == len(task.BlockedBy) {
+			return ErrDependencyNotFound
+		}
+		task.BlockedBy = blockedBy
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// Blockers returns the tasks blocking a task, ordered by ID. Blockers in the trash are left out.
+func (s *TaskService) Blockers(ctx context.Context, id int) ([]models.Task, error) {
+	task, err := s.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.activeTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockers := make([]models.Task, 0, len(task.BlockedBy))
+	for _, blockerID := range task.BlockedBy {
+		if blocker, ok := tasks[blockerID]; ok {
+			blockers = append(blockers, blocker)
+		}
+	}
+	return blockers, nil
+}
+
+// OpenBlockers returns the IDs of the tasks blocking a task that are not done
+func (s *TaskService) OpenBlockers(ctx context.Context, task models.Task) ([]int, error) {
+	if len(task.BlockedBy) == 0 {
+		return nil, nil
+	}
+	tasks, err := s.activeTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.openBlockers(tasks, task), nil
+}
+
+// ReadyTasks returns the tasks that are not done and have no open blockers, ordered by ID
+func (s *TaskService) ReadyTasks(ctx context.Context) ([]models.Task, error) {
+	tasks, err := s.GetAllTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := indexTasks(tasks)
+	ready := make([]models.Task, 0)
+	for _, task := range tasks {
+		if !s.isDone(task.Status) && len(s.openBlockers(byID, task)) == 0 {
+			ready = append(ready, task)
+		}
+	}
+	return ready, nil
+}
+
+// TopologicalOrder returns the tasks ordered so that every task comes after the tasks blocking it.
+// Tasks that do not depend on each other are ordered by ID.
+func (s *TaskService) TopologicalOrder(ctx context.Context) ([]models.Task, error) {
+	tasks, err := s.GetAllTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return topologicalOrder(tasks)
+}
+
+// CriticalPath returns the chain of open tasks with the largest total effort_hours, each blocking the next one
+func (s *TaskService) CriticalPath(ctx context.Context) (TaskPath, error) {
+	tasks, err := s.GetAllTasks(ctx)
+	if err != nil {
+		return TaskPath{}, err
+	}
+	open := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if !s.isDone(task.Status) {
+			open = append(open, task)
+		}
+	}
+	ordered, err := topologicalOrder(open)
+	if err != nil {
+		return TaskPath{}, err
+	}
+
+	// effort[id] is the largest effort of a chain ending with the task, previous[id] the task before it in that chain
+	effort := make(map[int]float64, len(ordered))
+	previous := make(map[int]int, len(ordered))
+	byID := indexTasks(ordered)
+	path := TaskPath{Tasks: []models.Task{}}
+	last := 0
+	for _, task := range ordered {
+		effort[task.ID] = task.EffortHours
+		for _, blockerID := range task.BlockedBy {
+			if _, ok := byID[blockerID]; ok && effort[blockerID]+task.EffortHours > effort[task.ID] {
+				effort[task.ID] = effort[blockerID] + task.EffortHours
+				previous[task.ID] = blockerID
+			}
+		}
+		if last == 0 || effort[task.ID] > effort[last] {
+			last = task.ID
+		}
+	}
+	if last == 0 {
+		return path, nil
+	}
+
+	path.EffortHours = effort[last]
+	for id := last; id != 0; id = previous[id] {
+		path.Tasks = append(path.Tasks, byID[id])
+	}
+	for i, j := 0, len(path.Tasks)-1; i < j; i, j = i+1, j-1 {
+		path.Tasks[i], path.Tasks[j] = path.Tasks[j], path.Tasks[i]
+	}
+	return path, nil
+}
+
+// checkDependencies enforces the dependency rules on a change of a task from before to after
+func (s *TaskService) checkDependencies(ctx context.Context, before, after models.Task) error {
+	added := addedIDs(before.BlockedBy, after.BlockedBy)
+	movesInProgress := s.isInProgress(after.Status) && !s.isInProgress(before.Status)
+	if len(added) == 0 && !(movesInProgress && len(after.BlockedBy) > 0 && s.BlockerPolicy() == BlockerPolicyFail) {
+		return nil
+	}
+
+	// Tasks in the trash keep their dependencies, so they take part in the cycle detection
+	all, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	tasks := indexTasks(all)
+	for _, blockerID := range added {
+		blocker, ok := tasks[blockerID]
+		if !ok || blocker.DeletedAt != nil {
+			return ErrBlockerNotFound
+		}
+		if blockerID == after.ID || blocks(tasks, after.ID, blockerID) {
+			return ErrDependencyCycle
+		}
+	}
+
+	if movesInProgress && s.BlockerPolicy() == BlockerPolicyFail && len(s.openBlockers(tasks, after)) > 0 {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// openBlockers returns the blockers of the task that are outside of the trash and not done
+func (s *TaskService) openBlockers(tasks map[int]models.Task, task models.Task) []int {
+	var open []int
+	for _, blockerID := range task.BlockedBy {
+		if blocker, ok := tasks[blockerID]; ok && blocker.DeletedAt == nil && !s.isDone(blocker.Status) {
+			open = append(open, blockerID)
+		}
+	}
+	return open
+}
+
+// activeTasks returns the tasks outside of the trash by ID
+func (s *TaskService) activeTasks(ctx context.Context) (map[int]models.Task, error) {
+	tasks, err := s.GetAllTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return indexTasks(tasks), nil
+}
+
+// isInProgress reports whether the status is in the in progress category of the workflow
+func (s *TaskService) isInProgress(status string) bool {
+	workflowStatus, ok := s.Workflow().Status(status)
+	return ok && workflowStatus.Category == models.CategoryInProgress
+}
+
+// blocks reports whether the task blocks target, directly or through other tasks
+func blocks(tasks map[int]models.Task, id, target int) bool {
+	visited := make(map[int]bool)
+	var walk func(current int) bool
+	walk = func(current int) bool {
+		if current == id {
+			return true
+		}
+		if visited[current] {
+			return false
+		}
+		visited[current] = true
+		for _, blockerID := range tasks[current].BlockedBy {
+			if walk(blockerID) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(target)
+}
+
+// topologicalOrder sorts the tasks so that every task comes after its blockers among them (Kahn's algorithm),
+// picking the lowest ID first among the tasks that are ready
+func topologicalOrder(tasks []models.Task) ([]models.Task, error) {
+	byID := indexTasks(tasks)
+	pending := make(map[int]int, len(tasks))
+	dependents := make(map[int][]int)
+	for _, task := range tasks {
+		for _, blockerID := range task.BlockedBy {
+			if _, ok := byID[blockerID]; ok {
+				pending[task.ID]++
+				dependents[blockerID] = append(dependents[blockerID], task.ID)
+			}
+		}
+	}
+
+	var ready []int
+	for _, task := range tasks {
+		if pending[task.ID] == 0 {
+			ready = append(ready, task.ID)
+		}
+	}
+	ordered := make([]models.Task, 0, len(tasks))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		id := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byID[id])
+		for _, dependent := range dependents[id] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(ordered) < len(tasks) {
+		return nil, ErrDependencyCycle
+	}
+	return ordered, nil
+}
+
+func indexTasks(tasks []models.Task) map[int]models.Task {
+	byID := make(map[int]models.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	return byID
+}
+
+// addedIDs returns the IDs of after that are not in before
+func addedIDs(before, after []int) []int {
+	var added []int
+	for _, id := range after {
+		if !containsInt(before, id) {
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBlockerPolicy sets the blocker policy of the default service
+func SetBlockerPolicy(policy BlockerPolicy) error {
+	return defaultService.SetBlockerPolicy(policy)
+}
+
+// OpenBlockers returns the open blockers of a task using the default service
+func OpenBlockers(ctx context.Context, task models.Task) ([]int, error) {
+	return defaultService.OpenBlockers(ctx, task)
+}
+
+// AddBlocker makes a task blocked by another one using the default service
+func AddBlocker(ctx context.Context, id, blockerID int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.AddBlocker(ctx, id, blockerID, preconditions...)
+}
+
+// RemoveBlocker removes a blocker of a task using the default service
+func RemoveBlocker(ctx context.Context, id, blockerID int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.RemoveBlocker(ctx, id, blockerID, preconditions...)
+}
+
+// Blockers returns the tasks blocking a task using the default service
+func Blockers(ctx context.Context, id int) ([]models.Task, error) {
+	return defaultService.Blockers(ctx, id)
+}
+
+// ReadyTasks returns the tasks without open blockers using the default service
+func ReadyTasks(ctx context.Context) ([]models.Task, error) {
+	return defaultService.ReadyTasks(ctx)
+}
+
+// TopologicalOrder returns the tasks in dependency order using the default service
+func TopologicalOrder(ctx context.Context) ([]models.Task, error) {
+	return defaultService.TopologicalOrder(ctx)
+}
+
+// CriticalPath returns the critical path of the open tasks using the default service
+func CriticalPath(ctx context.Context) (TaskPath, error) {
+	return defaultService.CriticalPath(ctx)
+}
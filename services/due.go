@@ -0,0 +1,32 @@
+package services
+
+import (
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+// present fills the computed fields of a task read from the store
+func (s *TaskService) present(task models.Task) models.Task {
+	task.Overdue = s.isOverdue(task, time.Now())
+	task.ChecklistProgress = checklistProgress(task.Checklist)
+	return task
+}
+
+// presentAll fills the computed fields of every task in place
+func (s *TaskService) presentAll(tasks []models.Task) []models.Task {
+	now := time.Now()
+	for i := range tasks {
+		tasks[i].Overdue = s.isOverdue(tasks[i], now)
+		tasks[i].ChecklistProgress = checklistProgress(tasks[i].Checklist)
+	}
+	return tasks
+}
+
+// isOverdue reports whether the task was due before now and its status is not in the done category
+func (s *TaskService) isOverdue(task models.Task, now time.Time) bool {
+	if task.DueAt == nil || task.DeletedAt != nil || !task.DueAt.Before(now) {
+		return false
+	}
+	status, ok := s.Workflow().Status(task.Status)
+	return !ok || status.Category != models.CategoryDone
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+// Task event types
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDeleted = "task.deleted"
+)
+
+// EventTypes are the types of the events published for every change of a task
+var EventTypes = []string{EventTaskCreated, EventTaskUpdated, EventTaskDeleted}
+
+// TaskEvent describes a change of a task
+type TaskEvent struct {
+	Type   string `json:"event"`
+	TaskID int    `json:"task_id"`
+	// Task is the task after the change, or before it for a deletion
+	Task models.Task `json:"task"`
+	// Changes are the fields changed by an update
+	Changes    []models.FieldChange `json:"changes,omitempty"`
+	Actor      string               `json:"actor,omitempty"`
+	Revision   int                  `json:"revision,omitempty"`
+	OccurredAt time.Time            `json:"occurred_at"`
+}
+
+// eventType returns the type of the event published for a revision, or "" when none is.
+// Moving a task to the trash deletes it and restoring it creates it again; purging a task
+// from the trash publishes nothing, as its deletion was already published.
+func eventType(revision models.Revision, before *models.Task) string {
+	switch revision.Action {
+	case models.RevisionCreated, models.RevisionRestored:
+		return EventTaskCreated
+	case models.RevisionUpdated, models.RevisionReverted:
+		return EventTaskUpdated
+	case models.RevisionDeleted:
+		return EventTaskDeleted
+	case models.RevisionPurged:
+		if before != nil && before.DeletedAt == nil {
+			return EventTaskDeleted
+		}
+	}
+	return ""
+}
+
+// publish emits the event of a recorded revision. It is called with the write mutex held and must not block.
+func (s *TaskService) publish(ctx context.Context, revision models.Revision, before *models.Task) {
+	eventType := eventType(revision, before)
+	if eventType == "" {
+		return
+	}
+	event := TaskEvent{
+		Type:       eventType,
+		TaskID:     revision.TaskID,
+		Task:       s.present(revision.Task),
+		Actor:      revision.Actor,
+		Revision:   revision.Number,
+		OccurredAt: revision.CreatedAt,
+	}
+	if eventType == EventTaskUpdated {
+		event.Changes = revision.Changes
+	}
+	s.feed.broadcast(event)
+	s.enqueueDeliveries(ctx, event)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EventReplaySize is the number of recent events kept to resume the change feed after a reconnection
+	EventReplaySize = 1024
+	// subscriberBuffer is the number of events a subscriber may fall behind before it is disconnected
+	subscriberBuffer = 64
+)
+
+// FeedEvent is a task event of the change feed, identified by an ID to resume the feed from
+type FeedEvent struct {
+	ID string
+	TaskEvent
+}
+
+// FeedFilter selects the events of a change feed subscription
+type FeedFilter struct {
+	// Statuses, when not empty, only selects the events of tasks that had or now have one of these statuses
+	Statuses []string
+	// TaskIDs, when not empty, only selects the events of these tasks
+	TaskIDs []int
+}
+
+// matches reports whether the filter selects an event
+func (f FeedFilter) matches(event TaskEvent) bool {
+	if len(f.TaskIDs) > 0 && !slices.Contains(f.TaskIDs, event.TaskID) {
+		return false
+	}
+	if len(f.Statuses) == 0 || slices.Contains(f.Statuses, event.Task.Status) {
+		return true
+	}
+	// An update moving a task out of a status is still an event of that status
+	for _, change := range event.Changes {
+		if change.Field == "status" {
+			var from string
+			return json.Unmarshal(change.From, &from) == nil && slices.Contains(f.Statuses, from)
+		}
+	}
+	return false
+}
+
+// Subscription receives the events of the change feed
+type Subscription struct {
+	// Replay are the events published after the event the subscription resumes from
+	Replay []FeedEvent
+	// Reset is true when the event to resume from is no longer, or never was, in the replay buffer, so events may
+	// have been missed and the client should reload the tasks
+	Reset bool
+	// Events receives the events published after the subscription. It is closed when the subscriber falls too far
+	// behind, and the client should then resume from the last event it received.
+	Events <-chan FeedEvent
+
+	feed   *eventFeed
+	events chan FeedEvent
+	filter FeedFilter
+}
+
+// Close stops the subscription
+func (sub *Subscription) Close() {
+	sub.feed.unsubscribe(sub)
+}
+
+// eventFeed broadcasts task events to the subscribers of the change feed and keeps the recent ones for replay.
+// Event IDs are "<stream>-<sequence>", where the stream identifies the feed, so IDs from before a restart are
+// recognised as unknown instead of being mistaken for recent events.
+type eventFeed struct {
+	mutex       sync.Mutex
+	stream      string
+	sequence    uint64
+	recent      []FeedEvent // ring buffer of the last EventReplaySize events, oldest at start
+	start       int
+	subscribers map[*Subscription]struct{}
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{
+		stream:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		recent:      make([]FeedEvent, 0, EventReplaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// broadcast assigns the next ID to an event, keeps it for replay and sends it to the matching subscribers.
+// It never blocks: a subscriber whose buffer is full is disconnected.
+func (f *eventFeed) broadcast(event TaskEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sequence++
+	feedEvent := FeedEvent{ID: f.stream + "-" + strconv.FormatUint(f.sequence, 10), TaskEvent: event}
+	if len(f.recent) < EventReplaySize {
+		f.recent = append(f.recent, feedEvent)
+	} else {
+		f.recent[f.start] = feedEvent
+		f.start = (f.start + 1) % EventReplaySize
+	}
+
+	for sub := range f.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- feedEvent:
+		default:
+			delete(f.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// subscribe registers a subscriber along with the events to replay after lastEventID, atomically so that no event
+// is missed or sent twice. An empty lastEventID starts from the next event.
+func (f *eventFeed) subscribe(lastEventID string, filter FeedFilter) *Subscription {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	events := make(chan FeedEvent, subscriberBuffer)
+	sub := &Subscription{Events: events, Replay: []FeedEvent{}, feed: f, events: events, filter: filter}
+	if lastEventID != "" {
+		sub.Replay, sub.Reset = f.replay(lastEventID, filter)
+	}
+	f.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// replay returns the kept events after lastEventID that match the filter, and whether lastEventID is unknown.
+// The caller must hold the mutex.
+func (f *eventFeed) replay(lastEventID string, filter FeedFilter) ([]FeedEvent, bool) {
+	stream, number, found := strings.Cut(lastEventID, "-")
+	sequence, err := strconv.ParseUint(number, 10, 64)
+	if !found || err != nil || stream != f.stream || sequence > f.sequence {
+		return []FeedEvent{}, true
+	}
+	// The oldest kept event must directly follow the last received one for the replay to have no gap
+	oldest := f.sequence - uint64(len(f.recent)) + 1
+	if sequence+1 < oldest {
+		return []FeedEvent{}, true
+	}
+
+	replay := make([]FeedEvent, 0)
+	for i := sequence + 1 - oldest; i < uint64(len(f.recent)); i++ {
+		event := f.recent[(f.start+int(i))%len(f.recent)]
+		if filter.matches(event.TaskEvent) {
+			replay = append(replay, event)
+		}
+	}
+	return replay, false
+}
+
+// unsubscribe removes a subscriber, unless it was already disconnected
+func (f *eventFeed) unsubscribe(sub *Subscription) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribe subscribes to the change feed. With a lastEventID, the subscription first replays the events published
+// after it, or sets Reset when they are no longer kept. The subscription must be closed once done.
+func (s *TaskService) Subscribe(lastEventID string, filter FeedFilter) *Subscription {
+	return s.feed.subscribe(lastEventID, filter)
+}
+
+// Subscribe subscribes to the change feed of the default service
+func Subscribe(lastEventID string, filter FeedFilter) *Subscription {
+	return defaultService.Subscribe(lastEventID, filter)
+}
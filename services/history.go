@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrRevisionNotFound is returned when a task has no revision with the requested number
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// untrackedFields are maintained by the service and left out of the revision changes
+var untrackedFields = map[string]bool{
+	models.JsonID:        true,
+	models.JsonCreatedAt: true,
+	models.JsonUpdatedAt: true,
+	models.JsonVersion:   true,
+	models.JsonOverdue:   true,
+	// The checklist itself is tracked
+	models.JsonChecklistProgress: true,
+	// The comments of a task have a history of their own
+	models.JsonCommentCount: true,
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the user making the changes, recorded in the task history
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the user set by WithActor, or "" if there is none
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// TaskHistory returns the revisions of a task, oldest first. The history of a deleted task remains available.
+func (s *TaskService) TaskHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	revisions, err := s.history.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		// Tasks created before the history was recorded have no revisions yet
+		if _, err := s.store.Get(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// TaskRevision returns revision n of a task
+func (s *TaskService) TaskRevision(ctx context.Context, id, n int) (models.Revision, error) {
+	revisions, err := s.TaskHistory(ctx, id)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	if n < 1 || n > len(revisions) {
+		return models.Revision{}, ErrRevisionNotFound
+	}
+	return revisions[n-1], nil
+}
+
+// record appends a revision for a change from before to after, either of which is nil for a creation or deletion,
+// and publishes the event of the change.
+// revision holds the action and, for reverts and undos, the revision they refer to.
+// The change is already stored, so failing the request would make clients retry it: a failure is only logged.
+// The caller must hold writeMutex so revisions are numbered in the order the changes were applied.
+func (s *TaskService) record(ctx context.Context, revision models.Revision, before, after *models.Task) models.Revision {
+	revision.Actor = ActorFromContext(ctx)
+	revision.CreatedAt = time.Now()
+	if after != nil {
+		revision.TaskID = after.ID
+		revision.Task = *after
+	} else {
+		revision.TaskID = before.ID
+		revision.Task = *before
+	}
+
+	changes, err := diffTasks(before, after)
+	if err == nil {
+		revision.Changes = changes
+		revision, err = s.history.AppendRevision(ctx, revision)
+	}
+	if err != nil {
+		log.Printf("failed to record revision of task %d: %v", revision.TaskID, err)
+	}
+	s.publish(ctx, revision, before)
+	return revision
+}
+
+// diffTasks lists the fields whose JSON value differs between two versions of a task, ordered by field name
+func diffTasks(before, after *models.Task) ([]models.FieldChange, error) {
+	from, err := taskFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := taskFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(from)+len(to))
+	for field := range from {
+		fields[field] = true
+	}
+	for field := range to {
+		fields[field] = true
+	}
+
+	changes := []models.FieldChange{}
+	for field := range fields {
+		if untrackedFields[field] || bytes.Equal(from[field], to[field]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: field, From: from[field], To: to[field]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// taskFields returns the JSON value of every field of the task, or nil for a nil task
+func taskFields(task *models.Task) (map[string]json.RawMessage, error) {
+	if task == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// TaskHistory returns the revisions of a task using the default service
+func TaskHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	return defaultService.TaskHistory(ctx, id)
+}
+
+// TaskRevision returns a revision of a task using the default service
+func TaskRevision(ctx context.Context, id, n int) (models.Revision, error) {
+	return defaultService.TaskRevision(ctx, id, n)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered unless configured otherwise
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyPurgeInterval limits how often expired idempotency keys are removed
+const idempotencyPurgeInterval = time.Minute
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// SetIdempotencyTTL changes how long idempotency keys are remembered
+func (s *TaskService) SetIdempotencyTTL(ttl time.Duration) {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	s.idempotencyTTL = ttl
+}
+
+// CreateTaskIdempotent creates a task at most once per idempotency key.
+// Repeating a key with the same task returns the originally created task and replayed = true.
+// Repeating it with a different task returns ErrIdempotencyKeyReused.
+func (s *TaskService) CreateTaskIdempotent(ctx context.Context, key string, task models.Task) (created models.Task, replayed bool, err error) {
+	hash, err := requestHash(task)
+	if err != nil {
+		return models.Task{}, false, err
+	}
+
+	// Serialize concurrent requests with the same key, so only one of them creates the task
+	lock := &s.keyLocks[keyLockIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	now := time.Now()
+	record, err := s.idempotency.GetIdempotencyKey(ctx, key, now)
+	switch {
+	case err == nil && record.RequestHash != hash:
+		return models.Task{}, false, ErrIdempotencyKeyReused
+	case err == nil:
+		return s.present(record.Task), true, nil
+	case !errors.Is(err, models.ErrIdempotencyKeyNotFound):
+		return models.Task{}, false, err
+	}
+
+	created, err = s.CreateTask(ctx, task)
+	if err != nil {
+		return models.Task{}, false, err
+	}
+
+	s.idempotencyMutex.Lock()
+	ttl := s.idempotencyTTL
+	purge := now.Sub(s.lastIdempotencyPurge) >= idempotencyPurgeInterval
+	if purge {
+		s.lastIdempotencyPurge = now
+	}
+	s.idempotencyMutex.Unlock()
+
+	// The task already exists at this point. Failing the request would make the client retry and
+	// create a duplicate, so a failure to remember the key is only logged.
+	if err := s.idempotency.PutIdempotencyKey(ctx, models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hash,
+		Task:        created,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}); err != nil {
+		log.Printf("failed to store idempotency key for task %d: %v", created.ID, err)
+	}
+	if purge {
+		if err := s.idempotency.DeleteExpiredIdempotencyKeys(ctx, now); err != nil {
+			log.Printf("failed to delete expired idempotency keys: %v", err)
+		}
+	}
+	return created, false, nil
+}
+
+// SetIdempotencyTTL changes how long idempotency keys are remembered by the default service
+func SetIdempotencyTTL(ttl time.Duration) {
+	defaultService.SetIdempotencyTTL(ttl)
+}
+
+// CreateTaskIdempotent creates a task at most once per idempotency key using the default service
+func CreateTaskIdempotent(ctx context.Context, key string, task models.Task) (models.Task, bool, error) {
+	return defaultService.CreateTaskIdempotent(ctx, key, task)
+}
+
+// requestHash fingerprints the task sent by the client
+func requestHash(task models.Task) (string, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func keyLockIndex(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % keyLockCount)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+)
+
+var (
+	// ErrLabelNotFound is returned when the requested label does not exist
+	ErrLabelNotFound = models.ErrLabelNotFound
+	// ErrLabelExists is returned when creating a label, or renaming one, to a name that is already taken
+	ErrLabelExists = errors.New("label already exists")
+	// ErrLabelsUnsupported is returned when the store does not implement models.LabelStore
+	ErrLabelsUnsupported = errors.New("the task store does not support labels")
+)
+
+// ListLabels returns every label ordered by name
+func (s *TaskService) ListLabels(ctx context.Context) ([]models.Label, error) {
+	if s.labels == nil {
+		return nil, ErrLabelsUnsupported
+	}
+	return s.labels.ListLabels(ctx)
+}
+
+// GetLabel returns a label by its name
+func (s *TaskService) GetLabel(ctx context.Context, name string) (models.Label, error) {
+	if s.labels == nil {
+		return models.Label{}, ErrLabelsUnsupported
+	}
+	return s.labels.GetLabel(ctx, name)
+}
+
+// CreateLabel defines a new label. A label already carried by tasks exists and is updated with UpdateLabel instead.
+func (s *TaskService) CreateLabel(ctx context.Context, label models.Label) (models.Label, error) {
+	if s.labels == nil {
+		return models.Label{}, ErrLabelsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.labels.GetLabel(ctx, label.Name); err == nil {
+		return models.Label{}, ErrLabelExists
+	} else if !errors.Is(err, ErrLabelNotFound) {
+		return models.Label{}, err
+	}
+	if err := s.labels.PutLabel(ctx, label); err != nil {
+		return models.Label{}, err
+	}
+	return s.labels.GetLabel(ctx, label.Name)
+}
+
+// UpdateLabel replaces the color and description of a label. When label.Name differs from name,
+// the label is also renamed on every task carrying it, atomically.
+func (s *TaskService) UpdateLabel(ctx context.Context, name string, label models.Label) (models.Label, error) {
+	if s.labels == nil {
+		return models.Label{}, ErrLabelsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.labels.GetLabel(ctx, name); err != nil {
+		return models.Label{}, err
+	}
+	if label.Name != name {
+		if _, err := s.labels.GetLabel(ctx, label.Name); err == nil {
+			return models.Label{}, ErrLabelExists
+		} else if !errors.Is(err, ErrLabelNotFound) {
+			return models.Label{}, err
+		}
+		if err := s.relabel(ctx, name, label.Name); err != nil {
+			return models.Label{}, err
+		}
+	}
+	if err := s.labels.PutLabel(ctx, label); err != nil {
+		return models.Label{}, err
+	}
+	return s.labels.GetLabel(ctx, label.Name)
+}
+
+// MergeLabel replaces the label from with into on every task and deletes it, atomically.
+// Both labels must exist.
+func (s *TaskService) MergeLabel(ctx context.Context, from, into string) (models.Label, error) {
+	if s.labels == nil {
+		return models.Label{}, ErrLabelsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	for _, name := range []string{from, into} {
+		if _, err := s.labels.GetLabel(ctx, name); err != nil {
+			return models.Label{}, err
+		}
+	}
+	if from != into {
+		if err := s.relabel(ctx, from, into); err != nil {
+			return models.Label{}, err
+		}
+	}
+	return s.labels.GetLabel(ctx, into)
+}
+
+// DeleteLabel deletes a label and removes it from every task carrying it, atomically
+func (s *TaskService) DeleteLabel(ctx context.Context, name string) error {
+	if s.labels == nil {
+		return ErrLabelsUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.relabel(ctx, name, "")
+}
+
+// AddTaskLabels adds labels to a task, keeping the ones it already carries
+func (s *TaskService) AddTaskLabels(ctx context.Context, id int, labels []string, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		task.Labels = append(task.Labels, labels...)
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// RemoveTaskLabel removes a label from a task. ErrLabelNotFound is returned if the task does not carry it.
+func (s *TaskService) RemoveTaskLabel(ctx context.Context, id int, label string, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		if !containsString(task.Labels, label) {
+			return ErrLabelNotFound
+		}
+		task.Labels = models.ReplaceLabel(task.Labels, label, "")
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// relabel replaces the label from with to on every task, or removes it when to is empty,
+// and records the change of every task. The caller must hold the write mutex.
+func (s *TaskService) relabel(ctx context.Context, from, to string) error {
+	before, after, err := s.labels.ReplaceLabel(ctx, from, to, func(task *models.Task) {
+		task.Overdue = false
+		task.ChecklistProgress = nil
+		touch(task)
+	})
+	if err != nil {
+		return err
+	}
+	for i := range before {
+		s.record(ctx, models.Revision{Action: models.RevisionUpdated}, &before[i], &after[i])
+	}
+	return nil
+}
+
+// tasksWithLabels returns the tasks outside of the trash carrying all of the labels, looked up in the label index
+func (s *TaskService) tasksWithLabels(ctx context.Context, labels []string) ([]models.Task, error) {
+	ids, err := s.labels.TaskIDsWithLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]models.Task, 0, len(ids))
+	for _, id := range ids {
+		task, err := s.store.Get(ctx, id)
+		if errors.Is(err, ErrTaskNotFound) {
+			// Deleted since the index was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if task.DeletedAt == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	return s.presentAll(tasks), nil
+}
+
+// ListLabels returns every label using the default service
+func ListLabels(ctx context.Context) ([]models.Label, error) {
+	return defaultService.ListLabels(ctx)
+}
+
+// GetLabel returns a label by its name using the default service
+func GetLabel(ctx context.Context, name string) (models.Label, error) {
+	return defaultService.GetLabel(ctx, name)
+}
+
+// CreateLabel defines a new label using the default service
+func CreateLabel(ctx context.Context, label models.Label) (models.Label, error) {
+	return defaultService.CreateLabel(ctx, label)
+}
+
+// UpdateLabel updates or renames a label using the default service
+func UpdateLabel(ctx context.Context, name string, label models.Label) (models.Label, error) {
+	return defaultService.UpdateLabel(ctx, name, label)
+}
+
+// MergeLabel merges a label into another using the default service
+func MergeLabel(ctx context.Context, from, into string) (models.Label, error) {
+	return defaultService.MergeLabel(ctx, from, into)
+}
+
+// DeleteLabel deletes a label using the default service
+func DeleteLabel(ctx context.Context, name string) error {
+	return defaultService.DeleteLabel(ctx, name)
+}
+
+// AddTaskLabels adds labels to a task using the default service
+func AddTaskLabels(ctx context.Context, id int, labels []string, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.AddTaskLabels(ctx, id, labels, preconditions...)
+}
+
+// RemoveTaskLabel removes a label from a task using the default service
+func RemoveTaskLabel(ctx context.Context, id int, label string, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.RemoveTaskLabel(ctx, id, label, preconditions...)
+}
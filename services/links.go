@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"log"
+	"time"
+)
+
+// Link types. Each type has an inverse, shown on the other end of the link; relates_to is its own inverse.
+const (
+	LinkDuplicates   = "duplicates"
+	LinkDuplicatedBy = "duplicated_by"
+	LinkRelatesTo    = "relates_to"
+	LinkClonedFrom   = "cloned_from"
+	LinkClonedBy     = "cloned_by"
+)
+
+// linkInverses maps every link type to its inverse
+var linkInverses = map[string]string{
+	LinkDuplicates:   LinkDuplicatedBy,
+	LinkDuplicatedBy: LinkDuplicates,
+	LinkRelatesTo:    LinkRelatesTo,
+	LinkClonedFrom:   LinkClonedBy,
+	LinkClonedBy:     LinkClonedFrom,
+}
+
+// storedLinkTypes are the types links are stored with. A link of an inverse type is stored reversed.
+var storedLinkTypes = map[string]bool{LinkDuplicates: true, LinkRelatesTo: true, LinkClonedFrom: true}
+
+var (
+	// ErrLinkNotFound is returned when the requested link does not exist or does not belong to the task
+	ErrLinkNotFound = models.ErrLinkNotFound
+	// ErrLinkExists is returned when the tasks are already linked with the same type
+	ErrLinkExists = errors.New("tasks are already linked with this type")
+	// ErrLinkedTaskNotFound is returned when the other end of a new link does not exist or is in the trash
+	ErrLinkedTaskNotFound = errors.New("linked task not found")
+	// ErrInvalidLink is returned for an unknown link type or a link from a task to itself
+	ErrInvalidLink = errors.New("invalid link")
+	// ErrLinksUnsupported is returned when the store does not implement models.LinkStore
+	ErrLinksUnsupported = errors.New("the task store does not support links")
+)
+
+// TaskLink is a link as seen from one of the tasks it connects
+type TaskLink struct {
+	ID int `json:"id"`
+	// Type is the relation of the task to the other one, e.g. duplicated_by on the task a duplicate points to
+	Type   string `json:"type"`
+	TaskID int    `json:"task_id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// IsLinkType reports whether linkType is a known link type
+func IsLinkType(linkType string) bool {
+	_, ok := linkInverses[linkType]
+	return ok
+}
+
+// LinkTasks links the task to the task targetID, e.g. with LinkDuplicates when the task duplicates targetID.
+// When resolution is set on a duplicates or duplicated_by link, the duplicate is closed with it:
+// it moves to the first done status the workflow allows, unless it is done already. When it cannot be closed,
+// the link is not kept.
+func (s *TaskService) LinkTasks(ctx context.Context, id int, linkType string, targetID int, resolution string) (TaskLink, error) {
+	if s.links == nil {
+		return TaskLink{}, ErrLinksUnsupported
+	}
+	if !IsLinkType(linkType) || id == targetID {
+		return TaskLink{}, ErrInvalidLink
+	}
+	if resolution != "" && linkType != LinkDuplicates && linkType != LinkDuplicatedBy {
+		return TaskLink{}, ErrInvalidLink
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, id); err != nil {
+		return TaskLink{}, err
+	}
+	target, err := s.GetTaskByID(ctx, targetID)
+	if errors.Is(err, ErrTaskNotFound) {
+		return TaskLink{}, ErrLinkedTaskNotFound
+	} else if err != nil {
+		return TaskLink{}, err
+	}
+
+	link := models.Link{Type: linkType, SourceID: id, TargetID: targetID, CreatedAt: time.Now()}
+	if !storedLinkTypes[linkType] {
+		link = models.Link{Type: linkInverses[linkType], SourceID: targetID, TargetID: id, CreatedAt: link.CreatedAt}
+	}
+	existing, err := s.links.TaskLinks(ctx, id)
+	if err != nil {
+		return TaskLink{}, err
+	}
+	for _, other := range existing {
+		if other.Type == link.Type && (other.SourceID == link.SourceID && other.TargetID == link.TargetID ||
+			link.Type == LinkRelatesTo && other.SourceID == link.TargetID && other.TargetID == link.SourceID) {
+			return TaskLink{}, ErrLinkExists
+		}
+	}
+
+	// The link is created first so the duplicate is never closed without it, and removed when closing fails
+	created, err := s.links.CreateLink(ctx, link)
+	if err != nil {
+		return TaskLink{}, err
+	}
+	if resolution != "" {
+		if err := s.resolveLocked(ctx, link.SourceID, resolution); err != nil {
+			if deleteErr := s.links.DeleteLink(ctx, created.ID); deleteErr != nil {
+				log.Printf("failed to remove link %d after failing to resolve task %d: %v", created.ID, link.SourceID, deleteErr)
+			}
+			return TaskLink{}, err
+		}
+		if link.SourceID == targetID {
+			target, err = s.GetTaskByID(ctx, targetID)
+			if err != nil {
+				return TaskLink{}, err
+			}
+		}
+	}
+	return TaskLink{ID: created.ID, Type: linkType, TaskID: targetID, Title: target.Title, Status: target.Status}, nil
+}
+
+// UnlinkTasks removes a link of the task
+func (s *TaskService) UnlinkTasks(ctx context.Context, id, linkID int) error {
+	if s.links == nil {
+		return ErrLinksUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, id); err != nil {
+		return err
+	}
+	link, err := s.links.GetLink(ctx, linkID)
+	if err != nil {
+		return err
+	}
+	if link.SourceID != id && link.TargetID != id {
+		return ErrLinkNotFound
+	}
+	return s.links.DeleteLink(ctx, linkID)
+}
+
+// TaskLinks returns the links of a task as seen from it, ordered by ID.
+// Links to tasks in the trash are left out until the tasks are restored.
+func (s *TaskService) TaskLinks(ctx context.Context, id int) ([]TaskLink, error) {
+	if s.links == nil {
+		return nil, ErrLinksUnsupported
+	}
+	if _, err := s.GetTaskByID(ctx, id); err != nil {
+		return nil, err
+	}
+	links, err := s.links.TaskLinks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	taskLinks := make([]TaskLink, 0, len(links))
+	for _, link := range links {
+		taskLink := TaskLink{ID: link.ID, Type: link.Type, TaskID: link.TargetID}
+		if link.SourceID != id {
+			taskLink.Type = linkInverses[link.Type]
+			taskLink.TaskID = link.SourceID
+		}
+		other, err := s.GetTaskByID(ctx, taskLink.TaskID)
+		if errors.Is(err, ErrTaskNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		taskLink.Title = other.Title
+		taskLink.Status = other.Status
+		taskLinks = append(taskLinks, taskLink)
+	}
+	return taskLinks, nil
+}
+
+// resolveLocked closes a task with the resolution. The caller must hold the write mutex.
+func (s *TaskService) resolveLocked(ctx context.Context, id int, resolution string) error {
+	_, err := s.updateLocked(ctx, id, func(task *models.Task) error {
+		if !s.isDone(task.Status) {
+			status, err := s.doneStatus(task.Status)
+			if err != nil {
+				return err
+			}
+			task.Status = status
+		}
+		task.Resolution = resolution
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+	return err
+}
+
+// doneStatus returns the first status of the done category the workflow allows moving to from status
+func (s *TaskService) doneStatus(status string) (string, error) {
+	workflow := s.Workflow()
+	var first string
+	for _, candidate := range workflow.Statuses {
+		if candidate.Category != models.CategoryDone {
+			continue
+		}
+		if workflow.Allows(status, candidate.Name) {
+			return candidate.Name, nil
+		}
+		if first == "" {
+			first = candidate.Name
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("%w: the workflow has no done status", ErrIllegalTransition)
+	}
+	return "", s.checkTransition(status, first)
+}
+
+// LinkTasks links two tasks using the default service
+func LinkTasks(ctx context.Context, id int, linkType string, targetID int, resolution string) (TaskLink, error) {
+	return defaultService.LinkTasks(ctx, id, linkType, targetID, resolution)
+}
+
+// UnlinkTasks removes a link of a task using the default service
+func UnlinkTasks(ctx context.Context, id, linkID int) error {
+	return defaultService.UnlinkTasks(ctx, id, linkID)
+}
+
+// TaskLinks returns the links of a task using the default service
+func TaskLinks(ctx context.Context, id int) ([]TaskLink, error) {
+	return defaultService.TaskLinks(ctx, id)
+}
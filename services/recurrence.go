@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/utils"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultOccurrences is the number of occurrences returned by Occurrences without a limit
+	DefaultOccurrences = 10
+	// MaxOccurrences is the largest number of occurrences returned by Occurrences
+	MaxOccurrences = 100
+)
+
+var (
+	// ErrInvalidRecurrence is returned for a recurrence with an invalid rule or time zone, or without a start
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	// ErrNotRecurring is returned when listing the occurrences of a task without a recurrence
+	ErrNotRecurring = errors.New("task does not recur")
+)
+
+// Occurrences returns up to limit occurrences of a recurring task after its due date, or after after when it is set,
+// skipping the excluded dates. The occurrences are in the time zone of the recurrence.
+func (s *TaskService) Occurrences(ctx context.Context, id int, after *time.Time, limit int) ([]time.Time, error) {
+	task, err := s.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Recurrence == nil {
+		return nil, ErrNotRecurring
+	}
+	from := task.Recurrence.Start
+	if task.DueAt != nil {
+		from = *task.DueAt
+	}
+	if after != nil {
+		from = *after
+	}
+	return occurrencesAfter(*task.Recurrence, from, min(max(limit, 1), MaxOccurrences))
+}
+
+// normalizeRecurrence checks the recurrence of a task and fills in its start from the due date, or the due date
+// from its start. The excluded dates are sorted and unique.
+func normalizeRecurrence(task *models.Task) error {
+	if task.Recurrence == nil {
+		return nil
+	}
+	recurrence := *task.Recurrence
+	if recurrence.Start.IsZero() {
+		if task.DueAt == nil {
+			return fmt.Errorf("%w: start or due_at is required", ErrInvalidRecurrence)
+		}
+		recurrence.Start = *task.DueAt
+	}
+	if _, _, err := recurrenceRule(recurrence); err != nil {
+		return err
+	}
+	if task.DueAt == nil {
+		due := recurrence.Start
+		task.DueAt = &due
+	}
+
+	exDates := append([]time.Time(nil), recurrence.ExDates...)
+	sort.Slice(exDates, func(i, j int) bool { return exDates[i].Before(exDates[j]) })
+	recurrence.ExDates = exDates[:0]
+	for i, exDate := range exDates {
+		if i == 0 || !exDate.Equal(exDates[i-1]) {
+			recurrence.ExDates = append(recurrence.ExDates, exDate)
+		}
+	}
+	if len(recurrence.ExDates) == 0 {
+		recurrence.ExDates = nil
+	}
+	task.Recurrence = &recurrence
+	return nil
+}
+
+// recurrenceRule parses the rule of a recurrence and loads its time zone
+func recurrenceRule(recurrence models.Recurrence) (utils.RecurrenceRule, *time.Location, error) {
+	loc, err := time.LoadLocation(recurrence.Timezone)
+	if err != nil {
+		return utils.RecurrenceRule{}, nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidRecurrence, recurrence.Timezone)
+	}
+	rule, err := utils.ParseRecurrenceRule(recurrence.RRule, loc)
+	if err != nil {
+		return utils.RecurrenceRule{}, nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return rule, loc, nil
+}
+
+// occurrencesAfter returns up to limit occurrences of a recurrence strictly after from, skipping the excluded dates
+func occurrencesAfter(recurrence models.Recurrence, from time.Time, limit int) ([]time.Time, error) {
+	rule, loc, err := recurrenceRule(recurrence)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := make([]time.Time, 0, limit)
+	for occurrence := range rule.Occurrences(recurrence.Start.In(loc)) {
+		if len(occurrences) == limit {
+			break
+		}
+		if !occurrence.After(from) || isExDate(recurrence, occurrence) {
+			continue
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+func isExDate(recurrence models.Recurrence, occurrence time.Time) bool {
+	for _, exDate := range recurrence.ExDates {
+		if exDate.Equal(occurrence) {
+			return true
+		}
+	}
+	return false
+}
+
+// completesOccurrence reports whether a change completes an occurrence of a recurring task
+// whose next occurrence was not created yet
+func (s *TaskService) completesOccurrence(before, after models.Task) bool {
+	return after.Recurrence != nil && after.NextOccurrenceID == nil && !s.isDone(before.Status) && s.isDone(after.Status)
+}
+
+// createNextOccurrence creates the task of the occurrence following the one of a recurring task, with the same
+// title, description, priority, effort, labels, parent and recurrence, its checklist unchecked and in the first
+// open status of the workflow. It is detached from a parent that was trashed or is done. It returns nil when the
+// recurrence has ended. The caller must hold the write mutex.
+func (s *TaskService) createNextOccurrence(ctx context.Context, task models.Task) (*models.Task, error) {
+	from := task.Recurrence.Start
+	if task.DueAt != nil {
+		from = *task.DueAt
+	}
+	occurrences, err := occurrencesAfter(*task.Recurrence, from, 1)
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+
+	recurrence := *task.Recurrence
+	recurrence.ExDates = append([]time.Time(nil), recurrence.ExDates...)
+	checklist := cloneChecklist(task.Checklist)
+	for i := range checklist {
+		checklist[i].Done = false
+	}
+	status := s.initialStatus()
+	parentID := task.ParentID
+	if err := s.checkParent(ctx, 0, parentID, status); errors.Is(err, ErrParentNotFound) || errors.Is(err, ErrParentDone) {
+		parentID = nil
+	} else if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	next, err := s.store.Create(ctx, models.Task{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      status,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+		DueAt:       &occurrences[0],
+		Priority:    task.Priority,
+		EffortHours: task.EffortHours,
+		Labels:      append([]string(nil), task.Labels...),
+		ParentID:    parentID,
+		Recurrence:  &recurrence,
+		Checklist:   checklist,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// initialStatus returns the first open status of the workflow, or its first status when none is open
+func (s *TaskService) initialStatus() string {
+	workflow := s.Workflow()
+	for _, status := range workflow.Statuses {
+		if status.Category == models.CategoryOpen {
+			return status.Name
+		}
+	}
+	return workflow.Statuses[0].Name
+}
+
+// Occurrences returns the upcoming occurrences of a recurring task using the default service
+func Occurrences(ctx context.Context, id int, after *time.Time, limit int) ([]time.Time, error) {
+	return defaultService.Occurrences(ctx, id, after, limit)
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"log"
+	"sort"
+	"time"
+)
+
+// DefaultReminderInterval is how often due reminders are sent unless configured otherwise
+const DefaultReminderInterval = 30 * time.Second
+
+const (
+	// ReminderRetryDelay is the delay before the first retry of a reminder that failed to send. It doubles with every
+	// failure.
+	ReminderRetryDelay = time.Minute
+	// MaxReminderRetryDelay caps the delay between two attempts of a reminder
+	MaxReminderRetryDelay = time.Hour
+	// MaxReminderAttempts is the number of failed attempts after which a reminder is given up for its fire time
+	MaxReminderAttempts = 5
+)
+
+// LogChannel is the reminder channel that writes notifications to the log. It is always available.
+const LogChannel = "log"
+
+var (
+	// ErrReminderNotFound is returned when the requested reminder does not exist or does not belong to the task
+	ErrReminderNotFound = models.ErrReminderNotFound
+	// ErrUnknownChannel is returned for a reminder channel no notifier is configured for
+	ErrUnknownChannel = errors.New("unknown reminder channel")
+	// ErrInvalidReminder is returned for a reminder firing after the due date of its task
+	ErrInvalidReminder = errors.New("reminders must fire before the due date")
+	// ErrInvalidRecipient is returned for a recipient the notifier of the reminder channel refuses
+	ErrInvalidRecipient = errors.New("invalid reminder recipient")
+	// ErrRemindersUnsupported is returned when the store does not implement models.ReminderStore
+	ErrRemindersUnsupported = errors.New("the task store does not support reminders")
+	// errAlreadyFired aborts claiming a reminder that already fired for its current fire time
+	errAlreadyFired = errors.New("reminder already fired")
+)
+
+// SetNotifier configures the notifier of a reminder channel, or removes the channel when notifier is nil
+func (s *TaskService) SetNotifier(channel string, notifier models.Notifier) {
+	s.notifiersMutex.Lock()
+	defer s.notifiersMutex.Unlock()
+
+	if notifier == nil {
+		delete(s.notifiers, channel)
+	} else {
+		s.notifiers[channel] = notifier
+	}
+}
+
+// Channels returns the names of the configured reminder channels in alphabetical order
+func (s *TaskService) Channels() []string {
+	s.notifiersMutex.RLock()
+	defer s.notifiersMutex.RUnlock()
+
+	channels := make([]string, 0, len(s.notifiers))
+	for channel := range s.notifiers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// notifier returns the notifier of a reminder channel
+func (s *TaskService) notifier(channel string) (models.Notifier, bool) {
+	s.notifiersMutex.RLock()
+	defer s.notifiersMutex.RUnlock()
+
+	notifier, ok := s.notifiers[channel]
+	return notifier, ok
+}
+
+// ListReminders returns the reminders of a task ordered by ID
+func (s *TaskService) ListReminders(ctx context.Context, taskID int) ([]models.Reminder, error) {
+	if s.reminders == nil {
+		return nil, ErrRemindersUnsupported
+	}
+	task, err := s.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := s.reminders.ListReminders(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reminders {
+		reminders[i] = s.presentReminder(reminders[i], task)
+	}
+	return reminders, nil
+}
+
+// GetReminder returns a reminder of a task
+func (s *TaskService) GetReminder(ctx context.Context, taskID, id int) (models.Reminder, error) {
+	if s.reminders == nil {
+		return models.Reminder{}, ErrRemindersUnsupported
+	}
+	task, err := s.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	reminder, err := s.taskReminder(ctx, taskID, id)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	return s.presentReminder(reminder, task), nil
+}
+
+// AddReminder adds a reminder to a task firing reminder.Before its due date through reminder.Channel
+func (s *TaskService) AddReminder(ctx context.Context, taskID int, reminder models.Reminder) (models.Reminder, error) {
+	if s.reminders == nil {
+		return models.Reminder{}, ErrRemindersUnsupported
+	}
+	if err := s.checkReminder(reminder); err != nil {
+		return models.Reminder{}, err
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	task, err := s.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	created, err := s.reminders.CreateReminder(ctx, models.Reminder{
+		TaskID:    taskID,
+		Before:    reminder.Before,
+		Channel:   reminder.Channel,
+		Recipient: reminder.Recipient,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	return s.presentReminder(created, task), nil
+}
+
+// UpdateReminder replaces the offset, channel and recipient of a reminder and cancels its snooze.
+// A reminder that already fired fires again when its fire time changes.
+func (s *TaskService) UpdateReminder(ctx context.Context, taskID, id int, reminder models.Reminder) (models.Reminder, error) {
+	if s.reminders == nil {
+		return models.Reminder{}, ErrRemindersUnsupported
+	}
+	if err := s.checkReminder(reminder); err != nil {
+		return models.Reminder{}, err
+	}
+	return s.updateReminder(ctx, taskID, id, func(current *models.Reminder) {
+		current.Before = reminder.Before
+		current.Channel = reminder.Channel
+		current.Recipient = reminder.Recipient
+		current.SnoozedUntil = nil
+	})
+}
+
+// SnoozeReminder makes a reminder fire at until instead of before the due date of its task,
+// even when it already fired
+func (s *TaskService) SnoozeReminder(ctx context.Context, taskID, id int, until time.Time) (models.Reminder, error) {
+	if s.reminders == nil {
+		return models.Reminder{}, ErrRemindersUnsupported
+	}
+	return s.updateReminder(ctx, taskID, id, func(current *models.Reminder) {
+		current.SnoozedUntil = &until
+	})
+}
+
+// DeleteReminder removes a reminder from a task
+func (s *TaskService) DeleteReminder(ctx context.Context, taskID, id int) error {
+	if s.reminders == nil {
+		return ErrRemindersUnsupported
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := s.GetTaskByID(ctx, taskID); err != nil {
+		return err
+	}
+	if _, err := s.taskReminder(ctx, taskID, id); err != nil {
+		return err
+	}
+	return s.reminders.DeleteReminder(ctx, id)
+}
+
+// ProcessReminders sends the reminders due at now and returns how many were sent.
+// A reminder is claimed in the store before it is sent, so it is sent at most once for each fire time,
+// even across restarts. A failure is recorded and the reminder retried after ReminderRetryDelay, doubling with every
+// failure up to MaxReminderRetryDelay, until it failed MaxReminderAttempts times.
+// Reminders of tasks that are done or in the trash are not sent.
+func (s *TaskService) ProcessReminders(ctx context.Context, now time.Time) (int, error) {
+	if s.reminders == nil {
+		return 0, nil
+	}
+	reminders, err := s.reminders.AllReminders(ctx)
+	if err != nil || len(reminders) == 0 {
+		return 0, err
+	}
+	tasks, err := s.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tasksByID := make(map[int]models.Task, len(tasks))
+	for _, task := range tasks {
+		tasksByID[task.ID] = task
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		task, ok := tasksByID[reminder.TaskID]
+		if !ok {
+			continue
+		}
+		fireAt, sendAt, pending := s.pendingFireTime(reminder, task)
+		if !pending || sendAt.After(now) {
+			continue
+		}
+		claimed, err := s.reminders.UpdateReminder(ctx, reminder.ID, func(current *models.Reminder) error {
+			if current.FiredFor != nil && current.FiredFor.Equal(fireAt) {
+				if current.RetryAt == nil || current.RetryAt.After(now) {
+					return errAlreadyFired
+				}
+			} else {
+				current.Failures = 0
+				current.LastError = ""
+			}
+			current.FiredFor = &fireAt
+			current.FiredAt = &now
+			current.RetryAt = nil
+			return nil
+		})
+		if errors.Is(err, errAlreadyFired) || errors.Is(err, ErrReminderNotFound) {
+			continue
+		} else if err != nil {
+			return sent, err
+		}
+
+		if err := s.sendReminder(ctx, claimed, task); err != nil {
+			log.Printf("failed to send reminder %d through %s: %v", claimed.ID, claimed.Channel, err)
+			if err := s.recordReminderFailure(ctx, claimed.ID, fireAt, err, now); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if claimed.Failures > 0 {
+			if _, err := s.reminders.UpdateReminder(ctx, claimed.ID, func(current *models.Reminder) error {
+				current.Failures = 0
+				current.LastError = ""
+				return nil
+			}); err != nil && !errors.Is(err, ErrReminderNotFound) {
+				return sent, err
+			}
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// sendReminder notifies the reminder of the task through its channel
+func (s *TaskService) sendReminder(ctx context.Context, reminder models.Reminder, task models.Task) error {
+	notifier, ok := s.notifier(reminder.Channel)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownChannel, reminder.Channel)
+	}
+	return notifier.Notify(ctx, models.Notification{Reminder: reminder, Task: s.present(task)})
+}
+
+// recordReminderFailure records that a reminder failed to send for fireAt and schedules its retry,
+// unless it failed MaxReminderAttempts times
+func (s *TaskService) recordReminderFailure(ctx context.Context, id int, fireAt time.Time, failure error, now time.Time) error {
+	_, err := s.reminders.UpdateReminder(ctx, id, func(current *models.Reminder) error {
+		if current.FiredFor == nil || !current.FiredFor.Equal(fireAt) {
+			// The reminder was rescheduled while it was being sent
+			return errAlreadyFired
+		}
+		current.Failures++
+		current.LastError = failure.Error()
+		if current.Failures < MaxReminderAttempts {
+			retryAt := now.Add(backoff(ReminderRetryDelay, MaxReminderRetryDelay, current.Failures))
+			current.RetryAt = &retryAt
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyFired) || errors.Is(err, ErrReminderNotFound) {
+		return nil
+	}
+	return err
+}
+
+// RunReminders sends the due reminders every interval, until ctx is done
+func (s *TaskService) RunReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := s.ProcessReminders(ctx, time.Now())
+		if err != nil {
+			log.Printf("failed to send reminders: %v", err)
+		} else if sent > 0 {
+			log.Printf("sent %d reminders", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReminder checks the offset and the channel of a reminder
+func (s *TaskService) checkReminder(reminder models.Reminder) error {
+	if reminder.Before < 0 {
+		return ErrInvalidReminder
+	}
+	notifier, ok := s.notifier(reminder.Channel)
+	if !ok {
+		return ErrUnknownChannel
+	}
+	if checker, ok := notifier.(models.RecipientChecker); ok && reminder.Recipient != "" {
+		if err := checker.CheckRecipient(reminder.Recipient); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	}
+	return nil
+}
+
+// updateReminder applies change to a reminder of a task
+func (s *TaskService) updateReminder(ctx context.Context, taskID, id int, change func(reminder *models.Reminder)) (models.Reminder, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	task, err := s.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	updated, err := s.reminders.UpdateReminder(ctx, id, func(reminder *models.Reminder) error {
+		if reminder.TaskID != taskID {
+			return ErrReminderNotFound
+		}
+		change(reminder)
+		return nil
+	})
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	return s.presentReminder(updated, task), nil
+}
+
+// taskReminder returns a reminder, or ErrReminderNotFound when it belongs to another task
+func (s *TaskService) taskReminder(ctx context.Context, taskID, id int) (models.Reminder, error) {
+	reminder, err := s.reminders.GetReminder(ctx, id)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	if reminder.TaskID != taskID {
+		return models.Reminder{}, ErrReminderNotFound
+	}
+	return reminder, nil
+}
+
+// pendingFireTime returns the time a reminder fires at: the end of its snooze, otherwise its offset before
+// the due date of the task, and the time it is sent at: its fire time, or its retry time after a failure.
+// It reports false when the reminder will not fire: the task has no due date, is done or is in the trash,
+// or the reminder already fired for that time and is not retried.
+func (s *TaskService) pendingFireTime(reminder models.Reminder, task models.Task) (fireAt, sendAt time.Time, pending bool) {
+	if task.DeletedAt != nil || s.isDone(task.Status) {
+		return time.Time{}, time.Time{}, false
+	}
+	switch {
+	case reminder.SnoozedUntil != nil:
+		fireAt = *reminder.SnoozedUntil
+	case task.DueAt != nil:
+		fireAt = task.DueAt.Add(-time.Duration(reminder.Before))
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+	if reminder.FiredFor != nil && reminder.FiredFor.Equal(fireAt) {
+		if reminder.RetryAt == nil {
+			return time.Time{}, time.Time{}, false
+		}
+		return fireAt, *reminder.RetryAt, true
+	}
+	return fireAt, fireAt, true
+}
+
+// presentReminder fills the computed fields of a reminder read from the store
+func (s *TaskService) presentReminder(reminder models.Reminder, task models.Task) models.Reminder {
+	reminder.FireAt = nil
+	if _, sendAt, pending := s.pendingFireTime(reminder, task); pending {
+		reminder.FireAt = &sendAt
+	}
+	return reminder
+}
+
+// SetNotifier configures the notifier of a reminder channel of the default service
+func SetNotifier(channel string, notifier models.Notifier) {
+	defaultService.SetNotifier(channel, notifier)
+}
+
+// Channels returns the reminder channels of the default service
+func Channels() []string {
+	return defaultService.Channels()
+}
+
+// ListReminders returns the reminders of a task using the default service
+func ListReminders(ctx context.Context, taskID int) ([]models.Reminder, error) {
+	return defaultService.ListReminders(ctx, taskID)
+}
+
+// GetReminder returns a reminder of a task using the default service
+func GetReminder(ctx context.Context, taskID, id int) (models.Reminder, error) {
+	return defaultService.GetReminder(ctx, taskID, id)
+}
+
+// AddReminder adds a reminder to a task using the default service
+func AddReminder(ctx context.Context, taskID int, reminder models.Reminder) (models.Reminder, error) {
+	return defaultService.AddReminder(ctx, taskID, reminder)
+}
+
+// UpdateReminder replaces a reminder of a task using the default service
+func UpdateReminder(ctx context.Context, taskID, id int, reminder models.Reminder) (models.Reminder, error) {
+	return defaultService.UpdateReminder(ctx, taskID, id, reminder)
+}
+
+// SnoozeReminder snoozes a reminder of a task using the default service
+func SnoozeReminder(ctx context.Context, taskID, id int, until time.Time) (models.Reminder, error) {
+	return defaultService.SnoozeReminder(ctx, taskID, id, until)
+}
+
+// DeleteReminder removes a reminder from a task using the default service
+func DeleteReminder(ctx context.Context, taskID, id int) error {
+	return defaultService.DeleteReminder(ctx, taskID, id)
+}
+
+// RunReminders periodically sends the due reminders of the default service
+func RunReminders(ctx context.Context, interval time.Duration) {
+	defaultService.RunReminders(ctx, interval)
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+// MaxTreeDepth is the deepest level of subtasks returned by Children
+const MaxTreeDepth = 10
+
+var (
+	// ErrParentNotFound is returned when the parent of a task does not exist or is in the trash
+	ErrParentNotFound = errors.New("parent task not found")
+	// ErrParentCycle is returned when a task would become a subtask of itself or of one of its subtasks
+	ErrParentCycle = errors.New("a task cannot be a subtask of itself or of its subtasks")
+	// ErrOpenSubtasks is returned when a task with subtasks that are not done moves to a done status
+	ErrOpenSubtasks = errors.New("task has subtasks that are not done")
+	// ErrParentDone is returned when a task that is not done would be a subtask of a done task
+	ErrParentDone = errors.New("parent task is done")
+	// ErrHasSubtasks is returned when deleting a task with subtasks in the DeleteReject mode
+	ErrHasSubtasks = errors.New("task has subtasks")
+)
+
+// DeleteMode decides what happens to the subtasks of a deleted task
+type DeleteMode string
+
+const (
+	// DeleteReject refuses to delete a task that has subtasks. It is the default.
+	DeleteReject DeleteMode = "reject"
+	// DeleteCascade deletes the subtasks along with the task, recursively
+	DeleteCascade DeleteMode = "cascade"
+	// DeleteOrphan turns the subtasks into top-level tasks
+	DeleteOrphan DeleteMode = "orphan"
+)
+
+// Progress is the share of the descendants of a task, at any depth, that are done
+type Progress = models.Progress
+
+// TaskNode is a subtask with its own progress and subtasks
+type TaskNode struct {
+	models.Task
+	// Progress is set for tasks that have subtasks
+	Progress *Progress `json:"progress,omitempty"`
+	// Children holds the subtasks, up to the requested depth
+	Children []TaskNode `json:"children,omitempty"`
+}
+
+// taskTree indexes the tasks outside of the trash by parent
+type taskTree struct {
+	children map[int][]models.Task
+}
+
+// Children returns the subtasks of a task, ordered by ID, with their own subtasks nested up to depth levels
+func (s *TaskService) Children(ctx context.Context, id, depth int) ([]TaskNode, error) {
+	if _, err := s.GetTaskByID(ctx, id); err != nil {
+		return nil, err
+	}
+	tree, err := s.loadTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.nodes(tree, id, min(max(depth, 1), MaxTreeDepth)), nil
+}
+
+// TaskProgress returns the share of the descendants of a task that are done
+func (s *TaskService) TaskProgress(ctx context.Context, id int) (Progress, error) {
+	if _, err := s.GetTaskByID(ctx, id); err != nil {
+		return Progress{}, err
+	}
+	tree, err := s.loadTree(ctx)
+	if err != nil {
+		return Progress{}, err
+	}
+	return s.progress(tree, id), nil
+}
+
+func (s *TaskService) nodes(tree taskTree, id, depth int) []TaskNode {
+	children := tree.children[id]
+	nodes := make([]TaskNode, 0, len(children))
+	for _, child := range children {
+		node := TaskNode{Task: child}
+		if len(tree.children[child.ID]) > 0 {
+			progress := s.progress(tree, child.ID)
+			node.Progress = &progress
+			if depth > 1 {
+				node.Children = s.nodes(tree, child.ID, depth-1)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (s *TaskService) progress(tree taskTree, id int) Progress {
+	descendants := tree.descendants(id)
+	completed := 0
+	for _, descendant := range descendants {
+		if s.isDone(descendant.Status) {
+			completed++
+		}
+	}
+	return models.NewProgress(completed, len(descendants))
+}
+
+// loadTree reads the tasks outside of the trash. Subtasks of a task in the trash are not part of the tree.
+func (s *TaskService) loadTree(ctx context.Context) (taskTree, error) {
+	tasks, err := s.GetAllTasks(ctx)
+	if err != nil {
+		return taskTree{}, err
+	}
+	tree := taskTree{children: make(map[int][]models.Task)}
+	for _, task := range tasks {
+		if task.ParentID != nil {
+			tree.children[*task.ParentID] = append(tree.children[*task.ParentID], task)
+		}
+	}
+	return tree, nil
+}
+
+// descendants returns the subtasks of a task at any depth, each after its own subtasks
+func (tree taskTree) descendants(id int) []models.Task {
+	var descendants []models.Task
+	visited := map[int]bool{id: true}
+	var walk func(id int)
+	walk = func(id int) {
+		for _, child := range tree.children[id] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			walk(child.ID)
+			descendants = append(descendants, child)
+		}
+	}
+	walk(id)
+	return descendants
+}
+
+// cascadedSubtasks returns the IDs of the subtasks at any depth of a task in the trash that were deleted along with it,
+// at deletedAt, each after its parent
+func cascadedSubtasks(tasks []models.Task, id int, deletedAt time.Time) []int {
+	children := make(map[int][]int)
+	for _, task := range tasks {
+		if task.ParentID != nil && task.DeletedAt != nil && task.DeletedAt.Equal(deletedAt) {
+			children[*task.ParentID] = append(children[*task.ParentID], task.ID)
+		}
+	}
+	var ids []int
+	visited := map[int]bool{id: true}
+	queue := []int{id}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parent] {
+			if !visited[child] {
+				visited[child] = true
+				ids = append(ids, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return ids
+}
+
+// deleteSubtasks applies mode to the subtasks of a task about to be removed with remove.
+// The preconditions are checked against the task first, so that its subtasks are left untouched when they fail.
+// The caller must hold the write mutex.
+func (s *TaskService) deleteSubtasks(ctx context.Context, id int, mode DeleteMode, preconditions []Precondition,
+	remove func(ctx context.Context, id int, preconditions []Precondition) error) error {
+	task, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkPreconditions(task, preconditions); err != nil {
+		return err
+	}
+	tree, err := s.loadTree(ctx)
+	if err != nil {
+		return err
+	}
+	if len(tree.children[id]) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case DeleteCascade:
+		for _, descendant := range tree.descendants(id) {
+			if err := remove(ctx, descendant.ID, nil); err != nil {
+				return err
+			}
+		}
+	case DeleteOrphan:
+		for _, child := range tree.children[id] {
+			if _, err := s.updateLocked(ctx, child.ID, func(task *models.Task) error {
+				task.ParentID = nil
+				touch(task)
+				return nil
+			}, models.RevisionUpdated); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrHasSubtasks
+	}
+	return nil
+}
+
+// checkHierarchy enforces the subtask rules on a change of a task from before to after
+func (s *TaskService) checkHierarchy(ctx context.Context, before, after models.Task) error {
+	reopened := s.isDone(before.Status) && !s.isDone(after.Status)
+	if !sameParent(before.ParentID, after.ParentID) || reopened {
+		if err := s.checkParent(ctx, after.ID, after.ParentID, after.Status); err != nil {
+			return err
+		}
+	}
+	if s.isDone(after.Status) && !s.isDone(before.Status) {
+		tree, err := s.loadTree(ctx)
+		if err != nil {
+			return err
+		}
+		for _, descendant := range tree.descendants(after.ID) {
+			if !s.isDone(descendant.Status) {
+				return ErrOpenSubtasks
+			}
+		}
+	}
+	return nil
+}
+
+// checkParent verifies that the task with the given ID (0 for a new task) and status can be a subtask of parentID
+func (s *TaskService) checkParent(ctx context.Context, id int, parentID *int, status string) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		return ErrParentCycle
+	}
+	parent, err := s.store.Get(ctx, *parentID)
+	if errors.Is(err, ErrTaskNotFound) || err == nil && parent.DeletedAt != nil {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	parentDone := s.isDone(parent.Status)
+
+	// Walk up the ancestors of the parent, which must not include the task
+	visited := map[int]bool{parent.ID: true}
+	for id != 0 && parent.ParentID != nil && !visited[*parent.ParentID] {
+		if *parent.ParentID == id {
+			return ErrParentCycle
+		}
+		visited[*parent.ParentID] = true
+		if parent, err = s.store.Get(ctx, *parent.ParentID); errors.Is(err, ErrTaskNotFound) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if parentDone && !s.isDone(status) {
+		return ErrParentDone
+	}
+	return nil
+}
+
+// isDone reports whether the status is in the done category of the workflow
+func (s *TaskService) isDone(status string) bool {
+	workflowStatus, ok := s.Workflow().Status(status)
+	return ok && workflowStatus.Category == models.CategoryDone
+}
+
+func sameParent(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// Children returns the subtasks of a task using the default service
+func Children(ctx context.Context, id, depth int) ([]TaskNode, error) {
+	return defaultService.Children(ctx, id, depth)
+}
+
+// TaskProgress returns the progress of the subtasks of a task using the default service
+func TaskProgress(ctx context.Context, id int) (Progress, error) {
+	return defaultService.TaskProgress(ctx, id)
+}
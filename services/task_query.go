@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxListLimit is the largest page size accepted by ListTasks
+const MaxListLimit = 1000
+
+// ErrInvalidListOptions is returned by ListTasks for an unknown sort field, a bad limit or a malformed cursor
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListOptions filters, sorts and paginates the task list
+type ListOptions struct {
+	// Statuses keeps only tasks with one of the given statuses
+	Statuses []string
+	// Query keeps only tasks whose title or description contain it, case-insensitively
+	Query string
+	// Labels keeps only tasks carrying all of the given labels
+	Labels []string
+	// Overdue, when set, keeps only tasks that are (or are not) overdue
+	Overdue *bool
+	// DueBefore and DueAfter, when set, keep only tasks due strictly before or after them
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// Sort is a comma separated list of fields, each optionally prefixed with "-" for descending order.
+	// Ties are broken by ID. Defaults to "id".
+	Sort string
+	// Limit is the maximum number of tasks to return. 0 returns all of them.
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// TaskPage is a single page of tasks
+type TaskPage struct {
+	Tasks []models.Task
+	// Total is the number of tasks matching the filters, across all pages
+	Total int
+	// NextCursor fetches the next page, empty on the last page
+	NextCursor string
+}
+
+// sortField describes a field tasks can be sorted by
+type sortField struct {
+	compare func(a, b models.Task) int
+	// key and fromKey convert the field value to and from its cursor representation
+	key     func(task models.Task) string
+	fromKey func(key string, task *models.Task) error
+}
+
+var sortFields = map[string]sortField{
+	models.JsonID: {
+		compare: func(a, b models.Task) int { return compareInts(a.ID, b.ID) },
+		key:     func(task models.Task) string { return "" },
+		fromKey: func(string, *models.Task) error { return nil },
+	},
+	models.JsonTitle: {
+		compare: func(a, b models.Task) int { return strings.Compare(a.Title, b.Title) },
+		key:     func(task models.Task) string { return task.Title },
+		fromKey: func(key string, task *models.Task) error { task.Title = key; return nil },
+	},
+	models.JsonStatus: {
+		compare: func(a, b models.Task) int { return strings.Compare(a.Status, b.Status) },
+		key:     func(task models.Task) string { return task.Status },
+		fromKey: func(key string, task *models.Task) error { task.Status = key; return nil },
+	},
+	// Tasks without a priority or due date come last in ascending order
+	models.JsonPriority: {
+		compare: func(a, b models.Task) int {
+			return compareInts(models.PriorityRank(a.Priority), models.PriorityRank(b.Priority))
+		},
+		key:     func(task models.Task) string { return task.Priority },
+		fromKey: func(key string, task *models.Task) error { task.Priority = key; return nil },
+	},
+	models.JsonDueAt: {
+		compare: func(a, b models.Task) int {
+			switch {
+			case a.DueAt == nil && b.DueAt == nil:
+				return 0
+			case a.DueAt == nil:
+				return 1
+			case b.DueAt == nil:
+				return -1
+			}
+			return a.DueAt.Compare(*b.DueAt)
+		},
+		key: func(task models.Task) string {
+			if task.DueAt == nil {
+				return ""
+			}
+			return task.DueAt.Format(time.RFC3339Nano)
+		},
+		fromKey: func(key string, task *models.Task) error {
+			if key == "" {
+				return nil
+			}
+			dueAt, err := time.Parse(time.RFC3339Nano, key)
+			task.DueAt = &dueAt
+			return err
+		},
+	},
+	models.JsonCreatedAt: {
+		compare: func(a, b models.Task) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		key:     func(task models.Task) string { return task.CreatedAt.Format(time.RFC3339Nano) },
+		fromKey: func(key string, task *models.Task) (err error) {
+			task.CreatedAt, err = time.Parse(time.RFC3339Nano, key)
+			return err
+		},
+	},
+}
+
+// sortKey is a single parsed entry of ListOptions.Sort
+type sortKey struct {
+	name       string
+	descending bool
+	field      sortField
+}
+
+// listCursor is the opaque pagination cursor: the sort keys of the last task returned.
+// Resuming strictly after that position keeps pages stable while tasks are created or deleted.
+type listCursor struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+	ID   int      `json:"i"`
+}
+
+// ListTasks returns the tasks matching opts, in the requested order, one page at a time
+func (s *TaskService) ListTasks(ctx context.Context, opts ListOptions) (TaskPage, error) {
+	if opts.Limit < 0 || opts.Limit > MaxListLimit {
+		return TaskPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListOptions, MaxListLimit)
+	}
+	keys, normalizedSort, err := parseSort(opts.Sort)
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	var tasks []models.Task
+	if len(opts.Labels) > 0 && s.labels != nil {
+		tasks, err = s.tasksWithLabels(ctx, opts.Labels)
+	} else {
+		tasks, err = s.GetAllTasks(ctx)
+	}
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	tasks = filterTasks(tasks, opts)
+	compare := func(a, b models.Task) int {
+		for _, key := range keys {
+			if c := key.field.compare(a, b); c != 0 {
+				if key.descending {
+					return -c
+				}
+				return c
+			}
+		}
+		return compareInts(a.ID, b.ID)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return compare(tasks[i], tasks[j]) < 0 })
+
+	page := TaskPage{Tasks: tasks, Total: len(tasks)}
+	if opts.Cursor != "" {
+		position, err := decodeCursor(opts.Cursor, normalizedSort, keys)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		start := sort.Search(len(tasks), func(i int) bool { return compare(tasks[i], position) > 0 })
+		page.Tasks = tasks[start:]
+	}
+
+	if opts.Limit > 0 && len(page.Tasks) > opts.Limit {
+		page.Tasks = page.Tasks[:opts.Limit]
+		page.NextCursor = encodeCursor(normalizedSort, keys, page.Tasks[len(page.Tasks)-1])
+	}
+	return page, nil
+}
+
+// ListTasks returns a filtered, sorted page of tasks using the default service
+func ListTasks(ctx context.Context, opts ListOptions) (TaskPage, error) {
+	return defaultService.ListTasks(ctx, opts)
+}
+
+func filterTasks(tasks []models.Task, opts ListOptions) []models.Task {
+	query := strings.ToLower(opts.Query)
+	filtered := tasks[:0]
+	for _, task := range tasks {
+		if len(opts.Statuses) > 0 && !containsString(opts.Statuses, task.Status) {
+			continue
+		}
+		if query != "" &&
+			!strings.Contains(strings.ToLower(task.Title), query) &&
+			!strings.Contains(strings.ToLower(task.Description), query) {
+			continue
+		}
+		if !containsAll(task.Labels, opts.Labels) {
+			continue
+		}
+		if opts.Overdue != nil && task.Overdue != *opts.Overdue {
+			continue
+		}
+		if opts.DueBefore != nil && (task.DueAt == nil || !task.DueAt.Before(*opts.DueBefore)) {
+			continue
+		}
+		if opts.DueAfter != nil && (task.DueAt == nil || !task.DueAt.After(*opts.DueAfter)) {
+			continue
+		}
+		filtered = append(filtered, task)
+	}
+	return filtered
+}
+
+// parseSort parses ListOptions.Sort and returns the keys with their normalized representation
+func parseSort(value string) ([]sortKey, string, error) {
+	if value == "" {
+		value = models.JsonID
+	}
+
+	var keys []sortKey
+	for _, name := range strings.Split(value, ",") {
+		key := sortKey{name: strings.TrimSpace(name)}
+		if strings.HasPrefix(key.name, "-") {
+			key.name = key.name[1:]
+			key.descending = true
+		}
+		field, ok := sortFields[key.name]
+		if !ok {
+			return nil, "", fmt.Errorf("%w: unknown sort field %q", ErrInvalidListOptions, key.name)
+		}
+		key.field = field
+		keys = append(keys, key)
+	}
+
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.name
+		if key.descending {
+			names[i] = "-" + key.name
+		}
+	}
+	return keys, strings.Join(names, ","), nil
+}
+
+func encodeCursor(normalizedSort string, keys []sortKey, last models.Task) string {
+	cursor := listCursor{Sort: normalizedSort, ID: last.ID, Keys: make([]string, len(keys))}
+	for i, key := range keys {
+		cursor.Keys[i] = key.field.key(last)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns a task positioned at the cursor. The cursor must have been issued for the same sort order.
+func decodeCursor(value, normalizedSort string, keys []sortKey) (models.Task, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return models.Task{}, invalid
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Keys) != len(keys) {
+		return models.Task{}, invalid
+	}
+	if cursor.Sort != normalizedSort {
+		return models.Task{}, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidListOptions, cursor.Sort)
+	}
+
+	position := models.Task{ID: cursor.ID}
+	for i, key := range keys {
+		if err := key.field.fromKey(cursor.Keys[i], &position); err != nil {
+			return models.Task{}, invalid
+		}
+	}
+	return position, nil
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(values, wanted []string) bool {
+	for _, value := range wanted {
+		if !containsString(values, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,73 +1,459 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/notify"
+	"log"
+	"net/http"
+	"sync"
 	"time"
 )
 
 const TaskNotFound = "task not found"
 
-// CreateTask adds a new task to the in-memory database
-func CreateTask(task models.Task) models.Task {
-	models.DB.Mutex.Lock()
-	defer models.DB.Mutex.Unlock()
+// ErrTaskNotFound is returned when the requested task does not exist
+var ErrTaskNotFound = models.ErrTaskNotFound
 
-	task.ID = models.DB.NextID
-	models.DB.NextID++
+// ErrPreconditionFailed is returned when a Precondition rejects the current state of a task
+var ErrPreconditionFailed = errors.New("task was modified since it was last read")
+
+// Precondition is checked against the current task, atomically with the change it guards.
+// It returns ErrPreconditionFailed (or another error) to abort the change.
+type Precondition func(current models.Task) error
+
+// keyLockCount is the number of locks idempotency keys are striped across
+const keyLockCount = 64
+
+// TaskService implements the task business logic on top of a models.TaskStore
+type TaskService struct {
+	store models.TaskStore
+	// idempotency is the store itself when it implements models.IdempotencyStore,
+	// otherwise keys are only kept in memory
+	idempotency models.IdempotencyStore
+	// history is the store itself when it implements models.HistoryStore,
+	// otherwise revisions are only kept in memory
+	history models.HistoryStore
+	// labels is the store itself when it implements models.LabelStore, nil otherwise
+	labels models.LabelStore
+	// links is the store itself when it implements models.LinkStore, nil otherwise
+	links models.LinkStore
+	// comments is the store itself when it implements models.CommentStore, nil otherwise
+	comments models.CommentStore
+	// attachments is the store itself when it implements models.AttachmentStore, nil otherwise
+	attachments models.AttachmentStore
+	// reminders is the store itself when it implements models.ReminderStore, nil otherwise
+	reminders models.ReminderStore
+	// webhooks is the store itself when it implements models.WebhookStore, nil otherwise
+	webhooks models.WebhookStore
+	// writeMutex serializes the changes of tasks with the revisions recording them
+	writeMutex sync.Mutex
+
+	// workflowMutex guards the workflow and the blocker policy
+	workflowMutex sync.RWMutex
+	workflow      models.Workflow
+	blockerPolicy BlockerPolicy
+
+	// attachmentsMutex guards the blob store and the maximum attachment size
+	attachmentsMutex  sync.RWMutex
+	blobs             models.BlobStore
+	maxAttachmentSize int64
+	// thumbnailMutex serializes the generation of thumbnails
+	thumbnailMutex sync.Mutex
+
+	// notifiersMutex guards the notifiers of the reminder channels by name
+	notifiersMutex sync.RWMutex
+	notifiers      map[string]models.Notifier
+
+	// deliveryMutex guards the webhook client and the IDs of the deliveries being sent
+	deliveryMutex sync.Mutex
+	webhookClient *http.Client
+	sending       map[int]bool
+	// deliverySlots limits the number of webhooks delivered concurrently
+	deliverySlots chan struct{}
+	// webhookWake signals that new webhook deliveries are due
+	webhookWake chan struct{}
+	// webhookHostsMutex guards the hosts webhooks may target although they are internal
+	webhookHostsMutex sync.RWMutex
+	webhookHosts      map[string]bool
+	// feed broadcasts task events to the change feed
+	feed *eventFeed
+
+	adminMutex sync.RWMutex
+	adminToken string
+
+	idempotencyMutex     sync.Mutex
+	idempotencyTTL       time.Duration
+	lastIdempotencyPurge time.Time
+	keyLocks             [keyLockCount]sync.Mutex
+}
+
+// NewTaskService returns a TaskService backed by the given store
+func NewTaskService(store models.TaskStore) *TaskService {
+	s := &TaskService{
+		store:             store,
+		workflow:          models.DefaultWorkflow(),
+		blockerPolicy:     BlockerPolicyWarn,
+		idempotencyTTL:    DefaultIdempotencyTTL,
+		maxAttachmentSize: DefaultMaxAttachmentSize,
+		notifiers:         map[string]models.Notifier{LogChannel: notify.NewLogNotifier(nil)},
+		webhookWake:       make(chan struct{}, 1),
+		sending:           make(map[int]bool),
+		deliverySlots:     make(chan struct{}, maxDeliveryWorkers),
+		feed:              newEventFeed(),
+	}
+	s.webhookClient = s.newWebhookClient()
+	if idempotency, ok := store.(models.IdempotencyStore); ok {
+		s.idempotency = idempotency
+	} else {
+		s.idempotency = models.NewDatabase()
+	}
+	if history, ok := store.(models.HistoryStore); ok {
+		s.history = history
+	} else {
+		s.history = models.NewDatabase()
+	}
+	if labels, ok := store.(models.LabelStore); ok {
+		s.labels = labels
+	}
+	if links, ok := store.(models.LinkStore); ok {
+		s.links = links
+	}
+	if comments, ok := store.(models.CommentStore); ok {
+		s.comments = comments
+	}
+	if attachments, ok := store.(models.AttachmentStore); ok {
+		s.attachments = attachments
+	}
+	if reminders, ok := store.(models.ReminderStore); ok {
+		s.reminders = reminders
+	}
+	if webhooks, ok := store.(models.WebhookStore); ok {
+		s.webhooks = webhooks
+	}
+	return s
+}
+
+// defaultService is used by the package level functions and the HTTP handlers
+var defaultService = NewTaskService(&models.DB)
+
+// SetStore replaces the storage backend used by the package level functions.
+// It is meant to be called once during startup, before serving requests.
+func SetStore(store models.TaskStore) {
+	defaultService = NewTaskService(store)
+}
+
+// CreateTask adds a new task to the store
+func (s *TaskService) CreateTask(ctx context.Context, task models.Task) (models.Task, error) {
+	task.Status = s.canonicalStatus(task.Status)
+	task.Overdue = false
+	task.Labels = models.NormalizeLabels(task.Labels)
+	task.BlockedBy = models.NormalizeIDs(task.BlockedBy)
+	task.Checklist = newChecklist(task.Checklist, task.Description)
+	task.ChecklistProgress = nil
+	task.NextOccurrenceID = nil
+	if err := normalizeRecurrence(&task); err != nil {
+		return models.Task{}, err
+	}
+	if !s.isDone(task.Status) {
+		task.Resolution = ""
+	}
 	task.CreatedAt = time.Now()
-	models.DB.Tasks[task.ID] = &task
-	return task
+	task.UpdatedAt = task.CreatedAt
+	task.Version = 1
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if err := s.checkParent(ctx, 0, task.ParentID, task.Status); err != nil {
+		return models.Task{}, err
+	}
+	if err := s.checkDependencies(ctx, models.Task{}, task); err != nil {
+		return models.Task{}, err
+	}
+
+	created, err := s.store.Create(ctx, task)
+	if err != nil {
+		return models.Task{}, err
+	}
+	s.record(ctx, models.Revision{Action: models.RevisionCreated}, nil, &created)
+	return s.present(created), nil
 }
 
-// GetAllTasks retrieves all tasks from the database
-func GetAllTasks() []models.Task {
-	models.DB.Mutex.RLock()
-	defer models.DB.Mutex.RUnlock()
+// GetAllTasks retrieves all tasks that are not in the trash
+func (s *TaskService) GetAllTasks(ctx context.Context) ([]models.Task, error) {
+	tasks, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := tasks[:0]
+	for _, task := range tasks {
+		if task.DeletedAt == nil {
+			active = append(active, task)
+		}
+	}
+	return s.presentAll(active), nil
+}
 
-	tasks := make([]models.Task, 0, len(models.DB.Tasks))
-	for _, task := range models.DB.Tasks {
-		tasks = append(tasks, *task)
+// GetTaskByID retrieves a task by its ID. Tasks in the trash are not found.
+func (s *TaskService) GetTaskByID(ctx context.Context, id int) (models.Task, error) {
+	task, err := s.store.Get(ctx, id)
+	if err != nil {
+		return models.Task{}, err
 	}
-	return tasks
+	if task.DeletedAt != nil {
+		return models.Task{}, ErrTaskNotFound
+	}
+	return s.present(task), nil
 }
 
-// GetTaskByID retrieves a task by its ID
-func GetTaskByID(id int) (models.Task, error) {
-	models.DB.Mutex.RLock()
-	defer models.DB.Mutex.RUnlock()
+// UpdateTask replaces the title, description, status, resolution, due date, priority, effort, labels, parent,
+// blockers and recurrence of an existing task. Its checklist is kept: it is managed item by item.
+// The status change must be allowed by the workflow, otherwise ErrIllegalTransition is returned.
+// Completing a recurring task creates the task of its next occurrence.
+func (s *TaskService) UpdateTask(ctx context.Context, id int, updatedTask models.Task, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		status := s.canonicalStatus(updatedTask.Status)
+		if err := s.checkTransition(task.Status, status); err != nil {
+			return err
+		}
+		task.Title = updatedTask.Title
+		task.Description = updatedTask.Description
+		task.Status = status
+		task.Resolution = updatedTask.Resolution
+		task.DueAt = updatedTask.DueAt
+		task.Priority = updatedTask.Priority
+		task.EffortHours = updatedTask.EffortHours
+		task.Labels = updatedTask.Labels
+		task.ParentID = updatedTask.ParentID
+		task.BlockedBy = updatedTask.BlockedBy
+		task.Recurrence = updatedTask.Recurrence
+		if err := normalizeRecurrence(task); err != nil {
+			return err
+		}
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
 
-	task, exists := models.DB.Tasks[id]
-	if !exists {
-		return models.Task{}, errors.New(TaskNotFound)
+// PatchTask partially updates an existing task. apply receives the current task and changes only the fields
+// that were sent, atomically with respect to other updates. The ID, version, timestamps, checklist and next occurrence
+// cannot be changed.
+// If apply returns an error, the task is left untouched and the error is returned.
+func (s *TaskService) PatchTask(ctx context.Context, id int, apply func(task *models.Task) error, preconditions ...Precondition) (models.Task, error) {
+	return s.update(ctx, id, func(task *models.Task) error {
+		current := *task
+		if err := checkPreconditions(current, preconditions); err != nil {
+			return err
+		}
+		if err := apply(task); err != nil {
+			return err
+		}
+		task.Status = s.canonicalStatus(task.Status)
+		if err := s.checkTransition(current.Status, task.Status); err != nil {
+			return err
+		}
+		task.ID = current.ID
+		task.CreatedAt = current.CreatedAt
+		task.UpdatedAt = current.UpdatedAt
+		task.Version = current.Version
+		task.DeletedAt = current.DeletedAt
+		task.Checklist = current.Checklist
+		task.NextOccurrenceID = current.NextOccurrenceID
+		if err := normalizeRecurrence(task); err != nil {
+			return err
+		}
+		touch(task)
+		return nil
+	}, models.RevisionUpdated)
+}
+
+// DeleteTask moves a task to the trash, from which RestoreTask brings it back until it is purged.
+// mode decides what happens to its subtasks.
+func (s *TaskService) DeleteTask(ctx context.Context, id int, mode DeleteMode, preconditions ...Precondition) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if mode == DeleteCascade {
+		return s.trashTreeLocked(ctx, id, preconditions)
 	}
-	return *task, nil
+	if err := s.deleteSubtasks(ctx, id, mode, preconditions, s.trashLocked); err != nil {
+		return err
+	}
+	return s.trashLocked(ctx, id, preconditions)
 }
 
-// UpdateTask updates an existing task
-func UpdateTask(id int, updatedTask models.Task) (models.Task, error) {
-	models.DB.Mutex.Lock()
-	defer models.DB.Mutex.Unlock()
+// trashTreeLocked moves a task and its subtasks at any depth to the trash atomically, with the same deletion time
+// so that RestoreTask brings them back together. The caller must hold the write mutex.
+func (s *TaskService) trashTreeLocked(ctx context.Context, id int, preconditions []Precondition) error {
+	tree, err := s.loadTree(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, descendant := range tree.descendants(id) {
+		ids = append(ids, descendant.ID)
+	}
+	ids = append(ids, id)
 
-	task, exists := models.DB.Tasks[id]
-	if !exists {
-		return models.Task{}, errors.New(TaskNotFound)
+	now := time.Now()
+	before, after, err := s.store.UpdateTasks(ctx, ids, func(task *models.Task) error {
+		if task.DeletedAt != nil {
+			return ErrTaskNotFound
+		}
+		if task.ID == id {
+			if err := checkPreconditions(*task, preconditions); err != nil {
+				return err
+			}
+		}
+		task.DeletedAt = &now
+		touch(task)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range before {
+		s.record(ctx, models.Revision{Action: models.RevisionDeleted}, &before[i], &after[i])
 	}
+	return nil
+}
 
-	task.Title = updatedTask.Title
-	task.Description = updatedTask.Description
-	task.Status = updatedTask.Status
-	return *task, nil
+// trashLocked moves a task to the trash. The caller must hold the write mutex.
+func (s *TaskService) trashLocked(ctx context.Context, id int, preconditions []Precondition) error {
+	_, err := s.updateLocked(ctx, id, func(task *models.Task) error {
+		if err := checkPreconditions(*task, preconditions); err != nil {
+			return err
+		}
+		now := time.Now()
+		task.DeletedAt = &now
+		touch(task)
+		return nil
+	}, models.RevisionDeleted)
+	return err
 }
 
-// DeleteTask removes a task by its ID
-func DeleteTask(id int) error {
-	models.DB.Mutex.Lock()
-	defer models.DB.Mutex.Unlock()
+// update changes a task that is not in the trash and records the change in its history as action
+func (s *TaskService) update(ctx context.Context, id int, change func(task *models.Task) error, action string) (models.Task, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.updateLocked(ctx, id, change, action)
+}
+
+// updateLocked is update for callers that hold the write mutex
+func (s *TaskService) updateLocked(ctx context.Context, id int, change func(task *models.Task) error, action string) (models.Task, error) {
+	// The subtask rules read other tasks, which the store cannot do while it updates the task.
+	// The change is tried on a copy first: the write mutex guarantees it has the same outcome below.
+	current, err := s.store.Get(ctx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if current.DeletedAt != nil {
+		return models.Task{}, ErrTaskNotFound
+	}
+	proposed := current
+	proposed.Labels = append([]string(nil), current.Labels...)
+	if err := change(&proposed); err != nil {
+		return models.Task{}, err
+	}
+	proposed.BlockedBy = models.NormalizeIDs(proposed.BlockedBy)
+	if err := s.checkHierarchy(ctx, current, proposed); err != nil {
+		return models.Task{}, err
+	}
+	if err := s.checkDependencies(ctx, current, proposed); err != nil {
+		return models.Task{}, err
+	}
+
+	// The next occurrence of a completed recurring task is created first, so the task can point to it
+	var next *models.Task
+	if action == models.RevisionUpdated && s.completesOccurrence(current, proposed) {
+		if next, err = s.createNextOccurrence(ctx, proposed); err != nil {
+			return models.Task{}, err
+		}
+	}
 
-	if _, exists := models.DB.Tasks[id]; !exists {
-		return errors.New(TaskNotFound)
+	var before models.Task
+	updated, err := s.store.Update(ctx, id, func(task *models.Task) error {
+		if task.DeletedAt != nil {
+			return ErrTaskNotFound
+		}
+		before = *task
+		if err := change(task); err != nil {
+			return err
+		}
+		task.Overdue = false
+		task.ChecklistProgress = nil
+		task.Labels = models.NormalizeLabels(task.Labels)
+		task.BlockedBy = models.NormalizeIDs(task.BlockedBy)
+		if !s.isDone(task.Status) {
+			task.Resolution = ""
+		}
+		if next != nil {
+			task.NextOccurrenceID = &next.ID
+		}
+		return nil
+	})
+	if err != nil {
+		if next != nil {
+			if deleteErr := s.store.Delete(ctx, next.ID, nil); deleteErr != nil {
+				log.Printf("failed to delete the next occurrence %d of task %d: %v", next.ID, id, deleteErr)
+			}
+		}
+		return models.Task{}, err
+	}
+	if next != nil {
+		s.record(ctx, models.Revision{Action: models.RevisionCreated}, nil, next)
+	}
+	s.record(ctx, models.Revision{Action: action}, &before, &updated)
+	return s.present(updated), nil
+}
+
+// touch marks the task as modified
+func touch(task *models.Task) {
+	task.Version++
+	task.UpdatedAt = time.Now()
+}
+
+func checkPreconditions(task models.Task, preconditions []Precondition) error {
+	for _, precondition := range preconditions {
+		if err := precondition(task); err != nil {
+			return err
+		}
 	}
-	delete(models.DB.Tasks, id)
 	return nil
 }
+
+// CreateTask adds a new task using the default service
+func CreateTask(ctx context.Context, task models.Task) (models.Task, error) {
+	return defaultService.CreateTask(ctx, task)
+}
+
+// GetAllTasks retrieves all tasks using the default service
+func GetAllTasks(ctx context.Context) ([]models.Task, error) {
+	return defaultService.GetAllTasks(ctx)
+}
+
+// GetTaskByID retrieves a task by its ID using the default service
+func GetTaskByID(ctx context.Context, id int) (models.Task, error) {
+	return defaultService.GetTaskByID(ctx, id)
+}
+
+// UpdateTask updates an existing task using the default service
+func UpdateTask(ctx context.Context, id int, updatedTask models.Task, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.UpdateTask(ctx, id, updatedTask, preconditions...)
+}
+
+// PatchTask partially updates an existing task using the default service
+func PatchTask(ctx context.Context, id int, apply func(task *models.Task) error, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.PatchTask(ctx, id, apply, preconditions...)
+}
+
+// DeleteTask moves a task to the trash using the default service
+func DeleteTask(ctx context.Context, id int, mode DeleteMode, preconditions ...Precondition) error {
+	return defaultService.DeleteTask(ctx, id, mode, preconditions...)
+}
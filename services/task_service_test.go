@@ -0,0 +1,894 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/notify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServices(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Task Service Suite")
+}
+
+var _ = Describe("Task Service Tests", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("should keep tasks of services backed by different stores isolated", func() {
+		first := NewTaskService(models.NewDatabase())
+		second := NewTaskService(models.NewDatabase())
+
+		created, err := first.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.ID).To(Equal(1))
+
+		_, err = second.GetTaskByID(ctx, created.ID)
+		Expect(err).To(MatchError(ErrTaskNotFound))
+
+		tasks, err := second.GetAllTasks(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(BeEmpty())
+	})
+
+	It("should return ErrTaskNotFound when updating or deleting a missing task", func() {
+		service := NewTaskService(models.NewDatabase())
+
+		_, err := service.UpdateTask(ctx, 1, models.Task{Title: "Task"})
+		Expect(err).To(MatchError(ErrTaskNotFound))
+		Expect(service.DeleteTask(ctx, 1, DeleteReject)).To(MatchError(ErrTaskNotFound))
+	})
+
+	It("should only purge tasks that were in the trash longer than the retention", func() {
+		service := NewTaskService(models.NewDatabase())
+		task := models.Task{Title: "Task", Description: "Description", Status: "TODO"}
+		for i := 0; i < 3; i++ {
+			_, err := service.CreateTask(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(service.DeleteTask(ctx, 1, DeleteReject)).To(Succeed())
+		cutoff := time.Now()
+		Expect(service.DeleteTask(ctx, 2, DeleteReject)).To(Succeed())
+
+		purged, err := service.PurgeTrash(ctx, cutoff)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(purged).To(Equal(1))
+
+		trash, err := service.ListTrash(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(trash).To(HaveLen(1))
+		Expect(trash[0].ID).To(Equal(2))
+
+		_, err = service.RestoreTask(ctx, 1)
+		Expect(err).To(MatchError(ErrTaskNotFound))
+	})
+
+	It("should reject a parent that would make a task a subtask of itself", func() {
+		service := NewTaskService(models.NewDatabase())
+		task := models.Task{Title: "Task", Description: "Description", Status: "TODO"}
+		for i := 1; i <= 3; i++ {
+			if i > 1 {
+				parentID := i - 1
+				task.ParentID = &parentID
+			}
+			_, err := service.CreateTask(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		_, err := service.PatchTask(ctx, 1, func(task *models.Task) error {
+			parentID := 3
+			task.ParentID = &parentID
+			return nil
+		})
+		Expect(err).To(MatchError(ErrParentCycle))
+
+		_, err = service.PatchTask(ctx, 2, func(task *models.Task) error {
+			task.ParentID = &task.ID
+			return nil
+		})
+		Expect(err).To(MatchError(ErrParentCycle))
+
+		missing := 10
+		task.ParentID = &missing
+		_, err = service.CreateTask(ctx, task)
+		Expect(err).To(MatchError(ErrParentNotFound))
+	})
+
+	It("should only start a blocked task under the warn blocker policy", func() {
+		service := NewTaskService(models.NewDatabase())
+		Expect(service.SetBlockerPolicy("ignore")).ToNot(Succeed())
+		Expect(service.SetBlockerPolicy(BlockerPolicyFail)).To(Succeed())
+
+		blocker, err := service.CreateTask(ctx, models.Task{Title: "Blocker", Description: "Description", Status: "TODO"})
+		Expect(err).ToNot(HaveOccurred())
+		blocked, err := service.CreateTask(ctx, models.Task{Title: "Blocked", Description: "Description", Status: "TODO", BlockedBy: []int{blocker.ID}})
+		Expect(err).ToNot(HaveOccurred())
+
+		start := func(task *models.Task) error {
+			task.Status = "in-progress"
+			return nil
+		}
+		_, err = service.PatchTask(ctx, blocked.ID, start)
+		Expect(err).To(MatchError(ErrBlocked))
+
+		Expect(service.SetBlockerPolicy(BlockerPolicyWarn)).To(Succeed())
+		started, err := service.PatchTask(ctx, blocked.ID, start)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(service.OpenBlockers(ctx, started)).To(Equal([]int{blocker.ID}))
+	})
+
+	Describe("recurring tasks", func() {
+		var service *TaskService
+
+		BeforeEach(func() {
+			service = NewTaskService(models.NewDatabase())
+		})
+
+		complete := func(task models.Task) models.Task {
+			task.Status = "Completed"
+			completed, err := service.UpdateTask(ctx, task.ID, task)
+			Expect(err).ToNot(HaveOccurred())
+			return completed
+		}
+
+		It("should create the next occurrence when an occurrence is completed", func() {
+			paris, err := time.LoadLocation("Europe/Paris")
+			Expect(err).ToNot(HaveOccurred())
+			due := time.Date(2024, 3, 29, 9, 0, 0, 0, paris)
+			created, err := service.CreateTask(ctx, models.Task{
+				Title: "Weekly report", Description: "Description", Status: "in-progress", DueAt: &due, Labels: []string{"reports"},
+				Checklist:  []models.ChecklistItem{{Text: "Write", Done: true}},
+				Recurrence: &models.Recurrence{RRule: "FREQ=WEEKLY;BYDAY=FR", Timezone: "Europe/Paris"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created.Recurrence.Start.Equal(due)).To(BeTrue())
+
+			completed := complete(created)
+			Expect(*completed.NextOccurrenceID).To(Equal(2))
+
+			next, err := service.GetTaskByID(ctx, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(next.Title).To(Equal("Weekly report"))
+			Expect(next.Status).To(Equal("TODO"))
+			Expect(next.Labels).To(Equal([]string{"reports"}))
+			Expect(next.Checklist).To(Equal([]models.ChecklistItem{{ID: 1, Text: "Write"}}))
+			// Daylight saving time starts in Paris on March 31st 2024: the occurrence stays at 9:00
+			Expect(next.DueAt.Equal(time.Date(2024, 4, 5, 9, 0, 0, 0, paris))).To(BeTrue())
+			Expect(next.DueAt.Location().String()).To(Equal("Europe/Paris"))
+
+			history, err := service.TaskHistory(ctx, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history).To(HaveLen(1))
+
+			// Completing the same occurrence again does not create another one
+			completed.Status = "TODO"
+			reopened, err := service.UpdateTask(ctx, 1, completed)
+			Expect(err).ToNot(HaveOccurred())
+			complete(reopened)
+			tasks, err := service.GetAllTasks(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tasks).To(HaveLen(2))
+		})
+
+		It("should skip excluded dates and stop at the end of the recurrence", func() {
+			start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+			task, err := service.CreateTask(ctx, models.Task{
+				Title: "Standup notes", Description: "Description", Status: "TODO",
+				Recurrence: &models.Recurrence{RRule: "FREQ=DAILY;COUNT=3", Start: start, ExDates: []time.Time{start.AddDate(0, 0, 1)}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.DueAt.Equal(start)).To(BeTrue())
+
+			occurrences, err := service.Occurrences(ctx, task.ID, nil, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(occurrences).To(Equal([]time.Time{start.AddDate(0, 0, 2)}))
+
+			completed := complete(task)
+			next, err := service.GetTaskByID(ctx, *completed.NextOccurrenceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(next.DueAt.Equal(start.AddDate(0, 0, 2))).To(BeTrue())
+
+			Expect(complete(next).NextOccurrenceID).To(BeNil())
+		})
+
+		It("should reject invalid recurrences", func() {
+			_, err := service.CreateTask(ctx, models.Task{
+				Title: "Task", Description: "Description", Status: "TODO", Recurrence: &models.Recurrence{RRule: "FREQ=DAILY"},
+			})
+			Expect(err).To(MatchError(ErrInvalidRecurrence))
+
+			_, err = service.Occurrences(ctx, 1, nil, 10)
+			Expect(err).To(MatchError(ErrTaskNotFound))
+		})
+	})
+
+	Describe("workflow", func() {
+		It("should load the example workflow configuration", func() {
+			workflow, err := LoadWorkflow("../config/workflow.json")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(workflow.Allows("Completed", "TODO")).To(BeTrue())
+			Expect(workflow.Allows("Completed", "Pending")).To(BeFalse())
+		})
+
+		It("should reject a workflow with transitions to undeclared statuses", func() {
+			service := NewTaskService(models.NewDatabase())
+			err := service.SetWorkflow(models.Workflow{
+				Statuses:    []models.WorkflowStatus{{Name: "TODO", Category: models.CategoryOpen}},
+				Transitions: map[string][]string{"TODO": {"Done"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(service.Workflow().StatusNames()).To(Equal(models.DefaultWorkflow().StatusNames()))
+		})
+	})
+
+	Describe("duplicate links", func() {
+		var service *TaskService
+
+		BeforeEach(func() {
+			service = NewTaskService(models.NewDatabase())
+			for i := 0; i < 2; i++ {
+				_, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("should not close the duplicate when the link cannot be created", func() {
+			service = NewTaskService(failingLinkStore{models.NewDatabase()})
+			for i := 0; i < 2; i++ {
+				_, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, err := service.LinkTasks(ctx, 1, LinkDuplicates, 2, "duplicate")
+			Expect(err).To(MatchError(errLinkStoreFull))
+			task, err := service.GetTaskByID(ctx, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(task.Status).To(Equal("TODO"))
+			Expect(task.Resolution).To(BeEmpty())
+		})
+
+		It("should not keep the link when the duplicate cannot be closed", func() {
+			Expect(service.SetWorkflow(models.Workflow{
+				Statuses: []models.WorkflowStatus{
+					{Name: "TODO", Category: models.CategoryOpen},
+					{Name: "in-progress", Category: models.CategoryInProgress},
+					{Name: "Completed", Category: models.CategoryDone},
+				},
+				Transitions: map[string][]string{"TODO": {"in-progress"}, "in-progress": {"Completed"}},
+			})).To(Succeed())
+
+			_, err := service.LinkTasks(ctx, 1, LinkDuplicates, 2, "duplicate")
+			Expect(err).To(MatchError(ErrIllegalTransition))
+			links, err := service.TaskLinks(ctx, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links).To(BeEmpty())
+
+			link, err := service.LinkTasks(ctx, 2, LinkDuplicatedBy, 1, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link.TaskID).To(Equal(1))
+		})
+	})
+
+	Describe("idempotent creation", func() {
+		var service *TaskService
+		var task models.Task
+
+		BeforeEach(func() {
+			service = NewTaskService(models.NewDatabase())
+			task = models.Task{Title: "Task", Description: "Description", Status: "TODO"}
+		})
+
+		It("should create the task only once for concurrent requests with the same key", func() {
+			results := make(chan models.Task, 10)
+			for i := 0; i < cap(results); i++ {
+				go func() {
+					defer GinkgoRecover()
+					created, _, err := service.CreateTaskIdempotent(ctx, "key", task)
+					Expect(err).ToNot(HaveOccurred())
+					results <- created
+				}()
+			}
+			for i := 0; i < cap(results); i++ {
+				Expect((<-results).ID).To(Equal(1))
+			}
+
+			tasks, err := service.GetAllTasks(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tasks).To(HaveLen(1))
+		})
+
+		It("should forget keys once they expire", func() {
+			service.SetIdempotencyTTL(time.Millisecond)
+
+			first, _, err := service.CreateTaskIdempotent(ctx, "key", task)
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(2 * time.Millisecond)
+
+			second, replayed, err := service.CreateTaskIdempotent(ctx, "key", task)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replayed).To(BeFalse())
+			Expect(second.ID).ToNot(Equal(first.ID))
+		})
+	})
+
+	Describe("reminders", func() {
+		var (
+			db       *models.Database
+			service  *TaskService
+			notifier *recordingNotifier
+			due      time.Time
+			task     models.Task
+		)
+
+		BeforeEach(func() {
+			db = models.NewDatabase()
+			service = NewTaskService(db)
+			notifier = &recordingNotifier{}
+			service.SetNotifier("test", notifier)
+
+			due = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+			var err error
+			task, err = service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO", DueAt: &due})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		process := func(now time.Time) int {
+			sent, err := service.ProcessReminders(ctx, now)
+			Expect(err).ToNot(HaveOccurred())
+			return sent
+		}
+
+		It("should send each reminder once at its offset before the due date, even after a restart", func() {
+			for _, before := range []time.Duration{24 * time.Hour, time.Hour} {
+				_, err := service.AddReminder(ctx, task.ID, models.Reminder{Before: models.Duration(before), Channel: "test"})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(process(due.Add(-25 * time.Hour))).To(Equal(0))
+			Expect(process(due.Add(-24 * time.Hour))).To(Equal(1))
+			Expect(process(due.Add(-2 * time.Hour))).To(Equal(0))
+			Expect(notifier.sent).To(HaveLen(1))
+			Expect(notifier.sent[0].Reminder.ID).To(Equal(1))
+			Expect(notifier.sent[0].Task.ID).To(Equal(task.ID))
+
+			// A new service over the same store knows which reminders already fired
+			service = NewTaskService(db)
+			service.SetNotifier("test", notifier)
+			Expect(process(due)).To(Equal(1))
+			Expect(process(due)).To(Equal(0))
+			Expect(notifier.sent).To(HaveLen(2))
+			Expect(notifier.sent[1].Reminder.ID).To(Equal(2))
+
+			reminders, err := service.ListReminders(ctx, task.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reminders[0].FiredAt).ToNot(BeNil())
+			Expect(reminders[0].FireAt).To(BeNil())
+		})
+
+		It("should send a snoozed reminder again at the end of its snooze", func() {
+			reminder, err := service.AddReminder(ctx, task.ID, models.Reminder{Before: models.Duration(time.Hour), Channel: "test"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reminder.FireAt.Equal(due.Add(-time.Hour))).To(BeTrue())
+			Expect(process(due.Add(-time.Hour))).To(Equal(1))
+
+			until := due.Add(-30 * time.Minute)
+			snoozed, err := service.SnoozeReminder(ctx, task.ID, reminder.ID, until)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snoozed.FireAt.Equal(until)).To(BeTrue())
+
+			Expect(process(due.Add(-45 * time.Minute))).To(Equal(0))
+			Expect(process(until)).To(Equal(1))
+			Expect(process(until)).To(Equal(0))
+			Expect(notifier.sent).To(HaveLen(2))
+		})
+
+		It("should retry a reminder that failed to send with backoff, then give up", func() {
+			reminder, err := service.AddReminder(ctx, task.ID, models.Reminder{Before: models.Duration(time.Hour), Channel: "test"})
+			Expect(err).ToNot(HaveOccurred())
+			notifier.err = errors.New("connection refused")
+
+			now := due.Add(-time.Hour)
+			Expect(process(now)).To(Equal(0))
+			failed, err := service.GetReminder(ctx, task.ID, reminder.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(failed.Failures).To(Equal(1))
+			Expect(failed.LastError).To(Equal("connection refused"))
+			Expect(failed.FireAt.Equal(now.Add(ReminderRetryDelay))).To(BeTrue())
+
+			Expect(process(now.Add(ReminderRetryDelay - time.Second))).To(Equal(0))
+			notifier.err = nil
+			Expect(process(now.Add(ReminderRetryDelay))).To(Equal(1))
+			sent, err := service.GetReminder(ctx, task.ID, reminder.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent.Failures).To(BeZero())
+			Expect(sent.FireAt).To(BeNil())
+
+			// After MaxReminderAttempts failures, the reminder is given up until it gets a new fire time
+			until := due.Add(-30 * time.Minute)
+			_, err = service.SnoozeReminder(ctx, task.ID, reminder.ID, until)
+			Expect(err).ToNot(HaveOccurred())
+			notifier.err = errors.New("connection refused")
+			now = until
+			for failures := 1; failures <= MaxReminderAttempts; failures++ {
+				process(now)
+				now = now.Add(backoff(ReminderRetryDelay, MaxReminderRetryDelay, failures))
+			}
+			notifier.err = nil
+			Expect(process(now.Add(24 * time.Hour))).To(Equal(0))
+			dead, err := service.GetReminder(ctx, task.ID, reminder.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dead.Failures).To(Equal(MaxReminderAttempts))
+			Expect(dead.RetryAt).To(BeNil())
+			Expect(dead.FireAt).To(BeNil())
+			Expect(notifier.sent).To(HaveLen(1))
+		})
+
+		It("should send a reminder again when the task is rescheduled", func() {
+			_, err := service.AddReminder(ctx, task.ID, models.Reminder{Before: models.Duration(time.Hour), Channel: "test"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(process(due)).To(Equal(1))
+
+			later := due.Add(24 * time.Hour)
+			task.DueAt = &later
+			_, err = service.UpdateTask(ctx, task.ID, task)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(process(due)).To(Equal(0))
+			Expect(process(later)).To(Equal(1))
+		})
+
+		It("should not send the reminders of tasks that are done, in the trash or not due", func() {
+			_, err := service.AddReminder(ctx, task.ID, models.Reminder{Channel: "test"})
+			Expect(err).ToNot(HaveOccurred())
+			task.Status = "Completed"
+			_, err = service.UpdateTask(ctx, task.ID, task)
+			Expect(err).ToNot(HaveOccurred())
+
+			undated, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = service.AddReminder(ctx, undated.ID, models.Reminder{Channel: "test"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(process(due.Add(time.Hour))).To(Equal(0))
+			Expect(notifier.sent).To(BeEmpty())
+		})
+
+		It("should reject unknown channels and negative offsets", func() {
+			_, err := service.AddReminder(ctx, task.ID, models.Reminder{Channel: "pager"})
+			Expect(err).To(MatchError(ErrUnknownChannel))
+			_, err = service.AddReminder(ctx, task.ID, models.Reminder{Before: -1, Channel: LogChannel})
+			Expect(err).To(MatchError(ErrInvalidReminder))
+			Expect(service.Channels()).To(Equal([]string{LogChannel, "test"}))
+		})
+
+		It("should only accept the recipients the notifier of the channel allows", func() {
+			service.SetNotifier("email", notify.NewSMTPNotifier("127.0.0.1:1", nil, "tasks@example.com", "team@example.com", []string{"ops@example.com"}))
+
+			_, err := service.AddReminder(ctx, task.ID, models.Reminder{Channel: "email", Recipient: "anyone@example.net"})
+			Expect(err).To(MatchError(ErrInvalidRecipient))
+			for _, recipient := range []string{"", "team@example.com", "Ops <ops@example.com>"} {
+				_, err = service.AddReminder(ctx, task.ID, models.Reminder{Channel: "email", Recipient: recipient})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			// The X-User header is not authenticated, so an actor with the same address gets no exception
+			_, err = service.AddReminder(WithActor(ctx, "alice@example.com"), task.ID, models.Reminder{Channel: "email", Recipient: "alice@example.com"})
+			Expect(err).To(MatchError(ErrInvalidRecipient))
+		})
+	})
+
+	Describe("webhooks", func() {
+		var (
+			service  *TaskService
+			server   *httptest.Server
+			received chan *http.Request
+			bodies   chan []byte
+			status   atomic.Int32
+		)
+
+		BeforeEach(func() {
+			service = NewTaskService(models.NewDatabase())
+			service.SetAdminToken("s3cret")
+			service.SetWebhookAllowedHosts([]string{"127.0.0.1"})
+			ctx = WithAdminToken(WithActor(ctx, "admin"), "s3cret")
+			received = make(chan *http.Request, 64)
+			bodies = make(chan []byte, 64)
+			status.Store(http.StatusNoContent)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received <- r
+				bodies <- body
+				w.WriteHeader(int(status.Load()))
+			}))
+			DeferCleanup(server.Close)
+		})
+
+		process := func(now time.Time) int {
+			succeeded, err := service.ProcessDeliveries(ctx, now)
+			Expect(err).ToNot(HaveOccurred())
+			return succeeded
+		}
+
+		It("should send signed payloads of the subscribed events only", func() {
+			webhook, err := service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: []string{EventTaskCreated}, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webhook.Secret).To(HaveLen(64))
+
+			task, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			task.Title = "Renamed"
+			_, err = service.UpdateTask(ctx, task.ID, task)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(received).To(BeEmpty(), "deliveries are not sent by the request making the change")
+
+			Expect(process(time.Now())).To(Equal(1))
+			Expect(received).To(HaveLen(1))
+			request, body := <-received, <-bodies
+			Expect(request.Header.Get("X-Webhook-Event")).To(Equal(EventTaskCreated))
+			timestamp, err := strconv.ParseInt(request.Header.Get("X-Webhook-Timestamp"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(request.Header.Get("X-Webhook-Signature")).To(Equal(WebhookSignature(webhook.Secret, timestamp, body)))
+
+			var event TaskEvent
+			Expect(json.Unmarshal(body, &event)).To(Succeed())
+			Expect(event.Type).To(Equal(EventTaskCreated))
+			Expect(event.TaskID).To(Equal(task.ID))
+			Expect(event.Task.Title).To(Equal("Task"))
+
+			deliveries, err := service.ListDeliveries(ctx, models.DeliveryFilter{WebhookID: webhook.ID})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deliveries).To(HaveLen(1))
+			Expect(deliveries[0].Status).To(Equal(models.DeliverySucceeded))
+			Expect(deliveries[0].Attempts[0].StatusCode).To(Equal(http.StatusNoContent))
+		})
+
+		It("should retry failed deliveries with exponential backoff and move them to the dead-letter list", func() {
+			webhook, err := service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: EventTypes, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			status.Store(http.StatusInternalServerError)
+			task, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(service.DeleteTask(ctx, task.ID, DeleteReject)).To(Succeed())
+
+			now := time.Now()
+			Expect(process(now)).To(Equal(0))
+			deliveries, err := service.ListDeliveries(ctx, models.DeliveryFilter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deliveries).To(HaveLen(2))
+			// The later delivery waits for the retry of the failed one instead of being attempted
+			Expect(deliveries[0].Event).To(Equal(EventTaskDeleted))
+			Expect(deliveries[0].Attempts).To(BeEmpty())
+			Expect(deliveries[0].NextAttemptAt.Equal(now.Add(WebhookRetryDelay))).To(BeTrue())
+			Expect(deliveries[1].Failures).To(Equal(1))
+			Expect(deliveries[1].NextAttemptAt.Equal(now.Add(WebhookRetryDelay))).To(BeTrue())
+
+			Expect(process(now.Add(WebhookRetryDelay - time.Second))).To(Equal(0))
+			Expect(received).To(HaveLen(1))
+			for failures := 1; failures < MaxDeliveryAttempts; failures++ {
+				now = now.Add(retryDelay(failures))
+				process(now)
+			}
+			Expect(received).To(HaveLen(MaxDeliveryAttempts))
+			for range MaxDeliveryAttempts {
+				now = now.Add(MaxWebhookRetryDelay)
+				process(now)
+			}
+			Expect(received).To(HaveLen(2 * MaxDeliveryAttempts))
+			Expect(retryDelay(2)).To(Equal(2 * WebhookRetryDelay))
+			Expect(retryDelay(100)).To(Equal(MaxWebhookRetryDelay))
+
+			dead, err := service.ListDeliveries(ctx, models.DeliveryFilter{Status: models.DeliveryDead})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dead).To(HaveLen(2))
+			Expect(dead[0].Failures).To(Equal(MaxDeliveryAttempts))
+			Expect(dead[0].NextAttemptAt).To(BeNil())
+
+			status.Store(http.StatusOK)
+			redelivered, err := service.Redeliver(ctx, webhook.ID, dead[0].ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(redelivered.Status).To(Equal(models.DeliveryPending))
+			_, err = service.Redeliver(ctx, webhook.ID, dead[0].ID)
+			Expect(err).To(MatchError(ErrDeliveryPending))
+			Expect(process(time.Now())).To(Equal(1))
+
+			delivery, err := service.GetDelivery(ctx, webhook.ID, dead[0].ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(delivery.Status).To(Equal(models.DeliverySucceeded))
+			Expect(delivery.Attempts).To(HaveLen(MaxDeliveryAttempts + 1))
+		})
+
+		It("should hold the deliveries of inactive webhooks and hide secrets once created", func() {
+			webhook, err := service.CreateWebhook(ctx, models.Webhook{
+				URL: server.URL, Events: []string{EventTaskCreated, EventTaskCreated}, Secret: "s3cret", Active: true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webhook.Events).To(Equal([]string{EventTaskCreated}))
+			_, err = service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+
+			webhook.Active = false
+			webhook.Secret = ""
+			updated, err := service.UpdateWebhook(ctx, webhook.ID, webhook)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated.Secret).To(BeEmpty())
+			Expect(process(time.Now())).To(Equal(0))
+			Expect(received).To(BeEmpty())
+
+			webhook.Active = true
+			_, err = service.UpdateWebhook(ctx, webhook.ID, webhook)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(process(time.Now())).To(Equal(1))
+			request, body := <-received, <-bodies
+			timestamp, err := strconv.ParseInt(request.Header.Get("X-Webhook-Timestamp"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(request.Header.Get("X-Webhook-Signature")).To(Equal(WebhookSignature("s3cret", timestamp, body)))
+
+			stored, err := service.GetWebhook(ctx, webhook.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored.Secret).To(BeEmpty())
+		})
+
+		It("should reject webhooks without an http URL or with unknown events", func() {
+			_, err := service.CreateWebhook(ctx, models.Webhook{URL: "ftp://example.com", Events: EventTypes})
+			Expect(err).To(MatchError(ErrInvalidWebhook))
+			_, err = service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: []string{"task.archived"}})
+			Expect(err).To(MatchError(ErrInvalidWebhook))
+			_, err = service.CreateWebhook(ctx, models.Webhook{URL: server.URL})
+			Expect(err).To(MatchError(ErrInvalidWebhook))
+		})
+
+		It("should not let a slow endpoint hold the deliveries of other webhooks", func() {
+			release := make(chan struct{})
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			DeferCleanup(slow.Close)
+			DeferCleanup(func() { close(release) })
+			_, err := service.CreateWebhook(ctx, models.Webhook{URL: slow.URL, Events: []string{EventTaskCreated}, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: []string{EventTaskCreated}, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+
+			done := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+				done <- process(time.Now())
+			}()
+			Eventually(received).Should(Receive())
+			Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+
+			// Neither the client nor the deliveries being sent are held by the slow endpoint
+			service.SetWebhookClient(service.newWebhookClient())
+			Expect(process(time.Now())).To(BeZero())
+			Expect(received).ToNot(Receive())
+
+			release <- struct{}{}
+			Eventually(done).Should(Receive(Equal(2)))
+		})
+
+		It("should return without waiting for the deliveries it dispatches", func() {
+			release := make(chan struct{})
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			DeferCleanup(slow.Close)
+			_, err := service.CreateWebhook(ctx, models.Webhook{URL: slow.URL, Events: []string{EventTaskCreated}, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+
+			outcomes, err := service.dispatchDeliveries(ctx, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Consistently(outcomes, 100*time.Millisecond).ShouldNot(Receive())
+
+			close(release)
+			Eventually(outcomes).Should(Receive(Equal(batchOutcome{succeeded: 1})))
+			Eventually(outcomes).Should(BeClosed())
+		})
+
+		It("should reject internal URLs unless their host is allowed", func() {
+			for _, url := range []string{"http://localhost:8080/hook", "http://10.0.0.5/hook", "http://169.254.169.254/latest", "http://[::1]/hook"} {
+				_, err := service.CreateWebhook(ctx, models.Webhook{URL: url, Events: EventTypes, Active: true})
+				Expect(err).To(MatchError(ErrInvalidWebhook), url)
+			}
+
+			service.SetWebhookAllowedHosts([]string{"LOCALHOST"})
+			_, err := service.CreateWebhook(ctx, models.Webhook{URL: "http://localhost:8080/hook", Events: EventTypes, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should not connect to internal addresses of hosts that are not allowed", func() {
+			webhook, err := service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: []string{EventTaskCreated}, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+			service.SetWebhookAllowedHosts(nil)
+			_, err = service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(process(time.Now())).To(BeZero())
+			Expect(received).ToNot(Receive())
+			deliveries, err := service.ListDeliveries(ctx, models.DeliveryFilter{WebhookID: webhook.ID})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deliveries[0].Attempts[0].Error).To(ContainSubstring("internal address"))
+		})
+
+		It("should only let administrators manage webhooks", func() {
+			webhook, err := service.CreateWebhook(ctx, models.Webhook{URL: server.URL, Events: EventTypes, Active: true})
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, actorCtx := range []context.Context{
+				context.Background(), WithActor(context.Background(), "admin"), WithAdminToken(context.Background(), "guess"),
+			} {
+				_, err = service.CreateWebhook(actorCtx, models.Webhook{URL: server.URL, Events: EventTypes, Active: true})
+				Expect(err).To(MatchError(ErrAdminRequired))
+				_, err = service.ListWebhooks(actorCtx)
+				Expect(err).To(MatchError(ErrAdminRequired))
+				_, err = service.UpdateWebhook(actorCtx, webhook.ID, webhook)
+				Expect(err).To(MatchError(ErrAdminRequired))
+				Expect(service.DeleteWebhook(actorCtx, webhook.ID)).To(MatchError(ErrAdminRequired))
+				_, err = service.ListDeliveries(actorCtx, models.DeliveryFilter{})
+				Expect(err).To(MatchError(ErrAdminRequired))
+				_, err = service.Redeliver(actorCtx, webhook.ID, 1)
+				Expect(err).To(MatchError(ErrAdminRequired))
+			}
+		})
+	})
+
+	Describe("change feed", func() {
+		var service *TaskService
+
+		BeforeEach(func() {
+			service = NewTaskService(models.NewDatabase())
+		})
+
+		receive := func(sub *Subscription) FeedEvent {
+			var event FeedEvent
+			Eventually(sub.Events).Should(Receive(&event))
+			return event
+		}
+
+		It("should stream the events of every change with increasing IDs", func() {
+			sub := service.Subscribe("", FeedFilter{})
+			DeferCleanup(sub.Close)
+			Expect(sub.Reset).To(BeFalse())
+			Expect(sub.Replay).To(BeEmpty())
+
+			task, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			task.Status = "Completed"
+			_, err = service.UpdateTask(ctx, task.ID, task)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(service.DeleteTask(ctx, task.ID, DeleteReject)).To(Succeed())
+			Expect(service.RestoreTask(ctx, task.ID)).Error().ToNot(HaveOccurred())
+
+			var types, ids []string
+			for range 4 {
+				event := receive(sub)
+				types = append(types, event.Type)
+				ids = append(ids, event.ID)
+			}
+			Expect(types).To(Equal([]string{EventTaskCreated, EventTaskUpdated, EventTaskDeleted, EventTaskCreated}))
+			Expect(ids).To(HaveLen(4))
+			Expect(ids[1]).ToNot(Equal(ids[0]))
+			Expect(sub.Events).ToNot(Receive())
+		})
+
+		It("should resume after the last event received, or reset when it is no longer kept", func() {
+			sub := service.Subscribe("", FeedFilter{})
+			task, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			last := receive(sub)
+			sub.Close()
+			Eventually(sub.Events).Should(BeClosed())
+
+			for _, status := range []string{"in-progress", "Completed"} {
+				task.Status = status
+				task, err = service.UpdateTask(ctx, task.ID, task)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			resumed := service.Subscribe(last.ID, FeedFilter{})
+			DeferCleanup(resumed.Close)
+			Expect(resumed.Reset).To(BeFalse())
+			Expect(resumed.Replay).To(HaveLen(2))
+			Expect(resumed.Replay[1].Task.Status).To(Equal("Completed"))
+
+			for _, id := range []string{"unknown", "0-1", last.ID + "0"} {
+				reset := service.Subscribe(id, FeedFilter{})
+				Expect(reset.Reset).To(BeTrue(), id)
+				reset.Close()
+			}
+			for range EventReplaySize {
+				service.feed.broadcast(TaskEvent{Type: EventTaskUpdated, TaskID: task.ID})
+			}
+			expired := service.Subscribe(last.ID, FeedFilter{})
+			Expect(expired.Reset).To(BeTrue())
+			expired.Close()
+		})
+
+		It("should only send the events of the selected tasks and statuses", func() {
+			first, err := service.CreateTask(ctx, models.Task{Title: "First", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			second, err := service.CreateTask(ctx, models.Task{Title: "Second", Description: "Description", Status: "Pending"})
+			Expect(err).ToNot(HaveOccurred())
+
+			byTask := service.Subscribe("", FeedFilter{TaskIDs: []int{second.ID}})
+			DeferCleanup(byTask.Close)
+			byStatus := service.Subscribe("", FeedFilter{Statuses: []string{"TODO"}})
+			DeferCleanup(byStatus.Close)
+
+			first.Status = "Completed"
+			_, err = service.UpdateTask(ctx, first.ID, first)
+			Expect(err).ToNot(HaveOccurred())
+			second.Title = "Renamed"
+			_, err = service.UpdateTask(ctx, second.ID, second)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(receive(byTask).TaskID).To(Equal(second.ID))
+			Expect(byTask.Events).ToNot(Receive())
+			// Moving a task out of a status is an event of that status
+			Expect(receive(byStatus).TaskID).To(Equal(first.ID))
+			Expect(byStatus.Events).ToNot(Receive())
+		})
+
+		It("should disconnect subscribers that fall behind without blocking changes", func() {
+			sub := service.Subscribe("", FeedFilter{})
+			task, err := service.CreateTask(ctx, models.Task{Title: "Task", Description: "Description", Status: "TODO"})
+			Expect(err).ToNot(HaveOccurred())
+			for i := range subscriberBuffer {
+				task.Title = "Task " + strconv.Itoa(i)
+				task, err = service.UpdateTask(ctx, task.ID, task)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			received := 0
+			for range sub.Events {
+				received++
+			}
+			Expect(received).To(Equal(subscriberBuffer))
+			sub.Close()
+		})
+	})
+})
+
+// errLinkStoreFull is returned by failingLinkStore
+var errLinkStoreFull = errors.New("link store is full")
+
+// failingLinkStore is a database failing to create links
+type failingLinkStore struct {
+	*models.Database
+}
+
+func (failingLinkStore) CreateLink(context.Context, models.Link) (models.Link, error) {
+	return models.Link{}, errLinkStoreFull
+}
+
+// recordingNotifier records the notifications it is asked to send
+type recordingNotifier struct {
+	sent []models.Notification
+	// err, when set, fails the notifications instead of recording them
+	err error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification models.Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
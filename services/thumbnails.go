@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"github.com/ofirmad/task-manager/utils"
+	"image"
+	_ "image/gif"  // registers the GIF decoder
+	_ "image/jpeg" // registers the JPEG decoder
+	"image/png"
+	"io"
+	"log"
+	"strconv"
+)
+
+const (
+	// DefaultThumbnailSize is the size of the thumbnails requested without a size
+	DefaultThumbnailSize = 128
+
+	// maxImageDimension is the largest width or height of an image thumbnails are generated for
+	maxImageDimension = 10000
+	// maxImagePixels is the largest number of pixels of an image thumbnails are generated for,
+	// so a small file declaring a huge image (a decompression bomb) cannot exhaust the memory
+	maxImagePixels = 24_000_000
+)
+
+// ThumbnailSizes are the sizes thumbnails are generated at: the length in pixels of their longest side
+var ThumbnailSizes = []int{64, 128, 256}
+
+// thumbnailTypes are the content types of the attachments thumbnails can be generated for
+var thumbnailTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true}
+
+var (
+	// ErrInvalidThumbnailSize is returned for a size that is not one of ThumbnailSizes
+	ErrInvalidThumbnailSize = errors.New("invalid thumbnail size")
+	// ErrNotAnImage is returned when generating the thumbnail of an attachment that is not a PNG, JPEG or GIF image
+	ErrNotAnImage = errors.New("thumbnails are only available for PNG, JPEG and GIF images")
+	// ErrImageTooLarge is returned when generating the thumbnail of an image with too many pixels
+	ErrImageTooLarge = errors.New("image is too large for a thumbnail")
+)
+
+// IsThumbnailSize reports whether thumbnails are generated at size
+func IsThumbnailSize(size int) bool {
+	for _, thumbnailSize := range ThumbnailSizes {
+		if size == thumbnailSize {
+			return true
+		}
+	}
+	return false
+}
+
+// Thumbnail returns an attachment of a task and its PNG thumbnail, fitting in a size by size square.
+// Thumbnails are cached next to the blob of the attachment when the blob store implements models.BlobCache.
+// The caller must close the thumbnail.
+func (s *TaskService) Thumbnail(ctx context.Context, taskID, id, size int) (models.Attachment, io.ReadSeekCloser, error) {
+	if !IsThumbnailSize(size) {
+		return models.Attachment{}, nil, ErrInvalidThumbnailSize
+	}
+	blobs, err := s.blobStore()
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	attachment, err := s.GetAttachment(ctx, taskID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	if !thumbnailTypes[attachment.ContentType] {
+		return models.Attachment{}, nil, ErrNotAnImage
+	}
+
+	name := "thumb-" + strconv.Itoa(size) + ".png"
+	cache, cached := blobs.(models.BlobCache)
+	if cached {
+		if thumbnail, err := cache.OpenCached(ctx, attachment.SHA256, name); err == nil {
+			return attachment, thumbnail, nil
+		}
+	}
+
+	// Images are decoded one at a time to bound the memory used by large ones
+	s.thumbnailMutex.Lock()
+	defer s.thumbnailMutex.Unlock()
+
+	if cached {
+		// The thumbnail may have been generated while waiting for the mutex
+		if thumbnail, err := cache.OpenCached(ctx, attachment.SHA256, name); err == nil {
+			return attachment, thumbnail, nil
+		}
+	}
+	thumbnail, err := s.generateThumbnail(ctx, blobs, attachment.SHA256, size)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	if cached {
+		if err := cache.PutCached(ctx, attachment.SHA256, name, thumbnail); err != nil && !errors.Is(err, models.ErrBlobNotFound) {
+			log.Printf("failed to cache the thumbnail of attachment %d: %v", id, err)
+		}
+	}
+	return attachment, readSeekNopCloser{bytes.NewReader(thumbnail)}, nil
+}
+
+// generateThumbnail decodes the image of a blob and encodes its thumbnail as PNG.
+// The dimensions of the image are checked before it is decoded.
+func (s *TaskService) generateThumbnail(ctx context.Context, blobs models.BlobStore, digest string, size int) ([]byte, error) {
+	content, err := blobs.Open(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = content.Close() }()
+
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAnImage, err)
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension ||
+		int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAnImage, err)
+	}
+
+	var thumbnail bytes.Buffer
+	if err := png.Encode(&thumbnail, utils.Thumbnail(img, size)); err != nil {
+		return nil, err
+	}
+	return thumbnail.Bytes(), nil
+}
+
+// readSeekNopCloser is a bytes.Reader with a Close method that does nothing
+type readSeekNopCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekNopCloser) Close() error {
+	return nil
+}
+
+// Thumbnail returns the thumbnail of an attachment using the default service
+func Thumbnail(ctx context.Context, taskID, id, size int) (models.Attachment, io.ReadSeekCloser, error) {
+	return defaultService.Thumbnail(ctx, taskID, id, size)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"log"
+	"sort"
+	"time"
+)
+
+// DefaultTrashRetention is how long tasks stay in the trash before they are purged unless configured otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+var (
+	// ErrTaskNotInTrash is returned when restoring a task that is not in the trash
+	ErrTaskNotInTrash = errors.New("task is not in the trash")
+	// ErrAdminRequired is returned when a request without the admin token permanently deletes a task or manages
+	// webhooks
+	ErrAdminRequired = errors.New("only administrators are allowed to do this")
+)
+
+// SetAdminToken sets the token administrators present to permanently delete tasks and to manage webhooks.
+// Nobody is an administrator while it is empty.
+func (s *TaskService) SetAdminToken(token string) {
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	s.adminToken = token
+}
+
+type adminTokenKey struct{}
+
+// WithAdminToken returns a context carrying the admin token presented by the user making the request
+func WithAdminToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, adminTokenKey{}, token)
+}
+
+// isAdmin reports whether the context carries the admin token. The actor is not trusted, as anyone can claim to be
+// anyone.
+func (s *TaskService) isAdmin(ctx context.Context) bool {
+	s.adminMutex.RLock()
+	defer s.adminMutex.RUnlock()
+
+	token, _ := ctx.Value(adminTokenKey{}).(string)
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// ListTrash returns the tasks in the trash, most recently deleted first
+func (s *TaskService) ListTrash(ctx context.Context) ([]models.Task, error) {
+	tasks, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trash := make([]models.Task, 0)
+	for _, task := range tasks {
+		if task.DeletedAt != nil {
+			trash = append(trash, task)
+		}
+	}
+	sort.SliceStable(trash, func(i, j int) bool { return trash[i].DeletedAt.After(*trash[j].DeletedAt) })
+	return s.presentAll(trash), nil
+}
+
+// RestoreTask brings a task back from the trash with the same ID, along with the subtasks deleted with it by
+// DeleteCascade. Subtasks deleted on their own before stay in the trash.
+// A subtask whose parent is no longer available, or is done while the subtask is not, becomes a top-level task.
+func (s *TaskService) RestoreTask(ctx context.Context, id int, preconditions ...Precondition) (models.Task, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	current, err := s.store.Get(ctx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if current.DeletedAt == nil {
+		return models.Task{}, ErrTaskNotInTrash
+	}
+	detach := current.ParentID != nil && s.checkParent(ctx, id, current.ParentID, current.Status) != nil
+	tasks, err := s.store.List(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	deletedAt := *current.DeletedAt
+	ids := append([]int{id}, cascadedSubtasks(tasks, id, deletedAt)...)
+	before, after, err := s.store.UpdateTasks(ctx, ids, func(task *models.Task) error {
+		if task.DeletedAt == nil || task.ID != id && !task.DeletedAt.Equal(deletedAt) {
+			return ErrTaskNotInTrash
+		}
+		if task.ID == id {
+			if err := checkPreconditions(*task, preconditions); err != nil {
+				return err
+			}
+			if detach {
+				task.ParentID = nil
+			}
+		}
+		task.DeletedAt = nil
+		touch(task)
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	for i := range before {
+		s.record(ctx, models.Revision{Action: models.RevisionRestored}, &before[i], &after[i])
+	}
+	return s.present(after[0]), nil
+}
+
+// PurgeTask permanently removes a task, whether it is in the trash or not. mode decides what happens to its subtasks.
+// Only administrators may purge tasks. The history of the task is kept.
+func (s *TaskService) PurgeTask(ctx context.Context, id int, mode DeleteMode, preconditions ...Precondition) error {
+	if !s.isAdmin(ctx) {
+		return ErrAdminRequired
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	purge := func(ctx context.Context, id int, preconditions []Precondition) error {
+		return s.purgeLocked(ctx, id, func(task models.Task) error {
+			return checkPreconditions(task, preconditions)
+		})
+	}
+	if err := s.deleteSubtasks(ctx, id, mode, preconditions, purge); err != nil {
+		return err
+	}
+	return purge(ctx, id, preconditions)
+}
+
+// PurgeTrash permanently removes the tasks that were moved to the trash before cutoff
+// and returns how many were removed
+func (s *TaskService) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	trash, err := s.ListTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, task := range trash {
+		if !task.DeletedAt.Before(cutoff) {
+			continue
+		}
+		err := s.purge(ctx, task.ID, func(current models.Task) error {
+			// The task may have been restored since the trash was listed
+			if current.DeletedAt == nil || !current.DeletedAt.Before(cutoff) {
+				return ErrTaskNotInTrash
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			purged++
+		case errors.Is(err, ErrTaskNotInTrash) || errors.Is(err, ErrTaskNotFound):
+		default:
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// RunTrashPurge purges the tasks older than retention from the trash every interval, until ctx is done
+func (s *TaskService) RunTrashPurge(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to purge the trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d tasks from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge removes a task from the store if check accepts it and records the removal
+func (s *TaskService) purge(ctx context.Context, id int, check func(task models.Task) error) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.purgeLocked(ctx, id, check)
+}
+
+// purgeLocked is purge for callers that hold the write mutex.
+// The blobs of the attachments of the task are deleted unless other attachments have the same content.
+func (s *TaskService) purgeLocked(ctx context.Context, id int, check func(task models.Task) error) error {
+	digests, err := s.taskAttachmentDigests(ctx, id)
+	if err != nil {
+		return err
+	}
+	var purged models.Task
+	if err := s.store.Delete(ctx, id, func(task models.Task) error {
+		purged = task
+		return check(task)
+	}); err != nil {
+		return err
+	}
+	s.record(ctx, models.Revision{Action: models.RevisionPurged}, &purged, nil)
+	s.collectBlobsLocked(ctx, digests...)
+	return nil
+}
+
+// SetAdminToken sets the token of the administrators of the default service
+func SetAdminToken(token string) {
+	defaultService.SetAdminToken(token)
+}
+
+// ListTrash returns the tasks in the trash using the default service
+func ListTrash(ctx context.Context) ([]models.Task, error) {
+	return defaultService.ListTrash(ctx)
+}
+
+// RestoreTask brings a task back from the trash using the default service
+func RestoreTask(ctx context.Context, id int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.RestoreTask(ctx, id, preconditions...)
+}
+
+// PurgeTask permanently removes a task using the default service
+func PurgeTask(ctx context.Context, id int, mode DeleteMode, preconditions ...Precondition) error {
+	return defaultService.PurgeTask(ctx, id, mode, preconditions...)
+}
+
+// RunTrashPurge periodically purges the trash of the default service
+func RunTrashPurge(ctx context.Context, retention, interval time.Duration) {
+	defaultService.RunTrashPurge(ctx, retention, interval)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+var (
+	// ErrActorRequired is returned by Undo when the context carries no actor
+	ErrActorRequired = errors.New("the user making the request must be identified")
+	// ErrNothingToUndo is returned by Undo when the actor has no change left to undo
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrUndoConflict is returned by Undo when someone else changed the task after the change being undone
+	ErrUndoConflict = errors.New("the task was changed by someone else since")
+)
+
+// RevertTask replaces the fields of a task with those of its revision n, as a new revision.
+// A task in the trash is restored and a purged task is recreated with its original ID. The workflow is not enforced,
+// since the task returns to a state it was already in, but the subtask and dependency rules are.
+func (s *TaskService) RevertTask(ctx context.Context, id, n int, preconditions ...Precondition) (models.Task, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	history, err := s.TaskHistory(ctx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if n < 1 || n > len(history) {
+		return models.Task{}, ErrRevisionNotFound
+	}
+
+	target := history[n-1].Task
+	revision, err := s.applyState(ctx, history, &target, models.Revision{RevertedTo: n}, preconditions)
+	if err != nil {
+		return models.Task{}, err
+	}
+	return s.present(revision.Task), nil
+}
+
+// Undo reverses the most recent change made by the actor of the context that was not undone yet,
+// so calling it repeatedly walks back through the actor's changes. It returns the revision recording the undo.
+// The change cannot be undone if another actor changed the same task after it.
+func (s *TaskService) Undo(ctx context.Context) (models.Revision, error) {
+	actor := ActorFromContext(ctx)
+	if actor == "" {
+		return models.Revision{}, ErrActorRequired
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	revisions, err := s.history.ListRevisionsByActor(ctx, actor)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	target, ok := lastUndoable(revisions)
+	if !ok {
+		return models.Revision{}, ErrNothingToUndo
+	}
+
+	history, err := s.history.ListRevisions(ctx, target.TaskID)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	for _, later := range history[target.Number:] {
+		if later.Actor != actor {
+			return models.Revision{}, ErrUndoConflict
+		}
+	}
+
+	// state is the task before the change, nil if it did not exist
+	var state *models.Task
+	if target.Action == models.RevisionPurged {
+		state = &target.Task
+	} else if target.Number > 1 {
+		if previous := history[target.Number-2]; previous.Action != models.RevisionPurged {
+			state = &previous.Task
+		}
+	}
+	return s.applyState(ctx, history, state, models.Revision{Undoes: target.Number}, nil)
+}
+
+// lastUndoable returns the most recent revision that is neither an undo nor undone
+func lastUndoable(revisions []models.Revision) (models.Revision, bool) {
+	undone := make(map[[2]int]bool)
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		if revision.Undoes != 0 {
+			undone[[2]int{revision.TaskID, revision.Undoes}] = true
+			continue
+		}
+		if !undone[[2]int{revision.TaskID, revision.Number}] {
+			return revision, true
+		}
+	}
+	return models.Revision{}, false
+}
+
+// applyState brings the task of history back to state, moving it to the trash when state is nil and
+// recreating it when it was purged, and records the change. revision holds the revert or undo reference.
+// The caller must hold writeMutex.
+func (s *TaskService) applyState(ctx context.Context, history []models.Revision, state *models.Task, revision models.Revision, preconditions []Precondition) (models.Revision, error) {
+	latest := history[len(history)-1]
+	id := latest.TaskID
+
+	current, err := s.store.Get(ctx, id)
+	exists := err == nil
+	if err != nil && !errors.Is(err, models.ErrTaskNotFound) {
+		return models.Revision{}, err
+	}
+
+	// revert returns the task brought back to state
+	revert := func(task models.Task) models.Task {
+		reverted := task
+		if state != nil {
+			reverted = *state
+			reverted.ID = task.ID
+			reverted.CreatedAt = task.CreatedAt
+			reverted.UpdatedAt = task.UpdatedAt
+			reverted.Version = task.Version
+		} else if reverted.DeletedAt == nil {
+			// The task did not exist before the change: it goes to the trash, from which it can be restored
+			now := time.Now()
+			reverted.DeletedAt = &now
+		}
+		reverted.Overdue = false
+		return reverted
+	}
+
+	switch {
+	case !exists && state == nil:
+		return models.Revision{}, ErrTaskNotFound
+
+	case exists:
+		// The rules of updateLocked apply to the reverted task, as its parent and blockers may have changed since.
+		// They are checked on a copy first: the write mutex guarantees the same outcome below.
+		if err := checkPreconditions(current, preconditions); err != nil {
+			return models.Revision{}, err
+		}
+		if err := s.checkRevertedState(ctx, current, revert(current)); err != nil {
+			return models.Revision{}, err
+		}
+		var before models.Task
+		updated, err := s.store.Update(ctx, id, func(task *models.Task) error {
+			if err := checkPreconditions(*task, preconditions); err != nil {
+				return err
+			}
+			before = *task
+			*task = revert(*task)
+			touch(task)
+			return nil
+		})
+		if err != nil {
+			return models.Revision{}, err
+		}
+		switch {
+		case before.DeletedAt == nil && updated.DeletedAt != nil:
+			revision.Action = models.RevisionDeleted
+		case before.DeletedAt != nil && updated.DeletedAt == nil:
+			revision.Action = models.RevisionRestored
+		default:
+			revision.Action = models.RevisionReverted
+		}
+		return s.record(ctx, revision, &before, &updated), nil
+
+	default:
+		// A task that does not exist has no version an If-Match could match
+		if len(preconditions) > 0 {
+			return models.Revision{}, ErrPreconditionFailed
+		}
+		restored := *state
+		restored.ID = id
+		restored.Version = latest.Task.Version + 1
+		restored.UpdatedAt = time.Now()
+		restored.DeletedAt = nil
+		if err := s.checkRevertedState(ctx, models.Task{ID: id}, restored); err != nil {
+			return models.Revision{}, err
+		}
+		restored, err := s.store.Recreate(ctx, restored)
+		if err != nil {
+			return models.Revision{}, err
+		}
+		revision.Action = models.RevisionRestored
+		return s.record(ctx, revision, nil, &restored), nil
+	}
+}
+
+// checkRevertedState enforces the subtask and dependency rules on a task brought back from before to after by a
+// revert or an undo. A task going to the trash is not checked.
+func (s *TaskService) checkRevertedState(ctx context.Context, before, after models.Task) error {
+	if after.DeletedAt != nil {
+		return nil
+	}
+	// The parent may have been trashed or completed since the task was out of the trash, so it is checked even
+	// when it does not change
+	if before.DeletedAt != nil || before.CreatedAt.IsZero() {
+		if err := s.checkParent(ctx, after.ID, after.ParentID, after.Status); err != nil {
+			return err
+		}
+	}
+	if err := s.checkHierarchy(ctx, before, after); err != nil {
+		return err
+	}
+	return s.checkDependencies(ctx, before, after)
+}
+
+// RevertTask reverts a task to one of its revisions using the default service
+func RevertTask(ctx context.Context, id, n int, preconditions ...Precondition) (models.Task, error) {
+	return defaultService.RevertTask(ctx, id, n, preconditions...)
+}
+
+// Undo reverses the most recent change of the actor of the context using the default service
+func Undo(ctx context.Context) (models.Revision, error) {
+	return defaultService.Undo(ctx)
+}
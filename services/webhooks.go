@@ -0,0 +1,761 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultWebhookInterval is how often due webhook deliveries are retried unless configured otherwise.
+	// New deliveries are sent right away.
+	DefaultWebhookInterval = 5 * time.Second
+	// WebhookRetryDelay is the delay before the first retry of a failed delivery. It doubles with every failure.
+	WebhookRetryDelay = 10 * time.Second
+	// MaxWebhookRetryDelay caps the delay between two attempts of a delivery
+	MaxWebhookRetryDelay = time.Hour
+	// MaxDeliveryAttempts is the number of failed attempts after which a delivery is moved to the dead-letter list
+	MaxDeliveryAttempts = 8
+	// DeliveryRetention is how long succeeded deliveries are kept in the delivery log
+	DeliveryRetention = 7 * 24 * time.Hour
+
+	// webhookTimeout bounds a single delivery attempt
+	webhookTimeout = 10 * time.Second
+	// maxKeptAttempts is the number of attempts kept in the log of a delivery
+	maxKeptAttempts = 20
+	// maxDeliveryWorkers is the number of webhooks delivered concurrently
+	maxDeliveryWorkers = 8
+)
+
+var (
+	// ErrWebhookNotFound is returned when the requested webhook does not exist
+	ErrWebhookNotFound = models.ErrWebhookNotFound
+	// ErrDeliveryNotFound is returned when the requested delivery does not exist or does not belong to the webhook
+	ErrDeliveryNotFound = models.ErrDeliveryNotFound
+	// ErrInvalidWebhook is returned for a webhook without an http(s) URL or subscribed to unknown events
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrDeliveryPending is returned when redelivering a delivery that is still being attempted
+	ErrDeliveryPending = errors.New("delivery is still pending")
+	// ErrWebhooksUnsupported is returned when the store does not implement models.WebhookStore
+	ErrWebhooksUnsupported = errors.New("the task store does not support webhooks")
+)
+
+// WebhookSignature returns the signature of a webhook payload sent in the X-Webhook-Signature header:
+// "sha256=" followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetWebhookClient sets the HTTP client deliveries are sent with
+func (s *TaskService) SetWebhookClient(client *http.Client) {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	s.webhookClient = client
+}
+
+// ListWebhooks returns every webhook ordered by ID, without their secrets.
+// Like every operation on webhooks and their deliveries, it is only allowed to administrators.
+func (s *TaskService) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return nil, ErrAdminRequired
+	}
+	webhooks, err := s.webhooks.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// GetWebhook returns a webhook, without its secret
+func (s *TaskService) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	if s.webhooks == nil {
+		return models.Webhook{}, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return models.Webhook{}, ErrAdminRequired
+	}
+	webhook, err := s.webhooks.GetWebhook(ctx, id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// CreateWebhook subscribes a URL to task events. A secret is generated when the webhook has none.
+// The returned webhook is the only one carrying the secret.
+func (s *TaskService) CreateWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if s.webhooks == nil {
+		return models.Webhook{}, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return models.Webhook{}, ErrAdminRequired
+	}
+	if err := s.checkWebhook(ctx, webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return models.Webhook{}, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+	now := time.Now()
+	return s.webhooks.CreateWebhook(ctx, models.Webhook{
+		URL:       webhook.URL,
+		Events:    normalizeEvents(webhook.Events),
+		Secret:    webhook.Secret,
+		Active:    webhook.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// UpdateWebhook replaces the URL, events and active flag of a webhook.
+// Its secret is replaced when webhook.Secret is set, and kept otherwise.
+func (s *TaskService) UpdateWebhook(ctx context.Context, id int, webhook models.Webhook) (models.Webhook, error) {
+	if s.webhooks == nil {
+		return models.Webhook{}, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return models.Webhook{}, ErrAdminRequired
+	}
+	if err := s.checkWebhook(ctx, webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	updated, err := s.webhooks.UpdateWebhook(ctx, id, func(current *models.Webhook) error {
+		current.URL = webhook.URL
+		current.Events = normalizeEvents(webhook.Events)
+		current.Active = webhook.Active
+		if webhook.Secret != "" {
+			current.Secret = webhook.Secret
+		}
+		current.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	updated.Secret = ""
+	s.wakeDeliveries()
+	return updated, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (s *TaskService) DeleteWebhook(ctx context.Context, id int) error {
+	if s.webhooks == nil {
+		return ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return ErrAdminRequired
+	}
+	return s.webhooks.DeleteWebhook(ctx, id)
+}
+
+// ListDeliveries returns the deliveries selected by the filter, most recent first
+func (s *TaskService) ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.Delivery, error) {
+	if s.webhooks == nil {
+		return nil, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return nil, ErrAdminRequired
+	}
+	if filter.WebhookID != 0 {
+		if _, err := s.webhooks.GetWebhook(ctx, filter.WebhookID); err != nil {
+			return nil, err
+		}
+	}
+	return s.webhooks.ListDeliveries(ctx, filter)
+}
+
+// GetDelivery returns a delivery of a webhook
+func (s *TaskService) GetDelivery(ctx context.Context, webhookID, id int) (models.Delivery, error) {
+	if s.webhooks == nil {
+		return models.Delivery{}, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return models.Delivery{}, ErrAdminRequired
+	}
+	if _, err := s.webhooks.GetWebhook(ctx, webhookID); err != nil {
+		return models.Delivery{}, err
+	}
+	delivery, err := s.webhooks.GetDelivery(ctx, id)
+	if err != nil {
+		return models.Delivery{}, err
+	}
+	if delivery.WebhookID != webhookID {
+		return models.Delivery{}, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// Redeliver sends a succeeded or dead delivery again with the same payload, with a fresh set of attempts.
+// It returns ErrDeliveryPending for a delivery that is still being attempted.
+func (s *TaskService) Redeliver(ctx context.Context, webhookID, id int) (models.Delivery, error) {
+	if s.webhooks == nil {
+		return models.Delivery{}, ErrWebhooksUnsupported
+	}
+	if !s.isAdmin(ctx) {
+		return models.Delivery{}, ErrAdminRequired
+	}
+	if _, err := s.webhooks.GetWebhook(ctx, webhookID); err != nil {
+		return models.Delivery{}, err
+	}
+	now := time.Now()
+	delivery, err := s.webhooks.UpdateDelivery(ctx, id, func(delivery *models.Delivery) error {
+		if delivery.WebhookID != webhookID {
+			return ErrDeliveryNotFound
+		}
+		if delivery.Status == models.DeliveryPending {
+			return ErrDeliveryPending
+		}
+		delivery.Status = models.DeliveryPending
+		delivery.Failures = 0
+		delivery.NextAttemptAt = &now
+		delivery.DeliveredAt = nil
+		return nil
+	})
+	if err != nil {
+		return models.Delivery{}, err
+	}
+	s.wakeDeliveries()
+	return delivery, nil
+}
+
+// ProcessDeliveries attempts the deliveries due at now and returns how many succeeded once they were all attempted.
+// A failed delivery is retried after WebhookRetryDelay, doubling with every failure up to MaxWebhookRetryDelay,
+// and moved to the dead-letter list after MaxDeliveryAttempts failures.
+// The deliveries of inactive webhooks wait until their webhook is active again.
+// See dispatchDeliveries for how the webhooks are delivered.
+func (s *TaskService) ProcessDeliveries(ctx context.Context, now time.Time) (int, error) {
+	outcomes, err := s.dispatchDeliveries(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	succeeded := 0
+	var errs []error
+	for outcome := range outcomes {
+		succeeded += outcome.succeeded
+		errs = append(errs, outcome.err)
+	}
+	return succeeded, errors.Join(errs...)
+}
+
+// batchOutcome is the result of deliverBatch
+type batchOutcome struct {
+	succeeded int
+	err       error
+}
+
+// dispatchDeliveries claims the deliveries due at now and sends them in the background, without waiting for them.
+// The webhooks are delivered concurrently, up to maxDeliveryWorkers at a time, and the deliveries of a webhook in
+// order, so a slow endpoint only delays its own deliveries. Deliveries still being sent by a previous call are skipped.
+// The returned channel receives the outcome of every webhook and is closed once they were all attempted.
+func (s *TaskService) dispatchDeliveries(ctx context.Context, now time.Time) (<-chan batchOutcome, error) {
+	var batches []deliveryBatch
+	var client *http.Client
+	if s.webhooks != nil {
+		var err error
+		if batches, client, err = s.claimDeliveries(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+
+	outcomes := make(chan batchOutcome, len(batches))
+	var senders sync.WaitGroup
+	for _, batch := range batches {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			defer s.releaseDeliveries([]deliveryBatch{batch})
+
+			select {
+			case s.deliverySlots <- struct{}{}:
+			case <-ctx.Done():
+				outcomes <- batchOutcome{err: ctx.Err()}
+				return
+			}
+			defer func() { <-s.deliverySlots }()
+			n, err := s.deliverBatch(ctx, client, batch, now)
+			outcomes <- batchOutcome{succeeded: n, err: err}
+		}()
+	}
+	go func() {
+		senders.Wait()
+		close(outcomes)
+	}()
+	return outcomes, nil
+}
+
+// deliveryBatch is the due deliveries of a webhook, oldest first
+type deliveryBatch struct {
+	webhook    models.Webhook
+	deliveries []models.Delivery
+}
+
+// claimDeliveries returns the deliveries due at now of the active webhooks, grouped by webhook, along with the client
+// to send them with. The deliveries are marked as being sent until releaseDeliveries.
+func (s *TaskService) claimDeliveries(ctx context.Context, now time.Time) ([]deliveryBatch, *http.Client, error) {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	deliveries, err := s.webhooks.DueDeliveries(ctx, now)
+	if err != nil || len(deliveries) == 0 {
+		return nil, nil, err
+	}
+	var batches []deliveryBatch
+	indexes := make(map[int]int)
+	skipped := make(map[int]bool)
+	for _, delivery := range deliveries {
+		if s.sending[delivery.ID] || skipped[delivery.WebhookID] {
+			continue
+		}
+		i, ok := indexes[delivery.WebhookID]
+		if !ok {
+			webhook, err := s.webhooks.GetWebhook(ctx, delivery.WebhookID)
+			if errors.Is(err, ErrWebhookNotFound) || err == nil && !webhook.Active {
+				skipped[delivery.WebhookID] = true
+				continue
+			} else if err != nil {
+				return nil, nil, err
+			}
+			i = len(batches)
+			indexes[webhook.ID] = i
+			batches = append(batches, deliveryBatch{webhook: webhook})
+		}
+		batches[i].deliveries = append(batches[i].deliveries, delivery)
+	}
+
+	for _, batch := range batches {
+		for _, delivery := range batch.deliveries {
+			s.sending[delivery.ID] = true
+		}
+	}
+	return batches, s.webhookClient, nil
+}
+
+// releaseDeliveries marks the deliveries claimed by claimDeliveries as no longer being sent
+func (s *TaskService) releaseDeliveries(batches []deliveryBatch) {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	for _, batch := range batches {
+		for _, delivery := range batch.deliveries {
+			delete(s.sending, delivery.ID)
+		}
+	}
+}
+
+// deliverBatch attempts the deliveries of a webhook in order and returns how many succeeded.
+// It stops at the first failure: the later deliveries wait for the retry of the failed one, so the webhook still
+// receives them in order and an endpoint that is down costs a single attempt per run.
+func (s *TaskService) deliverBatch(ctx context.Context, client *http.Client, batch deliveryBatch, now time.Time) (int, error) {
+	succeeded := 0
+	for i, delivery := range batch.deliveries {
+		attempt := attemptDelivery(ctx, client, batch.webhook, delivery)
+		updated, err := s.webhooks.UpdateDelivery(ctx, delivery.ID, func(delivery *models.Delivery) error {
+			recordAttempt(delivery, attempt, now)
+			return nil
+		})
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		} else if err != nil {
+			return succeeded, err
+		}
+		if attempt.Error == "" {
+			succeeded++
+			continue
+		}
+		if updated.NextAttemptAt != nil {
+			return succeeded, s.postponeDeliveries(ctx, batch.deliveries[i+1:], *updated.NextAttemptAt)
+		}
+		return succeeded, nil
+	}
+	return succeeded, nil
+}
+
+// postponeDeliveries moves the next attempt of the pending deliveries to next
+func (s *TaskService) postponeDeliveries(ctx context.Context, deliveries []models.Delivery, next time.Time) error {
+	for _, delivery := range deliveries {
+		if _, err := s.webhooks.UpdateDelivery(ctx, delivery.ID, func(delivery *models.Delivery) error {
+			if delivery.Status == models.DeliveryPending {
+				delivery.NextAttemptAt = &next
+			}
+			return nil
+		}); err != nil && !errors.Is(err, ErrDeliveryNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunWebhooks sends the new webhook deliveries as they are created and retries the failed ones every interval,
+// until ctx is done. Succeeded deliveries older than DeliveryRetention are pruned from the delivery log.
+func (s *TaskService) RunWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// The deliveries are sent in the background, so a slow webhook does not hold up the others until the next run
+		if outcomes, err := s.dispatchDeliveries(ctx, time.Now()); err != nil {
+			log.Printf("failed to deliver webhooks: %v", err)
+		} else {
+			go logDeliveryErrors(outcomes)
+		}
+		if s.webhooks != nil {
+			if _, err := s.webhooks.PruneDeliveries(ctx, time.Now().Add(-DeliveryRetention)); err != nil {
+				log.Printf("failed to prune webhook deliveries: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.webhookWake:
+		}
+	}
+}
+
+// logDeliveryErrors logs the errors among the outcomes of dispatchDeliveries
+func logDeliveryErrors(outcomes <-chan batchOutcome) {
+	for outcome := range outcomes {
+		if outcome.err != nil {
+			log.Printf("failed to deliver webhooks: %v", outcome.err)
+		}
+	}
+}
+
+// enqueueDeliveries stores a delivery of the event for every active webhook subscribed to it.
+// The deliveries are sent by RunWebhooks, so the request making the change does not wait for them.
+func (s *TaskService) enqueueDeliveries(ctx context.Context, event TaskEvent) {
+	if s.webhooks == nil {
+		return
+	}
+	webhooks, err := s.webhooks.ListWebhooks(ctx)
+	if err != nil {
+		log.Printf("failed to list the webhooks of %s for task %d: %v", event.Type, event.TaskID, err)
+		return
+	}
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhook.Active || !subscribes(webhook, event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				log.Printf("failed to encode %s for task %d: %v", event.Type, event.TaskID, err)
+				return
+			}
+		}
+		now := time.Now()
+		if _, err := s.webhooks.CreateDelivery(ctx, models.Delivery{
+			WebhookID:     webhook.ID,
+			Event:         event.Type,
+			TaskID:        event.TaskID,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			Attempts:      []models.DeliveryAttempt{},
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}); err != nil {
+			log.Printf("failed to queue %s for task %d to webhook %d: %v", event.Type, event.TaskID, webhook.ID, err)
+		}
+	}
+	if payload != nil {
+		s.wakeDeliveries()
+	}
+}
+
+// wakeDeliveries makes RunWebhooks process the due deliveries without waiting for its next tick
+func (s *TaskService) wakeDeliveries() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// attemptDelivery posts the payload of a delivery to its webhook with client, signed with the secret of the webhook
+func attemptDelivery(ctx context.Context, client *http.Client, webhook models.Webhook, delivery models.Delivery) models.DeliveryAttempt {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	start := time.Now()
+	attempt := models.DeliveryAttempt{At: start}
+	err := func() error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+		if err != nil {
+			return err
+		}
+		timestamp := start.Unix()
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("User-Agent", "task-manager-webhooks")
+		request.Header.Set("X-Webhook-Event", delivery.Event)
+		request.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+		request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+		request.Header.Set("X-Webhook-Signature", WebhookSignature(webhook.Secret, timestamp, delivery.Payload))
+
+		if client == nil {
+			client = http.DefaultClient
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = response.Body.Close() }()
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+		attempt.StatusCode = response.StatusCode
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+		}
+		return nil
+	}()
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt
+}
+
+// recordAttempt adds an attempt to the log of a delivery and, after a failure, schedules its next attempt
+// relative to now
+func recordAttempt(delivery *models.Delivery, attempt models.DeliveryAttempt, now time.Time) {
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	if len(delivery.Attempts) > maxKeptAttempts {
+		delivery.Attempts = delivery.Attempts[len(delivery.Attempts)-maxKeptAttempts:]
+	}
+	if attempt.Error == "" {
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &attempt.At
+		delivery.NextAttemptAt = nil
+		return
+	}
+	delivery.Failures++
+	if delivery.Failures >= MaxDeliveryAttempts {
+		delivery.Status = models.DeliveryDead
+		delivery.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(retryDelay(delivery.Failures))
+	delivery.NextAttemptAt = &next
+}
+
+// retryDelay returns the delay before the next attempt of a delivery that failed failures times
+func retryDelay(failures int) time.Duration {
+	return backoff(WebhookRetryDelay, MaxWebhookRetryDelay, failures)
+}
+
+// backoff returns first after the first failure, doubling with every other failure up to maxDelay
+func backoff(first, maxDelay time.Duration, failures int) time.Duration {
+	delay := first
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// checkWebhook checks the URL and the events of a webhook
+func (s *TaskService) checkWebhook(ctx context.Context, webhook models.Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if err := s.checkWebhookHost(ctx, target.Hostname()); err != nil {
+		return err
+	}
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("%w: events is required", ErrInvalidWebhook)
+	}
+	for _, event := range webhook.Events {
+		if !IsEventType(event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	return nil
+}
+
+// checkWebhookHost rejects a host resolving to a loopback, private, link-local or unspecified address, unless it is
+// one of the allowed webhook hosts. A host that cannot be resolved yet is accepted: the addresses are checked again
+// when the deliveries are sent.
+func (s *TaskService) checkWebhookHost(ctx context.Context, host string) error {
+	if s.webhookHostAllowed(host) {
+		return nil
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, address := range addresses {
+		if internalIP(address.IP) {
+			return fmt.Errorf("%w: url must not target the internal address %s", ErrInvalidWebhook, address.IP)
+		}
+	}
+	return nil
+}
+
+// SetWebhookAllowedHosts sets the hosts webhooks may target even though they resolve to a loopback, private or
+// link-local address
+func (s *TaskService) SetWebhookAllowedHosts(hosts []string) {
+	s.webhookHostsMutex.Lock()
+	defer s.webhookHostsMutex.Unlock()
+
+	s.webhookHosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			s.webhookHosts[host] = true
+		}
+	}
+}
+
+// webhookHostAllowed reports whether a host is one of the allowed webhook hosts
+func (s *TaskService) webhookHostAllowed(host string) bool {
+	s.webhookHostsMutex.RLock()
+	defer s.webhookHostsMutex.RUnlock()
+
+	return s.webhookHosts[strings.ToLower(host)]
+}
+
+// newWebhookClient returns the HTTP client deliveries are sent with by default. It refuses to connect to internal
+// addresses, unless the host is allowed, so a host resolving to another address after its registration is not reached.
+func (s *TaskService) newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{Timeout: webhookTimeout}
+		if !s.webhookHostAllowed(host) {
+			dialer.Control = func(_, address string, _ syscall.RawConn) error {
+				ip, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if internalIP(net.ParseIP(ip)) {
+					return fmt.Errorf("webhook host %s resolves to the internal address %s", host, ip)
+				}
+				return nil
+			}
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// internalIP reports whether an address is loopback, private, link-local or unspecified, or cannot be parsed
+func internalIP(ip net.IP) bool {
+	return ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
+
+// IsEventType reports whether eventType is one of EventTypes
+func IsEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEvents sorts event types and removes duplicates
+func normalizeEvents(events []string) []string {
+	normalized := slices.Clone(events)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// subscribes reports whether a webhook receives the events of a type
+func subscribes(webhook models.Webhook, eventType string) bool {
+	for _, event := range webhook.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWebhookClient sets the HTTP client deliveries of the default service are sent with
+func SetWebhookClient(client *http.Client) {
+	defaultService.SetWebhookClient(client)
+}
+
+// ListWebhooks returns every webhook using the default service
+func ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return defaultService.ListWebhooks(ctx)
+}
+
+// GetWebhook returns a webhook using the default service
+func GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	return defaultService.GetWebhook(ctx, id)
+}
+
+// CreateWebhook subscribes a URL to task events using the default service
+func CreateWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	return defaultService.CreateWebhook(ctx, webhook)
+}
+
+// UpdateWebhook replaces a webhook using the default service
+func UpdateWebhook(ctx context.Context, id int, webhook models.Webhook) (models.Webhook, error) {
+	return defaultService.UpdateWebhook(ctx, id, webhook)
+}
+
+// DeleteWebhook removes a webhook using the default service
+func DeleteWebhook(ctx context.Context, id int) error {
+	return defaultService.DeleteWebhook(ctx, id)
+}
+
+// ListDeliveries returns webhook deliveries using the default service
+func ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.Delivery, error) {
+	return defaultService.ListDeliveries(ctx, filter)
+}
+
+// GetDelivery returns a delivery of a webhook using the default service
+func GetDelivery(ctx context.Context, webhookID, id int) (models.Delivery, error) {
+	return defaultService.GetDelivery(ctx, webhookID, id)
+}
+
+// Redeliver sends a delivery again using the default service
+func Redeliver(ctx context.Context, webhookID, id int) (models.Delivery, error) {
+	return defaultService.Redeliver(ctx, webhookID, id)
+}
+
+// SetWebhookAllowedHosts sets the internal hosts webhooks of the default service may target
+func SetWebhookAllowedHosts(hosts []string) {
+	defaultService.SetWebhookAllowedHosts(hosts)
+}
+
+// RunWebhooks sends the webhook deliveries of the default service
+func RunWebhooks(ctx context.Context, interval time.Duration) {
+	defaultService.RunWebhooks(ctx, interval)
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"os"
+)
+
+// ErrIllegalTransition is returned when the workflow does not allow a task to move to the requested status
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// LoadWorkflow reads and validates a JSON workflow definition
+func LoadWorkflow(path string) (models.Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Workflow{}, fmt.Errorf("read workflow: %w", err)
+	}
+
+	var workflow models.Workflow
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return models.Workflow{}, fmt.Errorf("decode workflow: %w", err)
+	}
+	if err := workflow.Validate(); err != nil {
+		return models.Workflow{}, err
+	}
+	return workflow, nil
+}
+
+// Workflow returns the workflow enforced by the service
+func (s *TaskService) Workflow() models.Workflow {
+	s.workflowMutex.RLock()
+	defer s.workflowMutex.RUnlock()
+
+	return s.workflow
+}
+
+// SetWorkflow replaces the workflow enforced by the service
+func (s *TaskService) SetWorkflow(workflow models.Workflow) error {
+	if err := workflow.Validate(); err != nil {
+		return err
+	}
+
+	s.workflowMutex.Lock()
+	defer s.workflowMutex.Unlock()
+
+	s.workflow = workflow
+	return nil
+}
+
+// checkTransition returns ErrIllegalTransition if the workflow does not allow moving from one status to another
+func (s *TaskService) checkTransition(from, to string) error {
+	if !s.Workflow().Allows(from, to) {
+		return fmt.Errorf("%w: cannot move a task from %q to %q", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// canonicalStatus returns the status as declared by the workflow, so "completed" is stored as "Completed"
+func (s *TaskService) canonicalStatus(name string) string {
+	if status, ok := s.Workflow().Status(name); ok {
+		return status.Name
+	}
+	return name
+}
+
+// Workflow returns the workflow enforced by the default service
+func Workflow() models.Workflow {
+	return defaultService.Workflow()
+}
+
+// SetWorkflow replaces the workflow enforced by the default service
+func SetWorkflow(workflow models.Workflow) error {
+	return defaultService.SetWorkflow(workflow)
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// blobTempDir holds the blobs being written, until their digest is known
+const blobTempDir = "tmp"
+
+var (
+	// digestPattern matches a hex encoded SHA-256 digest
+	digestPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// cachedNamePattern matches the names of the files cached next to a blob, e.g. thumb-128.png
+	cachedNamePattern = regexp.MustCompile(`^[a-z0-9-]+\.[a-z]+$`)
+)
+
+// BlobStore is a content-addressed models.BlobStore on the local disk.
+// A blob is stored in dir/ab/abcd..., named after its SHA-256 digest and sharded by its first two characters.
+type BlobStore struct {
+	dir string
+}
+
+// OpenBlobStore returns a BlobStore keeping its blobs in dir, creating it if needed.
+// Blobs left half written by a crash are removed.
+func OpenBlobStore(dir string) (*BlobStore, error) {
+	if err := os.RemoveAll(filepath.Join(dir, blobTempDir)); err != nil {
+		return nil, fmt.Errorf("clean blob directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, blobTempDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create blob directory: %w", err)
+	}
+	return &BlobStore{dir: dir}, nil
+}
+
+// Dir returns the directory of the blob store
+func (s *BlobStore) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of the blob with the given digest
+func (s *BlobStore) Path(digest string) string {
+	return filepath.Join(s.dir, digest[:2], digest)
+}
+
+// Put writes the content to a temporary file while hashing it, then moves it to the path of its digest.
+// When a blob with the same digest already exists the temporary file is discarded.
+// A read error from r, e.g. a size limit, aborts the write and is returned as is.
+func (s *BlobStore) Put(_ context.Context, r io.Reader) (string, int64, error) {
+	file, err := os.CreateTemp(filepath.Join(s.dir, blobTempDir), "blob-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("create blob: %w", err)
+	}
+	tempPath := file.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	path := s.Path(digest)
+	if _, err := os.Stat(path); err == nil {
+		return digest, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", 0, fmt.Errorf("create blob: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return "", 0, fmt.Errorf("create blob: %w", err)
+	}
+	return digest, size, nil
+}
+
+// Open returns the content of a blob
+func (s *BlobStore) Open(_ context.Context, digest string) (io.ReadSeekCloser, error) {
+	if !digestPattern.MatchString(digest) {
+		return nil, models.ErrBlobNotFound
+	}
+	file, err := os.Open(s.Path(digest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, models.ErrBlobNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes a blob along with the files cached next to it, e.g. its thumbnails
+func (s *BlobStore) Delete(_ context.Context, digest string) error {
+	if !digestPattern.MatchString(digest) {
+		return nil
+	}
+	cached, err := filepath.Glob(s.Path(digest) + ".*")
+	if err != nil {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	for _, path := range append(cached, s.Path(digest)) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("delete blob: %w", err)
+		}
+	}
+	return nil
+}
+
+// OpenCached returns a file cached next to a blob
+func (s *BlobStore) OpenCached(_ context.Context, digest, name string) (io.ReadSeekCloser, error) {
+	if !digestPattern.MatchString(digest) || !cachedNamePattern.MatchString(name) {
+		return nil, models.ErrBlobNotFound
+	}
+	file, err := os.Open(s.Path(digest) + "." + name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, models.ErrBlobNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("open cached file: %w", err)
+	}
+	return file, nil
+}
+
+// PutCached writes a file next to a blob, e.g. dir/ab/abcd....thumb-128.png, through a temporary file
+// so it is never read half written
+func (s *BlobStore) PutCached(_ context.Context, digest, name string, content []byte) error {
+	if !cachedNamePattern.MatchString(name) {
+		return fmt.Errorf("cache file: invalid name %q", name)
+	}
+	if !digestPattern.MatchString(digest) {
+		return models.ErrBlobNotFound
+	}
+	if _, err := os.Stat(s.Path(digest)); errors.Is(err, fs.ErrNotExist) {
+		return models.ErrBlobNotFound
+	}
+
+	file, err := os.CreateTemp(filepath.Join(s.dir, blobTempDir), "cached-*")
+	if err != nil {
+		return fmt.Errorf("cache file: %w", err)
+	}
+	tempPath := file.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, s.Path(digest)+"."+name)
+	}
+	if err != nil {
+		return fmt.Errorf("cache file: %w", err)
+	}
+	return nil
+}
+
+// List returns the digests of every stored blob
+func (s *BlobStore) List(_ context.Context) ([]string, error) {
+	shards, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list blobs: %w", err)
+	}
+	digests := make([]string, 0)
+	for _, shard := range shards {
+		if !shard.IsDir() || shard.Name() == blobTempDir {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, shard.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("list blobs: %w", err)
+		}
+		for _, entry := range entries {
+			if digestPattern.MatchString(entry.Name()) {
+				digests = append(digests, entry.Name())
+			}
+		}
+	}
+	return digests, nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ = Describe("Blob Store Tests", func() {
+	var (
+		ctx   context.Context
+		dir   string
+		blobs *BlobStore
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+
+		var err error
+		blobs, err = OpenBlobStore(dir)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	read := func(digest string) string {
+		content, err := blobs.Open(ctx, digest)
+		Expect(err).ToNot(HaveOccurred())
+		defer func() { _ = content.Close() }()
+
+		data, err := io.ReadAll(content)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	It("should store contents under their SHA-256 digest once", func() {
+		sum := sha256.Sum256([]byte("hello"))
+		expected := hex.EncodeToString(sum[:])
+
+		digest, size, err := blobs.Put(ctx, strings.NewReader("hello"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal(expected))
+		Expect(size).To(Equal(int64(5)))
+		Expect(blobs.Path(digest)).To(Equal(filepath.Join(dir, expected[:2], expected)))
+		Expect(read(digest)).To(Equal("hello"))
+
+		again, _, err := blobs.Put(ctx, strings.NewReader("hello"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(Equal(digest))
+		Expect(blobs.List(ctx)).To(ConsistOf(digest))
+
+		temp, err := os.ReadDir(filepath.Join(dir, blobTempDir))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(temp).To(BeEmpty())
+	})
+
+	It("should abort a write when reading the content fails", func() {
+		failure := errors.New("too large")
+		_, _, err := blobs.Put(ctx, io.MultiReader(strings.NewReader("partial"), &failingReader{err: failure}))
+		Expect(err).To(MatchError(failure))
+		Expect(blobs.List(ctx)).To(BeEmpty())
+
+		temp, err := os.ReadDir(filepath.Join(dir, blobTempDir))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(temp).To(BeEmpty())
+	})
+
+	It("should cache files next to a blob and delete them along with it", func() {
+		digest, _, err := blobs.Put(ctx, strings.NewReader("image"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blobs.PutCached(ctx, digest, "thumb-64.png", []byte("thumbnail"))).To(Succeed())
+		cached, err := blobs.OpenCached(ctx, digest, "thumb-64.png")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(io.ReadAll(cached)).To(Equal([]byte("thumbnail")))
+		Expect(cached.Close()).To(Succeed())
+
+		Expect(blobs.List(ctx)).To(ConsistOf(digest))
+		Expect(blobs.Delete(ctx, digest)).To(Succeed())
+		Expect(blobs.Delete(ctx, digest)).To(Succeed())
+		Expect(blobs.List(ctx)).To(BeEmpty())
+		Expect(blobs.Path(digest) + ".thumb-64.png").ToNot(BeAnExistingFile())
+		Expect(blobs.PutCached(ctx, digest, "thumb-64.png", []byte("thumbnail"))).To(MatchError(models.ErrBlobNotFound))
+		_, err = blobs.OpenCached(ctx, digest, "thumb-64.png")
+		Expect(err).To(MatchError(models.ErrBlobNotFound))
+
+		_, err = blobs.Open(ctx, digest)
+		Expect(err).To(MatchError(models.ErrBlobNotFound))
+		_, err = blobs.Open(ctx, "../tasks.db")
+		Expect(err).To(MatchError(models.ErrBlobNotFound))
+	})
+
+	It("should remove the blobs left half written when it is opened", func() {
+		Expect(os.WriteFile(filepath.Join(dir, blobTempDir, "blob-1"), []byte("partial"), 0o644)).To(Succeed())
+
+		var err error
+		blobs, err = OpenBlobStore(dir)
+		Expect(err).ToNot(HaveOccurred())
+		temp, err := os.ReadDir(filepath.Join(dir, blobTempDir))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(temp).To(BeEmpty())
+	})
+})
+
+// failingReader fails every read with err
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a single versioned schema change.
+// Migrations are applied in order and must never be edited once released - add a new one instead.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create tasks table",
+		statements: []string{
+			`CREATE TABLE tasks (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				title       TEXT NOT NULL,
+				description TEXT NOT NULL,
+				status      TEXT NOT NULL,
+				created_at  TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add task version and updated_at",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+			`ALTER TABLE tasks ADD COLUMN updated_at TEXT NOT NULL DEFAULT ''`,
+			`UPDATE tasks SET updated_at = created_at`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create idempotency_keys table",
+		statements: []string{
+			// expires_at is stored in Unix nanoseconds so expiry can be compared in SQL
+			`CREATE TABLE idempotency_keys (
+				key          TEXT PRIMARY KEY,
+				request_hash TEXT NOT NULL,
+				task         TEXT NOT NULL,
+				created_at   TEXT NOT NULL,
+				expires_at   INTEGER NOT NULL
+			)`,
+			`CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "create task_revisions table",
+		statements: []string{
+			// No foreign key to tasks: the history of a task outlives it
+			`CREATE TABLE task_revisions (
+				task_id    INTEGER NOT NULL,
+				revision   INTEGER NOT NULL,
+				action     TEXT NOT NULL,
+				actor      TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				changes    TEXT NOT NULL,
+				task       TEXT NOT NULL,
+				PRIMARY KEY (task_id, revision)
+			)`,
+		},
+	},
+	{
+		version: 5,
+		name:    "add revision revert and undo references",
+		statements: []string{
+			`ALTER TABLE task_revisions ADD COLUMN reverted_to INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE task_revisions ADD COLUMN undoes INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX task_revisions_actor ON task_revisions (actor)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add task deleted_at",
+		statements: []string{
+			// NULL unless the task is in the trash
+			`ALTER TABLE tasks ADD COLUMN deleted_at TEXT`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add task due_at, priority and effort_hours",
+		statements: []string{
+			// due_at keeps its time zone offset, unlike the other timestamps
+			`ALTER TABLE tasks ADD COLUMN due_at TEXT`,
+			`ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN effort_hours REAL NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 8,
+		name:    "create labels and task_labels tables",
+		statements: []string{
+			`CREATE TABLE labels (
+				name        TEXT PRIMARY KEY,
+				color       TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE TABLE task_labels (
+				task_id INTEGER NOT NULL,
+				label   TEXT NOT NULL,
+				PRIMARY KEY (task_id, label)
+			)`,
+			// The label index answers ?label= queries without scanning the tasks
+			`CREATE INDEX task_labels_label ON task_labels (label, task_id)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add task parent_id",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN parent_id INTEGER`,
+			`CREATE INDEX tasks_parent_id ON tasks (parent_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add task blocked_by",
+		statements: []string{
+			// JSON array of the IDs of the blocking tasks, NULL when there are none
+			`ALTER TABLE tasks ADD COLUMN blocked_by TEXT`,
+		},
+	},
+	{
+		version: 11,
+		name:    "create task_links table and add task resolution",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN resolution TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE task_links (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				type       TEXT NOT NULL,
+				source_id  INTEGER NOT NULL,
+				target_id  INTEGER NOT NULL,
+				created_at TEXT NOT NULL
+			)`,
+			`CREATE INDEX task_links_source_id ON task_links (source_id)`,
+			`CREATE INDEX task_links_target_id ON task_links (target_id)`,
+		},
+	},
+	{
+		version: 12,
+		name:    "create comments table",
+		statements: []string{
+			// edits holds the previous bodies of a comment as a JSON array
+			`CREATE TABLE comments (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id    INTEGER NOT NULL,
+				parent_id  INTEGER,
+				author     TEXT NOT NULL DEFAULT '',
+				body       TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL,
+				deleted_at TEXT,
+				edits      TEXT
+			)`,
+			`CREATE INDEX comments_task_id ON comments (task_id)`,
+		},
+	},
+	{
+		version: 13,
+		name:    "create attachments table",
+		statements: []string{
+			`CREATE TABLE attachments (
+				id           INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id      INTEGER NOT NULL,
+				filename     TEXT NOT NULL,
+				content_type TEXT NOT NULL,
+				size         INTEGER NOT NULL,
+				sha256       TEXT NOT NULL,
+				uploader     TEXT NOT NULL DEFAULT '',
+				created_at   TEXT NOT NULL
+			)`,
+			`CREATE INDEX attachments_task_id ON attachments (task_id)`,
+		},
+	},
+	{
+		version: 14,
+		name:    "add task checklist",
+		statements: []string{
+			// JSON array of the checklist items in their order, NULL when there are none
+			`ALTER TABLE tasks ADD COLUMN checklist TEXT`,
+		},
+	},
+	{
+		version: 15,
+		name:    "add task recurrence",
+		statements: []string{
+			// JSON object of the recurrence, NULL for tasks that do not recur
+			`ALTER TABLE tasks ADD COLUMN recurrence TEXT`,
+			`ALTER TABLE tasks ADD COLUMN next_occurrence_id INTEGER`,
+		},
+	},
+	{
+		version: 16,
+		name:    "create reminders table",
+		statements: []string{
+			// before_ns is the offset before the due date in nanoseconds
+			`CREATE TABLE reminders (
+				id            INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id       INTEGER NOT NULL,
+				before_ns     INTEGER NOT NULL,
+				channel       TEXT NOT NULL,
+				recipient     TEXT NOT NULL DEFAULT '',
+				snoozed_until TEXT,
+				fired_for     TEXT,
+				fired_at      TEXT,
+				created_at    TEXT NOT NULL
+			)`,
+			`CREATE INDEX reminders_task_id ON reminders (task_id)`,
+		},
+	},
+	{
+		version: 17,
+		name:    "create webhooks and deliveries tables",
+		statements: []string{
+			// events is a JSON array of the event types of the webhook
+			`CREATE TABLE webhooks (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				url        TEXT NOT NULL,
+				events     TEXT NOT NULL,
+				secret     TEXT NOT NULL,
+				active     INTEGER NOT NULL,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL
+			)`,
+			// attempts is a JSON array of the logged attempts of the delivery
+			`CREATE TABLE webhook_deliveries (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id      INTEGER NOT NULL,
+				event           TEXT NOT NULL,
+				task_id         INTEGER NOT NULL,
+				payload         TEXT NOT NULL,
+				status          TEXT NOT NULL,
+				failures        INTEGER NOT NULL DEFAULT 0,
+				attempts        TEXT NOT NULL,
+				next_attempt_at TEXT,
+				created_at      TEXT NOT NULL,
+				delivered_at    TEXT
+			)`,
+			`CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id)`,
+			`CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status, next_attempt_at)`,
+		},
+	},
+	{
+		version: 18,
+		name:    "add reminder retries",
+		statements: []string{
+			`ALTER TABLE reminders ADD COLUMN failures INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE reminders ADD COLUMN last_error TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE reminders ADD COLUMN retry_at TEXT`,
+		},
+	},
+}
+
+// migrate applies every migration newer than the current schema version.
+// Each migration runs in its own transaction together with its schema_migrations record.
+func migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the version of the latest applied migration, or 0 for an empty database
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
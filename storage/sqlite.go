@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+
+	// Pure-Go SQLite driver, registered as "sqlite". Builds without cgo.
+	_ "modernc.org/sqlite"
+)
+
+// taskColumns lists the columns of a task, in the order of taskPlaceholders, taskValues and scanTask
+const (
+	taskColumns      = `id, title, description, status, created_at, updated_at, version, deleted_at, due_at, priority, effort_hours, parent_id, blocked_by, resolution, checklist, recurrence, next_occurrence_id`
+	taskPlaceholders = `?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?`
+	// taskSelect is taskColumns followed by the computed comment count, as read by scanTask
+	taskSelect = taskColumns + `, (SELECT COUNT(*) FROM comments WHERE comments.task_id = tasks.id AND comments.deleted_at IS NULL)`
+)
+
+// SQLiteStore is a models.TaskStore backed by a SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (or creates) the SQLite database at path and applies any pending migrations.
+// Use ":memory:" for a throwaway database.
+func OpenSQLite(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer. Serializing connections avoids SQLITE_BUSY errors
+	// and keeps ":memory:" databases from being split across connections.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Create inserts a new task and returns it with its assigned ID
+func (s *SQLiteStore) Create(ctx context.Context, task models.Task) (models.Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("insert task: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// A NULL id makes SQLite assign the next one
+	task.ID = 0
+	result, err := tx.ExecContext(ctx, `INSERT INTO tasks (`+taskColumns+`) VALUES (`+taskPlaceholders+`)`, taskValues(task)...)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("insert task: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Task{}, fmt.Errorf("insert task: %w", err)
+	}
+	task.ID = int(id)
+	task.CommentCount = 0
+	if err := saveTaskLabels(ctx, tx, task); err != nil {
+		return models.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Task{}, fmt.Errorf("insert task: %w", err)
+	}
+	return task, nil
+}
+
+// Recreate inserts a deleted task again under its original ID
+func (s *SQLiteStore) Recreate(ctx context.Context, task models.Task) (models.Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("recreate task: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := getTask(ctx, tx, task.ID); err == nil {
+		return models.Task{}, models.ErrTaskExists
+	} else if !errors.Is(err, models.ErrTaskNotFound) {
+		return models.Task{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO tasks (`+taskColumns+`) VALUES (`+taskPlaceholders+`)`, taskValues(task)...); err != nil {
+		return models.Task{}, fmt.Errorf("recreate task: %w", err)
+	}
+	if err := saveTaskLabels(ctx, tx, task); err != nil {
+		return models.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Task{}, fmt.Errorf("recreate task: %w", err)
+	}
+	task.CommentCount = 0
+	return task, nil
+}
+
+// Get retrieves a task by its ID
+func (s *SQLiteStore) Get(ctx context.Context, id int) (models.Task, error) {
+	return getTask(ctx, s.db, id)
+}
+
+// List retrieves all tasks ordered by ID
+func (s *SQLiteStore) List(ctx context.Context) ([]models.Task, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+taskSelect+` FROM tasks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	tasks := make([]models.Task, 0)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	_ = rows.Close()
+
+	labels, err := s.allTaskLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		tasks[i].Labels = labels[tasks[i].ID]
+	}
+	return tasks, nil
+}
+
+// Update reads the task, applies update and writes it back in a single transaction
+func (s *SQLiteStore) Update(ctx context.Context, id int, update func(task *models.Task) error) (models.Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("update task: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	task, err := getTask(ctx, tx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	commentCount := task.CommentCount
+	if err := update(&task); err != nil {
+		return models.Task{}, err
+	}
+	task.ID = id
+	task.CommentCount = commentCount
+
+	if err := writeTask(ctx, tx, task); err != nil {
+		return models.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Task{}, fmt.Errorf("update task: %w", err)
+	}
+	return task, nil
+}
+
+// UpdateTasks reads the tasks, applies update and writes them back in a single transaction
+func (s *SQLiteStore) UpdateTasks(ctx context.Context, ids []int, update func(task *models.Task) error) ([]models.Task, []models.Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("update tasks: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	before := make([]models.Task, 0, len(ids))
+	after := make([]models.Task, 0, len(ids))
+	for _, id := range ids {
+		task, err := getTask(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		before = append(before, task)
+		if err := update(&task); err != nil {
+			return nil, nil, err
+		}
+		task.ID = id
+		task.CommentCount = before[len(before)-1].CommentCount
+		if err := writeTask(ctx, tx, task); err != nil {
+			return nil, nil, err
+		}
+		after = append(after, task)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("update tasks: %w", err)
+	}
+	return before, after, nil
+}
+
+// Delete removes a task by its ID if check, when set, accepts it
+func (s *SQLiteStore) Delete(ctx context.Context, id int, check func(task models.Task) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	task, err := getTask(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(task); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tasks WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_labels WHERE task_id = ?`, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_links WHERE source_id = ? OR target_id = ?`, id, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE task_id = ?`, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM attachments WHERE task_id = ?`, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE task_id = ?`, id); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	return nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func getTask(ctx context.Context, q querier, id int) (models.Task, error) {
+	task, err := scanTask(q.QueryRowContext(ctx, `SELECT `+taskSelect+` FROM tasks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Task{}, models.ErrTaskNotFound
+	}
+	if err != nil {
+		return models.Task{}, err
+	}
+	task.Labels, err = taskLabels(ctx, q, id)
+	return task, err
+}
+
+// writeTask replaces the columns and labels of an existing task
+func writeTask(ctx context.Context, tx *sql.Tx, task models.Task) error {
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE tasks SET (`+taskColumns+`) = (`+taskPlaceholders+`) WHERE id = ?`,
+		append(taskValues(task), task.ID)...,
+	); err != nil {
+		return fmt.Errorf("update task: %w", err)
+	}
+	return saveTaskLabels(ctx, tx, task)
+}
+
+// taskValues returns the column values of a task. A zero ID is stored as NULL.
+func taskValues(task models.Task) []any {
+	var id any
+	if task.ID != 0 {
+		id = task.ID
+	}
+	return []any{
+		id, task.Title, task.Description, task.Status, formatTime(task.CreatedAt), formatTime(task.UpdatedAt), task.Version,
+		formatNullTime(task.DeletedAt), formatZonedTime(task.DueAt), task.Priority, task.EffortHours, task.ParentID,
+		formatIDs(task.BlockedBy), task.Resolution, formatChecklist(task.Checklist), formatRecurrence(task.Recurrence),
+		task.NextOccurrenceID,
+	}
+}
+
+func scanTask(row scanner) (models.Task, error) {
+	var (
+		task                 models.Task
+		createdAt, updatedAt string
+		deletedAt, dueAt     sql.NullString
+		parentID, nextID     sql.NullInt64
+		blockedBy, checklist sql.NullString
+		recurrence           sql.NullString
+	)
+	if err := row.Scan(&task.ID, &task.Title, &task.Description, &task.Status, &createdAt, &updatedAt, &task.Version,
+		&deletedAt, &dueAt, &task.Priority, &task.EffortHours, &parentID, &blockedBy, &task.Resolution, &checklist, &recurrence, &nextID, &task.CommentCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Task{}, err
+		}
+		return models.Task{}, fmt.Errorf("scan task: %w", err)
+	}
+
+	var err error
+	if task.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+	}
+	if task.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+	}
+	if task.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+	}
+	if task.DueAt, err = parseNullTime(dueAt); err != nil {
+		return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		task.ParentID = &id
+	}
+	if blockedBy.Valid {
+		if err := json.Unmarshal([]byte(blockedBy.String), &task.BlockedBy); err != nil {
+			return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+		}
+	}
+	if checklist.Valid {
+		if err := json.Unmarshal([]byte(checklist.String), &task.Checklist); err != nil {
+			return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+		}
+	}
+	if recurrence.Valid {
+		if err := json.Unmarshal([]byte(recurrence.String), &task.Recurrence); err != nil {
+			return models.Task{}, fmt.Errorf("scan task %d: %w", task.ID, err)
+		}
+	}
+	if nextID.Valid {
+		id := int(nextID.Int64)
+		task.NextOccurrenceID = &id
+	}
+	return task, nil
+}
+
+// Timestamps are stored as RFC 3339 text in UTC so they keep their full precision
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// formatNullTime stores a nil time as NULL
+func formatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// formatIDs stores task IDs as a JSON array, or NULL when there are none
+func formatIDs(ids []int) any {
+	if len(ids) == 0 {
+		return nil
+	}
+	encoded, _ := json.Marshal(ids)
+	return string(encoded)
+}
+
+// formatChecklist stores a checklist as a JSON array, or NULL when it is empty
+func formatChecklist(checklist []models.ChecklistItem) any {
+	if len(checklist) == 0 {
+		return nil
+	}
+	encoded, _ := json.Marshal(checklist)
+	return string(encoded)
+}
+
+// formatRecurrence stores a recurrence as a JSON object, or NULL for tasks that do not recur
+func formatRecurrence(recurrence *models.Recurrence) any {
+	if recurrence == nil {
+		return nil
+	}
+	encoded, _ := json.Marshal(recurrence)
+	return string(encoded)
+}
+
+// formatZonedTime stores a nil time as NULL and keeps the time zone offset of the others
+func formatZonedTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
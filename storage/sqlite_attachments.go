@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+)
+
+const attachmentColumns = `id, task_id, filename, content_type, size, sha256, uploader, created_at`
+
+// CreateAttachment stores a new attachment
+func (s *SQLiteStore) CreateAttachment(ctx context.Context, attachment models.Attachment) (models.Attachment, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO attachments (task_id, filename, content_type, size, sha256, uploader, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		attachment.TaskID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.SHA256,
+		attachment.Uploader, formatTime(attachment.CreatedAt),
+	)
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("create attachment: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("create attachment: %w", err)
+	}
+	attachment.ID = int(id)
+	return attachment, nil
+}
+
+// GetAttachment returns an attachment by its ID
+func (s *SQLiteStore) GetAttachment(ctx context.Context, id int) (models.Attachment, error) {
+	attachment, err := scanAttachment(s.db.QueryRowContext(ctx, `SELECT `+attachmentColumns+` FROM attachments WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Attachment{}, models.ErrAttachmentNotFound
+	}
+	return attachment, err
+}
+
+// DeleteAttachment removes an attachment by its ID
+func (s *SQLiteStore) DeleteAttachment(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	} else if n == 0 {
+		return models.ErrAttachmentNotFound
+	}
+	return nil
+}
+
+// ListAttachments returns the attachments of a task ordered by ID
+func (s *SQLiteStore) ListAttachments(ctx context.Context, taskID int) ([]models.Attachment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+attachmentColumns+` FROM attachments WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	attachments := make([]models.Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// ReferencedBlobs returns the digests of the blobs referenced by any attachment
+func (s *SQLiteStore) ReferencedBlobs(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT sha256 FROM attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("list referenced blobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	digests := make(map[string]bool)
+	for rows.Next() {
+		var digest string
+		if err := rows.Scan(&digest); err != nil {
+			return nil, fmt.Errorf("list referenced blobs: %w", err)
+		}
+		digests[digest] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list referenced blobs: %w", err)
+	}
+	return digests, nil
+}
+
+func scanAttachment(row scanner) (models.Attachment, error) {
+	var (
+		attachment models.Attachment
+		createdAt  string
+	)
+	if err := row.Scan(&attachment.ID, &attachment.TaskID, &attachment.Filename, &attachment.ContentType,
+		&attachment.Size, &attachment.SHA256, &attachment.Uploader, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Attachment{}, err
+		}
+		return models.Attachment{}, fmt.Errorf("scan attachment: %w", err)
+	}
+	var err error
+	if attachment.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Attachment{}, fmt.Errorf("scan attachment: %w", err)
+	}
+	return attachment, nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+)
+
+// commentColumns lists the columns of a comment, in the order of commentValues and scanComment
+const commentColumns = `task_id, parent_id, author, body, created_at, updated_at, deleted_at, edits`
+
+// CreateComment stores a new comment
+func (s *SQLiteStore) CreateComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	values, err := commentValues(comment)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("create comment: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, `INSERT INTO comments (`+commentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, values...)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("create comment: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("create comment: %w", err)
+	}
+	comment.ID = int(id)
+	return comment, nil
+}
+
+// GetComment returns a comment by its ID
+func (s *SQLiteStore) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	return getComment(ctx, s.db, id)
+}
+
+// UpdateComment reads the comment, applies update and writes it back in a single transaction
+func (s *SQLiteStore) UpdateComment(ctx context.Context, id int, update func(comment *models.Comment) error) (models.Comment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("update comment: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	comment, err := getComment(ctx, tx, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if err := update(&comment); err != nil {
+		return models.Comment{}, err
+	}
+	comment.ID = id
+
+	values, err := commentValues(comment)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("update comment: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE comments SET (`+commentColumns+`) = (?, ?, ?, ?, ?, ?, ?, ?) WHERE id = ?`, append(values, id)...,
+	); err != nil {
+		return models.Comment{}, fmt.Errorf("update comment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Comment{}, fmt.Errorf("update comment: %w", err)
+	}
+	return comment, nil
+}
+
+// DeleteComment removes a comment by its ID
+func (s *SQLiteStore) DeleteComment(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete comment: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("delete comment: %w", err)
+	} else if n == 0 {
+		return models.ErrCommentNotFound
+	}
+	return nil
+}
+
+// ListComments returns the comments of a task ordered by ID
+func (s *SQLiteStore) ListComments(ctx context.Context, taskID int) ([]models.Comment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, `+commentColumns+` FROM comments WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list comments: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	comments := make([]models.Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list comments: %w", err)
+	}
+	return comments, nil
+}
+
+func getComment(ctx context.Context, q querier, id int) (models.Comment, error) {
+	comment, err := scanComment(q.QueryRowContext(ctx, `SELECT id, `+commentColumns+` FROM comments WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Comment{}, models.ErrCommentNotFound
+	}
+	return comment, err
+}
+
+// commentValues returns the column values of a comment
+func commentValues(comment models.Comment) ([]any, error) {
+	var edits any
+	if len(comment.Edits) > 0 {
+		encoded, err := json.Marshal(comment.Edits)
+		if err != nil {
+			return nil, err
+		}
+		edits = string(encoded)
+	}
+	return []any{
+		comment.TaskID, comment.ParentID, comment.Author, comment.Body, formatTime(comment.CreatedAt),
+		formatTime(comment.UpdatedAt), formatNullTime(comment.DeletedAt), edits,
+	}, nil
+}
+
+func scanComment(row scanner) (models.Comment, error) {
+	var (
+		comment              models.Comment
+		parentID             sql.NullInt64
+		createdAt, updatedAt string
+		deletedAt, edits     sql.NullString
+	)
+	if err := row.Scan(&comment.ID, &comment.TaskID, &parentID, &comment.Author, &comment.Body, &createdAt, &updatedAt,
+		&deletedAt, &edits); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Comment{}, err
+		}
+		return models.Comment{}, fmt.Errorf("scan comment: %w", err)
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		comment.ParentID = &id
+	}
+	var err error
+	if comment.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Comment{}, fmt.Errorf("scan comment %d: %w", comment.ID, err)
+	}
+	if comment.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return models.Comment{}, fmt.Errorf("scan comment %d: %w", comment.ID, err)
+	}
+	if comment.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return models.Comment{}, fmt.Errorf("scan comment %d: %w", comment.ID, err)
+	}
+	if edits.Valid {
+		if err := json.Unmarshal([]byte(edits.String), &comment.Edits); err != nil {
+			return models.Comment{}, fmt.Errorf("scan comment %d: %w", comment.ID, err)
+		}
+	}
+	return comment, nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+)
+
+// AppendRevision stores a revision of a task, numbering it after the latest one in the same transaction
+func (s *SQLiteStore) AppendRevision(ctx context.Context, revision models.Revision) (models.Revision, error) {
+	changes, err := json.Marshal(revision.Changes)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	task, err := json.Marshal(revision.Task)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Revision{}, fmt.Errorf("append revision: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(revision), 0) + 1 FROM task_revisions WHERE task_id = ?`, revision.TaskID,
+	).Scan(&revision.Number); err != nil {
+		return models.Revision{}, fmt.Errorf("append revision: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO task_revisions (task_id, revision, action, actor, created_at, changes, task, reverted_to, undoes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		revision.TaskID, revision.Number, revision.Action, revision.Actor, formatTime(revision.CreatedAt), string(changes), string(task),
+		revision.RevertedTo, revision.Undoes,
+	); err != nil {
+		return models.Revision{}, fmt.Errorf("append revision: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Revision{}, fmt.Errorf("append revision: %w", err)
+	}
+	return revision, nil
+}
+
+// ListRevisions returns the revisions of a task ordered by number
+func (s *SQLiteStore) ListRevisions(ctx context.Context, taskID int) ([]models.Revision, error) {
+	return s.queryRevisions(ctx, `WHERE task_id = ? ORDER BY revision`, taskID)
+}
+
+// ListRevisionsByActor returns the revisions made by an actor across all tasks, oldest first
+func (s *SQLiteStore) ListRevisionsByActor(ctx context.Context, actor string) ([]models.Revision, error) {
+	revisions, err := s.queryRevisions(ctx, `WHERE actor = ?`, actor)
+	if err != nil {
+		return nil, err
+	}
+	// created_at is RFC 3339 text, which does not sort chronologically
+	models.SortRevisions(revisions)
+	return revisions, nil
+}
+
+func (s *SQLiteStore) queryRevisions(ctx context.Context, where string, args ...any) ([]models.Revision, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT task_id, revision, action, actor, created_at, changes, task, reverted_to, undoes FROM task_revisions `+where,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var (
+			revision                 models.Revision
+			createdAt, changes, task string
+		)
+		if err := rows.Scan(&revision.TaskID, &revision.Number, &revision.Action, &revision.Actor, &createdAt, &changes, &task,
+			&revision.RevertedTo, &revision.Undoes); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		if revision.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		if err := json.Unmarshal([]byte(changes), &revision.Changes); err != nil {
+			return nil, fmt.Errorf("decode revision changes: %w", err)
+		}
+		if err := json.Unmarshal([]byte(task), &revision.Task); err != nil {
+			return nil, fmt.Errorf("decode revision task: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	return revisions, nil
+}
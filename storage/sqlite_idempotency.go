@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+// GetIdempotencyKey returns a stored idempotency key that has not expired
+func (s *SQLiteStore) GetIdempotencyKey(ctx context.Context, key string, now time.Time) (models.IdempotencyKey, error) {
+	var (
+		record    models.IdempotencyKey
+		task      string
+		createdAt string
+		expiresAt int64
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT key, request_hash, task, created_at, expires_at FROM idempotency_keys WHERE key = ? AND expires_at > ?`,
+		key, now.UnixNano(),
+	).Scan(&record.Key, &record.RequestHash, &task, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.IdempotencyKey{}, models.ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("get idempotency key: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(task), &record.Task); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("decode idempotency key task: %w", err)
+	}
+	if record.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.IdempotencyKey{}, fmt.Errorf("get idempotency key: %w", err)
+	}
+	record.ExpiresAt = time.Unix(0, expiresAt)
+	return record, nil
+}
+
+// PutIdempotencyKey stores an idempotency key, replacing an existing one
+func (s *SQLiteStore) PutIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error {
+	task, err := json.Marshal(key.Task)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO idempotency_keys (key, request_hash, task, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		key.Key, key.RequestHash, string(task), formatTime(key.CreatedAt), key.ExpiresAt.UnixNano(),
+	); err != nil {
+		return fmt.Errorf("put idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys that expired before now
+func (s *SQLiteStore) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now.UnixNano()); err != nil {
+		return fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	return nil
+}
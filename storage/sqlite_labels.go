@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"strings"
+)
+
+// allLabels selects the defined labels together with the labels carried by tasks without a definition,
+// and the number of tasks outside of the trash carrying each of them
+const allLabels = `
+	WITH all_labels AS (
+		SELECT name, color, description FROM labels
+		UNION ALL
+		SELECT DISTINCT label, '', '' FROM task_labels WHERE label NOT IN (SELECT name FROM labels)
+	)
+	SELECT name, color, description, (
+		SELECT COUNT(*) FROM task_labels JOIN tasks ON tasks.id = task_labels.task_id
+		WHERE task_labels.label = all_labels.name AND tasks.deleted_at IS NULL
+	) FROM all_labels`
+
+// ListLabels returns every label ordered by name
+func (s *SQLiteStore) ListLabels(ctx context.Context) ([]models.Label, error) {
+	rows, err := s.db.QueryContext(ctx, allLabels+` ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list labels: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	labels := make([]models.Label, 0)
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list labels: %w", err)
+	}
+	return labels, nil
+}
+
+// GetLabel returns a label by its name
+func (s *SQLiteStore) GetLabel(ctx context.Context, name string) (models.Label, error) {
+	return getLabel(ctx, s.db, name)
+}
+
+// PutLabel creates or replaces a label
+func (s *SQLiteStore) PutLabel(ctx context.Context, label models.Label) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO labels (name, color, description) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET color = excluded.color, description = excluded.description`,
+		label.Name, label.Color, label.Description,
+	); err != nil {
+		return fmt.Errorf("put label: %w", err)
+	}
+	return nil
+}
+
+// ReplaceLabel replaces a label on every task carrying it and renames, merges or deletes its definition
+// in a single transaction
+func (s *SQLiteStore) ReplaceLabel(ctx context.Context, from, to string, touch func(task *models.Task)) ([]models.Task, []models.Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replace label: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := getLabel(ctx, tx, from); err != nil {
+		return nil, nil, err
+	}
+	ids, err := queryIDs(ctx, tx, `SELECT task_id FROM task_labels WHERE label = ? ORDER BY task_id`, from)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replace label: %w", err)
+	}
+
+	var before, after []models.Task
+	for _, id := range ids {
+		task, err := getTask(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		updated := task
+		updated.Labels = models.ReplaceLabel(task.Labels, from, to)
+		touch(&updated)
+		if err := writeTask(ctx, tx, updated); err != nil {
+			return nil, nil, err
+		}
+		before = append(before, task)
+		after = append(after, updated)
+	}
+
+	// The definition is renamed unless the target is already defined, in which case it is merged into it
+	if to != "" {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE labels SET name = ? WHERE name = ? AND NOT EXISTS (SELECT 1 FROM labels WHERE name = ?)`, to, from, to,
+		); err != nil {
+			return nil, nil, fmt.Errorf("replace label: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM labels WHERE name = ?`, from); err != nil {
+		return nil, nil, fmt.Errorf("replace label: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("replace label: %w", err)
+	}
+	return before, after, nil
+}
+
+// TaskIDsWithLabels returns the IDs of the tasks carrying all of the labels, using the task_labels index
+func (s *SQLiteStore) TaskIDsWithLabels(ctx context.Context, labels []string) ([]int, error) {
+	labels = models.NormalizeLabels(labels)
+	if len(labels) == 0 {
+		return []int{}, nil
+	}
+	args := make([]any, 0, len(labels)+1)
+	for _, label := range labels {
+		args = append(args, label)
+	}
+	args = append(args, len(labels))
+	ids, err := queryIDs(ctx, s.db,
+		`SELECT task_id FROM task_labels WHERE label IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(labels)), ", ")+`)
+		GROUP BY task_id HAVING COUNT(*) = ? ORDER BY task_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query label index: %w", err)
+	}
+	return ids, nil
+}
+
+func getLabel(ctx context.Context, q querier, name string) (models.Label, error) {
+	label, err := scanLabel(q.QueryRowContext(ctx, allLabels+` WHERE name = ?`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Label{}, models.ErrLabelNotFound
+	}
+	return label, err
+}
+
+func scanLabel(row scanner) (models.Label, error) {
+	var label models.Label
+	if err := row.Scan(&label.Name, &label.Color, &label.Description, &label.TaskCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Label{}, err
+		}
+		return models.Label{}, fmt.Errorf("scan label: %w", err)
+	}
+	return label, nil
+}
+
+// taskLabels returns the labels of a task in order
+func taskLabels(ctx context.Context, q querier, id int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT label FROM task_labels WHERE task_id = ? ORDER BY label`, id)
+	if err != nil {
+		return nil, fmt.Errorf("read task labels: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var labels []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, fmt.Errorf("read task labels: %w", err)
+		}
+		labels = append(labels, label)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read task labels: %w", err)
+	}
+	return labels, nil
+}
+
+// allTaskLabels returns the labels of every task by task ID
+func (s *SQLiteStore) allTaskLabels(ctx context.Context) (map[int][]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT task_id, label FROM task_labels ORDER BY task_id, label`)
+	if err != nil {
+		return nil, fmt.Errorf("read task labels: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	labels := make(map[int][]string)
+	for rows.Next() {
+		var (
+			id    int
+			label string
+		)
+		if err := rows.Scan(&id, &label); err != nil {
+			return nil, fmt.Errorf("read task labels: %w", err)
+		}
+		labels[id] = append(labels[id], label)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read task labels: %w", err)
+	}
+	return labels, nil
+}
+
+// saveTaskLabels replaces the labels of a task
+func saveTaskLabels(ctx context.Context, tx *sql.Tx, task models.Task) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_labels WHERE task_id = ?`, task.ID); err != nil {
+		return fmt.Errorf("save task labels: %w", err)
+	}
+	for _, label := range models.NormalizeLabels(task.Labels) {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO task_labels (task_id, label) VALUES (?, ?)`, task.ID, label); err != nil {
+			return fmt.Errorf("save task labels: %w", err)
+		}
+	}
+	return nil
+}
+
+func queryIDs(ctx context.Context, q querier, query string, args ...any) ([]int, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
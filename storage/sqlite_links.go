@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+)
+
+const linkColumns = `id, type, source_id, target_id, created_at`
+
+// CreateLink stores a new link
+func (s *SQLiteStore) CreateLink(ctx context.Context, link models.Link) (models.Link, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO task_links (type, source_id, target_id, created_at) VALUES (?, ?, ?, ?)`,
+		link.Type, link.SourceID, link.TargetID, formatTime(link.CreatedAt),
+	)
+	if err != nil {
+		return models.Link{}, fmt.Errorf("create link: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Link{}, fmt.Errorf("create link: %w", err)
+	}
+	link.ID = int(id)
+	return link, nil
+}
+
+// GetLink returns a link by its ID
+func (s *SQLiteStore) GetLink(ctx context.Context, id int) (models.Link, error) {
+	link, err := scanLink(s.db.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM task_links WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Link{}, models.ErrLinkNotFound
+	}
+	return link, err
+}
+
+// DeleteLink removes a link by its ID
+func (s *SQLiteStore) DeleteLink(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM task_links WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete link: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("delete link: %w", err)
+	} else if n == 0 {
+		return models.ErrLinkNotFound
+	}
+	return nil
+}
+
+// TaskLinks returns the links from or to a task, ordered by ID
+func (s *SQLiteStore) TaskLinks(ctx context.Context, taskID int) ([]models.Link, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM task_links WHERE source_id = ? OR target_id = ? ORDER BY id`, taskID, taskID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list links: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	links := make([]models.Link, 0)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list links: %w", err)
+	}
+	return links, nil
+}
+
+func scanLink(row scanner) (models.Link, error) {
+	var (
+		link      models.Link
+		createdAt string
+	)
+	if err := row.Scan(&link.ID, &link.Type, &link.SourceID, &link.TargetID, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Link{}, err
+		}
+		return models.Link{}, fmt.Errorf("scan link: %w", err)
+	}
+	var err error
+	if link.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Link{}, fmt.Errorf("scan link: %w", err)
+	}
+	return link, nil
+}
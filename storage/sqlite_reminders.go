@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"time"
+)
+
+// reminderColumns lists the columns of a reminder, in the order of reminderValues and scanReminder
+const reminderColumns = `task_id, before_ns, channel, recipient, snoozed_until, fired_for, fired_at, failures, last_error,
+	retry_at, created_at`
+
+// CreateReminder stores a new reminder
+func (s *SQLiteStore) CreateReminder(ctx context.Context, reminder models.Reminder) (models.Reminder, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO reminders (`+reminderColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, reminderValues(reminder)...,
+	)
+	if err != nil {
+		return models.Reminder{}, fmt.Errorf("create reminder: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Reminder{}, fmt.Errorf("create reminder: %w", err)
+	}
+	reminder.ID = int(id)
+	reminder.FireAt = nil
+	return reminder, nil
+}
+
+// GetReminder returns a reminder by its ID
+func (s *SQLiteStore) GetReminder(ctx context.Context, id int) (models.Reminder, error) {
+	return getReminder(ctx, s.db, id)
+}
+
+// UpdateReminder reads the reminder, applies update and writes it back in a single transaction
+func (s *SQLiteStore) UpdateReminder(ctx context.Context, id int, update func(reminder *models.Reminder) error) (models.Reminder, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Reminder{}, fmt.Errorf("update reminder: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	reminder, err := getReminder(ctx, tx, id)
+	if err != nil {
+		return models.Reminder{}, err
+	}
+	if err := update(&reminder); err != nil {
+		return models.Reminder{}, err
+	}
+	reminder.ID = id
+	reminder.FireAt = nil
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE reminders SET (`+reminderColumns+`) = (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) WHERE id = ?`,
+		append(reminderValues(reminder), id)...,
+	); err != nil {
+		return models.Reminder{}, fmt.Errorf("update reminder: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Reminder{}, fmt.Errorf("update reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// DeleteReminder removes a reminder by its ID
+func (s *SQLiteStore) DeleteReminder(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM reminders WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete reminder: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("delete reminder: %w", err)
+	} else if n == 0 {
+		return models.ErrReminderNotFound
+	}
+	return nil
+}
+
+// ListReminders returns the reminders of a task ordered by ID
+func (s *SQLiteStore) ListReminders(ctx context.Context, taskID int) ([]models.Reminder, error) {
+	return s.listReminders(ctx, `SELECT id, `+reminderColumns+` FROM reminders WHERE task_id = ? ORDER BY id`, taskID)
+}
+
+// AllReminders returns the reminders of every task ordered by ID
+func (s *SQLiteStore) AllReminders(ctx context.Context) ([]models.Reminder, error) {
+	return s.listReminders(ctx, `SELECT id, `+reminderColumns+` FROM reminders ORDER BY id`)
+}
+
+func (s *SQLiteStore) listReminders(ctx context.Context, query string, args ...any) ([]models.Reminder, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list reminders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	reminders := make([]models.Reminder, 0)
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+func getReminder(ctx context.Context, q querier, id int) (models.Reminder, error) {
+	reminder, err := scanReminder(q.QueryRowContext(ctx, `SELECT id, `+reminderColumns+` FROM reminders WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Reminder{}, models.ErrReminderNotFound
+	}
+	return reminder, err
+}
+
+// reminderValues returns the column values of a reminder
+func reminderValues(reminder models.Reminder) []any {
+	return []any{
+		reminder.TaskID, int64(reminder.Before), reminder.Channel, reminder.Recipient,
+		formatNullTime(reminder.SnoozedUntil), formatNullTime(reminder.FiredFor), formatNullTime(reminder.FiredAt),
+		reminder.Failures, reminder.LastError, formatNullTime(reminder.RetryAt), formatTime(reminder.CreatedAt),
+	}
+}
+
+func scanReminder(row scanner) (models.Reminder, error) {
+	var (
+		reminder                                 models.Reminder
+		before                                   int64
+		snoozedUntil, firedFor, firedAt, retryAt sql.NullString
+		createdAt                                string
+	)
+	if err := row.Scan(&reminder.ID, &reminder.TaskID, &before, &reminder.Channel, &reminder.Recipient,
+		&snoozedUntil, &firedFor, &firedAt, &reminder.Failures, &reminder.LastError, &retryAt, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Reminder{}, err
+		}
+		return models.Reminder{}, fmt.Errorf("scan reminder: %w", err)
+	}
+
+	reminder.Before = models.Duration(time.Duration(before))
+	var err error
+	if reminder.SnoozedUntil, err = parseNullTime(snoozedUntil); err != nil {
+		return models.Reminder{}, fmt.Errorf("scan reminder %d: %w", reminder.ID, err)
+	}
+	if reminder.FiredFor, err = parseNullTime(firedFor); err != nil {
+		return models.Reminder{}, fmt.Errorf("scan reminder %d: %w", reminder.ID, err)
+	}
+	if reminder.FiredAt, err = parseNullTime(firedAt); err != nil {
+		return models.Reminder{}, fmt.Errorf("scan reminder %d: %w", reminder.ID, err)
+	}
+	if reminder.RetryAt, err = parseNullTime(retryAt); err != nil {
+		return models.Reminder{}, fmt.Errorf("scan reminder %d: %w", reminder.ID, err)
+	}
+	if reminder.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Reminder{}, fmt.Errorf("scan reminder %d: %w", reminder.ID, err)
+	}
+	return reminder, nil
+}
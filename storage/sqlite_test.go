@@ -0,0 +1,557 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("SQLite Store Tests", func() {
+	var (
+		ctx   context.Context
+		path  string
+		store *SQLiteStore
+		task  models.Task
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		path = filepath.Join(GinkgoT().TempDir(), "tasks.db")
+
+		var err error
+		store, err = OpenSQLite(ctx, path)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { _ = store.Close() })
+
+		task = models.Task{
+			Title:       "New Task",
+			Description: "Task Description",
+			Status:      "Pending",
+			CreatedAt:   time.Now(),
+		}
+	})
+
+	It("should apply every migration and record the schema version", func() {
+		version, err := schemaVersion(ctx, store.db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).To(Equal(migrations[len(migrations)-1].version))
+	})
+
+	It("should not re-apply migrations when reopening the database", func() {
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = OpenSQLite(ctx, path)
+		Expect(err).ToNot(HaveOccurred())
+
+		var applied int
+		Expect(store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&applied)).To(Succeed())
+		Expect(applied).To(Equal(len(migrations)))
+	})
+
+	It("should create, get, update and delete tasks", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.ID).To(Equal(1))
+
+		fetched, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fetched.Title).To(Equal(task.Title))
+		Expect(fetched.CreatedAt.Equal(task.CreatedAt)).To(BeTrue())
+
+		updated, err := store.Update(ctx, created.ID, func(t *models.Task) error {
+			t.Status = "Completed"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Status).To(Equal("Completed"))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+		_, err = store.Get(ctx, created.ID)
+		Expect(err).To(MatchError(models.ErrTaskNotFound))
+	})
+
+	It("should return ErrTaskNotFound for missing tasks", func() {
+		_, err := store.Get(ctx, 1)
+		Expect(err).To(MatchError(models.ErrTaskNotFound))
+
+		_, err = store.Update(ctx, 1, func(*models.Task) error { return nil })
+		Expect(err).To(MatchError(models.ErrTaskNotFound))
+
+		Expect(store.Delete(ctx, 1, nil)).To(MatchError(models.ErrTaskNotFound))
+	})
+
+	It("should leave the task untouched when the update function fails", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		updateErr := errors.New("rejected")
+		_, err = store.Update(ctx, created.ID, func(t *models.Task) error {
+			t.Title = "Changed"
+			return updateErr
+		})
+		Expect(err).To(MatchError(updateErr))
+
+		fetched, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fetched.Title).To(Equal(task.Title))
+	})
+
+	It("should update several tasks in one transaction", func() {
+		first, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		updateErr := errors.New("rejected")
+		_, _, err = store.UpdateTasks(ctx, []int{first.ID, second.ID}, func(t *models.Task) error {
+			if t.ID == second.ID {
+				return updateErr
+			}
+			t.Title = "Changed"
+			return nil
+		})
+		Expect(err).To(MatchError(updateErr))
+		fetched, err := store.Get(ctx, first.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fetched.Title).To(Equal(task.Title))
+
+		_, _, err = store.UpdateTasks(ctx, []int{first.ID, 42}, func(t *models.Task) error { return nil })
+		Expect(err).To(MatchError(models.ErrTaskNotFound))
+
+		before, after, err := store.UpdateTasks(ctx, []int{second.ID, first.ID}, func(t *models.Task) error {
+			t.Title = "Changed"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(before[0].ID).To(Equal(second.ID))
+		Expect(before[0].Title).To(Equal(task.Title))
+		Expect(after[1].Title).To(Equal("Changed"))
+		fetched, err = store.Get(ctx, second.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fetched.Title).To(Equal("Changed"))
+	})
+
+	It("should keep tasks and never reuse IDs across restarts", func() {
+		first, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Delete(ctx, second.ID, nil)).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		store, err = OpenSQLite(ctx, path)
+		Expect(err).ToNot(HaveOccurred())
+
+		tasks, err := store.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(1))
+		Expect(tasks[0].ID).To(Equal(first.ID))
+
+		third, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(third.ID).To(Equal(second.ID + 1))
+	})
+
+	It("should keep idempotency keys across restarts until they expire", func() {
+		now := time.Now()
+		key := models.IdempotencyKey{Key: "key", RequestHash: "hash", Task: task, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		Expect(store.PutIdempotencyKey(ctx, key)).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = OpenSQLite(ctx, path)
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.GetIdempotencyKey(ctx, "key", now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.RequestHash).To(Equal("hash"))
+		Expect(stored.Task.Title).To(Equal(task.Title))
+
+		_, err = store.GetIdempotencyKey(ctx, "key", now.Add(2*time.Hour))
+		Expect(err).To(MatchError(models.ErrIdempotencyKeyNotFound))
+
+		Expect(store.DeleteExpiredIdempotencyKeys(ctx, now.Add(2*time.Hour))).To(Succeed())
+		_, err = store.GetIdempotencyKey(ctx, "key", now)
+		Expect(err).To(MatchError(models.ErrIdempotencyKeyNotFound))
+	})
+
+	It("should number revisions per task and keep them after the task is deleted", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		changes := []models.FieldChange{{Field: models.JsonStatus, From: []byte(`"Pending"`), To: []byte(`"Completed"`)}}
+		for _, action := range []string{models.RevisionCreated, models.RevisionUpdated} {
+			_, err := store.AppendRevision(ctx, models.Revision{TaskID: created.ID, Action: action, Actor: "alice", CreatedAt: time.Now(), Changes: changes, Task: created})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		other, err := store.AppendRevision(ctx, models.Revision{TaskID: created.ID + 1, Action: models.RevisionCreated, CreatedAt: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Number).To(Equal(1))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+
+		revisions, err := store.ListRevisions(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[1].Number).To(Equal(2))
+		Expect(revisions[1].Action).To(Equal(models.RevisionUpdated))
+		Expect(revisions[1].Actor).To(Equal("alice"))
+		Expect(string(revisions[1].Changes[0].To)).To(Equal(`"Completed"`))
+		Expect(revisions[1].Task.Title).To(Equal(task.Title))
+	})
+
+	It("should recreate a deleted task with its original ID", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = store.Recreate(ctx, created)
+		Expect(err).To(MatchError(models.ErrTaskExists))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+		recreated, err := store.Recreate(ctx, created)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recreated.ID).To(Equal(created.ID))
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Title).To(Equal(task.Title))
+	})
+
+	It("should store the deletion time of a task in the trash", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.DeletedAt).To(BeNil())
+
+		deletedAt := time.Now()
+		_, err = store.Update(ctx, created.ID, func(task *models.Task) error {
+			task.DeletedAt = &deletedAt
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.DeletedAt).ToNot(BeNil())
+		Expect(stored.DeletedAt.Equal(deletedAt)).To(BeTrue())
+	})
+
+	It("should keep the time zone of the due date along with the priority and effort", func() {
+		dueAt := time.Date(2030, 1, 2, 9, 0, 0, 0, time.FixedZone("", 2*60*60))
+		task.DueAt = &dueAt
+		task.Priority = "P1"
+		task.EffortHours = 2.5
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.DueAt.Format(time.RFC3339)).To(Equal("2030-01-02T09:00:00+02:00"))
+		Expect(stored.Priority).To(Equal("P1"))
+		Expect(stored.EffortHours).To(Equal(2.5))
+	})
+
+	It("should store the parent of a subtask", func() {
+		parent, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		task.ParentID = &parent.ID
+		child, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, child.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ParentID).To(Equal(&parent.ID))
+
+		stored, err = store.Get(ctx, parent.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ParentID).To(BeNil())
+	})
+
+	It("should store the blockers of a task", func() {
+		blocker, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		task.BlockedBy = []int{blocker.ID}
+		blocked, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, blocked.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.BlockedBy).To(Equal([]int{blocker.ID}))
+
+		_, err = store.Update(ctx, blocked.ID, func(task *models.Task) error {
+			task.BlockedBy = nil
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		stored, err = store.Get(ctx, blocked.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.BlockedBy).To(BeEmpty())
+	})
+
+	It("should store the checklist of a task in its order", func() {
+		task.Checklist = []models.ChecklistItem{{ID: 2, Text: "review", Assignee: "bob"}, {ID: 1, Text: "write", Done: true}}
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Checklist).To(Equal(task.Checklist))
+
+		_, err = store.Update(ctx, created.ID, func(task *models.Task) error {
+			task.Checklist = nil
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		stored, err = store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Checklist).To(BeEmpty())
+	})
+
+	It("should store the recurrence of a task and its next occurrence", func() {
+		start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("", 3600))
+		task.Recurrence = &models.Recurrence{RRule: "FREQ=DAILY", Timezone: "Europe/Paris", Start: start, ExDates: []time.Time{start.AddDate(0, 0, 1)}}
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		next, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = store.Update(ctx, created.ID, func(task *models.Task) error {
+			task.NextOccurrenceID = &next.ID
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Recurrence.RRule).To(Equal("FREQ=DAILY"))
+		Expect(stored.Recurrence.Timezone).To(Equal("Europe/Paris"))
+		Expect(stored.Recurrence.Start.Equal(start)).To(BeTrue())
+		Expect(stored.Recurrence.ExDates).To(HaveLen(1))
+		Expect(*stored.NextOccurrenceID).To(Equal(next.ID))
+
+		stored, err = store.Get(ctx, next.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.NextOccurrenceID).To(BeNil())
+	})
+
+	It("should store links and delete them along with their tasks", func() {
+		first, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		link, err := store.CreateLink(ctx, models.Link{Type: "duplicates", SourceID: first.ID, TargetID: second.ID, CreatedAt: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.ID).To(Equal(1))
+		stored, err := store.GetLink(ctx, link.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Type).To(Equal("duplicates"))
+		Expect(store.TaskLinks(ctx, second.ID)).To(HaveLen(1))
+
+		Expect(store.Delete(ctx, first.ID, nil)).To(Succeed())
+		Expect(store.TaskLinks(ctx, second.ID)).To(BeEmpty())
+		Expect(store.DeleteLink(ctx, link.ID)).To(MatchError(models.ErrLinkNotFound))
+	})
+
+	It("should store comments and count them on their task", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.CommentCount).To(BeZero())
+
+		comment, err := store.CreateComment(ctx, models.Comment{TaskID: created.ID, Author: "alice", Body: "Hello", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+		reply, err := store.CreateComment(ctx, models.Comment{TaskID: created.ID, ParentID: &comment.ID, Body: "Hi", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+
+		edited, err := store.UpdateComment(ctx, comment.ID, func(comment *models.Comment) error {
+			comment.Edits = append(comment.Edits, models.CommentEdit{Body: comment.Body, Editor: "alice", EditedAt: time.Now()})
+			comment.Body = "Hello!"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(edited.Body).To(Equal("Hello!"))
+
+		comments, err := store.ListComments(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(comments).To(HaveLen(2))
+		Expect(comments[0].Edits).To(HaveLen(1))
+		Expect(comments[0].Edits[0].Body).To(Equal("Hello"))
+		Expect(comments[1].ParentID).To(Equal(&comment.ID))
+
+		stored, err := store.Get(ctx, created.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.CommentCount).To(Equal(2))
+		Expect(store.DeleteComment(ctx, reply.ID)).To(Succeed())
+		tasks, err := store.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks[0].CommentCount).To(Equal(1))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+		_, err = store.GetComment(ctx, comment.ID)
+		Expect(err).To(MatchError(models.ErrCommentNotFound))
+	})
+
+	It("should store attachments and delete them along with their tasks", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		attachment, err := store.CreateAttachment(ctx, models.Attachment{
+			TaskID: created.ID, Filename: "shot.png", ContentType: "image/png", Size: 3, SHA256: "abc", CreatedAt: time.Now(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = store.CreateAttachment(ctx, models.Attachment{TaskID: created.ID, Filename: "copy.png", SHA256: "abc", CreatedAt: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.GetAttachment(ctx, attachment.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Filename).To(Equal("shot.png"))
+		Expect(stored.ContentType).To(Equal("image/png"))
+		Expect(stored.Size).To(Equal(int64(3)))
+		Expect(store.ReferencedBlobs(ctx)).To(Equal(map[string]bool{"abc": true}))
+
+		Expect(store.DeleteAttachment(ctx, attachment.ID)).To(Succeed())
+		Expect(store.DeleteAttachment(ctx, attachment.ID)).To(MatchError(models.ErrAttachmentNotFound))
+		Expect(store.ListAttachments(ctx, created.ID)).To(HaveLen(1))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+		Expect(store.ListAttachments(ctx, created.ID)).To(BeEmpty())
+		Expect(store.ReferencedBlobs(ctx)).To(BeEmpty())
+	})
+
+	It("should store reminders and delete them along with their tasks", func() {
+		created, err := store.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+
+		reminder, err := store.CreateReminder(ctx, models.Reminder{
+			TaskID: created.ID, Before: models.Duration(time.Hour), Channel: "email", Recipient: "alice@example.com",
+			CreatedAt: time.Now(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		firedFor := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+		_, err = store.UpdateReminder(ctx, reminder.ID, func(reminder *models.Reminder) error {
+			reminder.FiredFor = &firedFor
+			reminder.FiredAt = &firedFor
+			reminder.Failures = 1
+			reminder.LastError = "connection refused"
+			reminder.RetryAt = &firedFor
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		stored, err := store.GetReminder(ctx, reminder.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Before).To(Equal(models.Duration(time.Hour)))
+		Expect(stored.Channel).To(Equal("email"))
+		Expect(stored.Recipient).To(Equal("alice@example.com"))
+		Expect(stored.SnoozedUntil).To(BeNil())
+		Expect(stored.FiredFor.Equal(firedFor)).To(BeTrue())
+		Expect(stored.Failures).To(Equal(1))
+		Expect(stored.LastError).To(Equal("connection refused"))
+		Expect(stored.RetryAt.Equal(firedFor)).To(BeTrue())
+		Expect(store.AllReminders(ctx)).To(HaveLen(1))
+
+		Expect(store.Delete(ctx, created.ID, nil)).To(Succeed())
+		Expect(store.ListReminders(ctx, created.ID)).To(BeEmpty())
+		Expect(store.DeleteReminder(ctx, reminder.ID)).To(MatchError(models.ErrReminderNotFound))
+	})
+
+	It("should store webhooks and their deliveries and delete them together", func() {
+		webhook, err := store.CreateWebhook(ctx, models.Webhook{
+			URL: "https://example.com/hook", Events: []string{"task.created", "task.deleted"}, Secret: "s3cret",
+			Active: true, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = store.UpdateWebhook(ctx, webhook.ID, func(webhook *models.Webhook) error {
+			webhook.Active = false
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		stored, err := store.GetWebhook(ctx, webhook.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Events).To(Equal([]string{"task.created", "task.deleted"}))
+		Expect(stored.Secret).To(Equal("s3cret"))
+		Expect(stored.Active).To(BeFalse())
+
+		now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+		later, sooner := now.Add(-time.Second), now.Add(-time.Minute)
+		first, err := store.CreateDelivery(ctx, models.Delivery{
+			WebhookID: webhook.ID, Event: "task.created", TaskID: 1, Payload: []byte(`{"event":"task.created"}`),
+			Status: models.DeliveryPending, NextAttemptAt: &later, CreatedAt: now.Add(-48 * time.Hour),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		second, err := store.CreateDelivery(ctx, models.Delivery{
+			WebhookID: webhook.ID, Event: "task.deleted", TaskID: 1, Payload: []byte(`{"event":"task.deleted"}`),
+			Status: models.DeliveryPending, NextAttemptAt: &sooner, CreatedAt: now,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		due, err := store.DueDeliveries(ctx, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(due).To(HaveLen(2))
+		Expect(due[0].ID).To(Equal(second.ID))
+		Expect(store.DueDeliveries(ctx, sooner.Add(-time.Second))).To(BeEmpty())
+
+		_, err = store.UpdateDelivery(ctx, first.ID, func(delivery *models.Delivery) error {
+			delivery.Status = models.DeliverySucceeded
+			delivery.Attempts = append(delivery.Attempts, models.DeliveryAttempt{At: now, StatusCode: 204, DurationMS: 12})
+			delivery.NextAttemptAt = nil
+			delivery.DeliveredAt = &now
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		delivered, err := store.GetDelivery(ctx, first.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(delivered.Payload)).To(Equal(`{"event":"task.created"}`))
+		Expect(delivered.Attempts).To(HaveLen(1))
+		Expect(delivered.Attempts[0].StatusCode).To(Equal(204))
+		Expect(delivered.DeliveredAt.Equal(now)).To(BeTrue())
+
+		pending, err := store.ListDeliveries(ctx, models.DeliveryFilter{Status: models.DeliveryPending})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pending).To(HaveLen(1))
+		Expect(store.ListDeliveries(ctx, models.DeliveryFilter{WebhookID: webhook.ID, Limit: 1})).To(HaveLen(1))
+		Expect(store.PruneDeliveries(ctx, now.Add(-24*time.Hour))).To(Equal(1))
+		Expect(store.GetDelivery(ctx, first.ID)).Error().To(MatchError(models.ErrDeliveryNotFound))
+
+		Expect(store.DeleteWebhook(ctx, webhook.ID)).To(Succeed())
+		Expect(store.ListWebhooks(ctx)).To(BeEmpty())
+		Expect(store.ListDeliveries(ctx, models.DeliveryFilter{})).To(BeEmpty())
+		Expect(store.DeleteWebhook(ctx, webhook.ID)).To(MatchError(models.ErrWebhookNotFound))
+	})
+
+	It("should store labels and rename them on every task in one transaction", func() {
+		for _, labels := range [][]string{{"backend", "urgent"}, {"backend"}, {"urgent"}} {
+			task.Labels = labels
+			_, err := store.Create(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(store.PutLabel(ctx, models.Label{Name: "backend", Color: "#1d76db"})).To(Succeed())
+
+		ids, err := store.TaskIDsWithLabels(ctx, []string{"backend", "urgent"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids).To(Equal([]int{1}))
+
+		before, after, err := store.ReplaceLabel(ctx, "backend", "server", func(task *models.Task) { task.Version++ })
+		Expect(err).ToNot(HaveOccurred())
+		Expect(before).To(HaveLen(2))
+		Expect(after[0].Labels).To(Equal([]string{"server", "urgent"}))
+
+		stored, err := store.Get(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Labels).To(Equal([]string{"server", "urgent"}))
+		Expect(stored.Version).To(Equal(1))
+
+		labels, err := store.ListLabels(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(labels).To(Equal([]models.Label{
+			{Name: "server", Color: "#1d76db", TaskCount: 2},
+			{Name: "urgent", TaskCount: 2},
+		}))
+
+		_, err = store.GetLabel(ctx, "backend")
+		Expect(err).To(MatchError(models.ErrLabelNotFound))
+	})
+})
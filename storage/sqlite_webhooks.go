@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookColumns lists the columns of a webhook, in the order of webhookValues and scanWebhook
+	webhookColumns = `url, events, secret, active, created_at, updated_at`
+	// deliveryColumns lists the columns of a delivery, in the order of deliveryValues and scanDelivery
+	deliveryColumns = `webhook_id, event, task_id, payload, status, failures, attempts, next_attempt_at, created_at, delivered_at`
+)
+
+// CreateWebhook stores a new webhook
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	values, err := webhookValues(webhook)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, `INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?, ?)`, values...)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+	webhook.ID = int(id)
+	return webhook, nil
+}
+
+// GetWebhook returns a webhook by its ID
+func (s *SQLiteStore) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	return getWebhook(ctx, s.db, id)
+}
+
+// UpdateWebhook reads the webhook, applies update and writes it back in a single transaction
+func (s *SQLiteStore) UpdateWebhook(ctx context.Context, id int, update func(webhook *models.Webhook) error) (models.Webhook, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("update webhook: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	webhook, err := getWebhook(ctx, tx, id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if err := update(&webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.ID = id
+
+	values, err := webhookValues(webhook)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("update webhook: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhooks SET (`+webhookColumns+`) = (?, ?, ?, ?, ?, ?) WHERE id = ?`, append(values, id)...,
+	); err != nil {
+		return models.Webhook{}, fmt.Errorf("update webhook: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Webhook{}, fmt.Errorf("update webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries in a single transaction
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	} else if n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListWebhooks returns every webhook ordered by ID
+func (s *SQLiteStore) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	webhooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// CreateDelivery stores a new delivery
+func (s *SQLiteStore) CreateDelivery(ctx context.Context, delivery models.Delivery) (models.Delivery, error) {
+	values, err := deliveryValues(delivery)
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("create delivery: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (`+deliveryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, values...,
+	)
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("create delivery: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("create delivery: %w", err)
+	}
+	delivery.ID = int(id)
+	return delivery, nil
+}
+
+// GetDelivery returns a delivery by its ID
+func (s *SQLiteStore) GetDelivery(ctx context.Context, id int) (models.Delivery, error) {
+	return getDelivery(ctx, s.db, id)
+}
+
+// UpdateDelivery reads the delivery, applies update and writes it back in a single transaction
+func (s *SQLiteStore) UpdateDelivery(ctx context.Context, id int, update func(delivery *models.Delivery) error) (models.Delivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("update delivery: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	delivery, err := getDelivery(ctx, tx, id)
+	if err != nil {
+		return models.Delivery{}, err
+	}
+	if err := update(&delivery); err != nil {
+		return models.Delivery{}, err
+	}
+	delivery.ID = id
+
+	values, err := deliveryValues(delivery)
+	if err != nil {
+		return models.Delivery{}, fmt.Errorf("update delivery: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET (`+deliveryColumns+`) = (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) WHERE id = ?`,
+		append(values, id)...,
+	); err != nil {
+		return models.Delivery{}, fmt.Errorf("update delivery: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Delivery{}, fmt.Errorf("update delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// ListDeliveries returns the deliveries selected by the filter, most recent first
+func (s *SQLiteStore) ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.Delivery, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.WebhookID != 0 {
+		conditions = append(conditions, `webhook_id = ?`)
+		args = append(args, filter.WebhookID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, `status = ?`)
+		args = append(args, filter.Status)
+	}
+	query := `SELECT id, ` + deliveryColumns + ` FROM webhook_deliveries`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	return s.listDeliveries(ctx, query, args...)
+}
+
+// DueDeliveries returns the pending deliveries whose next attempt is not after now, the most overdue first.
+// The attempt times are compared once parsed, as their stored text does not sort chronologically.
+func (s *SQLiteStore) DueDeliveries(ctx context.Context, now time.Time) ([]models.Delivery, error) {
+	deliveries, err := s.listDeliveries(ctx,
+		`SELECT id, `+deliveryColumns+` FROM webhook_deliveries WHERE status = ? ORDER BY id`, models.DeliveryPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]models.Delivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt) })
+	return due, nil
+}
+
+// PruneDeliveries removes the succeeded deliveries created before the cutoff
+func (s *SQLiteStore) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	deliveries, err := s.listDeliveries(ctx,
+		`SELECT id, `+deliveryColumns+` FROM webhook_deliveries WHERE status = ?`, models.DeliverySucceeded,
+	)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, delivery := range deliveries {
+		if !delivery.CreatedAt.Before(before) {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = ?`, delivery.ID); err != nil {
+			return pruned, fmt.Errorf("prune deliveries: %w", err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+func (s *SQLiteStore) listDeliveries(ctx context.Context, query string, args ...any) ([]models.Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list deliveries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	deliveries := make([]models.Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func getWebhook(ctx context.Context, q querier, id int) (models.Webhook, error) {
+	webhook, err := scanWebhook(q.QueryRowContext(ctx, `SELECT id, `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, models.ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+func getDelivery(ctx context.Context, q querier, id int) (models.Delivery, error) {
+	delivery, err := scanDelivery(q.QueryRowContext(ctx, `SELECT id, `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Delivery{}, models.ErrDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// webhookValues returns the column values of a webhook
+func webhookValues(webhook models.Webhook) ([]any, error) {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		webhook.URL, string(events), webhook.Secret, webhook.Active, formatTime(webhook.CreatedAt), formatTime(webhook.UpdatedAt),
+	}, nil
+}
+
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var (
+		webhook              models.Webhook
+		events               string
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Active, &createdAt, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Webhook{}, err
+		}
+		return models.Webhook{}, fmt.Errorf("scan webhook: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(events), &webhook.Events); err != nil {
+		return models.Webhook{}, fmt.Errorf("scan webhook %d: %w", webhook.ID, err)
+	}
+	var err error
+	if webhook.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Webhook{}, fmt.Errorf("scan webhook %d: %w", webhook.ID, err)
+	}
+	if webhook.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return models.Webhook{}, fmt.Errorf("scan webhook %d: %w", webhook.ID, err)
+	}
+	return webhook, nil
+}
+
+// deliveryValues returns the column values of a delivery
+func deliveryValues(delivery models.Delivery) ([]any, error) {
+	attempts := delivery.Attempts
+	if attempts == nil {
+		attempts = []models.DeliveryAttempt{}
+	}
+	encoded, err := json.Marshal(attempts)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		delivery.WebhookID, delivery.Event, delivery.TaskID, string(delivery.Payload), delivery.Status, delivery.Failures,
+		string(encoded), formatNullTime(delivery.NextAttemptAt), formatTime(delivery.CreatedAt),
+		formatNullTime(delivery.DeliveredAt),
+	}, nil
+}
+
+func scanDelivery(row scanner) (models.Delivery, error) {
+	var (
+		delivery                   models.Delivery
+		payload, attempts          string
+		createdAt                  string
+		nextAttemptAt, deliveredAt sql.NullString
+	)
+	if err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.TaskID, &payload, &delivery.Status,
+		&delivery.Failures, &attempts, &nextAttemptAt, &createdAt, &deliveredAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Delivery{}, err
+		}
+		return models.Delivery{}, fmt.Errorf("scan delivery: %w", err)
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	if err := json.Unmarshal([]byte(attempts), &delivery.Attempts); err != nil {
+		return models.Delivery{}, fmt.Errorf("scan delivery %d: %w", delivery.ID, err)
+	}
+	var err error
+	if delivery.NextAttemptAt, err = parseNullTime(nextAttemptAt); err != nil {
+		return models.Delivery{}, fmt.Errorf("scan delivery %d: %w", delivery.ID, err)
+	}
+	if delivery.CreatedAt, err = parseTime(createdAt); err != nil {
+		return models.Delivery{}, fmt.Errorf("scan delivery %d: %w", delivery.ID, err)
+	}
+	if delivery.DeliveredAt, err = parseNullTime(deliveredAt); err != nil {
+		return models.Delivery{}, fmt.Errorf("scan delivery %d: %w", delivery.ID, err)
+	}
+	return delivery, nil
+}
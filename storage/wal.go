@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ofirmad/task-manager/models"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	// DefaultSnapshotEvery is the number of log records after which the log is compacted into a snapshot
+	DefaultSnapshotEvery = 1000
+
+	// frameHeaderSize is the length (uint32) and CRC-32C (uint32) preceding every record
+	frameHeaderSize = 8
+	// maxRecordSize guards against allocating huge buffers for a corrupt length field
+	maxRecordSize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorruptRecord marks a record that failed validation while replaying the log
+var errCorruptRecord = errors.New("corrupt record")
+
+// WAL is an append-only write-ahead log with periodic snapshots for a models.Database.
+// Every mutation is fsync'd to the log before it is applied in memory. Once the log holds
+// SnapshotEvery records, the whole database is written to a snapshot and the log is truncated.
+type WAL struct {
+	dir           string
+	db            *models.Database
+	snapshotEvery int
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	records    int
+	compacting atomic.Bool
+	wg         sync.WaitGroup
+}
+
+// OpenWAL restores db from the snapshot and log in dir, then journals every further mutation of db.
+// A corrupt or partially written record at the end of the log (e.g. after a crash) is truncated.
+// snapshotEvery <= 0 uses DefaultSnapshotEvery.
+func OpenWAL(dir string, db *models.Database, snapshotEvery int) (*WAL, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create wal directory: %w", err)
+	}
+
+	w := &WAL{dir: dir, db: db, snapshotEvery: snapshotEvery}
+	if err := w.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+	w.file = file
+
+	if err := w.replay(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	db.SetJournal(w)
+	return w, nil
+}
+
+// Append writes the record to the log and fsyncs it
+func (w *WAL) Append(record models.Record) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := w.file.Write(frame); err != nil {
+		w.rollback()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.rollback()
+		return err
+	}
+
+	w.size += int64(len(frame))
+	w.records++
+	if w.records >= w.snapshotEvery && w.compacting.CompareAndSwap(false, true) {
+		// Append runs with the database lock held, so compaction has to wait for it in the background
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			defer w.compacting.Store(false)
+			if err := w.Compact(); err != nil {
+				log.Printf("wal: compaction failed: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Compact writes a snapshot of the database and truncates the log it supersedes
+func (w *WAL) Compact() error {
+	return w.db.Checkpoint(func(snapshot models.Snapshot) error {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if w.file == nil {
+			return os.ErrClosed
+		}
+		if err := w.writeSnapshot(snapshot); err != nil {
+			return err
+		}
+		if err := w.file.Truncate(0); err != nil {
+			return fmt.Errorf("truncate wal: %w", err)
+		}
+		if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("truncate wal: %w", err)
+		}
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("truncate wal: %w", err)
+		}
+		w.size = 0
+		w.records = 0
+		return nil
+	})
+}
+
+// rollback drops a partially written frame so later records are not appended after garbage
+func (w *WAL) rollback() {
+	if err := w.file.Truncate(w.size); err != nil {
+		log.Printf("wal: failed to roll back partial record: %v", err)
+		return
+	}
+	if _, err := w.file.Seek(w.size, io.SeekStart); err != nil {
+		log.Printf("wal: failed to roll back partial record: %v", err)
+	}
+}
+
+// Close waits for a running compaction and closes the log. The database must no longer be mutated.
+func (w *WAL) Close() error {
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *WAL) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(w.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snapshot models.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	w.db.Restore(snapshot)
+	return nil
+}
+
+// writeSnapshot atomically replaces the snapshot file: write to a temporary file, fsync, rename, fsync the directory
+func (w *WAL) writeSnapshot(snapshot models.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(w.dir, snapshotFileName+".tmp")
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(w.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return syncDir(w.dir)
+}
+
+// replay applies every valid record in the log to the database and truncates the log after the last one
+func (w *WAL) replay() error {
+	reader := bufio.NewReader(w.file)
+	var offset int64
+
+	for {
+		record, size, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, errCorruptRecord) || errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("wal: truncating corrupt log tail at offset %d: %v", offset, err)
+			if err := w.file.Truncate(offset); err != nil {
+				return fmt.Errorf("truncate corrupt wal: %w", err)
+			}
+			if err := w.file.Sync(); err != nil {
+				return fmt.Errorf("truncate corrupt wal: %w", err)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read wal: %w", err)
+		}
+
+		if err := w.db.Replay(record); err != nil {
+			return fmt.Errorf("replay wal record at offset %d: %w", offset, err)
+		}
+		offset += size
+		w.records++
+	}
+
+	if _, err := w.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wal: %w", err)
+	}
+	w.size = offset
+	return nil
+}
+
+// readRecord reads one framed record and returns it with its size on disk.
+// It returns io.EOF only when the log ends exactly on a record boundary.
+func readRecord(reader io.Reader) (models.Record, int64, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return models.Record{}, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return models.Record{}, 0, fmt.Errorf("%w: length %d exceeds limit", errCorruptRecord, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return models.Record{}, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return models.Record{}, 0, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+
+	var record models.Record
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return models.Record{}, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	return record, int64(frameHeaderSize + length), nil
+}
+
+// syncDir fsyncs a directory so a rename inside it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
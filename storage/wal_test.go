@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"github.com/ofirmad/task-manager/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("WAL Tests", func() {
+	var (
+		ctx  context.Context
+		dir  string
+		db   *models.Database
+		wal  *WAL
+		task models.Task
+	)
+
+	// reopen simulates a restart: the current log is closed and a fresh database is restored from dir
+	reopen := func(snapshotEvery int) {
+		Expect(wal.Close()).To(Succeed())
+
+		var err error
+		db = models.NewDatabase()
+		wal, err = OpenWAL(dir, db, snapshotEvery)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+		db = models.NewDatabase()
+
+		var err error
+		wal, err = OpenWAL(dir, db, 0)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { _ = wal.Close() })
+
+		task = models.Task{
+			Title:       "New Task",
+			Description: "Task Description",
+			Status:      "Pending",
+			CreatedAt:   time.Now(),
+		}
+	})
+
+	It("should restore tasks and NextID from the log after a restart", func() {
+		first, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = db.Update(ctx, first.ID, func(t *models.Task) error {
+			t.Status = "Completed"
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Delete(ctx, second.ID, nil)).To(Succeed())
+
+		reopen(0)
+
+		tasks, err := db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(1))
+		Expect(tasks[0].ID).To(Equal(first.ID))
+		Expect(tasks[0].Status).To(Equal("Completed"))
+		Expect(db.NextID).To(Equal(3))
+	})
+
+	It("should compact the log into a snapshot and restore from both", func() {
+		for i := 0; i < 5; i++ {
+			_, err := db.Create(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(wal.Compact()).To(Succeed())
+
+		info, err := os.Stat(filepath.Join(dir, walFileName))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeZero())
+
+		Expect(db.Delete(ctx, 5, nil)).To(Succeed())
+
+		reopen(0)
+
+		tasks, err := db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(4))
+		Expect(db.NextID).To(Equal(6))
+	})
+
+	It("should compact automatically after the configured number of records", func() {
+		reopen(3)
+
+		for i := 0; i < 3; i++ {
+			_, err := db.Create(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Eventually(func() error {
+			_, err := os.Stat(filepath.Join(dir, snapshotFileName))
+			return err
+		}).Should(Succeed())
+
+		reopen(3)
+		tasks, err := db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(3))
+	})
+
+	It("should truncate a corrupt trailing record instead of failing to start", func() {
+		_, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Close()).To(Succeed())
+
+		path := filepath.Join(dir, walFileName)
+		valid, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		// A torn write: a header announcing more bytes than were written
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.Write([]byte{0, 0, 0, 42, 1, 2, 3, 4, '{', '"'})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		db = models.NewDatabase()
+		wal, err = OpenWAL(dir, db, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		tasks, err := db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(1))
+
+		truncated, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated.Size()).To(Equal(valid.Size()))
+
+		// New records are appended right after the last valid one
+		_, err = db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		reopen(0)
+		tasks, err = db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(2))
+	})
+
+	It("should detect a record whose checksum does not match", func() {
+		_, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Close()).To(Succeed())
+
+		path := filepath.Join(dir, walFileName)
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		data[len(data)-2] ^= 0xff
+		Expect(os.WriteFile(path, data, 0o644)).To(Succeed())
+
+		db = models.NewDatabase()
+		wal, err = OpenWAL(dir, db, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		tasks, err := db.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tasks).To(HaveLen(1))
+		Expect(db.NextID).To(Equal(2))
+	})
+
+	It("should restore idempotency keys from the log and the snapshot", func() {
+		now := time.Now()
+		for _, name := range []string{"snapshotted", "logged"} {
+			Expect(db.PutIdempotencyKey(ctx, models.IdempotencyKey{Key: name, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})).To(Succeed())
+			if name == "snapshotted" {
+				Expect(wal.Compact()).To(Succeed())
+			}
+		}
+
+		reopen(0)
+
+		for _, name := range []string{"snapshotted", "logged"} {
+			_, err := db.GetIdempotencyKey(ctx, name, now)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("should restore revisions from the log and the snapshot", func() {
+		for _, action := range []string{models.RevisionCreated, models.RevisionUpdated} {
+			_, err := db.AppendRevision(ctx, models.Revision{TaskID: 1, Action: action, CreatedAt: time.Now()})
+			Expect(err).ToNot(HaveOccurred())
+			if action == models.RevisionCreated {
+				Expect(wal.Compact()).To(Succeed())
+			}
+		}
+
+		reopen(0)
+
+		revisions, err := db.ListRevisions(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[0].Action).To(Equal(models.RevisionCreated))
+		Expect(revisions[1].Number).To(Equal(2))
+	})
+
+	It("should restore labels and the label index from the log and the snapshot", func() {
+		task.Labels = []string{"backend"}
+		_, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.PutLabel(ctx, models.Label{Name: "backend", Color: "#1d76db"})).To(Succeed())
+		Expect(wal.Compact()).To(Succeed())
+		_, _, err = db.ReplaceLabel(ctx, "backend", "server", func(*models.Task) {})
+		Expect(err).ToNot(HaveOccurred())
+
+		reopen(0)
+
+		ids, err := db.TaskIDsWithLabels(ctx, []string{"server"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids).To(Equal([]int{1}))
+		label, err := db.GetLabel(ctx, "server")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(label).To(Equal(models.Label{Name: "server", Color: "#1d76db", TaskCount: 1}))
+	})
+
+	It("should restore links and the next link ID from the log and the snapshot", func() {
+		for i := 0; i < 3; i++ {
+			_, err := db.Create(ctx, task)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		first, err := db.CreateLink(ctx, models.Link{Type: "relates_to", SourceID: 1, TargetID: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Compact()).To(Succeed())
+		_, err = db.CreateLink(ctx, models.Link{Type: "duplicates", SourceID: 3, TargetID: 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Delete(ctx, 2, nil)).To(Succeed())
+
+		reopen(0)
+
+		links, err := db.TaskLinks(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(links).To(HaveLen(1))
+		Expect(links[0].ID).To(Equal(first.ID + 1))
+		next, err := db.CreateLink(ctx, models.Link{Type: "relates_to", SourceID: 1, TargetID: 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next.ID).To(Equal(first.ID + 2))
+	})
+
+	It("should restore comments and their counts from the log and the snapshot", func() {
+		_, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		comment, err := db.CreateComment(ctx, models.Comment{TaskID: 1, Body: "Hello"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Compact()).To(Succeed())
+		_, err = db.CreateComment(ctx, models.Comment{TaskID: 1, ParentID: &comment.ID, Body: "Hi"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = db.UpdateComment(ctx, comment.ID, func(comment *models.Comment) error {
+			now := time.Now()
+			comment.DeletedAt = &now
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		reopen(0)
+
+		restored, err := db.Get(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored.CommentCount).To(Equal(1))
+		comments, err := db.ListComments(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(comments).To(HaveLen(2))
+		Expect(comments[0].DeletedAt).ToNot(BeNil())
+	})
+
+	It("should restore reminders and the next reminder ID from the log and the snapshot", func() {
+		_, err := db.Create(ctx, task)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = db.CreateReminder(ctx, models.Reminder{TaskID: 1, Before: models.Duration(24 * time.Hour), Channel: "log"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Compact()).To(Succeed())
+		reminder, err := db.CreateReminder(ctx, models.Reminder{TaskID: 1, Before: models.Duration(time.Hour), Channel: "log"})
+		Expect(err).ToNot(HaveOccurred())
+		firedFor := time.Now()
+		_, err = db.UpdateReminder(ctx, reminder.ID, func(reminder *models.Reminder) error {
+			reminder.FiredFor = &firedFor
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		reopen(0)
+
+		reminders, err := db.ListReminders(ctx, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reminders).To(HaveLen(2))
+		Expect(reminders[1].FiredFor).ToNot(BeNil())
+		Expect(db.NextReminderID).To(Equal(3))
+	})
+
+	It("should restore webhooks, deliveries and their pruning from the log and the snapshot", func() {
+		webhook, err := db.CreateWebhook(ctx, models.Webhook{URL: "https://example.com/hook", Events: []string{"task.created"}})
+		Expect(err).ToNot(HaveOccurred())
+		old := time.Now().Add(-48 * time.Hour)
+		for _, status := range []string{models.DeliverySucceeded, models.DeliveryDead} {
+			_, err = db.CreateDelivery(ctx, models.Delivery{WebhookID: webhook.ID, Status: status, CreatedAt: old})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(wal.Compact()).To(Succeed())
+		Expect(db.PruneDeliveries(ctx, time.Now())).To(Equal(1))
+		_, err = db.CreateWebhook(ctx, models.Webhook{URL: "https://example.com/other", Events: []string{"task.deleted"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		reopen(0)
+
+		Expect(db.ListWebhooks(ctx)).To(HaveLen(2))
+		deliveries, err := db.ListDeliveries(ctx, models.DeliveryFilter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deliveries).To(HaveLen(1))
+		Expect(deliveries[0].Status).To(Equal(models.DeliveryDead))
+		Expect(db.NextWebhookID).To(Equal(3))
+		Expect(db.NextDeliveryID).To(Equal(3))
+
+		Expect(db.DeleteWebhook(ctx, webhook.ID)).To(Succeed())
+		reopen(0)
+		Expect(db.ListDeliveries(ctx, models.DeliveryFilter{})).To(BeEmpty())
+	})
+})
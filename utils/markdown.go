@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	unorderedItemPattern = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedItemPattern   = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	linkPattern          = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern        = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emphasisPattern      = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	taskListItemPattern  = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*\S)\s*$`)
+)
+
+// safeLinkSchemes are the only URL schemes rendered as links. Other links are rendered as plain text.
+var safeLinkSchemes = []string{"http://", "https://", "mailto:"}
+
+// RenderMarkdown renders a safe subset of Markdown to HTML: paragraphs, line breaks, headings, lists,
+// fenced code blocks, code spans, strong and emphasized text and http(s) or mailto links.
+// Any HTML in the source is escaped, so the result can be embedded in a page as is.
+func RenderMarkdown(source string) string {
+	r := markdownRenderer{}
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		r.line(line)
+	}
+	if r.inCode {
+		r.flushCode()
+	}
+	r.flush()
+	return strings.Join(r.blocks, "\n")
+}
+
+// TaskListItem is an item of a Markdown task list, e.g. "- [x] done"
+type TaskListItem struct {
+	Text string
+	Done bool
+}
+
+// ParseTaskList returns the task list items of a Markdown document, "- [ ] text" or "- [x] text",
+// in their order. Items inside fenced code blocks are ignored.
+func ParseTaskList(source string) []TaskListItem {
+	var (
+		items  []TaskListItem
+		inCode bool
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if match := taskListItemPattern.FindStringSubmatch(line); match != nil {
+			items = append(items, TaskListItem{Text: match[2], Done: match[1] != " "})
+		}
+	}
+	return items
+}
+
+// markdownRenderer accumulates the HTML blocks of a Markdown document line by line
+type markdownRenderer struct {
+	blocks    []string
+	paragraph []string
+	listTag   string
+	items     []string
+	inCode    bool
+	code      []string
+}
+
+func (r *markdownRenderer) line(line string) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") {
+		if r.inCode {
+			r.flushCode()
+		} else {
+			r.flush()
+			r.inCode = true
+		}
+		return
+	}
+	if r.inCode {
+		r.code = append(r.code, line)
+		return
+	}
+
+	if trimmed == "" {
+		r.flush()
+		return
+	}
+	if match := headingPattern.FindStringSubmatch(trimmed); match != nil {
+		r.flush()
+		level := string(rune('0' + len(match[1])))
+		r.blocks = append(r.blocks, "<h"+level+">"+renderInline(match[2])+"</h"+level+">")
+		return
+	}
+	if match := unorderedItemPattern.FindStringSubmatch(line); match != nil {
+		r.item("ul", match[1])
+		return
+	}
+	if match := orderedItemPattern.FindStringSubmatch(line); match != nil {
+		r.item("ol", match[1])
+		return
+	}
+	r.flushList()
+	r.paragraph = append(r.paragraph, renderInline(trimmed))
+}
+
+func (r *markdownRenderer) item(tag, text string) {
+	r.flushParagraph()
+	if r.listTag != tag {
+		r.flushList()
+		r.listTag = tag
+	}
+	r.items = append(r.items, "<li>"+renderInline(strings.TrimSpace(text))+"</li>")
+}
+
+func (r *markdownRenderer) flush() {
+	r.flushParagraph()
+	r.flushList()
+}
+
+func (r *markdownRenderer) flushParagraph() {
+	if len(r.paragraph) > 0 {
+		r.blocks = append(r.blocks, "<p>"+strings.Join(r.paragraph, "<br>\n")+"</p>")
+		r.paragraph = nil
+	}
+}
+
+func (r *markdownRenderer) flushList() {
+	if len(r.items) > 0 {
+		r.blocks = append(r.blocks, "<"+r.listTag+">\n"+strings.Join(r.items, "\n")+"\n</"+r.listTag+">")
+		r.items = nil
+	}
+	r.listTag = ""
+}
+
+func (r *markdownRenderer) flushCode() {
+	r.blocks = append(r.blocks, "<pre><code>"+html.EscapeString(strings.Join(r.code, "\n"))+"</code></pre>")
+	r.code = nil
+	r.inCode = false
+}
+
+// renderInline renders the code spans, links and emphasis of a line of text
+func renderInline(text string) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(text, "`")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+1:], "`")
+		if end < 0 {
+			break
+		}
+		out.WriteString(renderSpan(text[:start]))
+		out.WriteString("<code>" + html.EscapeString(text[start+1:start+1+end]) + "</code>")
+		text = text[start+end+2:]
+	}
+	out.WriteString(renderSpan(text))
+	return out.String()
+}
+
+// renderSpan renders the links and emphasis of text without code spans
+func renderSpan(text string) string {
+	text = html.EscapeString(text)
+	text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		match := linkPattern.FindStringSubmatch(link)
+		for _, scheme := range safeLinkSchemes {
+			if strings.HasPrefix(strings.ToLower(match[2]), scheme) {
+				return `<a href="` + match[2] + `" rel="nofollow">` + match[1] + `</a>`
+			}
+		}
+		return match[1]
+	})
+	text = strongPattern.ReplaceAllString(text, "<strong>$1</strong>")
+	return emphasisPattern.ReplaceAllString(text, "<em>$1</em>")
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Markdown Tests", func() {
+	DescribeTable("RenderMarkdown",
+		func(source, expected string) {
+			Expect(RenderMarkdown(source)).To(Equal(expected))
+		},
+		Entry("paragraphs and line breaks", "first\nline\n\nsecond", "<p>first<br>\nline</p>\n<p>second</p>"),
+		Entry("headings", "## Steps", "<h2>Steps</h2>"),
+		Entry("emphasis", "**bold** and *italic*", "<p><strong>bold</strong> and <em>italic</em></p>"),
+		Entry("lists", "- one\n- two\n1. first", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>"),
+		Entry("code spans", "run `a <b> **c**`", "<p>run <code>a &lt;b&gt; **c**</code></p>"),
+		Entry("fenced code", "```\n<x>\n  y\n```", "<pre><code>&lt;x&gt;\n  y</code></pre>"),
+		Entry("links", "[docs](https://example.com/?a=1&b=2)", `<p><a href="https://example.com/?a=1&amp;b=2" rel="nofollow">docs</a></p>`),
+		Entry("unsafe links as text", "[click](javascript:void0)", "<p>click</p>"),
+		Entry("escaped HTML", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>"),
+		Entry("attributes cannot be broken out of", `[x](https://a.b/"onmouseover="y)`, `<p><a href="https://a.b/&#34;onmouseover=&#34;y" rel="nofollow">x</a></p>`),
+	)
+
+	DescribeTable("ParseTaskList",
+		func(source string, expected []TaskListItem) {
+			Expect(ParseTaskList(source)).To(Equal(expected))
+		},
+		Entry("no task list", "- one\n- two", nil),
+		Entry("open and done items", "Steps:\n- [ ] write\r\n* [x] review \n  + [X] ship", []TaskListItem{
+			{Text: "write"}, {Text: "review", Done: true}, {Text: "ship", Done: true},
+		}),
+		Entry("empty items", "- [ ]\n- [ ]   ", nil),
+		Entry("fenced code", "```\n- [ ] not a step\n```\n- [ ] step", []TaskListItem{{Text: "step"}}),
+	)
+})
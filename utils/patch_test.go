@@ -0,0 +1,65 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestUtils(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Utils Suite")
+}
+
+var _ = Describe("Patch Tests", func() {
+	DescribeTable("JSON Merge Patch (RFC 7396 appendix A)",
+		func(doc, patch, expected string) {
+			result, err := ApplyMergePatch([]byte(doc), []byte(patch))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(MatchJSON(expected))
+		},
+		Entry("replaces a member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`),
+		Entry("adds a member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`),
+		Entry("removes a member", `{"a":"b"}`, `{"a":null}`, `{}`),
+		Entry("replaces arrays as a whole", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`),
+		Entry("merges nested objects", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`),
+		Entry("replaces a non-object target", `["c"]`, `{"a":"b"}`, `{"a":"b"}`),
+		Entry("keeps large numbers intact", `{"n":12345678901234567890}`, `{"a":1}`, `{"n":12345678901234567890,"a":1}`),
+	)
+
+	DescribeTable("JSON Patch (RFC 6902 appendix A)",
+		func(doc, patch, expected string) {
+			result, err := ApplyJSONPatch([]byte(doc), []byte(patch))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(MatchJSON(expected))
+		},
+		Entry("adds an object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`),
+		Entry("adds an array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`),
+		Entry("appends to an array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`),
+		Entry("removes an object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`),
+		Entry("removes an array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`),
+		Entry("replaces a value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`),
+		Entry("moves a value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`),
+		Entry("moves an array element", `{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`),
+		Entry("tests a value", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`),
+		Entry("unescapes pointer tokens", `{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, `{}`),
+	)
+
+	DescribeTable("invalid JSON Patch operations",
+		func(doc, patch string, expected error) {
+			_, err := ApplyJSONPatch([]byte(doc), []byte(patch))
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("a failed test", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrPatchConflict),
+		Entry("a missing target location", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrPatchConflict),
+		Entry("removing a missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrPatchConflict),
+		Entry("an out of bounds index", `{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":2}]`, ErrPatchConflict),
+		Entry("an unknown operation", `{}`, `[{"op":"jump","path":"/a"}]`, ErrInvalidPatch),
+		Entry("a missing value", `{}`, `[{"op":"add","path":"/a"}]`, ErrInvalidPatch),
+		Entry("a document that is not an array", `{}`, `{"op":"add"}`, ErrInvalidPatch),
+	)
+})
@@ -3,8 +3,32 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+// Code is an extension member holding a stable, machine-readable error code.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Detail, when set, adds context to the message, e.g. the values accepted by the field
+	Detail string `json:"detail,omitempty"`
+}
+
 // SendResponse sends a JSON response
 func SendResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -14,7 +38,22 @@ func SendResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 	}
 }
 
-// SendError sends an error response
+// SendProblem sends an application/problem+json response
+func SendProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// SendError sends a generic problem response for the status code, with message as its detail
 func SendError(w http.ResponseWriter, message string, statusCode int) {
-	SendResponse(w, map[string]string{"error": message}, statusCode)
+	SendProblem(w, Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: message,
+		Code:   strings.ToLower(strings.ReplaceAll(http.StatusText(statusCode), " ", "_")),
+	})
 }
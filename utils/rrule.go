@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies supported by ParseRecurrenceRule
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// recurrenceHorizon is how many years occurrences are searched for. The Gregorian calendar repeats every
+// 400 years, so a rule without an occurrence in that span never has one, e.g. FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30.
+const recurrenceHorizon = 400
+
+// ErrInvalidRecurrenceRule is returned for a recurrence rule that is malformed or uses unsupported parts
+var ErrInvalidRecurrenceRule = errors.New("invalid recurrence rule")
+
+var (
+	weekdayPattern = regexp.MustCompile(`^([+-]?\d{1,2})?(MO|TU|WE|TH|FR|SA|SU)$`)
+	weekdayNames   = map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+)
+
+// RecurrenceWeekday is a BYDAY value: a weekday, and for monthly and yearly rules an optional ordinal,
+// e.g. 2 for the second Monday or -1 for the last Friday of the period
+type RecurrenceWeekday struct {
+	N   int
+	Day time.Weekday
+}
+
+// RecurrenceRule is a parsed RFC 5545 RRULE. The DAILY, WEEKLY, MONTHLY and YEARLY frequencies are supported,
+// along with the INTERVAL, COUNT, UNTIL, BYMONTH, BYMONTHDAY, BYDAY, BYSETPOS and WKST parts.
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	// Count limits the number of occurrences, zero for no limit
+	Count int
+	// Until is the last time an occurrence can happen, inclusive
+	Until      *time.Time
+	ByMonth    []int
+	ByMonthDay []int
+	ByDay      []RecurrenceWeekday
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// ParseRecurrenceRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", with or without
+// its "RRULE:" prefix. An UNTIL without a time zone is read in loc, and an UNTIL date includes the whole day.
+func ParseRecurrenceRule(value string, loc *time.Location) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1, WeekStart: time.Monday}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return RecurrenceRule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRecurrenceRule)
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, raw, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		if !ok || raw == "" {
+			return RecurrenceRule{}, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalidRecurrenceRule, part)
+		}
+		if seen[name] {
+			return RecurrenceRule{}, fmt.Errorf("%w: %s is repeated", ErrInvalidRecurrenceRule, name)
+		}
+		seen[name] = true
+		raw = strings.ToUpper(raw)
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch raw {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = raw
+			default:
+				err = fmt.Errorf("unsupported FREQ %s", raw)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseRulePart(name, raw, 1, 1000, false)
+		case "COUNT":
+			rule.Count, err = parseRulePart(name, raw, 1, 10000, false)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(raw, loc)
+			rule.Until = &until
+		case "BYMONTH":
+			rule.ByMonth, err = parseRuleList(name, raw, 1, 12, false)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseRuleList(name, raw, 1, 31, true)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseRuleList(name, raw, 1, 366, true)
+		case "BYDAY":
+			for _, item := range strings.Split(raw, ",") {
+				match := weekdayPattern.FindStringSubmatch(item)
+				if match == nil {
+					err = fmt.Errorf("invalid BYDAY %s", item)
+					break
+				}
+				weekday := RecurrenceWeekday{Day: weekdayNames[match[2]]}
+				if match[1] != "" {
+					if weekday.N, err = parseRulePart(name, match[1], 1, 53, true); err != nil {
+						break
+					}
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "WKST":
+			weekday, ok := weekdayNames[raw]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", raw)
+			}
+			rule.WeekStart = weekday
+		default:
+			err = fmt.Errorf("%s is not supported", name)
+		}
+		if err != nil {
+			return RecurrenceRule{}, fmt.Errorf("%w: %v", ErrInvalidRecurrenceRule, err)
+		}
+	}
+
+	if err := rule.validate(); err != nil {
+		return RecurrenceRule{}, fmt.Errorf("%w: %v", ErrInvalidRecurrenceRule, err)
+	}
+	return rule, nil
+}
+
+func (rule RecurrenceRule) validate() error {
+	if rule.Freq == "" {
+		return errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if rule.Freq == FreqWeekly && len(rule.ByMonthDay) > 0 {
+		return errors.New("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, weekday := range rule.ByDay {
+		if weekday.N != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return errors.New("BYDAY ordinals are only allowed with FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		if weekday.N != 0 && (rule.Freq == FreqMonthly || len(rule.ByMonth) > 0) && (weekday.N > 5 || weekday.N < -5) {
+			return errors.New("BYDAY ordinals within a month must be between -5 and 5")
+		}
+	}
+	if len(rule.BySetPos) > 0 && len(rule.ByMonth)+len(rule.ByMonthDay)+len(rule.ByDay) == 0 {
+		return errors.New("BYSETPOS requires another BYxxx part")
+	}
+	return nil
+}
+
+// parseRulePart parses an integer between low and high, or between -high and -low when negative is allowed
+func parseRulePart(name, raw string, low, high int, negative bool) (int, error) {
+	n, err := strconv.Atoi(raw)
+	if err == nil && negative && n < 0 {
+		n = -n
+		if n >= low && n <= high {
+			return -n, nil
+		}
+	} else if err == nil && n >= low && n <= high {
+		return n, nil
+	}
+	return 0, fmt.Errorf("invalid %s %s", name, raw)
+}
+
+func parseRuleList(name, raw string, low, high int, negative bool) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(raw, ",") {
+		n, err := parseRulePart(name, item, low, high, negative)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// parseUntil parses a DATE (20241231), a UTC DATE-TIME (20241231T090000Z) or a local DATE-TIME (20241231T090000)
+func parseUntil(raw string, loc *time.Location) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", raw); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102T150405", raw, loc); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102", raw, loc); err == nil {
+		return until.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %s", raw)
+}
+
+// Occurrences returns the occurrences of the rule in ascending order, starting from start (DTSTART), which
+// gives the time zone and the time of day of every occurrence. start itself is only an occurrence if it
+// matches the rule. Without COUNT or UNTIL the sequence stops after 400 years.
+func (rule RecurrenceRule) Occurrences(start time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		loc := start.Location()
+		first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		horizon := first.AddDate(recurrenceHorizon, 0, 0)
+		count := 0
+		for period := 0; ; period++ {
+			periodStart, days := rule.periodDays(first, period)
+			if periodStart.After(horizon) {
+				return
+			}
+			for _, day := range rule.setPositions(days) {
+				occurrence := time.Date(day.Year(), day.Month(), day.Day(),
+					start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+				if occurrence.Before(start) {
+					continue
+				}
+				if rule.Until != nil && occurrence.After(*rule.Until) {
+					return
+				}
+				if !yield(occurrence) {
+					return
+				}
+				if count++; rule.Count > 0 && count >= rule.Count {
+					return
+				}
+			}
+		}
+	}
+}
+
+// periodDays returns the first day of the period-th period of the rule after the one of first,
+// and its days matching the rule in ascending order. Days are midnight UTC, to be read as calendar dates.
+func (rule RecurrenceRule) periodDays(first time.Time, period int) (time.Time, []time.Time) {
+	step := period * rule.Interval
+	var days []time.Time
+	switch rule.Freq {
+	case FreqDaily:
+		day := first.AddDate(0, 0, step)
+		if rule.matchesMonth(day) && rule.matchesMonthDay(day) && rule.matchesWeekday(day, 0, 0) {
+			days = append(days, day)
+		}
+		return day, days
+
+	case FreqWeekly:
+		offset := (int(first.Weekday()) - int(rule.WeekStart) + 7) % 7
+		weekStart := first.AddDate(0, 0, 7*step-offset)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			matches := day.Weekday() == first.Weekday()
+			if len(rule.ByDay) > 0 {
+				matches = rule.matchesWeekday(day, 0, 0)
+			}
+			if matches && rule.matchesMonth(day) {
+				days = append(days, day)
+			}
+		}
+		return weekStart, days
+
+	case FreqMonthly:
+		month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, step, 0)
+		if rule.matchesMonth(month) {
+			days = rule.monthDays(month, first.Day())
+		}
+		return month, days
+
+	default:
+		year := time.Date(first.Year()+step, 1, 1, 0, 0, 0, 0, time.UTC)
+		switch {
+		case len(rule.ByMonth) > 0 || len(rule.ByMonthDay) > 0:
+			for m := 0; m < 12; m++ {
+				month := year.AddDate(0, m, 0)
+				if rule.matchesMonth(month) {
+					days = append(days, rule.monthDays(month, first.Day())...)
+				}
+			}
+		case len(rule.ByDay) > 0:
+			end := year.AddDate(1, 0, 0)
+			for day := year; day.Before(end); day = day.AddDate(0, 0, 1) {
+				if rule.matchesWeekday(day, day.YearDay(), end.AddDate(0, 0, -1).YearDay()) {
+					days = append(days, day)
+				}
+			}
+		default:
+			if day := time.Date(year.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC); day.Day() == first.Day() {
+				days = append(days, day)
+			}
+		}
+		return year, days
+	}
+}
+
+// monthDays returns the days of a month matching BYMONTHDAY and BYDAY, with BYDAY ordinals counted within the month.
+// Without either, it returns the day of the month of the first occurrence, when the month has it.
+func (rule RecurrenceRule) monthDays(month time.Time, dayOfMonth int) []time.Time {
+	daysInMonth := month.AddDate(0, 1, -1).Day()
+	if len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		if dayOfMonth > daysInMonth {
+			return nil
+		}
+		return []time.Time{month.AddDate(0, 0, dayOfMonth-1)}
+	}
+	var days []time.Time
+	for d := 1; d <= daysInMonth; d++ {
+		day := month.AddDate(0, 0, d-1)
+		if rule.matchesMonthDay(day) && rule.matchesWeekday(day, d, daysInMonth) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (rule RecurrenceRule) matchesMonth(day time.Time) bool {
+	if len(rule.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range rule.ByMonth {
+		if time.Month(month) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule RecurrenceRule) matchesMonthDay(day time.Time) bool {
+	if len(rule.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, monthDay := range rule.ByMonthDay {
+		if monthDay == day.Day() || monthDay < 0 && daysInMonth+monthDay+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday reports whether the day matches BYDAY. The ordinals count the weekdays of a period of length days,
+// of which the day is the index-th (from 1).
+func (rule RecurrenceRule) matchesWeekday(day time.Time, index, length int) bool {
+	if len(rule.ByDay) == 0 {
+		return true
+	}
+	for _, weekday := range rule.ByDay {
+		if weekday.Day != day.Weekday() {
+			continue
+		}
+		switch {
+		case weekday.N == 0:
+			return true
+		case weekday.N > 0 && (index-1)/7+1 == weekday.N:
+			return true
+		case weekday.N < 0 && (length-index)/7+1 == -weekday.N:
+			return true
+		}
+	}
+	return false
+}
+
+// setPositions keeps the days of a period at the BYSETPOS positions, in ascending order
+func (rule RecurrenceRule) setPositions(days []time.Time) []time.Time {
+	if len(rule.BySetPos) == 0 {
+		return days
+	}
+	var kept []time.Time
+	for _, position := range rule.BySetPos {
+		i := position - 1
+		if position < 0 {
+			i = len(days) + position
+		}
+		if i < 0 || i >= len(days) {
+			continue
+		}
+		duplicate := false
+		for _, day := range kept {
+			duplicate = duplicate || day.Equal(days[i])
+		}
+		if !duplicate {
+			kept = append(kept, days[i])
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Before(kept[j]) })
+	return kept
+}
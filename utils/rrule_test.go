@@ -0,0 +1,85 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Recurrence Rule Tests", func() {
+	// occurrences returns up to n occurrences of the rule starting at start, formatted as "2006-01-02 15:04"
+	occurrences := func(value string, start time.Time, n int) []string {
+		rule, err := ParseRecurrenceRule(value, start.Location())
+		Expect(err).ToNot(HaveOccurred())
+
+		dates := []string{}
+		for occurrence := range rule.Occurrences(start) {
+			dates = append(dates, occurrence.Format("2006-01-02 15:04"))
+			if len(dates) == n {
+				break
+			}
+		}
+		return dates
+	}
+
+	// Monday, January 1st 2024
+	start := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	DescribeTable("Occurrences",
+		func(value string, expected ...string) {
+			Expect(occurrences(value, start, 5)).To(Equal(expected))
+		},
+		Entry("daily", "FREQ=DAILY;INTERVAL=2;COUNT=3", "2024-01-01 09:30", "2024-01-03 09:30", "2024-01-05 09:30"),
+		Entry("weekdays", "RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "2024-01-01 09:30", "2024-01-02 09:30", "2024-01-03 09:30", "2024-01-04 09:30", "2024-01-05 09:30"),
+		Entry("weekly on several days", "FREQ=WEEKLY;BYDAY=WE,MO;COUNT=4", "2024-01-01 09:30", "2024-01-03 09:30", "2024-01-08 09:30", "2024-01-10 09:30"),
+		Entry("every other week", "FREQ=WEEKLY;INTERVAL=2;UNTIL=20240129", "2024-01-01 09:30", "2024-01-15 09:30", "2024-01-29 09:30"),
+		Entry("monthly on the 31st", "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", "2024-01-31 09:30", "2024-03-31 09:30", "2024-05-31 09:30"),
+		Entry("last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2", "2024-01-31 09:30", "2024-02-29 09:30"),
+		Entry("second Tuesday", "FREQ=MONTHLY;BYDAY=2TU;COUNT=2", "2024-01-09 09:30", "2024-02-13 09:30"),
+		Entry("last Friday", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=2", "2024-01-26 09:30", "2024-02-23 09:30"),
+		Entry("last weekday", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3", "2024-01-31 09:30", "2024-02-29 09:30", "2024-03-29 09:30"),
+		Entry("yearly", "FREQ=YEARLY;COUNT=2", "2024-01-01 09:30", "2025-01-01 09:30"),
+		Entry("yearly in a month", "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;COUNT=2", "2024-03-31 09:30", "2025-03-30 09:30"),
+		Entry("first Monday of the year", "FREQ=YEARLY;BYDAY=1MO;COUNT=2", "2024-01-01 09:30", "2025-01-06 09:30"),
+		Entry("never", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"),
+	)
+
+	It("should keep the local time across daylight saving time changes", func() {
+		newYork, err := time.LoadLocation("America/New_York")
+		Expect(err).ToNot(HaveOccurred())
+
+		rule, err := ParseRecurrenceRule("FREQ=WEEKLY;COUNT=2", newYork)
+		Expect(err).ToNot(HaveOccurred())
+		var dates []time.Time
+		for occurrence := range rule.Occurrences(time.Date(2024, 3, 4, 9, 0, 0, 0, newYork)) {
+			dates = append(dates, occurrence)
+		}
+		Expect(dates).To(HaveLen(2))
+		Expect(dates[1].Hour()).To(Equal(9))
+		Expect(dates[1].Sub(dates[0])).To(Equal(7*24*time.Hour - time.Hour))
+	})
+
+	It("should read UNTIL in the time zone of the rule unless it is UTC", func() {
+		Expect(occurrences("FREQ=DAILY;UNTIL=20240102T093000Z", start, 5)).To(HaveLen(2))
+		Expect(occurrences("FREQ=DAILY;UNTIL=20240102T092959", start, 5)).To(HaveLen(1))
+	})
+
+	DescribeTable("Invalid rules",
+		func(value, message string) {
+			_, err := ParseRecurrenceRule(value, time.UTC)
+			Expect(err).To(MatchError(ErrInvalidRecurrenceRule))
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		Entry("empty", "", "FREQ is required"),
+		Entry("missing frequency", "COUNT=2", "FREQ is required"),
+		Entry("unsupported frequency", "FREQ=HOURLY", "unsupported FREQ HOURLY"),
+		Entry("unsupported part", "FREQ=DAILY;BYHOUR=9", "BYHOUR is not supported"),
+		Entry("count and until", "FREQ=DAILY;COUNT=2;UNTIL=20240101", "COUNT and UNTIL"),
+		Entry("invalid interval", "FREQ=DAILY;INTERVAL=0", "invalid INTERVAL 0"),
+		Entry("invalid weekday", "FREQ=WEEKLY;BYDAY=XX", "invalid BYDAY XX"),
+		Entry("ordinal with a weekly rule", "FREQ=WEEKLY;BYDAY=1MO", "BYDAY ordinals"),
+		Entry("month day with a weekly rule", "FREQ=WEEKLY;BYMONTHDAY=1", "BYMONTHDAY cannot be used"),
+		Entry("repeated part", "FREQ=DAILY;FREQ=WEEKLY", "FREQ is repeated"),
+		Entry("malformed part", "FREQ=DAILY;COUNT", `"COUNT" is not NAME=VALUE`),
+	)
+})
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"image"
+	"image/color"
+)
+
+var _ = Describe("Thumbnail Tests", func() {
+	// checkerboard returns an image of width by height pixels alternating between black and white columns
+	checkerboard := func(width, height int) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if x%2 == 0 {
+					img.Set(x, y, color.White)
+				} else {
+					img.Set(x, y, color.Black)
+				}
+			}
+		}
+		return img
+	}
+
+	DescribeTable("Thumbnail dimensions",
+		func(width, height, size, expectedWidth, expectedHeight int) {
+			thumb := Thumbnail(checkerboard(width, height), size)
+			Expect(thumb.Bounds()).To(Equal(image.Rect(0, 0, expectedWidth, expectedHeight)))
+		},
+		Entry("landscape", 400, 200, 100, 100, 50),
+		Entry("portrait", 200, 400, 100, 50, 100),
+		Entry("thin", 1000, 2, 100, 100, 1),
+		Entry("small images are kept", 40, 30, 100, 40, 30),
+	)
+
+	It("should average the pixels each thumbnail pixel covers", func() {
+		thumb := Thumbnail(checkerboard(4, 4), 2)
+		Expect(thumb.RGBAAt(0, 0)).To(Equal(color.RGBA{R: 127, G: 127, B: 127, A: 255}))
+
+		transparent := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		transparent.Set(0, 0, color.NRGBA{R: 255, A: 255})
+		transparent.Set(1, 0, color.NRGBA{G: 255})
+		Expect(Thumbnail(transparent, 1).RGBAAt(0, 0)).To(Equal(color.RGBA{R: 127, A: 127}))
+	})
+
+	It("should scale images whose bounds do not start at the origin", func() {
+		img := checkerboard(8, 8).SubImage(image.Rect(4, 4, 8, 8))
+		Expect(Thumbnail(img, 2).Bounds()).To(Equal(image.Rect(0, 0, 2, 2)))
+	})
+})